@@ -0,0 +1,157 @@
+package alarm
+
+import (
+	"fmt"
+	"time"
+)
+
+// Severity is how serious an alarm is
+type Severity int
+
+const (
+	// Info - worth knowing about but no action needed
+	Info Severity = iota
+	// Warning - a reading is outside of its limits
+	Warning
+	// Critical - a reading is outside of limits that the device is configured to page for
+	Critical
+)
+
+// String returns the name of the severity
+func (s Severity) String() string {
+	switch s {
+	case Info:
+		return "info"
+	case Warning:
+		return "warning"
+	case Critical:
+		return "critical"
+	default:
+		return fmt.Sprintf("severity(%d)", int(s))
+	}
+}
+
+// Condition is the way in which a reading has breached its limit
+type Condition string
+
+const (
+	// Low - the reading is below the minimum
+	Low Condition = "low"
+	// High - the reading is above the maximum
+	High Condition = "high"
+	// Offline - the device has gone offline
+	Offline Condition = "offline"
+)
+
+// Source is where a limit came from
+type Source string
+
+const (
+	// DeviceSource - the limit is from the alarm configuration of the device
+	DeviceSource Source = "device"
+	// UserSource - the limit was added by the user of the SDK
+	UserSource Source = "user"
+)
+
+// Limit is the range that a metric should be kept within
+type Limit struct {
+	Metric      string        `json:"metric"`
+	Min         float64       `json:"min"`
+	HasMin      bool          `json:"has_min"`
+	Max         float64       `json:"max"`
+	HasMax      bool          `json:"has_max"`
+	Hysteresis  float64       `json:"hysteresis"`
+	MinDuration time.Duration `json:"min_duration"`
+	Severity    Severity      `json:"severity"`
+	Source      Source        `json:"source"`
+}
+
+// Below returns a limit that alarms when the metric falls below the given value
+func Below(metric string, min float64, sev Severity) Limit {
+	return Limit{Metric: metric, Min: min, HasMin: true, Severity: sev, Source: UserSource}
+}
+
+// Above returns a limit that alarms when the metric rises above the given value
+func Above(metric string, max float64, sev Severity) Limit {
+	return Limit{Metric: metric, Max: max, HasMax: true, Severity: sev, Source: UserSource}
+}
+
+// Between returns a limit that alarms when the metric leaves the given range
+func Between(metric string, min, max float64, sev Severity) Limit {
+	return Limit{Metric: metric, Min: min, HasMin: true, Max: max, HasMax: true, Severity: sev, Source: UserSource}
+}
+
+// breach returns the condition that the value breaches, if any
+func (l Limit) breach(v float64) (Condition, float64, bool) {
+	switch {
+	case l.HasMin && v < l.Min:
+		return Low, l.Min, true
+	case l.HasMax && v > l.Max:
+		return High, l.Max, true
+	}
+	return "", 0, false
+}
+
+// cleared returns true if the value has come back within the limit by the hysteresis margin
+func (l Limit) cleared(c Condition, v float64) bool {
+	switch c {
+	case Low:
+		return v >= l.Min+l.Hysteresis
+	case High:
+		return v <= l.Max-l.Hysteresis
+	}
+	return true
+}
+
+// Alarm is a breach of a limit by a device
+type Alarm struct {
+	ID           string    `json:"id"`
+	Device       string    `json:"device"`
+	Metric       string    `json:"metric"`
+	Condition    Condition `json:"condition"`
+	Source       Source    `json:"source"`
+	Severity     Severity  `json:"severity"`
+	Value        float64   `json:"value"`
+	Threshold    float64   `json:"threshold"`
+	RaisedAt     time.Time `json:"raised_at"`
+	ClearedAt    time.Time `json:"cleared_at,omitempty"`
+	Acknowledged bool      `json:"acknowledged"`
+	AckedAt      time.Time `json:"acked_at,omitempty"`
+	AckedBy      string    `json:"acked_by,omitempty"`
+}
+
+// Active returns true if the alarm has not been cleared
+func (a Alarm) Active() bool {
+	return a.ClearedAt.IsZero()
+}
+
+// String returns a human readable description of the alarm
+func (a Alarm) String() string {
+	if a.Metric == HealthMetric {
+		return fmt.Sprintf("%s is %s", a.Device, a.Condition)
+	}
+	return fmt.Sprintf("%s %s is %s at %0.2f (limit %0.2f)", a.Device, a.Metric, a.Condition, a.Value, a.Threshold)
+}
+
+func alarmID(device, metric string, src Source, c Condition) string {
+	return fmt.Sprintf("%s/%s/%s/%s", device, metric, src, c)
+}
+
+// EventKind is the kind of change to an alarm
+type EventKind string
+
+const (
+	// Raised - the alarm has been raised
+	Raised EventKind = "raised"
+	// Cleared - the alarm has cleared
+	Cleared EventKind = "cleared"
+	// Acknowledged - the alarm has been acknowledged by someone
+	Acknowledged EventKind = "acknowledged"
+)
+
+// Event is a change to an alarm
+type Event struct {
+	Kind  EventKind `json:"kind"`
+	Time  time.Time `json:"time"`
+	Alarm Alarm     `json:"alarm"`
+}
@@ -0,0 +1,23 @@
+// Package alarm provides an engine that evaluates live readings from devices against the
+// alarm limits configured on each device, and any custom limits layered on top by the user.
+//
+// Alarms are raised once a reading has been outside of its limit for the minimum duration and
+// cleared once it has come back inside the limit by the hysteresis margin, which for the limits
+// of a device is given by DeviceHysteresis.  Changing or removing a limit clears the alarms it
+// raised.  Each change is sent as an event:
+//
+//     engine := alarm.NewEngine(100)
+//     engine.SetUserLimit("", alarm.Above("co2", 1800, alarm.Critical))
+//
+//     go func() {
+//       for ev := range engine.Events() {
+//         log.Printf("%s: %s", ev.Kind, ev.Alarm)
+//       }
+//     }()
+//
+//     for {
+//       doser.GetMetrics()
+//       alarm.EvaluateIntelliDose(engine, doser)
+//       time.Sleep(time.Minute)
+//     }
+package alarm
@@ -0,0 +1,364 @@
+package alarm
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/autogrow/go-jelly/health"
+)
+
+// HealthMetric is the metric used for alarms raised when a device goes offline
+const HealthMetric = "health"
+
+type limitKey struct {
+	metric string
+	source Source
+}
+
+// Engine evaluates readings from devices against their limits, raising and clearing alarms
+type Engine struct {
+	lock    *sync.Mutex
+	device  map[string]map[limitKey]Limit
+	user    map[string]map[limitKey]Limit
+	pending map[string]time.Time
+	active  map[string]*Alarm
+	events  chan Event
+	dropped int
+}
+
+// NewEngine returns a new alarm engine.  Events are sent on the channel returned by Events,
+// which holds the given number of events before further events are dropped and counted by
+// Dropped.
+func NewEngine(buffer int) *Engine {
+	return &Engine{
+		lock:    new(sync.Mutex),
+		device:  map[string]map[limitKey]Limit{},
+		user:    map[string]map[limitKey]Limit{},
+		pending: map[string]time.Time{},
+		active:  map[string]*Alarm{},
+		events:  make(chan Event, buffer),
+	}
+}
+
+// Events returns the channel that alarm events are sent on
+func (e *Engine) Events() <-chan Event {
+	return e.events
+}
+
+// SetDeviceLimits replaces the limits from the configuration of the given device.  The alarms
+// raised by limits that have been removed or changed are cleared, and the events returned.
+func (e *Engine) SetDeviceLimits(device string, limits []Limit) []Event {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	old := e.device[device]
+	e.device[device] = map[limitKey]Limit{}
+	for _, l := range limits {
+		l.Source = DeviceSource
+		e.device[device][limitKey{l.Metric, l.Source}] = l
+	}
+
+	events := []Event{}
+	now := time.Now()
+	for key, l := range old {
+		if nl, ok := e.device[device][key]; !ok || nl != l {
+			events = append(events, e.drop(device, key, now)...)
+		}
+	}
+
+	e.send(events)
+	return events
+}
+
+// SetUserLimit adds a custom limit for the given device, replacing any previous custom limit
+// for the same metric.  Custom limits are evaluated as well as the limits of the device.  An
+// empty device applies the limit to all devices that don't have their own custom limit for
+// the metric.  The alarms raised by a limit that has been replaced are cleared, and the events
+// returned.
+func (e *Engine) SetUserLimit(device string, l Limit) []Event {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	l.Source = UserSource
+	key := limitKey{l.Metric, l.Source}
+	if _, ok := e.user[device]; !ok {
+		e.user[device] = map[limitKey]Limit{}
+	}
+
+	events := []Event{}
+	if old, ok := e.user[device][key]; !ok || old != l {
+		events = e.dropUser(device, key, time.Now())
+	}
+	e.user[device][key] = l
+
+	e.send(events)
+	return events
+}
+
+// RemoveUserLimit removes the custom limit on the given metric for the device.  The alarms it
+// raised are cleared, and the events returned.
+func (e *Engine) RemoveUserLimit(device, metric string) []Event {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	key := limitKey{metric, UserSource}
+	if _, ok := e.user[device][key]; !ok {
+		return []Event{}
+	}
+
+	delete(e.user[device], key)
+	events := e.dropUser(device, key, time.Now())
+	e.send(events)
+	return events
+}
+
+// dropUser forgets the state of the custom limit for the device, or for all the devices that
+// it applies to if the device is empty
+func (e *Engine) dropUser(device string, key limitKey, at time.Time) []Event {
+	if device != "" {
+		return e.drop(device, key, at)
+	}
+
+	events := []Event{}
+	for _, d := range e.devicesWith(key) {
+		// the device has a limit of its own which isn't affected
+		if _, ok := e.user[d][key]; ok {
+			continue
+		}
+		events = append(events, e.drop(d, key, at)...)
+	}
+	return events
+}
+
+// devicesWith returns the devices that have an alarm raised or pending for the limit
+func (e *Engine) devicesWith(key limitKey) []string {
+	seen := map[string]bool{}
+	for _, a := range e.active {
+		if a.Metric == key.metric && a.Source == key.source {
+			seen[a.Device] = true
+		}
+	}
+
+	for id := range e.pending {
+		for _, c := range []Condition{Low, High} {
+			if suffix := alarmID("", key.metric, key.source, c); strings.HasSuffix(id, suffix) {
+				seen[strings.TrimSuffix(id, suffix)] = true
+			}
+		}
+	}
+
+	devices := []string{}
+	for d := range seen {
+		devices = append(devices, d)
+	}
+	sort.Strings(devices)
+	return devices
+}
+
+// drop forgets the state of the limit for the device, clearing any alarms it has raised
+func (e *Engine) drop(device string, key limitKey, at time.Time) []Event {
+	events := []Event{}
+	for _, c := range []Condition{Low, High} {
+		id := alarmID(device, key.metric, key.source, c)
+		delete(e.pending, id)
+
+		a, ok := e.active[id]
+		if !ok {
+			continue
+		}
+
+		a.ClearedAt = at
+		delete(e.active, id)
+		events = append(events, Event{Cleared, at, *a})
+	}
+	return events
+}
+
+func (e *Engine) limits(device string) []Limit {
+	limits := []Limit{}
+	for _, l := range e.device[device] {
+		limits = append(limits, l)
+	}
+	for key, l := range e.user[""] {
+		// a limit for the device replaces the one for all devices
+		if _, ok := e.user[device][key]; ok && device != "" {
+			continue
+		}
+		limits = append(limits, l)
+	}
+	if device != "" {
+		for _, l := range e.user[device] {
+			limits = append(limits, l)
+		}
+	}
+	return limits
+}
+
+// Evaluate the readings from the device taken at the given time, returning any events that
+// resulted.  Metrics missing from the readings are not evaluated.
+func (e *Engine) Evaluate(device string, readings map[string]float64, at time.Time) []Event {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	events := []Event{}
+	for _, l := range e.limits(device) {
+		v, ok := readings[l.Metric]
+		if !ok {
+			continue
+		}
+		events = append(events, e.evaluate(device, l, v, at)...)
+	}
+
+	e.send(events)
+	return events
+}
+
+func (e *Engine) evaluate(device string, l Limit, v float64, at time.Time) []Event {
+	events := []Event{}
+
+	// clear any active alarms that have recovered
+	for _, c := range []Condition{Low, High} {
+		id := alarmID(device, l.Metric, l.Source, c)
+		a, ok := e.active[id]
+		if !ok {
+			continue
+		}
+
+		a.Value = v
+		if l.cleared(c, v) {
+			a.ClearedAt = at
+			delete(e.active, id)
+			events = append(events, Event{Cleared, at, *a})
+		}
+	}
+
+	c, threshold, breached := l.breach(v)
+	if !breached {
+		delete(e.pending, alarmID(device, l.Metric, l.Source, Low))
+		delete(e.pending, alarmID(device, l.Metric, l.Source, High))
+		return events
+	}
+
+	id := alarmID(device, l.Metric, l.Source, c)
+	if _, ok := e.active[id]; ok {
+		return events
+	}
+
+	since, ok := e.pending[id]
+	if !ok {
+		since = at
+		e.pending[id] = at
+	}
+
+	if at.Sub(since) < l.MinDuration {
+		return events
+	}
+
+	delete(e.pending, id)
+	a := &Alarm{
+		ID:        id,
+		Device:    device,
+		Metric:    l.Metric,
+		Condition: c,
+		Source:    l.Source,
+		Severity:  l.Severity,
+		Value:     v,
+		Threshold: threshold,
+		RaisedAt:  at,
+	}
+	e.active[id] = a
+
+	return append(events, Event{Raised, at, *a})
+}
+
+// EvaluateHealth raises an alarm when the device goes offline and clears it when the device
+// comes back online
+func (e *Engine) EvaluateHealth(device string, state health.State, at time.Time) []Event {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	events := []Event{}
+	id := alarmID(device, HealthMetric, DeviceSource, Offline)
+	a, active := e.active[id]
+
+	switch {
+	case state == health.Offline && !active:
+		a = &Alarm{
+			ID:        id,
+			Device:    device,
+			Metric:    HealthMetric,
+			Condition: Offline,
+			Source:    DeviceSource,
+			Severity:  Critical,
+			RaisedAt:  at,
+		}
+		e.active[id] = a
+		events = append(events, Event{Raised, at, *a})
+
+	case state == health.Online && active:
+		a.ClearedAt = at
+		delete(e.active, id)
+		events = append(events, Event{Cleared, at, *a})
+	}
+
+	e.send(events)
+	return events
+}
+
+// Acknowledge the active alarm with the given ID
+func (e *Engine) Acknowledge(id, by string) error {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	a, ok := e.active[id]
+	if !ok {
+		return fmt.Errorf("no active alarm with ID %s", id)
+	}
+
+	if a.Acknowledged {
+		return nil
+	}
+
+	a.Acknowledged = true
+	a.AckedAt = time.Now()
+	a.AckedBy = by
+
+	e.send([]Event{{Acknowledged, a.AckedAt, *a}})
+	return nil
+}
+
+// Active returns the alarms that are currently raised, oldest first
+func (e *Engine) Active() []Alarm {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	alarms := []Alarm{}
+	for _, a := range e.active {
+		alarms = append(alarms, *a)
+	}
+
+	sort.Slice(alarms, func(i, j int) bool { return alarms[i].RaisedAt.Before(alarms[j].RaisedAt) })
+	return alarms
+}
+
+// Dropped returns the number of events that have been dropped because the channel returned by
+// Events was full
+func (e *Engine) Dropped() int {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	return e.dropped
+}
+
+// send the events without blocking, events are dropped and counted if the channel is full
+func (e *Engine) send(events []Event) {
+	for _, ev := range events {
+		select {
+		case e.events <- ev:
+		default:
+			e.dropped++
+		}
+	}
+}
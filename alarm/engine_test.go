@@ -0,0 +1,143 @@
+package alarm
+
+import (
+	"testing"
+	"time"
+
+	"github.com/autogrow/go-jelly/health"
+	"github.com/autogrow/go-jelly/units"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestEngine(t *testing.T) {
+	start := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	Convey("given an engine with an EC limit from the device", t, func() {
+		e := NewEngine(10)
+		e.SetDeviceLimits("ID1", []Limit{{Metric: "ec", Min: 1.2, HasMin: true, Max: 2.0, HasMax: true, Hysteresis: 0.1, Severity: Warning}})
+
+		Convey("it should not raise an alarm while in range", func() {
+			So(e.Evaluate("ID1", map[string]float64{"ec": 1.5}, start), ShouldBeEmpty)
+		})
+
+		Convey("when the EC drops too low", func() {
+			evs := e.Evaluate("ID1", map[string]float64{"ec": 1.0}, start)
+
+			Convey("it should raise an alarm", func() {
+				So(len(evs), ShouldEqual, 1)
+				So(evs[0].Kind, ShouldEqual, Raised)
+				So(evs[0].Alarm.Condition, ShouldEqual, Low)
+				So(evs[0].Alarm.Source, ShouldEqual, DeviceSource)
+				So(len(e.Active()), ShouldEqual, 1)
+				So((<-e.Events()).Kind, ShouldEqual, Raised)
+			})
+
+			Convey("it should not clear until past the hysteresis", func() {
+				So(e.Evaluate("ID1", map[string]float64{"ec": 1.25}, start.Add(time.Minute)), ShouldBeEmpty)
+
+				evs = e.Evaluate("ID1", map[string]float64{"ec": 1.35}, start.Add(2*time.Minute))
+				So(len(evs), ShouldEqual, 1)
+				So(evs[0].Kind, ShouldEqual, Cleared)
+				So(evs[0].Alarm.Active(), ShouldBeFalse)
+				So(e.Active(), ShouldBeEmpty)
+			})
+
+			Convey("it should clear when the device limit is removed", func() {
+				evs = e.SetDeviceLimits("ID1", []Limit{})
+				So(len(evs), ShouldEqual, 1)
+				So(evs[0].Kind, ShouldEqual, Cleared)
+				So(e.Active(), ShouldBeEmpty)
+			})
+
+			Convey("it should clear when the device limit is changed, and be raised again if still breached", func() {
+				evs = e.SetDeviceLimits("ID1", []Limit{{Metric: "ec", Min: 0.8, HasMin: true, Hysteresis: 0.1, Severity: Warning}})
+				So(len(evs), ShouldEqual, 1)
+				So(evs[0].Kind, ShouldEqual, Cleared)
+				So(e.Evaluate("ID1", map[string]float64{"ec": 1.0}, start.Add(time.Minute)), ShouldBeEmpty)
+			})
+
+			Convey("it should not clear when the same device limits are set again", func() {
+				So(e.SetDeviceLimits("ID1", []Limit{{Metric: "ec", Min: 1.2, HasMin: true, Max: 2.0, HasMax: true, Hysteresis: 0.1, Severity: Warning}}), ShouldBeEmpty)
+				So(len(e.Active()), ShouldEqual, 1)
+			})
+
+			Convey("it should be able to be acknowledged", func() {
+				So(e.Acknowledge(evs[0].Alarm.ID, "bob"), ShouldBeNil)
+				So(e.Active()[0].Acknowledged, ShouldBeTrue)
+				So(e.Acknowledge("nope", "bob"), ShouldNotBeNil)
+			})
+		})
+
+		Convey("when a user limit is layered on top with a minimum duration", func() {
+			l := Above("ec", 1.8, Critical)
+			l.MinDuration = 10 * time.Minute
+			e.SetUserLimit("", l)
+
+			Convey("it should only raise once it has been breached long enough", func() {
+				So(e.Evaluate("ID1", map[string]float64{"ec": 1.9}, start), ShouldBeEmpty)
+				So(e.Evaluate("ID1", map[string]float64{"ec": 1.9}, start.Add(5*time.Minute)), ShouldBeEmpty)
+
+				evs := e.Evaluate("ID1", map[string]float64{"ec": 1.9}, start.Add(10*time.Minute))
+				So(len(evs), ShouldEqual, 1)
+				So(evs[0].Alarm.Source, ShouldEqual, UserSource)
+				So(evs[0].Alarm.Severity, ShouldEqual, Critical)
+			})
+
+			Convey("it should clear when the user limit is removed", func() {
+				So(e.Evaluate("ID1", map[string]float64{"ec": 1.9}, start), ShouldBeEmpty)
+				So(e.Evaluate("ID1", map[string]float64{"ec": 1.9}, start.Add(10*time.Minute)), ShouldHaveLength, 1)
+
+				evs := e.RemoveUserLimit("", "ec")
+				So(len(evs), ShouldEqual, 1)
+				So(evs[0].Kind, ShouldEqual, Cleared)
+				So(evs[0].Alarm.Device, ShouldEqual, "ID1")
+				So(e.Evaluate("ID1", map[string]float64{"ec": 1.9}, start.Add(20*time.Minute)), ShouldBeEmpty)
+			})
+
+			Convey("replacing it should restart the duration", func() {
+				So(e.Evaluate("ID1", map[string]float64{"ec": 1.9}, start), ShouldBeEmpty)
+				So(e.SetUserLimit("", Above("ec", 1.85, Critical)), ShouldBeEmpty)
+				So(e.SetUserLimit("", l), ShouldBeEmpty)
+				So(e.Evaluate("ID1", map[string]float64{"ec": 1.9}, start.Add(10*time.Minute)), ShouldBeEmpty)
+			})
+
+			Convey("it should reset the duration if the reading recovers", func() {
+				So(e.Evaluate("ID1", map[string]float64{"ec": 1.9}, start), ShouldBeEmpty)
+				So(e.Evaluate("ID1", map[string]float64{"ec": 1.5}, start.Add(5*time.Minute)), ShouldBeEmpty)
+				So(e.Evaluate("ID1", map[string]float64{"ec": 1.9}, start.Add(10*time.Minute)), ShouldBeEmpty)
+			})
+		})
+	})
+
+	Convey("given an engine with no room for events", t, func() {
+		e := NewEngine(0)
+		e.SetDeviceLimits("ID1", []Limit{{Metric: "ec", Min: 1.2, HasMin: true, Severity: Warning}})
+
+		Convey("the events that couldn't be sent should be counted", func() {
+			So(e.Evaluate("ID1", map[string]float64{"ec": 1.0}, start), ShouldHaveLength, 1)
+			So(e.Dropped(), ShouldEqual, 1)
+		})
+	})
+
+	Convey("the limits from a device should be cleared with the hysteresis in its units", t, func() {
+		limits := hysteresis([]Limit{{Metric: "ec"}, {Metric: "air_temp"}, {Metric: "power_fail"}}, units.System{Temperature: units.Fahrenheit, Conductivity: units.CF})
+		So(limits[0].Hysteresis, ShouldAlmostEqual, 1)
+		So(limits[1].Hysteresis, ShouldAlmostEqual, 0.9)
+		So(limits[2].Hysteresis, ShouldEqual, 0)
+	})
+
+	Convey("given a device that goes offline", t, func() {
+		e := NewEngine(10)
+
+		evs := e.EvaluateHealth("ID1", health.Offline, start)
+		So(len(evs), ShouldEqual, 1)
+		So(evs[0].Alarm.Condition, ShouldEqual, Offline)
+
+		Convey("it should clear when it comes back online", func() {
+			So(e.EvaluateHealth("ID1", health.Stale, start.Add(time.Minute)), ShouldBeEmpty)
+			evs := e.EvaluateHealth("ID1", health.Online, start.Add(2*time.Minute))
+			So(len(evs), ShouldEqual, 1)
+			So(evs[0].Kind, ShouldEqual, Cleared)
+		})
+	})
+}
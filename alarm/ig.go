@@ -0,0 +1,223 @@
+package alarm
+
+import (
+	"time"
+
+	"github.com/autogrow/go-jelly/health"
+	"github.com/autogrow/go-jelly/ig"
+	"github.com/autogrow/go-jelly/ig/datastructs"
+	"github.com/autogrow/go-jelly/units"
+)
+
+// severity returns the severity of a device limit, which is critical if the device is
+// configured to page for it
+func severity(page bool) Severity {
+	if page {
+		return Critical
+	}
+	return Warning
+}
+
+// boolValue returns the value used for boolean alarms such as a power failure
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// flagLimit returns a limit that alarms when a boolean metric is set
+func flagLimit(metric string, detent time.Duration, page bool) Limit {
+	return Limit{Metric: metric, Max: 0.5, HasMax: true, MinDuration: detent, Severity: severity(page)}
+}
+
+// DeviceHysteresis is the margin, in metric units, by which a reading must come back inside a
+// limit from the configuration of a device before its alarm is cleared.  The devices don't
+// report the band they clear their own alarms with, so these follow the deadband of their
+// controls and can be changed to suit.
+var DeviceHysteresis = map[string]float64{
+	"ec":       0.1,
+	"ph":       0.1,
+	"nut_temp": 0.5,
+	"air_temp": 0.5,
+	"rh":       2,
+	"co2":      50,
+}
+
+// hysteresis sets the DeviceHysteresis of each limit, converted into the unit system that the
+// limits are in
+func hysteresis(limits []Limit, sys units.System) []Limit {
+	for i, l := range limits {
+		h := DeviceHysteresis[l.Metric]
+		switch l.Metric {
+		case "ec":
+			h = units.ConvertConductivity(h, units.EC, sys.Conductivity)
+		case "nut_temp", "air_temp":
+			h = units.ConvertTemperatureDifference(h, units.Celsius, sys.Temperature)
+		}
+		limits[i].Hysteresis = h
+	}
+	return limits
+}
+
+// detent returns the delay configured on the device before an alarm is raised, given in minutes
+func detent(d byte) time.Duration {
+	return time.Duration(d) * time.Minute
+}
+
+// IntelliDoseLimits returns the alarm limits configured on the IntelliDose, cleared with the
+// DeviceHysteresis.  The limits are converted into the metric units the readings are given in.
+// The config and state must have been fetched.
+func IntelliDoseLimits(id *ig.IntelliDose) []Limit {
+	n := id.Status.Nutrient
+	sys := id.Units()
+	delay := detent(n.Detent)
+	limits := []Limit{}
+
+	if n.Ec.Enabled {
+		min := sys.ConductivityTo(n.Ec.Min, units.Metric)
+		max := sys.ConductivityTo(n.Ec.Max, units.Metric)
+		limits = append(limits, Limit{Metric: "ec", Min: min, HasMin: true, Max: max, HasMax: true, MinDuration: delay, Severity: Warning})
+	}
+
+	if n.Ph.Enabled {
+		limits = append(limits, Limit{Metric: "ph", Min: n.Ph.Min, HasMin: true, Max: n.Ph.Max, HasMax: true, MinDuration: delay, Severity: Warning})
+	}
+
+	if n.NutTemp.Enabled {
+		min := sys.TemperatureTo(n.NutTemp.Min, units.Metric)
+		max := sys.TemperatureTo(n.NutTemp.Max, units.Metric)
+		limits = append(limits, Limit{Metric: "nut_temp", Min: min, HasMin: true, Max: max, HasMax: true, MinDuration: delay, Severity: Warning})
+	}
+
+	return hysteresis(limits, units.Metric)
+}
+
+// IntelliDoseReadings returns the last readings of the IntelliDose keyed by metric
+func IntelliDoseReadings(id *ig.IntelliDose) map[string]float64 {
+	return map[string]float64{
+		"ec":       id.Metrics.Ec,
+		"ph":       id.Metrics.PH,
+		"nut_temp": id.Metrics.NutTemp,
+	}
+}
+
+// IntelliClimateLimits returns the alarm limits configured on the IntelliClimate, cleared with
+// the DeviceHysteresis.  Temperatures are converted into °C as the readings are given in.  The
+// config and state must have been fetched.
+func IntelliClimateLimits(ic *ig.IntelliClimate) []Limit {
+	sys := ic.Units()
+	limits := climateLimits(ic.Status.Readings, func(v float64) float64 {
+		return sys.TemperatureTo(v, units.Metric)
+	})
+	return hysteresis(limits, units.Metric)
+}
+
+// climateLimits returns the limits of the alarm settings of an IntelliClimate, with the
+// temperatures converted by the given func
+func climateLimits(r datastructs.ReadingsIClimate, temp func(float64) float64) []Limit {
+	delay := detent(r.Detent)
+	limits := []Limit{}
+
+	if r.AirTemp.Enabled {
+		min := temp(r.AirTemp.Min)
+		max := temp(r.AirTemp.Max)
+		limits = append(limits, Limit{Metric: "air_temp", Min: min, HasMin: true, Max: max, HasMax: true, MinDuration: delay, Severity: severity(r.AirTemp.Page)})
+	}
+
+	if r.Rh.Enabled {
+		limits = append(limits, Limit{Metric: "rh", Min: float64(r.Rh.Min), HasMin: true, Max: float64(r.Rh.Max), HasMax: true, MinDuration: delay, Severity: severity(r.Rh.Page)})
+	}
+
+	if r.CO2.Enabled {
+		limits = append(limits, Limit{Metric: "co2", Min: r.CO2.Min, HasMin: true, Max: r.CO2.Max, HasMax: true, MinDuration: delay, Severity: severity(r.CO2.Page)})
+	}
+
+	if r.Light.Enabled {
+		limits = append(limits, Limit{Metric: "light", Min: r.Light.Min, HasMin: true, MinDuration: delay, Severity: severity(r.Light.Page)})
+	}
+
+	if r.PowerFail.Enabled {
+		limits = append(limits, flagLimit("power_fail", 0, r.PowerFail.Page))
+	}
+
+	if r.FailSafeAlarms.Enabled {
+		limits = append(limits, flagLimit("fail_safe_alarms", 0, r.FailSafeAlarms.Page))
+	}
+
+	if r.IntruderAlarm.Enabled {
+		limits = append(limits, flagLimit("intruder_alarm", 0, r.IntruderAlarm.Page))
+	}
+
+	return limits
+}
+
+// IntelliClimateReadings returns the last readings of the IntelliClimate keyed by metric, with
+// the boolean alarms given as 1 when set
+func IntelliClimateReadings(ic *ig.IntelliClimate) map[string]float64 {
+	return climateReadings(*ic.Metrics)
+}
+
+func climateReadings(m datastructs.MetricsIClimate) map[string]float64 {
+	return map[string]float64{
+		"air_temp":         m.AirTemp,
+		"rh":               m.Rh,
+		"co2":              m.Co2,
+		"light":            m.Light,
+		"vpd":              m.Vpd,
+		"power_fail":       boolValue(m.PowerFail),
+		"fail_safe_alarms": boolValue(m.FailSafeAlarms),
+		"intruder_alarm":   boolValue(m.Intruder),
+	}
+}
+
+// EvaluateIntelliDose updates the limits of the IntelliDose in the engine from its config and
+// evaluates its last readings.  The readings are only evaluated while the device is online, an
+// alarm is raised if it goes offline.
+func EvaluateIntelliDose(e *Engine, id *ig.IntelliDose) []Event {
+	events := []Event{}
+	if id.ValidStatus {
+		events = e.SetDeviceLimits(id.GetID(), IntelliDoseLimits(id))
+	}
+	return append(events, evaluateDevice(e, id.Device, IntelliDoseReadings(id))...)
+}
+
+// EvaluateIntelliClimate updates the limits of the IntelliClimate in the engine from its config
+// and evaluates its last readings.  The readings are only evaluated while the device is online,
+// an alarm is raised if it goes offline.
+func EvaluateIntelliClimate(e *Engine, ic *ig.IntelliClimate) []Event {
+	events := []Event{}
+	if ic.ValidStatus {
+		events = e.SetDeviceLimits(ic.GetID(), IntelliClimateLimits(ic))
+	}
+	return append(events, evaluateDevice(e, ic.Device, IntelliClimateReadings(ic))...)
+}
+
+// EvaluateGrowroom evaluates all the devices in the growroom
+func EvaluateGrowroom(e *Engine, g *ig.Growroom) []Event {
+	events := []Event{}
+
+	doses, _ := g.IntelliDoses()
+	for _, id := range doses {
+		events = append(events, EvaluateIntelliDose(e, id)...)
+	}
+
+	climates, _ := g.IntelliClimates()
+	for _, ic := range climates {
+		events = append(events, EvaluateIntelliClimate(e, ic)...)
+	}
+
+	return events
+}
+
+func evaluateDevice(e *Engine, d *ig.Device, readings map[string]float64) []Event {
+	now := time.Now()
+	state := d.HealthState()
+	events := e.EvaluateHealth(d.GetID(), state, now)
+
+	if state != health.Online {
+		return events
+	}
+
+	return append(events, e.Evaluate(d.GetID(), readings, d.LastContact())...)
+}
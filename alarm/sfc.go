@@ -0,0 +1,98 @@
+package alarm
+
+import (
+	"time"
+
+	"github.com/autogrow/go-jelly/health"
+	"github.com/autogrow/go-jelly/sfc"
+	"github.com/autogrow/go-jelly/units"
+)
+
+// SFCIntelliDoseLimits returns the alarm limits configured on an IntelliDose connected over the
+// local NATS bus, cleared with the DeviceHysteresis.  The limits are in the units the device is
+// configured with.
+func SFCIntelliDoseLimits(id *sfc.IntelliDose) []Limit {
+	reported := id.Snapshot().Reported
+	n := reported.Status.Nutrient
+	delay := detent(n.Detent)
+	limits := []Limit{}
+
+	if n.Ec.Enabled {
+		limits = append(limits, Limit{Metric: "ec", Min: n.Ec.Min, HasMin: true, Max: n.Ec.Max, HasMax: true, MinDuration: delay, Severity: Warning})
+	}
+
+	if n.Ph.Enabled {
+		limits = append(limits, Limit{Metric: "ph", Min: n.Ph.Min, HasMin: true, Max: n.Ph.Max, HasMax: true, MinDuration: delay, Severity: Warning})
+	}
+
+	if n.NutTemp.Enabled {
+		limits = append(limits, Limit{Metric: "nut_temp", Min: n.NutTemp.Min, HasMin: true, Max: n.NutTemp.Max, HasMax: true, MinDuration: delay, Severity: Warning})
+	}
+
+	return hysteresis(limits, reported.Config.Units.System())
+}
+
+// SFCIntelliDoseReadings returns the last readings of an IntelliDose connected over the local
+// NATS bus keyed by metric
+func SFCIntelliDoseReadings(id *sfc.IntelliDose) map[string]float64 {
+	r := id.Readings()
+	return map[string]float64{
+		"ec":       r.Ec,
+		"ph":       r.PH,
+		"nut_temp": r.NutTemp,
+	}
+}
+
+// EvaluateSFCIntelliDose updates the limits of an IntelliDose connected over the local NATS bus
+// and evaluates its last readings.  The readings are only evaluated while the device is online
+// according to the given policy, an alarm is raised if it goes offline.
+func EvaluateSFCIntelliDose(e *Engine, id *sfc.IntelliDose, policy health.Policy) []Event {
+	now := time.Now()
+	snap := id.Snapshot()
+	state := policy.Evaluate(health.Rootzone, snap.Received, snap.Reported.Connected, now)
+	events := e.EvaluateHealth(id.Serial(), state, now)
+
+	if state != health.Online {
+		return events
+	}
+
+	events = append(events, e.SetDeviceLimits(id.Serial(), SFCIntelliDoseLimits(id))...)
+	return append(events, e.Evaluate(id.Serial(), SFCIntelliDoseReadings(id), snap.Received)...)
+}
+
+// SFCIntelliClimateLimits returns the alarm limits configured on an IntelliClimate connected
+// over the local NATS bus, cleared with the DeviceHysteresis.  The limits are in the units the
+// device is configured with.
+func SFCIntelliClimateLimits(ic *sfc.IntelliClimate) []Limit {
+	reported := ic.Snapshot().Reported
+	limits := climateLimits(reported.Status.Readings, func(v float64) float64 { return v })
+
+	sys := units.Metric
+	if t, err := units.ParseTemperatureUnit(reported.Config.Units.Temperature); err == nil {
+		sys.Temperature = t
+	}
+	return hysteresis(limits, sys)
+}
+
+// SFCIntelliClimateReadings returns the last readings of an IntelliClimate connected over the
+// local NATS bus keyed by metric, with the boolean alarms given as 1 when set
+func SFCIntelliClimateReadings(ic *sfc.IntelliClimate) map[string]float64 {
+	return climateReadings(ic.Readings())
+}
+
+// EvaluateSFCIntelliClimate updates the limits of an IntelliClimate connected over the local
+// NATS bus and evaluates its last readings.  The readings are only evaluated while the device is
+// online according to the given policy, an alarm is raised if it goes offline.
+func EvaluateSFCIntelliClimate(e *Engine, ic *sfc.IntelliClimate, policy health.Policy) []Event {
+	now := time.Now()
+	snap := ic.Snapshot()
+	state := policy.Evaluate(health.Climate, snap.Received, snap.Reported.Connected, now)
+	events := e.EvaluateHealth(ic.Serial(), state, now)
+
+	if state != health.Online {
+		return events
+	}
+
+	events = append(events, e.SetDeviceLimits(ic.Serial(), SFCIntelliClimateLimits(ic))...)
+	return append(events, e.Evaluate(ic.Serial(), SFCIntelliClimateReadings(ic), snap.Received)...)
+}
@@ -0,0 +1,282 @@
+package anomaly
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/autogrow/go-jelly/calc"
+)
+
+// Kind is the type of anomaly
+type Kind string
+
+const (
+	// Flatline - the sensor reported the same value for longer than is plausible
+	Flatline Kind = "flatline"
+	// Step - the readings jumped to a new level and stayed there
+	Step Kind = "step"
+	// Rate - the readings changed faster than is physically plausible and came back
+	Rate Kind = "rate"
+	// Divergence - two devices in the same growroom disagree for a sustained period
+	Divergence Kind = "divergence"
+)
+
+// Anomaly is a suspicious pattern in the readings of a sensor
+type Anomaly struct {
+	Kind   Kind   `json:"kind"`
+	Device string `json:"device"`
+	Metric string `json:"metric"`
+	// Other is the device that the readings diverged from
+	Other string    `json:"other,omitempty"`
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+	// Value is the size of the anomaly: the flatlined value, the size of the step, the rate of
+	// change per hour or the mean divergence
+	Value float64 `json:"value"`
+	// Confidence is between 0.5 and 1, with 0.5 being just over the limit
+	Confidence float64 `json:"confidence"`
+}
+
+// String describes the anomaly
+func (a Anomaly) String() string {
+	var desc string
+	switch a.Kind {
+	case Flatline:
+		desc = fmt.Sprintf("flatlined at %g for %s", a.Value, a.End.Sub(a.Start))
+	case Step:
+		desc = fmt.Sprintf("stepped by %+g", a.Value)
+	case Rate:
+		desc = fmt.Sprintf("changed at %g/h", a.Value)
+	case Divergence:
+		desc = fmt.Sprintf("diverged from %s by %g for %s", a.Other, a.Value, a.End.Sub(a.Start))
+	}
+	return fmt.Sprintf("%s %s %s (%.0f%% confidence)", a.Device, a.Metric, desc, a.Confidence*100)
+}
+
+// Limits are the thresholds beyond which readings of a metric are anomalous
+type Limits struct {
+	// FlatlineWindow is how long readings can stay within FlatlineTolerance of each other
+	FlatlineWindow    time.Duration
+	FlatlineTolerance float64
+	// StepSize is the change between consecutive readings that is a step
+	StepSize float64
+	// MaxRate is the fastest plausible change per hour
+	MaxRate float64
+	// MaxDivergence is how far apart two devices can be for longer than DivergenceWindow
+	MaxDivergence    float64
+	DivergenceWindow time.Duration
+}
+
+// DefaultLimits are limits for the metrics reported by IntelliDoses and IntelliClimates, with
+// temperatures in °C and EC in mS/cm
+var DefaultLimits = map[string]Limits{
+	"ec":       {FlatlineWindow: 6 * time.Hour, FlatlineTolerance: 0.001, StepSize: 0.5, MaxRate: 1, MaxDivergence: 0.3, DivergenceWindow: time.Hour},
+	"ph":       {FlatlineWindow: 6 * time.Hour, FlatlineTolerance: 0.001, StepSize: 0.5, MaxRate: 1, MaxDivergence: 0.3, DivergenceWindow: time.Hour},
+	"nut_temp": {FlatlineWindow: 12 * time.Hour, FlatlineTolerance: 0.01, StepSize: 3, MaxRate: 5, MaxDivergence: 2, DivergenceWindow: time.Hour},
+	"air_temp": {FlatlineWindow: 6 * time.Hour, FlatlineTolerance: 0.01, StepSize: 5, MaxRate: 15, MaxDivergence: 3, DivergenceWindow: time.Hour},
+	"rh":       {FlatlineWindow: 6 * time.Hour, FlatlineTolerance: 0.01, StepSize: 20, MaxRate: 60, MaxDivergence: 10, DivergenceWindow: time.Hour},
+	"co2":      {FlatlineWindow: 6 * time.Hour, FlatlineTolerance: 0.5, StepSize: 500, MaxRate: 2000, MaxDivergence: 200, DivergenceWindow: time.Hour},
+}
+
+// confidence scores how far over a limit a ratio of value to limit is, 1 being at the limit
+func confidence(ratio float64) float64 {
+	if ratio < 1 {
+		return 0
+	}
+	return 1 - 0.5/ratio
+}
+
+// Analyze returns the flatlines, steps and implausible rates of change in the samples of a
+// metric, which must be in time order
+func Analyze(device, metric string, samples []calc.Sample, lim Limits) []Anomaly {
+	anomalies := []Anomaly{}
+	anomalies = append(anomalies, Flatlines(device, metric, samples, lim)...)
+	anomalies = append(anomalies, Jumps(device, metric, samples, lim)...)
+	return anomalies
+}
+
+// Flatlines returns the periods that the readings stayed within the flatline tolerance of each
+// other for longer than the flatline window.  Confidence grows from 0.5 as the period gets
+// longer than the window.
+func Flatlines(device, metric string, samples []calc.Sample, lim Limits) []Anomaly {
+	anomalies := []Anomaly{}
+	if lim.FlatlineWindow <= 0 {
+		return anomalies
+	}
+
+	report := func(run []calc.Sample) {
+		if len(run) < 3 {
+			return
+		}
+
+		dur := run[len(run)-1].Time.Sub(run[0].Time)
+		if dur < lim.FlatlineWindow {
+			return
+		}
+
+		anomalies = append(anomalies, Anomaly{
+			Kind:       Flatline,
+			Device:     device,
+			Metric:     metric,
+			Start:      run[0].Time,
+			End:        run[len(run)-1].Time,
+			Value:      run[0].Value,
+			Confidence: confidence(float64(dur) / float64(lim.FlatlineWindow)),
+		})
+	}
+
+	start := 0
+	lo, hi := math.Inf(1), math.Inf(-1)
+	for i, s := range samples {
+		lo, hi = math.Min(lo, s.Value), math.Max(hi, s.Value)
+		if hi-lo <= lim.FlatlineTolerance {
+			continue
+		}
+
+		report(samples[start:i])
+		start = i
+		lo, hi = s.Value, s.Value
+	}
+	report(samples[start:])
+
+	return anomalies
+}
+
+// persistence is the number of samples after a jump that are checked to see if the readings
+// stayed at the new level
+const persistence = 3
+
+// Jumps returns the changes between consecutive readings that are steps, where steady readings
+// jump by more than the step size and stay at the new level, or implausible rates of change.
+// The return from a spike is not a step as the readings weren't steady before it.
+func Jumps(device, metric string, samples []calc.Sample, lim Limits) []Anomaly {
+	anomalies := []Anomaly{}
+
+	for i := 1; i < len(samples); i++ {
+		prev, cur := samples[i-1], samples[i]
+		jump := cur.Value - prev.Value
+
+		if lim.StepSize > 0 && math.Abs(jump) > lim.StepSize && steady(samples[:i], samples[i:], lim.StepSize/2) {
+			anomalies = append(anomalies, Anomaly{
+				Kind:       Step,
+				Device:     device,
+				Metric:     metric,
+				Start:      prev.Time,
+				End:        cur.Time,
+				Value:      jump,
+				Confidence: confidence(math.Abs(jump) / lim.StepSize),
+			})
+			continue
+		}
+
+		hours := cur.Time.Sub(prev.Time).Hours()
+		if lim.MaxRate <= 0 || hours <= 0 {
+			continue
+		}
+
+		rate := math.Abs(jump) / hours
+		if rate > lim.MaxRate {
+			anomalies = append(anomalies, Anomaly{
+				Kind:       Rate,
+				Device:     device,
+				Metric:     metric,
+				Start:      prev.Time,
+				End:        cur.Time,
+				Value:      rate,
+				Confidence: confidence(rate / lim.MaxRate),
+			})
+		}
+	}
+
+	return anomalies
+}
+
+// steady returns true if the readings before a jump stayed within the tolerance of the last
+// reading before it, and the readings after it stayed within the tolerance of the first reading
+// after it.  There must be enough readings after the jump to tell.
+func steady(before, after []calc.Sample, tolerance float64) bool {
+	if len(after) < persistence+1 {
+		return false
+	}
+
+	for _, s := range after[1 : persistence+1] {
+		if math.Abs(s.Value-after[0].Value) > tolerance {
+			return false
+		}
+	}
+
+	last := before[len(before)-1]
+	for i := len(before) - 2; i >= 0 && i >= len(before)-1-persistence; i-- {
+		if math.Abs(before[i].Value-last.Value) > tolerance {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Diverged returns the periods that the readings of two devices were further apart than the
+// maximum divergence for longer than the divergence window.  The readings of b are matched to
+// the closest reading of a within the tolerance.  Confidence grows with the mean divergence.
+func Diverged(metric, deviceA string, a []calc.Sample, deviceB string, b []calc.Sample, tolerance time.Duration, lim Limits) []Anomaly {
+	anomalies := []Anomaly{}
+	if lim.MaxDivergence <= 0 {
+		return anomalies
+	}
+
+	var start, end time.Time
+	var sum float64
+	var count int
+
+	report := func() {
+		if count > 0 && end.Sub(start) >= lim.DivergenceWindow {
+			mean := sum / float64(count)
+			anomalies = append(anomalies, Anomaly{
+				Kind:       Divergence,
+				Device:     deviceA,
+				Other:      deviceB,
+				Metric:     metric,
+				Start:      start,
+				End:        end,
+				Value:      mean,
+				Confidence: confidence(mean / lim.MaxDivergence),
+			})
+		}
+		count, sum = 0, 0
+	}
+
+	j := 0
+	for _, sa := range a {
+		for j+1 < len(b) && absDuration(b[j+1].Time.Sub(sa.Time)) <= absDuration(b[j].Time.Sub(sa.Time)) {
+			j++
+		}
+
+		if j >= len(b) || absDuration(b[j].Time.Sub(sa.Time)) > tolerance {
+			continue
+		}
+
+		diff := math.Abs(sa.Value - b[j].Value)
+		if diff <= lim.MaxDivergence {
+			report()
+			continue
+		}
+
+		if count == 0 {
+			start = sa.Time
+		}
+		end = sa.Time
+		sum += diff
+		count++
+	}
+	report()
+
+	return anomalies
+}
+
+func absDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
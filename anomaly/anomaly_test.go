@@ -0,0 +1,245 @@
+package anomaly
+
+import (
+	"testing"
+	"time"
+
+	"github.com/autogrow/go-jelly/calc"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+var start = time.Date(2018, 6, 1, 0, 0, 0, 0, time.UTC)
+
+// series returns samples every 10 minutes with the given values
+func series(values ...float64) []calc.Sample {
+	samples := []calc.Sample{}
+	for i, v := range values {
+		samples = append(samples, calc.Sample{Time: start.Add(time.Duration(i) * 10 * time.Minute), Value: v})
+	}
+	return samples
+}
+
+// repeat returns the value n times
+func repeat(v float64, n int) []float64 {
+	values := []float64{}
+	for i := 0; i < n; i++ {
+		values = append(values, v)
+	}
+	return values
+}
+
+// wobble returns n values that move around the value by less than the step size and rate
+func wobble(v float64, n int) []float64 {
+	values := []float64{}
+	for i := 0; i < n; i++ {
+		values = append(values, v+float64(i%3)*0.05)
+	}
+	return values
+}
+
+func TestFlatlines(t *testing.T) {
+	lim := DefaultLimits["ph"]
+
+	Convey("given readings that don't change for longer than the flatline window", t, func() {
+		values := append(wobble(6, 6), repeat(6.2, 48)...)
+		values = append(values, wobble(6, 6)...)
+		anomalies := Flatlines("ID1", "ph", series(values...), lim)
+
+		Convey("it should find the flatline", func() {
+			So(anomalies, ShouldHaveLength, 1)
+			a := anomalies[0]
+			So(a.Kind, ShouldEqual, Flatline)
+			So(a.Value, ShouldEqual, 6.2)
+			So(a.Start, ShouldEqual, start.Add(time.Hour))
+			So(a.End.Sub(a.Start), ShouldEqual, 470*time.Minute)
+			So(a.Confidence, ShouldBeBetween, 0.5, 1)
+		})
+	})
+
+	Convey("given readings that don't change for less than the flatline window", t, func() {
+		values := append(wobble(6, 6), repeat(6.2, 30)...)
+		values = append(values, wobble(6, 6)...)
+
+		Convey("it should not find a flatline", func() {
+			So(Flatlines("ID1", "ph", series(values...), lim), ShouldBeEmpty)
+		})
+	})
+
+	Convey("a longer flatline should have more confidence", t, func() {
+		short := Flatlines("ID1", "ph", series(repeat(6, 40)...), lim)
+		long := Flatlines("ID1", "ph", series(repeat(6, 80)...), lim)
+		So(short, ShouldHaveLength, 1)
+		So(long, ShouldHaveLength, 1)
+		So(short[0].Confidence, ShouldBeGreaterThanOrEqualTo, 0.5)
+		So(long[0].Confidence, ShouldBeGreaterThan, short[0].Confidence)
+		So(long[0].Confidence, ShouldBeLessThan, 1)
+	})
+}
+
+func TestJumps(t *testing.T) {
+	lim := DefaultLimits["ec"]
+
+	Convey("given readings that jump to a new level and stay there", t, func() {
+		values := append(wobble(1.5, 7), wobble(2.5, 6)...)
+		anomalies := Jumps("ID1", "ec", series(values...), lim)
+
+		Convey("it should find a step", func() {
+			So(anomalies, ShouldHaveLength, 1)
+			So(anomalies[0].Kind, ShouldEqual, Step)
+			So(anomalies[0].Value, ShouldAlmostEqual, 1.0)
+			So(anomalies[0].Start, ShouldEqual, start.Add(time.Hour))
+			So(anomalies[0].Confidence, ShouldAlmostEqual, 0.75)
+		})
+	})
+
+	Convey("given readings that spike and come back", t, func() {
+		values := append(wobble(1.5, 6), 2.5)
+		values = append(values, wobble(1.5, 6)...)
+		anomalies := Jumps("ID1", "ec", series(values...), lim)
+
+		Convey("it should find implausible rates of change up and down", func() {
+			So(anomalies, ShouldHaveLength, 2)
+			for _, a := range anomalies {
+				So(a.Kind, ShouldEqual, Rate)
+				So(a.Value, ShouldBeGreaterThan, lim.MaxRate)
+			}
+		})
+	})
+
+	Convey("given readings that change slowly", t, func() {
+		values := []float64{}
+		for i := 0; i < 30; i++ {
+			values = append(values, 1.5+float64(i)*0.01)
+		}
+
+		Convey("it should not find anything", func() {
+			So(Jumps("ID1", "ec", series(values...), lim), ShouldBeEmpty)
+		})
+	})
+
+	Convey("a jump at the end of the readings should not be a step until it has persisted", t, func() {
+		values := append(wobble(1.5, 6), 2.5, 2.5)
+		anomalies := Jumps("ID1", "ec", series(values...), lim)
+		So(anomalies, ShouldHaveLength, 1)
+		So(anomalies[0].Kind, ShouldEqual, Rate)
+	})
+}
+
+func TestDiverged(t *testing.T) {
+	lim := DefaultLimits["ph"]
+
+	Convey("given two devices that drift apart", t, func() {
+		a := series(wobble(6, 20)...)
+		values := append(wobble(6, 6), wobble(6.6, 14)...)
+		b := series(values...)
+
+		anomalies := Diverged("ph", "ID1", a, "ID2", b, DefaultTolerance, lim)
+
+		Convey("it should find the divergence", func() {
+			So(anomalies, ShouldHaveLength, 1)
+			d := anomalies[0]
+			So(d.Kind, ShouldEqual, Divergence)
+			So(d.Device, ShouldEqual, "ID1")
+			So(d.Other, ShouldEqual, "ID2")
+			So(d.Start, ShouldEqual, start.Add(time.Hour))
+			So(d.Value, ShouldAlmostEqual, 0.6)
+			So(d.Confidence, ShouldAlmostEqual, 0.75)
+		})
+	})
+
+	Convey("given two devices that differ for less than the divergence window", t, func() {
+		a := series(wobble(6, 20)...)
+		values := append(wobble(6, 6), wobble(6.6, 4)...)
+		values = append(values, wobble(6, 10)...)
+
+		Convey("it should not find a divergence", func() {
+			So(Diverged("ph", "ID1", a, "ID2", series(values...), DefaultTolerance, lim), ShouldBeEmpty)
+		})
+	})
+
+	Convey("readings too far apart in time should not be compared", t, func() {
+		a := series(wobble(6, 20)...)
+		b := series(wobble(7, 20)...)
+		for i := range b {
+			b[i].Time = b[i].Time.Add(time.Hour * 24)
+		}
+		So(Diverged("ph", "ID1", a, "ID2", b, DefaultTolerance, lim), ShouldBeEmpty)
+	})
+}
+
+func TestMonitor(t *testing.T) {
+	Convey("given a monitor", t, func() {
+		m := NewMonitor(24 * time.Hour)
+		m.Pair("ID2", "ID1")
+
+		add := func(device string, values []float64) []Anomaly {
+			anomalies := []Anomaly{}
+			for _, s := range series(values...) {
+				anomalies = append(anomalies, m.Add(device, "ph", s)...)
+			}
+			return anomalies
+		}
+
+		Convey("it should return a flatline once", func() {
+			anomalies := add("ID1", repeat(6, 60))
+			So(anomalies, ShouldHaveLength, 1)
+			So(anomalies[0].Kind, ShouldEqual, Flatline)
+		})
+
+		Convey("it should return a flatline longer than the window once", func() {
+			m.Window = 12 * time.Hour
+			anomalies := add("ID1", repeat(6, 6*48))
+			So(anomalies, ShouldHaveLength, 1)
+			So(anomalies[0].Kind, ShouldEqual, Flatline)
+
+			Convey("and a new flatline after it has ended", func() {
+				values := append(wobble(7, 6), repeat(8, 60)...)
+				anomalies := []Anomaly{}
+				for i, v := range values {
+					s := calc.Sample{Time: start.Add(time.Duration(6*48+i) * 10 * time.Minute), Value: v}
+					anomalies = append(anomalies, m.Add("ID1", "ph", s)...)
+				}
+				flat := []Anomaly{}
+				for _, a := range anomalies {
+					if a.Kind == Flatline {
+						flat = append(flat, a)
+					}
+				}
+				So(flat, ShouldHaveLength, 1)
+				So(flat[0].Value, ShouldEqual, 8)
+			})
+		})
+
+		Convey("it should return a divergence between paired devices once", func() {
+			anomalies := add("ID1", wobble(6, 20))
+			So(anomalies, ShouldBeEmpty)
+
+			diverged := []Anomaly{}
+			for _, a := range add("ID2", append(wobble(6, 6), wobble(6.6, 14)...)) {
+				if a.Kind == Divergence {
+					diverged = append(diverged, a)
+				}
+			}
+			So(diverged, ShouldHaveLength, 1)
+			So(diverged[0].Device, ShouldEqual, "ID1")
+			So(diverged[0].Other, ShouldEqual, "ID2")
+		})
+
+		Convey("it should ignore readings that are out of order", func() {
+			m.Add("ID1", "ph", calc.Sample{Time: start.Add(time.Hour), Value: 6})
+			So(m.Add("ID1", "ph", calc.Sample{Time: start, Value: 9}), ShouldBeEmpty)
+			So(m.series["ID1|ph"], ShouldHaveLength, 1)
+		})
+
+		Convey("it should ignore metrics without limits", func() {
+			So(m.Add("ID1", "light", calc.Sample{Time: start, Value: 9}), ShouldBeEmpty)
+			So(m.series, ShouldBeEmpty)
+		})
+
+		Convey("it should forget readings older than the window", func() {
+			m.Window = time.Hour
+			add("ID1", wobble(6, 20))
+			So(m.series["ID1|ph"], ShouldHaveLength, 7)
+		})
+	})
+}
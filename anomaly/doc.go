@@ -0,0 +1,36 @@
+// Package anomaly finds readings that suggest a sensor needs cleaning or calibrating, such as a
+// dirty pH electrode or a failing EC probe.
+//
+// It looks for four kinds of anomaly:
+//
+//     flatline    the readings stayed the same for longer than is plausible
+//     step        the readings jumped to a new level and stayed there
+//     rate        the readings changed faster than is physically plausible
+//     divergence  two devices in the same growroom disagree for a sustained period
+//
+// Each anomaly has a confidence between 0.5 and 1 that grows the further the readings are past
+// the limits for the metric.  The default limits expect temperatures in °C and EC in mS/cm, as
+// the history and metrics are given in.
+//
+// History is analyzed once it has been fetched for each device:
+//
+//     for _, id := range doses {
+//       id.GetHistory(time.Now(), time.Now().Add(-7*24*time.Hour), 1000)
+//     }
+//
+//     for _, a := range anomaly.AnalyzeGrowroom(room, anomaly.DefaultLimits) {
+//       fmt.Println(a)
+//     }
+//
+// Live readings are given to a monitor each time the growrooms are updated, which only returns
+// each anomaly once:
+//
+//     monitor := anomaly.NewMonitor(24 * time.Hour)
+//     for {
+//       client.UpdateAllGrowrooms()
+//       for _, a := range anomaly.MonitorGrowroom(monitor, room) {
+//         log.Println(a)
+//       }
+//       time.Sleep(time.Minute)
+//     }
+package anomaly
@@ -0,0 +1,202 @@
+package anomaly
+
+import (
+	"sort"
+	"time"
+
+	"github.com/autogrow/go-jelly/alarm"
+	"github.com/autogrow/go-jelly/calc"
+	"github.com/autogrow/go-jelly/health"
+	"github.com/autogrow/go-jelly/ig"
+)
+
+// Series is the readings of each metric of a device in time order
+type Series map[string][]calc.Sample
+
+func (s Series) add(metric string, ts float64, v float64) {
+	s[metric] = append(s[metric], calc.Sample{Time: time.Unix(0, int64(ts)*int64(time.Millisecond)), Value: v})
+}
+
+func (s Series) sort() {
+	for _, samples := range s {
+		sort.SliceStable(samples, func(i, j int) bool { return samples[i].Time.Before(samples[j].Time) })
+	}
+}
+
+// DoserSeries returns the EC, pH and nutrient temperature readings from the history of the
+// IntelliDose fetched with GetHistory
+func DoserSeries(id *ig.IntelliDose) Series {
+	s := Series{}
+	if id.History == nil {
+		return s
+	}
+
+	for _, p := range id.History.Points {
+		s.add("ec", p.Timestamp, p.Metrics.EC)
+		s.add("ph", p.Timestamp, p.Metrics.PH)
+		s.add("nut_temp", p.Timestamp, p.Metrics.Temp)
+	}
+
+	s.sort()
+	return s
+}
+
+// ClimateSeries returns the air temperature, humidity and CO2 readings from the history of the
+// IntelliClimate fetched with GetHistory
+func ClimateSeries(ic *ig.IntelliClimate) Series {
+	s := Series{}
+	if ic.History == nil {
+		return s
+	}
+
+	for _, p := range ic.History.Points {
+		s.add("air_temp", p.Timestamp, p.Metrics.AirTemp)
+		s.add("rh", p.Timestamp, p.Metrics.Rh)
+		s.add("co2", p.Timestamp, p.Metrics.CO2)
+	}
+
+	s.sort()
+	return s
+}
+
+// AnalyzeSeries returns the anomalies in each metric of the device that has limits
+func AnalyzeSeries(device string, s Series, limits map[string]Limits) []Anomaly {
+	anomalies := []Anomaly{}
+	for _, metric := range metrics(s) {
+		if lim, ok := limits[metric]; ok {
+			anomalies = append(anomalies, Analyze(device, metric, s[metric], lim)...)
+		}
+	}
+	return anomalies
+}
+
+// metrics returns the metrics of the series in a stable order
+func metrics(s Series) []string {
+	names := []string{}
+	for name := range s {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// AnalyzeIntelliDose returns the anomalies in the history of the IntelliDose
+func AnalyzeIntelliDose(id *ig.IntelliDose, limits map[string]Limits) []Anomaly {
+	return AnalyzeSeries(id.GetID(), DoserSeries(id), limits)
+}
+
+// AnalyzeIntelliClimate returns the anomalies in the history of the IntelliClimate
+func AnalyzeIntelliClimate(ic *ig.IntelliClimate, limits map[string]Limits) []Anomaly {
+	return AnalyzeSeries(ic.GetID(), ClimateSeries(ic), limits)
+}
+
+// AnalyzeGrowroom returns the anomalies in the history of each device in the growroom, and where
+// devices of the same type in the room diverge from each other.  The history of each device must
+// have been fetched with GetHistory for the same period.
+func AnalyzeGrowroom(g *ig.Growroom, limits map[string]Limits) []Anomaly {
+	anomalies := []Anomaly{}
+
+	ids, _ := g.IntelliDoses()
+	dosers := map[string]Series{}
+	for _, id := range ids {
+		dosers[id.GetID()] = DoserSeries(id)
+	}
+
+	ics, _ := g.IntelliClimates()
+	climates := map[string]Series{}
+	for _, ic := range ics {
+		climates[ic.GetID()] = ClimateSeries(ic)
+	}
+
+	for _, devices := range []map[string]Series{dosers, climates} {
+		anomalies = append(anomalies, analyzeRoom(devices, limits)...)
+	}
+
+	return anomalies
+}
+
+// analyzeRoom analyzes each device and compares each pair of devices
+func analyzeRoom(devices map[string]Series, limits map[string]Limits) []Anomaly {
+	serials := []string{}
+	for serial := range devices {
+		serials = append(serials, serial)
+	}
+	sort.Strings(serials)
+
+	anomalies := []Anomaly{}
+	for i, a := range serials {
+		anomalies = append(anomalies, AnalyzeSeries(a, devices[a], limits)...)
+
+		for _, b := range serials[i+1:] {
+			for _, metric := range metrics(devices[a]) {
+				if lim, ok := limits[metric]; ok {
+					anomalies = append(anomalies, Diverged(metric, a, devices[a][metric], b, devices[b][metric], DefaultTolerance, lim)...)
+				}
+			}
+		}
+	}
+
+	return anomalies
+}
+
+// MonitorIntelliDose adds the last readings of the IntelliDose to the monitor while it is online
+// and returns any new anomalies
+func MonitorIntelliDose(m *Monitor, id *ig.IntelliDose) []Anomaly {
+	return monitorDevice(m, id.Device, alarm.IntelliDoseReadings(id))
+}
+
+// MonitorIntelliClimate adds the last readings of the IntelliClimate to the monitor while it is
+// online and returns any new anomalies
+func MonitorIntelliClimate(m *Monitor, ic *ig.IntelliClimate) []Anomaly {
+	return monitorDevice(m, ic.Device, alarm.IntelliClimateReadings(ic))
+}
+
+// MonitorGrowroom pairs the devices of the same type in the growroom, adds their last readings
+// to the monitor and returns any new anomalies
+func MonitorGrowroom(m *Monitor, g *ig.Growroom) []Anomaly {
+	anomalies := []Anomaly{}
+
+	ids, _ := g.IntelliDoses()
+	for i, id := range ids {
+		for _, other := range ids[i+1:] {
+			m.Pair(id.GetID(), other.GetID())
+		}
+	}
+
+	ics, _ := g.IntelliClimates()
+	for i, ic := range ics {
+		for _, other := range ics[i+1:] {
+			m.Pair(ic.GetID(), other.GetID())
+		}
+	}
+
+	for _, id := range ids {
+		anomalies = append(anomalies, MonitorIntelliDose(m, id)...)
+	}
+
+	for _, ic := range ics {
+		anomalies = append(anomalies, MonitorIntelliClimate(m, ic)...)
+	}
+
+	return anomalies
+}
+
+func monitorDevice(m *Monitor, d *ig.Device, readings map[string]float64) []Anomaly {
+	anomalies := []Anomaly{}
+	if d.HealthState() != health.Online {
+		return anomalies
+	}
+
+	at := d.LastContact()
+	names := []string{}
+	for name := range readings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		anomalies = append(anomalies, m.Add(d.GetID(), name, calc.Sample{Time: at, Value: readings[name]})...)
+	}
+
+	return anomalies
+}
@@ -0,0 +1,124 @@
+package anomaly
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/autogrow/go-jelly/calc"
+)
+
+// DefaultTolerance is how far apart in time readings of two devices can be and still be compared
+const DefaultTolerance = 5 * time.Minute
+
+// Monitor looks for anomalies in live readings as they are added.  It keeps the readings of each
+// device and metric for the window, which must be longer than the flatline and divergence
+// windows of the limits, and only returns each anomaly once.
+type Monitor struct {
+	// Limits are the limits for each metric, metrics without limits are ignored
+	Limits map[string]Limits
+	// Window is how long readings are kept for
+	Window time.Duration
+	// Tolerance is how far apart in time readings of paired devices can be and still be compared
+	Tolerance time.Duration
+
+	mu     sync.Mutex
+	series map[string][]calc.Sample
+	pairs  map[string][2]string
+	seen   map[string]span
+}
+
+// span is the start and end of an anomaly that has been returned
+type span struct {
+	start, end time.Time
+}
+
+// NewMonitor returns a monitor using the default limits that keeps readings for the window
+func NewMonitor(window time.Duration) *Monitor {
+	return &Monitor{
+		Limits:    DefaultLimits,
+		Window:    window,
+		Tolerance: DefaultTolerance,
+		series:    map[string][]calc.Sample{},
+		pairs:     map[string][2]string{},
+		seen:      map[string]span{},
+	}
+}
+
+// Pair will compare the readings of the two devices, which should be in the same growroom, for
+// divergence
+func (m *Monitor) Pair(a, b string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if a > b {
+		a, b = b, a
+	}
+	m.pairs[a+"|"+b] = [2]string{a, b}
+}
+
+// Add adds a reading of the metric from the device and returns any new anomalies.  Readings
+// older than the last reading of the metric are ignored.
+func (m *Monitor) Add(device, metric string, s calc.Sample) []Anomaly {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	lim, ok := m.Limits[metric]
+	if !ok {
+		return []Anomaly{}
+	}
+
+	key := device + "|" + metric
+	samples := m.series[key]
+	if n := len(samples); n > 0 && !s.Time.After(samples[n-1].Time) {
+		return []Anomaly{}
+	}
+
+	samples = append(samples, s)
+	cutoff := s.Time.Add(-m.Window)
+	for len(samples) > 0 && samples[0].Time.Before(cutoff) {
+		samples = samples[1:]
+	}
+	m.series[key] = samples
+
+	found := Analyze(device, metric, samples, lim)
+	for _, pair := range m.pairs {
+		if device != pair[0] && device != pair[1] {
+			continue
+		}
+
+		a, b := m.series[pair[0]+"|"+metric], m.series[pair[1]+"|"+metric]
+		found = append(found, Diverged(metric, pair[0], a, pair[1], b, m.Tolerance, lim)...)
+	}
+
+	return m.unseen(found, cutoff)
+}
+
+// unseen returns the anomalies that haven't been returned before.  Anomalies are keyed by kind,
+// devices and metric rather than start, as trimming readings older than the window moves the
+// start of a long flatline forward, so one that starts before the end of the last returned
+// anomaly with the same key is still going and only extends it.
+func (m *Monitor) unseen(found []Anomaly, cutoff time.Time) []Anomaly {
+	for id, sp := range m.seen {
+		if sp.end.Before(cutoff) {
+			delete(m.seen, id)
+		}
+	}
+
+	anomalies := []Anomaly{}
+	for _, a := range found {
+		id := fmt.Sprintf("%s|%s|%s|%s", a.Kind, a.Device, a.Other, a.Metric)
+		if sp, ok := m.seen[id]; ok && (a.Start.Equal(sp.start) || a.Start.Before(sp.end)) {
+			if a.End.After(sp.end) {
+				sp.end = a.End
+				m.seen[id] = sp
+			}
+			continue
+		}
+
+		m.seen[id] = span{a.Start, a.End}
+		anomalies = append(anomalies, a)
+	}
+
+	return anomalies
+}
@@ -0,0 +1,224 @@
+package calc
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// SaturationVapourPressure returns the saturation vapour pressure in kPa at the given
+// temperature using the Tetens equation
+func SaturationVapourPressure(temp float64) float64 {
+	return 0.61078 * math.Exp(17.27*temp/(temp+237.3))
+}
+
+// ActualVapourPressure returns the vapour pressure in kPa of air at the given temperature and
+// relative humidity
+func ActualVapourPressure(temp, rh float64) float64 {
+	return SaturationVapourPressure(temp) * rh / 100
+}
+
+// VPD returns the vapour pressure deficit of the air in kPa
+func VPD(airTemp, rh float64) float64 {
+	return SaturationVapourPressure(airTemp) - ActualVapourPressure(airTemp, rh)
+}
+
+// LeafVPD returns the vapour pressure deficit in kPa between the leaf and the air, where the
+// leaf temperature is the air temperature plus the given offset (usually negative as leaves
+// are cooler than the air under most lighting)
+func LeafVPD(airTemp, rh, leafOffset float64) float64 {
+	return SaturationVapourPressure(airTemp+leafOffset) - ActualVapourPressure(airTemp, rh)
+}
+
+// DewPoint returns the dew point in °C for the given air temperature and relative humidity
+// using the Magnus formula.  It will return false if the relative humidity isn't above zero,
+// as dry air has no dew point.
+func DewPoint(temp, rh float64) (float64, bool) {
+	if !(rh > 0) {
+		return 0, false
+	}
+
+	const a, b = 17.27, 237.7
+	gamma := a*temp/(b+temp) + math.Log(rh/100)
+	return b * gamma / (a - gamma), true
+}
+
+// AbsoluteHumidity returns the mass of water vapour in the air in g/m³
+func AbsoluteHumidity(temp, rh float64) float64 {
+	// the molar mass of water over the gas constant, scaled from kPa to g/m³
+	return 2166.8 * ActualVapourPressure(temp, rh) / (temp + 273.15)
+}
+
+// Sample is a single reading taken at a point in time
+type Sample struct {
+	Time  time.Time
+	Value float64
+}
+
+// DLI returns the daily light integral in mol/m²/day accumulated from the given light samples.
+// The factor converts a light reading into PPFD (µmol/m²/s) and depends on the sensor and the
+// light source.  Samples are integrated with the trapezoidal rule and so do not need to be evenly
+// spaced, but gaps are interpolated across.
+func DLI(samples []Sample, factor float64) float64 {
+	if len(samples) < 2 {
+		return 0
+	}
+
+	sorted := make([]Sample, len(samples))
+	copy(sorted, samples)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Time.Before(sorted[j].Time) })
+
+	var total float64
+	for i := 1; i < len(sorted); i++ {
+		dt := sorted[i].Time.Sub(sorted[i-1].Time).Seconds()
+		total += (sorted[i].Value + sorted[i-1].Value) / 2 * factor * dt
+	}
+
+	// µmol to mol
+	return total / 1e6
+}
+
+// DailyLightIntegral is the DLI for a single day
+type DailyLightIntegral struct {
+	Day time.Time `json:"day"`
+	DLI float64   `json:"dli"`
+}
+
+// DLIByDay splits the samples into days in the given location and returns the DLI for each
+// day in order.  The light between two samples either side of midnight is split between the
+// days, with the reading interpolated at midnight.
+func DLIByDay(samples []Sample, factor float64, loc *time.Location) []DailyLightIntegral {
+	sorted := make([]Sample, len(samples))
+	copy(sorted, samples)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Time.Before(sorted[j].Time) })
+
+	days := map[time.Time]float64{}
+	for i, s := range sorted {
+		// every day with a sample has a DLI, even if it is the only sample that day
+		day := startOfDay(s.Time, loc)
+		if _, ok := days[day]; !ok {
+			days[day] = 0
+		}
+		if i > 0 {
+			lightByDay(sorted[i-1], s, loc, func(day time.Time, light float64) {
+				days[day] += light
+			})
+		}
+	}
+
+	dlis := []DailyLightIntegral{}
+	for day, total := range days {
+		// µmol to mol
+		dlis = append(dlis, DailyLightIntegral{day, total * factor / 1e6})
+	}
+
+	sort.Slice(dlis, func(i, j int) bool { return dlis[i].Day.Before(dlis[j].Day) })
+	return dlis
+}
+
+func startOfDay(t time.Time, loc *time.Location) time.Time {
+	t = t.In(loc)
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+}
+
+// lightByDay integrates the readings between two samples with the trapezoidal rule, calling add
+// with each day in loc that the interval falls in and the light in that day.  The interval is
+// split at midnight, with the reading interpolated there.
+func lightByDay(from, to Sample, loc *time.Location, add func(day time.Time, light float64)) {
+	span := to.Time.Sub(from.Time).Seconds()
+	at := func(t time.Time) float64 {
+		return from.Value + (to.Value-from.Value)*t.Sub(from.Time).Seconds()/span
+	}
+
+	for start := from; start.Time.Before(to.Time); {
+		day := startOfDay(start.Time, loc)
+		end := to
+		if midnight := day.AddDate(0, 0, 1); midnight.Before(to.Time) {
+			end = Sample{midnight, at(midnight)}
+		}
+
+		add(day, (start.Value+end.Value)/2*end.Time.Sub(start.Time).Seconds())
+		start = end
+	}
+}
+
+// Derived holds the metrics derived from a single air temperature and RH reading.  The dew point
+// is nil if the air has no dew point.
+type Derived struct {
+	Time             time.Time `json:"time"`
+	VPD              float64   `json:"vpd"`
+	LeafVPD          float64   `json:"leaf_vpd"`
+	DewPoint         *float64  `json:"dew_point,omitempty"`
+	AbsoluteHumidity float64   `json:"absolute_humidity"`
+}
+
+// Derive calculates all the derived metrics for the given reading
+func Derive(at time.Time, airTemp, rh, leafOffset float64) Derived {
+	d := Derived{
+		Time:             at,
+		VPD:              VPD(airTemp, rh),
+		LeafVPD:          LeafVPD(airTemp, rh, leafOffset),
+		AbsoluteHumidity: AbsoluteHumidity(airTemp, rh),
+	}
+
+	if dp, ok := DewPoint(airTemp, rh); ok {
+		d.DewPoint = &dp
+	}
+
+	return d
+}
+
+// LightAccumulator accumulates live light readings into a daily light integral, starting again
+// at midnight in its location.  The light between readings either side of midnight is split
+// between the days.
+type LightAccumulator struct {
+	loc   *time.Location
+	day   time.Time
+	last  *Sample
+	total float64
+}
+
+// NewLightAccumulator returns a new accumulator that splits days in the given location
+func NewLightAccumulator(loc *time.Location) *LightAccumulator {
+	return &LightAccumulator{loc: loc}
+}
+
+// Add a light reading taken at the given time.  Readings older than the last one are ignored.
+func (la *LightAccumulator) Add(at time.Time, value float64) {
+	if la.last != nil {
+		if !at.After(la.last.Time) {
+			return
+		}
+
+		lightByDay(*la.last, Sample{at, value}, la.loc, func(day time.Time, light float64) {
+			la.startDay(day)
+			la.total += light
+		})
+	}
+
+	la.startDay(startOfDay(at, la.loc))
+	la.last = &Sample{at, value}
+}
+
+func (la *LightAccumulator) startDay(day time.Time) {
+	if !day.Equal(la.day) {
+		la.day = day
+		la.total = 0
+	}
+}
+
+// DLI returns the daily light integral in mol/m²/day accumulated so far today, using the
+// factor to convert the light readings into PPFD (µmol/m²/s)
+func (la *LightAccumulator) DLI(factor float64) float64 {
+	return la.total * factor / 1e6
+}
+
+// Location returns the location that days are split in
+func (la *LightAccumulator) Location() *time.Location {
+	return la.loc
+}
+
+// Day returns the day that is currently being accumulated
+func (la *LightAccumulator) Day() time.Time {
+	return la.day
+}
@@ -0,0 +1,105 @@
+package calc
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestHumidity(t *testing.T) {
+	Convey("given air at 25°C and 60% RH", t, func() {
+		Convey("it should calculate the VPD", func() {
+			So(VPD(25, 60), ShouldAlmostEqual, 1.27, 0.01)
+		})
+
+		Convey("it should calculate a higher leaf VPD for a warmer leaf", func() {
+			So(LeafVPD(25, 60, 0), ShouldAlmostEqual, VPD(25, 60))
+			So(LeafVPD(25, 60, 2), ShouldBeGreaterThan, VPD(25, 60))
+		})
+
+		Convey("it should calculate the dew point", func() {
+			dp, ok := DewPoint(25, 60)
+			So(ok, ShouldBeTrue)
+			So(dp, ShouldAlmostEqual, 16.7, 0.1)
+		})
+
+		Convey("it should not give a dew point for dry air", func() {
+			_, ok := DewPoint(25, 0)
+			So(ok, ShouldBeFalse)
+			So(Derive(time.Time{}, 25, 0, -2).DewPoint, ShouldBeNil)
+		})
+
+		Convey("it should calculate the absolute humidity", func() {
+			So(AbsoluteHumidity(25, 60), ShouldAlmostEqual, 13.8, 0.1)
+		})
+	})
+}
+
+func TestDLI(t *testing.T) {
+	start := time.Date(2018, 1, 1, 6, 0, 0, 0, time.UTC)
+
+	Convey("given 12 hours of constant light", t, func() {
+		samples := []Sample{}
+		for h := 0; h <= 12; h++ {
+			samples = append(samples, Sample{start.Add(time.Duration(h) * time.Hour), 500})
+		}
+
+		Convey("it should integrate the PPFD over the day", func() {
+			So(DLI(samples, 1), ShouldAlmostEqual, 21.6, 0.001)
+		})
+
+		Convey("it should accumulate the same value from live readings", func() {
+			la := NewLightAccumulator(time.UTC)
+			for _, s := range samples {
+				la.Add(s.Time, s.Value)
+			}
+			So(la.DLI(1), ShouldAlmostEqual, 21.6, 0.001)
+
+			Convey("and start again the next day with the light since midnight", func() {
+				la.Add(start.Add(24*time.Hour), 500)
+				So(la.Day(), ShouldEqual, start.Add(18*time.Hour))
+				So(la.DLI(1), ShouldAlmostEqual, 10.8, 0.001)
+			})
+		})
+
+		Convey("it should split the samples by day", func() {
+			next := Sample{start.Add(24 * time.Hour), 500}
+			dlis := DLIByDay(append(samples, next), 1, time.UTC)
+			So(len(dlis), ShouldEqual, 2)
+			So(dlis[0].DLI, ShouldAlmostEqual, 21.6+10.8, 0.001)
+			So(dlis[1].DLI, ShouldAlmostEqual, 10.8, 0.001)
+		})
+	})
+
+	Convey("given light readings either side of midnight", t, func() {
+		samples := []Sample{
+			{time.Date(2018, 1, 1, 22, 0, 0, 0, time.UTC), 0},
+			{time.Date(2018, 1, 2, 2, 0, 0, 0, time.UTC), 1000},
+		}
+
+		Convey("it should split the light at midnight", func() {
+			dlis := DLIByDay(samples, 1, time.UTC)
+			So(len(dlis), ShouldEqual, 2)
+			So(dlis[0].Day, ShouldEqual, time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC))
+			So(dlis[0].DLI, ShouldAlmostEqual, 1.8, 0.001)
+			So(dlis[1].Day, ShouldEqual, time.Date(2018, 1, 2, 0, 0, 0, 0, time.UTC))
+			So(dlis[1].DLI, ShouldAlmostEqual, 5.4, 0.001)
+
+			la := NewLightAccumulator(time.UTC)
+			for _, s := range samples {
+				la.Add(s.Time, s.Value)
+			}
+			So(la.Day(), ShouldEqual, dlis[1].Day)
+			So(la.DLI(1), ShouldAlmostEqual, 5.4, 0.001)
+		})
+
+		Convey("it should split at midnight in the given location", func() {
+			loc := time.FixedZone("UTC+2", 2*3600)
+			dlis := DLIByDay(samples, 1, loc)
+			So(len(dlis), ShouldEqual, 1)
+			So(dlis[0].Day, ShouldEqual, time.Date(2018, 1, 2, 0, 0, 0, 0, loc))
+			So(dlis[0].DLI, ShouldAlmostEqual, 7.2, 0.001)
+		})
+	})
+}
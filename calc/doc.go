@@ -0,0 +1,6 @@
+// Package calc provides calculations for environmental metrics that can be derived from the
+// readings reported by Autogrow devices, such as the vapour pressure deficit, dew point,
+// absolute humidity and daily light integral.
+//
+// All temperatures are in °C, relative humidity in percent and pressures in kPa.
+package calc
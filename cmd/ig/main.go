@@ -8,8 +8,13 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/autogrow/go-jelly/ig"
+	"github.com/autogrow/go-jelly/recipe"
+	"github.com/autogrow/go-jelly/schedule"
+	"github.com/autogrow/go-jelly/units"
+	"github.com/autogrow/go-jelly/usage"
 )
 
 type creds struct {
@@ -21,15 +26,41 @@ func main() {
 	var listDevices, listGrowrooms bool
 	var id, gr string
 	var printReadings, fmtJSON bool
+	var tempUnit, ecUnit string
+	var jobsFile, recipesFile, ratingsFile string
+	var showRuns bool
+	var days int
 	flag.BoolVar(&listDevices, "l", false, "list known devices")
 	flag.BoolVar(&listGrowrooms, "g", false, "list growrooms")
 	flag.StringVar(&id, "id", "", "serial number to work with")
 	flag.StringVar(&gr, "growroom", "", "growroom name to work with")
 	flag.BoolVar(&printReadings, "r", false, "print readings")
 	flag.BoolVar(&fmtJSON, "json", false, "format as JSON")
+	flag.StringVar(&tempUnit, "temp", "C", "temperature unit to display (C or F)")
+	flag.StringVar(&ecUnit, "ec", "EC", "nutrient unit to display (EC, CF, ppm500, ppm640 or ppm700)")
+	flag.StringVar(&jobsFile, "schedule", "", "run the jobs in the given file as a daemon")
+	flag.StringVar(&recipesFile, "recipes", "", "apply the crop recipes in the given file as a daemon, or preview the plan for -growroom")
+	flag.BoolVar(&showRuns, "runs", false, "print the scheduled job run log")
+	flag.IntVar(&days, "days", 1, "number of days ahead to list maintenance tasks for, or back to report usage for")
+	flag.StringVar(&ratingsFile, "ratings", "", "equipment ratings to estimate usage with")
+	flag.Usage = help
 	flag.Parse()
 
-	credsFile := os.Getenv("HOME") + "/.intelligrow/creds"
+	sys, err := parseUnits(tempUnit, ecUnit)
+	if err != nil {
+		log.Fatalf("%s", err)
+	}
+
+	dataDir := os.Getenv("HOME") + "/.intelligrow"
+	credsFile := dataDir + "/creds"
+
+	if showRuns {
+		if err := printRuns(dataDir + "/schedule.log"); err != nil {
+			log.Fatalf("%s", err)
+		}
+		return
+	}
+
 	creds, err := readCreds(credsFile)
 	if err != nil {
 		initCreds(credsFile)
@@ -40,10 +71,36 @@ func main() {
 	if err != nil {
 		log.Fatalf("failed to create IG client: %s", err)
 	}
+	cl.SetUnits(sys)
 
 	app := &app{cl}
 
 	switch {
+	case flag.Arg(0) == "maintenance":
+		if err := app.maintenance(flag.Args()[1:], days); err != nil {
+			log.Fatalf("%s", err)
+		}
+
+	case flag.Arg(0) == "usage":
+		if err := app.printUsage(gr, ratingsFile, days, fmtJSON); err != nil {
+			log.Fatalf("%s", err)
+		}
+
+	case recipesFile != "" && gr != "":
+		if err := previewRecipe(recipesFile, gr); err != nil {
+			log.Fatalf("%s", err)
+		}
+
+	case recipesFile != "":
+		if err := app.runRecipes(recipesFile, dataDir); err != nil {
+			log.Fatalf("%s", err)
+		}
+
+	case jobsFile != "":
+		if err := app.runScheduler(jobsFile, dataDir); err != nil {
+			log.Fatalf("%s", err)
+		}
+
 	case listGrowrooms:
 		fmt.Println("Growrooms:")
 		for _, name := range cl.ListGrowrooms() {
@@ -73,6 +130,30 @@ func main() {
 	}
 }
 
+func help() {
+	fmt.Fprintf(os.Stderr, "Usage:\n")
+	fmt.Fprintf(os.Stderr, "  ig [flags]\n")
+	fmt.Fprintf(os.Stderr, "  ig [-days N] maintenance                list maintenance tasks due in the next N days\n")
+	fmt.Fprintf(os.Stderr, "  ig maintenance done <serial> <task>      mark a maintenance task as done\n")
+	fmt.Fprintf(os.Stderr, "  ig [-days N] [-ratings file] usage       report equipment usage per room for the last N days\n\n")
+	fmt.Fprintf(os.Stderr, "Flags:\n")
+	flag.PrintDefaults()
+}
+
+func parseUnits(temp, ec string) (units.System, error) {
+	t, err := units.ParseTemperatureUnit(temp)
+	if err != nil {
+		return units.Metric, err
+	}
+
+	c, err := units.ParseConductivityUnit(ec, 0)
+	if err != nil {
+		return units.Metric, err
+	}
+
+	return units.System{Temperature: t, Conductivity: c}, nil
+}
+
 func initCreds(credsFile string) {
 	data, err := json.Marshal(creds{})
 	if err != nil {
@@ -126,33 +207,65 @@ func (a *app) printGrowroomMetrics(gr string) error {
 		return fmt.Errorf("Growroom %s not found", gr)
 	}
 
+	sys := a.cl.Units()
+
 	ics, _ := room.IntelliClimates()
 	if len(ics) > 0 {
-		fmt.Printf("%20s: %0.2f °C\n", "Air", room.Climate.AirTemp)
+		fmt.Printf("%20s: %0.2f %s\n", "Air", units.Metric.TemperatureTo(room.Climate.AirTemp, sys), sys.Temperature.Symbol())
 		fmt.Printf("%20s: %0.2f %%H\n", "RH", room.Climate.RH)
 		fmt.Printf("%20s: %0.2f kPa\n", "VPD", room.Climate.VPD)
 		fmt.Printf("%20s: %0.2f ppm\n", "CO2", room.Climate.CO2)
+		if dp, ok := room.DewPoint(); !ok {
+			fmt.Printf("%20s: n/a\n", "Dew Point")
+		} else {
+			fmt.Printf("%20s: %0.2f %s\n", "Dew Point", units.Metric.TemperatureTo(dp, sys), sys.Temperature.Symbol())
+		}
+		fmt.Printf("%20s: %0.2f g/m³\n", "Abs. Humidity", room.AbsoluteHumidity())
+		if vpd, ok := room.SecondaryVPD(); ok {
+			fmt.Printf("%20s: %0.2f kPa\n", "VPD (sensor 2)", vpd)
+		}
+		if room.OutsideTempSensor != 0 {
+			fmt.Printf("%20s: %0.2f %s\n", "Outside", units.Metric.TemperatureTo(room.OutsideTempSensor, sys), sys.Temperature.Symbol())
+		}
+
+		for _, sensor := range room.Climate.Sensors {
+			if !sensor.Healthy {
+				fmt.Printf("%20s: %s\n", sensor.Label, sensor.Problem)
+				continue
+			}
+
+			fmt.Printf("%20s: %0.2f %s %0.2f %%H %0.0f ppm\n", sensor.Label,
+				units.Metric.TemperatureTo(sensor.AirTemp, sys), sys.Temperature.Symbol(), sensor.RH, sensor.CO2)
+		}
+
+		if spread := room.Climate.Spread; spread != nil {
+			fmt.Printf("%20s: %0.2f %s %0.2f %%H\n", "Sensor Spread",
+				units.ConvertTemperatureDifference(spread.AirTemp, units.Celsius, sys.Temperature),
+				sys.Temperature.Symbol(), spread.RH)
+		}
 	}
 
 	ids, _ := room.IntelliDoses()
 	if len(ids) > 0 {
-		fmt.Printf("%20s: %0.2f mS/cm²\n", "Nutrient", room.Rootzone.EC)
+		fmt.Printf("%20s: %0.2f %s\n", "Nutrient", units.Metric.ConductivityTo(room.Rootzone.EC, sys), sys.Conductivity.Symbol())
 		fmt.Printf("%20s: %0.2f pH\n", "Acidity", room.Rootzone.PH)
-		fmt.Printf("%20s: %0.2f °C\n", "Water", room.Rootzone.Temp)
+		fmt.Printf("%20s: %0.2f %s\n", "Water", units.Metric.TemperatureTo(room.Rootzone.Temp, sys), sys.Temperature.Symbol())
 	}
 
 	return nil
 }
 
 func (a *app) printDeviceMetrics(id string) error {
+	sys := a.cl.Units()
+
 	if doser, err := a.cl.IntelliDose(id); err == nil {
 		if err = doser.GetMetrics(); err != nil {
 			return err
 		}
 
-		fmt.Printf("%20s: %0.2f mS/cm²\n", "Nutrient", doser.Metrics.Ec)
+		fmt.Printf("%20s: %0.2f %s\n", "Nutrient", doser.ECIn(sys.Conductivity), sys.Conductivity.Symbol())
 		fmt.Printf("%20s: %0.2f pH\n", "Acidity", doser.Metrics.PH)
-		fmt.Printf("%20s: %0.2f °C\n", "Water", doser.Metrics.NutTemp)
+		fmt.Printf("%20s: %0.2f %s\n", "Water", doser.NutTempIn(sys.Temperature), sys.Temperature.Symbol())
 		return nil
 	}
 
@@ -161,8 +274,8 @@ func (a *app) printDeviceMetrics(id string) error {
 			return err
 		}
 
-		fmt.Println("IntelliClimate: %d")
-		fmt.Printf("%20s: %0.2f °C\n", "Air", clim.Metrics.AirTemp)
+		fmt.Printf("IntelliClimate: %s\n", clim.ID)
+		fmt.Printf("%20s: %0.2f %s\n", "Air", clim.AirTempIn(sys.Temperature), sys.Temperature.Symbol())
 		fmt.Printf("%20s: %0.2f %%H\n", "RH", clim.Metrics.Rh)
 		fmt.Printf("%20s: %0.2f kPa\n", "VPD", clim.Metrics.Vpd)
 		fmt.Printf("%20s: %0.2f ppm\n", "CO2", clim.Metrics.Co2)
@@ -193,9 +306,218 @@ func (a *app) dumpJSON(id string) error {
 }
 
 func (a *app) printDevices() error {
-	fmt.Printf("%-12s %-18s %-10s %s\n", "Type", "ID", "Name", "Growroom")
+	fmt.Printf("%-12s %-18s %-10s %-10s %s\n", "Type", "ID", "Name", "Health", "Growroom")
 	for _, d := range a.cl.Devices() {
-		fmt.Printf("%-12s %-18s %-10s %s\n", d.Type, d.ID, d.DeviceName, d.Growroom)
+		fmt.Printf("%-12s %-18s %-10s %-10s %s\n", d.Type, d.ID, d.DeviceName, d.HealthState(), d.Growroom)
+	}
+	return nil
+}
+
+func (a *app) runScheduler(jobsFile, dataDir string) error {
+	jobs, err := schedule.LoadJobs(jobsFile)
+	if err != nil {
+		return err
+	}
+
+	s, err := schedule.NewScheduler(jobs, schedule.NewClientExecutor(a.cl))
+	if err != nil {
+		return err
+	}
+
+	s.StateFile = dataDir + "/schedule.state"
+	s.LogFile = dataDir + "/schedule.log"
+	s.OnRun = func(run schedule.Run) {
+		log.Println(formatRun(run))
+	}
+
+	log.Printf("running %d jobs from %s", len(jobs), jobsFile)
+	s.Run(30*time.Second, make(chan bool), func(err error) {
+		log.Printf("ERROR: %s", err)
+	})
+
+	return nil
+}
+
+func printRuns(logFile string) error {
+	runs, err := schedule.ReadLog(logFile)
+	if err != nil {
+		return err
+	}
+
+	for _, run := range runs {
+		fmt.Println(formatRun(run))
+	}
+
+	return nil
+}
+
+func formatRun(run schedule.Run) string {
+	status := "ok"
+	switch {
+	case run.Skipped:
+		status = "skipped"
+	case run.Error != "":
+		status = "failed: " + run.Error
+	}
+
+	line := fmt.Sprintf("%s %-20s %-18s %s", run.Scheduled.Format(time.RFC3339), run.Job, run.Device, status)
+	if run.Missed > 0 {
+		line += fmt.Sprintf(" (%d missed)", run.Missed)
 	}
+
+	return line
+}
+
+func previewRecipe(recipesFile, gr string) error {
+	cfg, err := recipe.Load(recipesFile)
+	if err != nil {
+		return err
+	}
+
+	days, err := recipe.NewRunner(cfg, nil).Preview(gr)
+	if err != nil {
+		return err
+	}
+
+	for _, day := range days {
+		fmt.Printf("%s %4d %-15s %s\n", day.Date, day.Day, day.Stage, day.Targets)
+	}
+
+	return nil
+}
+
+func (a *app) runRecipes(recipesFile, dataDir string) error {
+	cfg, err := recipe.Load(recipesFile)
+	if err != nil {
+		return err
+	}
+
+	r := recipe.NewRunner(cfg, recipe.NewClientApplier(a.cl))
+	r.LogFile = dataDir + "/recipes.log"
+	r.OnApply = func(app recipe.Application) {
+		if app.Error != "" {
+			log.Printf("%s day %d (%s): failed to apply %s: %s", app.Room, app.Day, app.Stage, app.Targets, app.Error)
+			return
+		}
+		log.Printf("%s day %d (%s): applied %s", app.Room, app.Day, app.Stage, app.Targets)
+	}
+
+	log.Printf("applying recipes to %d growrooms from %s", len(cfg.Plans), recipesFile)
+	r.Run(15*time.Minute, make(chan bool), func(err error) {
+		log.Printf("ERROR: %s", err)
+	})
+
+	return nil
+}
+
+func (a *app) maintenance(args []string, days int) error {
+	if len(args) > 0 && args[0] == "done" {
+		if len(args) != 3 {
+			return fmt.Errorf("usage: ig maintenance done <serial> <task>")
+		}
+		return a.maintenanceDone(args[1], ig.MaintenanceTask(args[2]))
+	}
+
+	if len(args) > 0 {
+		return fmt.Errorf("unknown maintenance command %s", args[0])
+	}
+
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	items, err := a.cl.UpcomingMaintenance(today.AddDate(0, 0, days))
+	if err != nil {
+		log.Printf("ERROR: %s", err)
+	}
+
+	if len(items) == 0 {
+		fmt.Println("No maintenance due")
+		return nil
+	}
+
+	fmt.Printf("%-8s %-10s %-18s %-10s %-20s %s\n", "Status", "Due", "ID", "Name", "Task", "Growroom")
+	for _, mi := range items {
+		fmt.Printf("%-8s %-10s %-18s %-10s %-20s %s\n", mi.Status(now), mi.Due.Format("2006-01-02"),
+			mi.Device, mi.DeviceName, string(mi.Task), mi.Growroom)
+	}
+
+	return nil
+}
+
+func (a *app) maintenanceDone(id string, task ig.MaintenanceTask) error {
+	doser, err := a.cl.IntelliDose(id)
+	if err != nil {
+		return err
+	}
+
+	if err := doser.MarkMaintenanceDone(task, time.Now()); err != nil {
+		return err
+	}
+
+	fmt.Printf("Marked %s as done on %s\n", task, doser.GetID())
+	return nil
+}
+
+func (a *app) printUsage(gr, ratingsFile string, days int, asJSON bool) error {
+	ratings := usage.Ratings{}
+	if ratingsFile != "" {
+		var err error
+		if ratings, err = usage.LoadRatings(ratingsFile); err != nil {
+			return err
+		}
+	}
+
+	rooms := a.cl.Growrooms()
+	if gr != "" {
+		room, ok := a.cl.Growroom(gr)
+		if !ok {
+			return fmt.Errorf("Growroom %s not found", gr)
+		}
+		rooms = []*ig.Growroom{room}
+	}
+
+	// history every 5 minutes
+	to := time.Now()
+	from := to.AddDate(0, 0, -days)
+	points := days * 288
+
+	usages := []usage.Usage{}
+	for _, room := range rooms {
+		ids, _ := room.IntelliDoses()
+		for _, id := range ids {
+			if err := id.GetHistory(to, from, points); err != nil {
+				log.Printf("ERROR: %s: %s", id.GetID(), err)
+			}
+		}
+
+		ics, _ := room.IntelliClimates()
+		for _, ic := range ics {
+			if err := ic.GetHistory(to, from, points); err != nil {
+				log.Printf("ERROR: %s: %s", ic.GetID(), err)
+			}
+		}
+
+		usages = append(usages, usage.GrowroomUsage(room, ratings, usage.DefaultMaxGap)...)
+	}
+
+	if asJSON {
+		dumpJSON(usages)
+		return nil
+	}
+
+	for _, u := range usages {
+		c := u.Consumption
+		fmt.Printf("%s %s: %0.1f kWh, %0.2f kg CO2, %0.0f L water, %0.1f L nutrient\n", u.Day.Format("2006-01-02"),
+			u.Room, c.Energy, c.CO2, c.Water, c.Nutrient)
+
+		for _, rt := range u.Runtimes {
+			fmt.Printf("    %-18s %-25s %8s %4d cycles %5.1f%%\n", rt.Device, rt.Function,
+				rt.OnTime.Round(time.Minute), rt.Cycles, rt.DutyCycle()*100)
+		}
+
+		for _, unrated := range u.Unrated {
+			fmt.Printf("    no rating for %s\n", unrated)
+		}
+	}
+
 	return nil
 }
@@ -0,0 +1,121 @@
+package device
+
+import (
+	"context"
+	"time"
+
+	"github.com/autogrow/go-jelly/health"
+	"github.com/autogrow/go-jelly/ig"
+	"github.com/autogrow/go-jelly/ig/datastructs"
+	"github.com/autogrow/go-jelly/units"
+)
+
+// Transport is how a device is reached
+type Transport string
+
+const (
+	// Cloud - the device is reached through the IntelliGrow API
+	Cloud Transport = "cloud"
+	// Local - the device is reached over the NATS bus of the IntelliLink
+	Local Transport = "local"
+)
+
+// Update is sent each time a device reports new readings
+type Update struct {
+	Serial    string    `json:"serial"`
+	Transport Transport `json:"transport"`
+	Time      time.Time `json:"time"`
+	// Readings are in metric (°C and EC in mS/cm) keyed by metric
+	Readings map[string]float64 `json:"readings"`
+}
+
+// Device is a device reached over either transport
+type Device interface {
+	Serial() string
+	Transport() Transport
+	// HealthState is whether the device is reporting fresh readings over the transport
+	HealthState() health.State
+	LastUpdated() time.Time
+	// Readings returns the last readings in metric (°C and EC in mS/cm) keyed by metric
+	Readings() map[string]float64
+	// Refresh fetches the latest state of the device, which is a no-op for transports that are
+	// pushed updates
+	Refresh() error
+	// Updates returns a channel that is sent each update of the device until the context is done,
+	// when it is closed.  Only the latest update is kept if the receiver falls behind.
+	Updates(ctx context.Context) <-chan Update
+}
+
+// Doser is an IntelliDose reached over either transport, with the same commands as an
+// ig.IntelliDose
+type Doser interface {
+	Device
+
+	// Metrics returns the last readings in metric (°C and EC in mS/cm)
+	Metrics() datastructs.MetricsIDose
+	Config() datastructs.ConfigIDose
+	Status() datastructs.StatusIDose
+
+	ForceNutrientDose() error
+	ForcePHDose() error
+	ForceIrrigation() error
+	ForceIrrigationStation(n int) error
+	SetPHTarget(target float64) error
+	SetNutrientTargetIn(target float64, unit units.ConductivityUnit) error
+	SetIrrigationMode(mode ig.IrrigationMode) error
+	SetStationName(n int, name string) error
+	SetStationIntervals(n int, day, night time.Duration) error
+	SetStationInterval(n int, every time.Duration) error
+	SetStationDuration(n int, d time.Duration) error
+	EnableStation(n int) error
+	DisableStation(n int) error
+	RestoreConfig(data []byte) error
+	// Transaction sends the commands given to the doser passed to the runner as one command.
+	// Only the commands given to that doser are part of the transaction.
+	Transaction(runner func(Doser) error) error
+}
+
+// Climate is an IntelliClimate reached over either transport
+type Climate interface {
+	Device
+
+	// Metrics returns the last readings with temperatures in °C
+	Metrics() datastructs.MetricsIClimate
+	Config() datastructs.ConfigIClimate
+	Status() datastructs.StatusIClimate
+}
+
+func doseReadings(m datastructs.MetricsIDose) map[string]float64 {
+	return map[string]float64{
+		"ec":       m.Ec,
+		"ph":       m.PH,
+		"nut_temp": m.NutTemp,
+	}
+}
+
+func climateReadings(m datastructs.MetricsIClimate) map[string]float64 {
+	return map[string]float64{
+		"air_temp": m.AirTemp,
+		"rh":       m.Rh,
+		"co2":      m.Co2,
+		"light":    m.Light,
+		"vpd":      m.Vpd,
+	}
+}
+
+// sendLatest sends the update, replacing one the receiver hasn't taken yet
+func sendLatest(ch chan Update, u Update) {
+	select {
+	case <-ch:
+	default:
+	}
+	ch <- u
+}
+
+var (
+	_ Doser   = &CloudDoser{}
+	_ Doser   = &LocalDoser{}
+	_ Doser   = &FailoverDoser{}
+	_ Climate = &CloudClimate{}
+	_ Climate = &LocalClimate{}
+)
@@ -0,0 +1,197 @@
+package device
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/autogrow/go-jelly/health"
+	"github.com/autogrow/go-jelly/ig"
+	"github.com/autogrow/go-jelly/sfc"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// fakeDoser records the commands sent to it
+type fakeDoser struct {
+	Doser
+	transport Transport
+
+	mu       sync.Mutex
+	state    health.State
+	err      error
+	commands []string
+	updates  chan Update
+}
+
+func newFakeDoser(t Transport) *fakeDoser {
+	return &fakeDoser{transport: t, state: health.Online, updates: make(chan Update, 10)}
+}
+
+func (d *fakeDoser) Transport() Transport { return d.transport }
+func (d *fakeDoser) HealthState() health.State {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.state
+}
+
+func (d *fakeDoser) setState(s health.State) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.state = s
+}
+
+func (d *fakeDoser) ForceIrrigation() error {
+	d.commands = append(d.commands, "irrigate")
+	return d.err
+}
+
+func (d *fakeDoser) SetPHTarget(target float64) error {
+	d.commands = append(d.commands, "ph")
+	return d.err
+}
+
+func (d *fakeDoser) Transaction(runner func(Doser) error) error {
+	d.commands = append(d.commands, "tx")
+	if err := runner(d); err != nil {
+		return err
+	}
+	return d.err
+}
+
+func (d *fakeDoser) Updates(ctx context.Context) <-chan Update {
+	ch := make(chan Update)
+	go func() {
+		defer close(ch)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case u := <-d.updates:
+				ch <- u
+			}
+		}
+	}()
+	return ch
+}
+
+func TestFailoverDoser(t *testing.T) {
+	Convey("given a doser reachable locally and through the cloud", t, func() {
+		local := newFakeDoser(Local)
+		cloud := newFakeDoser(Cloud)
+		f := NewFailoverDoser(local, cloud)
+
+		Convey("the local doser should be preferred while it is online", func() {
+			So(f.Transport(), ShouldEqual, Local)
+			So(f.ForceIrrigation(), ShouldBeNil)
+			So(local.commands, ShouldResemble, []string{"irrigate"})
+			So(cloud.commands, ShouldBeEmpty)
+		})
+
+		Convey("the cloud doser should be used while the local one is offline", func() {
+			local.setState(health.Offline)
+			So(f.Transport(), ShouldEqual, Cloud)
+			So(f.ForceIrrigation(), ShouldBeNil)
+			So(local.commands, ShouldBeEmpty)
+			So(cloud.commands, ShouldResemble, []string{"irrigate"})
+		})
+
+		Convey("commands that can't reach the device locally should be sent through the cloud", func() {
+			local.err = sfc.CommandError{Serial: "ASLID17081149", Err: sfc.ErrNotConnected}
+			So(f.ForceIrrigation(), ShouldBeNil)
+			So(cloud.commands, ShouldResemble, []string{"irrigate"})
+		})
+
+		Convey("commands that weren't acknowledged in time should not be sent again", func() {
+			local.err = sfc.CommandError{Serial: "ASLID17081149", Err: errors.New("nats: timeout")}
+			So(f.ForceIrrigation(), ShouldResemble, local.err)
+			So(cloud.commands, ShouldBeEmpty)
+		})
+
+		Convey("commands refused by the device should not be sent again", func() {
+			local.err = sfc.CommandError{Serial: "ASLID17081149", Err: errors.New("busy"), Replied: true}
+			So(f.ForceIrrigation(), ShouldResemble, local.err)
+			So(cloud.commands, ShouldBeEmpty)
+		})
+
+		Convey("the commands in a transaction should go to the same doser", func() {
+			err := f.Transaction(func(d Doser) error {
+				return d.SetPHTarget(6.2)
+			})
+
+			So(err, ShouldBeNil)
+			So(local.commands, ShouldResemble, []string{"tx", "ph"})
+			So(cloud.commands, ShouldBeEmpty)
+
+			Convey("and be run again through the cloud if the device can't be reached", func() {
+				local.err = sfc.ErrNotAttached
+				err := f.Transaction(func(d Doser) error {
+					return d.SetPHTarget(6.2)
+				})
+
+				So(err, ShouldBeNil)
+				So(cloud.commands, ShouldResemble, []string{"tx", "ph"})
+			})
+		})
+
+		Convey("cloud updates should only be passed on while the local doser is offline", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			updates := f.Updates(ctx)
+
+			local.updates <- Update{Transport: Local}
+			So((<-updates).Transport, ShouldEqual, Local)
+
+			cloud.updates <- Update{Transport: Cloud}
+			local.setState(health.Offline)
+			cloud.updates <- Update{Transport: Cloud}
+			So((<-updates).Transport, ShouldEqual, Cloud)
+		})
+	})
+}
+
+func TestLocalDoser(t *testing.T) {
+	Convey("given an IntelliDose on the local bus configured in ppm and fahrenheit", t, func() {
+		id := sfc.NewIntelliDose("ASLID17081149")
+		d := NewLocalDoser(id, nil, health.DefaultPolicy)
+
+		So(d.HealthState(), ShouldEqual, health.Unknown)
+
+		err := id.Update([]byte(`{"state": {"reported": {
+			"connected": true,
+			"config": {"units": {"temperature": "fahrenheit", "ec": "ppm", "tds_conversation_standart": 700}},
+			"metrics": {"ec": 1050, "pH": 6.1, "nut_temp": 68}
+		}}}`))
+		So(err, ShouldBeNil)
+
+		Convey("the readings should be converted to metric", func() {
+			r := d.Readings()
+			So(r["ec"], ShouldAlmostEqual, 1.5)
+			So(r["nut_temp"], ShouldAlmostEqual, 20)
+			So(r["ph"], ShouldEqual, 6.1)
+		})
+
+		Convey("it should be online once it has reported", func() {
+			So(d.HealthState(), ShouldEqual, health.Online)
+			So(d.Transport(), ShouldEqual, Local)
+		})
+
+		Convey("commands should fail as unreachable until it is subscribed", func() {
+			So(unreachable(d.SetIrrigationMode(ig.IrrigationDayNight)), ShouldBeTrue)
+		})
+
+		Convey("updates should be sent until the context is done", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			updates := d.Updates(ctx)
+			id.Update([]byte(`{"state": {"reported": {"metrics": {"pH": 6.3}}}}`))
+
+			u := <-updates
+			So(u.Readings["ph"], ShouldEqual, 6.3)
+			So(u.Time.IsZero(), ShouldBeFalse)
+
+			cancel()
+			for range updates {
+			}
+		})
+	})
+}
@@ -0,0 +1,19 @@
+// Package device lets code be written once for devices reached through the IntelliGrow API
+// (the ig package) or over the NATS bus of the IntelliLink (the sfc package).
+//
+// Adapters wrap the devices of each package in the Doser and Climate interfaces, with readings
+// in metric whichever way they were reached:
+//
+//     cloud := device.NewCloudDoser(igDoser, time.Minute)
+//     local := device.NewLocalDoser(sfcDoser, conn, health.DefaultPolicy)
+//
+// A failover doser uses the local bus while the device is online there, and the API otherwise.
+// Commands that can't reach the device over the local bus are sent through the API:
+//
+//     doser := device.NewFailoverDoser(local, cloud)
+//     for u := range doser.Updates(ctx) {
+//       fmt.Println(u.Transport, u.Readings["ec"])
+//     }
+//
+//     doser.ForceIrrigation()
+package device
@@ -0,0 +1,220 @@
+package device
+
+import (
+	"context"
+	"time"
+
+	"github.com/autogrow/go-jelly/health"
+	"github.com/autogrow/go-jelly/ig"
+	"github.com/autogrow/go-jelly/ig/datastructs"
+	"github.com/autogrow/go-jelly/sfc"
+	"github.com/autogrow/go-jelly/units"
+)
+
+// FailoverDoser is an IntelliDose reached over the local bus while it is online there, and
+// through the IntelliGrow API otherwise.  Commands that can't reach the device over the local
+// bus are sent through the API.
+type FailoverDoser struct {
+	local Doser
+	cloud Doser
+}
+
+// NewFailoverDoser returns a Doser that prefers the local doser over the cloud one
+func NewFailoverDoser(local, cloud Doser) *FailoverDoser {
+	return &FailoverDoser{local: local, cloud: cloud}
+}
+
+// Active returns the doser that is used for readings and commands
+func (f *FailoverDoser) Active() Doser {
+	if f.local.HealthState() == health.Online {
+		return f.local
+	}
+	return f.cloud
+}
+
+// unreachable returns true if the command wasn't sent because the device isn't subscribed to a
+// connection or the connection is down.  A command that wasn't acknowledged in time may still
+// have been carried out, so sending it again could dose twice.
+func unreachable(err error) bool {
+	if err == sfc.ErrNotAttached {
+		return true
+	}
+
+	cerr, ok := err.(sfc.CommandError)
+	return ok && cerr.Err == sfc.ErrNotConnected
+}
+
+// do sends the command to the active doser, and through the API if the device couldn't be
+// reached over the local bus
+func (f *FailoverDoser) do(cmd func(Doser) error) error {
+	d := f.Active()
+	err := cmd(d)
+	if d == f.local && unreachable(err) {
+		return cmd(f.cloud)
+	}
+	return err
+}
+
+// Serial returns the serial number of the device
+func (f *FailoverDoser) Serial() string {
+	return f.local.Serial()
+}
+
+// Transport returns the transport of the active doser
+func (f *FailoverDoser) Transport() Transport {
+	return f.Active().Transport()
+}
+
+// HealthState returns the health of the active doser
+func (f *FailoverDoser) HealthState() health.State {
+	return f.Active().HealthState()
+}
+
+// LastUpdated returns when the device last reported over either transport
+func (f *FailoverDoser) LastUpdated() time.Time {
+	local, cloud := f.local.LastUpdated(), f.cloud.LastUpdated()
+	if local.After(cloud) {
+		return local
+	}
+	return cloud
+}
+
+// Readings returns the last readings from the active doser
+func (f *FailoverDoser) Readings() map[string]float64 {
+	return f.Active().Readings()
+}
+
+// Refresh refreshes both dosers, it only fails if neither can be refreshed
+func (f *FailoverDoser) Refresh() error {
+	lerr := f.local.Refresh()
+	cerr := f.cloud.Refresh()
+	if lerr != nil && cerr != nil {
+		return cerr
+	}
+	return nil
+}
+
+// Updates returns a channel that is sent the updates from the local doser, and from the cloud
+// doser while the local one isn't online, until the context is done
+func (f *FailoverDoser) Updates(ctx context.Context) <-chan Update {
+	ch := make(chan Update, 1)
+	local := f.local.Updates(ctx)
+	cloud := f.cloud.Updates(ctx)
+
+	go func() {
+		defer close(ch)
+
+		for local != nil || cloud != nil {
+			select {
+			case u, ok := <-local:
+				if !ok {
+					local = nil
+					continue
+				}
+				sendLatest(ch, u)
+			case u, ok := <-cloud:
+				if !ok {
+					cloud = nil
+					continue
+				}
+				if f.local.HealthState() != health.Online {
+					sendLatest(ch, u)
+				}
+			}
+		}
+	}()
+
+	return ch
+}
+
+// Metrics returns the last readings from the active doser
+func (f *FailoverDoser) Metrics() datastructs.MetricsIDose {
+	return f.Active().Metrics()
+}
+
+// Config returns the config from the active doser
+func (f *FailoverDoser) Config() datastructs.ConfigIDose {
+	return f.Active().Config()
+}
+
+// Status returns the status from the active doser
+func (f *FailoverDoser) Status() datastructs.StatusIDose {
+	return f.Active().Status()
+}
+
+// ForceNutrientDose will force a nutrient dose on the controller
+func (f *FailoverDoser) ForceNutrientDose() error {
+	return f.do(func(d Doser) error { return d.ForceNutrientDose() })
+}
+
+// ForcePHDose will force a pH dose on the controller
+func (f *FailoverDoser) ForcePHDose() error {
+	return f.do(func(d Doser) error { return d.ForcePHDose() })
+}
+
+// ForceIrrigation will force an irrigation on the controller
+func (f *FailoverDoser) ForceIrrigation() error {
+	return f.do(func(d Doser) error { return d.ForceIrrigation() })
+}
+
+// ForceIrrigationStation will force an irrigation on the given station
+func (f *FailoverDoser) ForceIrrigationStation(n int) error {
+	return f.do(func(d Doser) error { return d.ForceIrrigationStation(n) })
+}
+
+// SetPHTarget will set the target pH the system should dose to
+func (f *FailoverDoser) SetPHTarget(target float64) error {
+	return f.do(func(d Doser) error { return d.SetPHTarget(target) })
+}
+
+// SetNutrientTargetIn will set the target EC the system should dose to in the given unit
+func (f *FailoverDoser) SetNutrientTargetIn(target float64, unit units.ConductivityUnit) error {
+	return f.do(func(d Doser) error { return d.SetNutrientTargetIn(target, unit) })
+}
+
+// SetIrrigationMode will set how the device decides when to irrigate
+func (f *FailoverDoser) SetIrrigationMode(mode ig.IrrigationMode) error {
+	return f.do(func(d Doser) error { return d.SetIrrigationMode(mode) })
+}
+
+// SetStationName will set the name of the given irrigation station
+func (f *FailoverDoser) SetStationName(n int, name string) error {
+	return f.do(func(d Doser) error { return d.SetStationName(n, name) })
+}
+
+// SetStationIntervals will set the day and night intervals of the given station
+func (f *FailoverDoser) SetStationIntervals(n int, day, night time.Duration) error {
+	return f.do(func(d Doser) error { return d.SetStationIntervals(n, day, night) })
+}
+
+// SetStationInterval will set the interval of the given station in every mode
+func (f *FailoverDoser) SetStationInterval(n int, every time.Duration) error {
+	return f.do(func(d Doser) error { return d.SetStationInterval(n, every) })
+}
+
+// SetStationDuration will set how long each irrigation of the given station runs for
+func (f *FailoverDoser) SetStationDuration(n int, dur time.Duration) error {
+	return f.do(func(d Doser) error { return d.SetStationDuration(n, dur) })
+}
+
+// EnableStation will enable the given irrigation station
+func (f *FailoverDoser) EnableStation(n int) error {
+	return f.do(func(d Doser) error { return d.EnableStation(n) })
+}
+
+// DisableStation will disable the given irrigation station
+func (f *FailoverDoser) DisableStation(n int) error {
+	return f.do(func(d Doser) error { return d.DisableStation(n) })
+}
+
+// RestoreConfig will replace the config on the controller with a saved config
+func (f *FailoverDoser) RestoreConfig(data []byte) error {
+	return f.do(func(d Doser) error { return d.RestoreConfig(data) })
+}
+
+// Transaction runs the commands given to the doser passed to the runner on the active doser as
+// one command.  If the device can't be reached over the local bus the runner is run again with
+// the cloud doser.
+func (f *FailoverDoser) Transaction(runner func(Doser) error) error {
+	return f.do(func(d Doser) error { return d.Transaction(runner) })
+}
@@ -0,0 +1,295 @@
+package device
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/autogrow/go-jelly/health"
+	"github.com/autogrow/go-jelly/ig"
+	"github.com/autogrow/go-jelly/ig/datastructs"
+	"github.com/autogrow/go-jelly/units"
+)
+
+// DefaultPollInterval is how often devices are polled for updates through the IntelliGrow API
+const DefaultPollInterval = time.Minute
+
+// cloud is the part of a device reached through the IntelliGrow API that is the same for each
+// type of device.  The ig devices aren't safe to use from more than one goroutine, so all use of
+// them is under the lock.
+type cloud struct {
+	interval time.Duration
+	dev      *ig.Device
+	fetch    func() error
+	readings func() map[string]float64
+
+	// mu is shared with the copy of the device given to the runner of a transaction
+	mu      *sync.Mutex
+	lastErr error
+	// inTx is set on the copy of the device given to the runner of a transaction, which runs
+	// while the lock is held
+	inTx bool
+}
+
+// lock takes the lock unless called from the runner of a transaction, returning the function
+// that releases it
+func (c *cloud) lock() func() {
+	if c.inTx {
+		return func() {}
+	}
+
+	c.mu.Lock()
+	return c.mu.Unlock
+}
+
+// Serial returns the serial number of the device
+func (c *cloud) Serial() string {
+	return c.dev.GetID()
+}
+
+// Transport returns Cloud
+func (c *cloud) Transport() Transport {
+	return Cloud
+}
+
+// HealthState is offline if the API couldn't be reached when the device was last refreshed, or
+// the state of the device by the health policy of the client
+func (c *cloud) HealthState() health.State {
+	defer c.lock()()
+
+	if c.lastErr != nil {
+		return health.Offline
+	}
+	return c.dev.HealthState()
+}
+
+// LastUpdated returns when the device last reported to the API
+func (c *cloud) LastUpdated() time.Time {
+	defer c.lock()()
+	return c.dev.LastContact()
+}
+
+// Readings returns the readings last fetched from the API keyed by metric
+func (c *cloud) Readings() map[string]float64 {
+	defer c.lock()()
+	return c.readings()
+}
+
+// Refresh fetches the readings, config and state of the device from the API
+func (c *cloud) Refresh() error {
+	defer c.lock()()
+
+	c.lastErr = c.fetch()
+	return c.lastErr
+}
+
+// Updates polls the API at the interval of the device and sends an update each time the device
+// has reported since the last poll
+func (c *cloud) Updates(ctx context.Context) <-chan Update {
+	ch := make(chan Update, 1)
+
+	go func() {
+		defer close(ch)
+
+		t := time.NewTicker(c.interval)
+		defer t.Stop()
+
+		var last time.Time
+		for {
+			if err := c.Refresh(); err == nil {
+				if updated := c.LastUpdated(); updated.After(last) {
+					last = updated
+					sendLatest(ch, Update{c.Serial(), Cloud, updated, c.Readings()})
+				}
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-t.C:
+			}
+		}
+	}()
+
+	return ch
+}
+
+// do runs the command under the lock, unless it is called from the runner of a transaction
+func (c *cloud) do(cmd func() error) error {
+	defer c.lock()()
+	return cmd()
+}
+
+func pollInterval(interval time.Duration) time.Duration {
+	if interval <= 0 {
+		return DefaultPollInterval
+	}
+	return interval
+}
+
+// CloudDoser is an IntelliDose reached through the IntelliGrow API
+type CloudDoser struct {
+	cloud
+	id *ig.IntelliDose
+}
+
+// NewCloudDoser returns a Doser for the IntelliDose, which is polled at the given interval for
+// updates, DefaultPollInterval if zero
+func NewCloudDoser(id *ig.IntelliDose, interval time.Duration) *CloudDoser {
+	d := &CloudDoser{id: id}
+	d.cloud = cloud{
+		mu:       new(sync.Mutex),
+		interval: pollInterval(interval),
+		dev:      id.Device,
+		fetch:    id.GetAll,
+		readings: func() map[string]float64 { return doseReadings(*id.Metrics) },
+	}
+	return d
+}
+
+// Metrics returns the last readings in metric (°C and EC in mS/cm)
+func (d *CloudDoser) Metrics() datastructs.MetricsIDose {
+	defer d.lock()()
+	return *d.id.Metrics
+}
+
+// Config returns the config last fetched from the API
+func (d *CloudDoser) Config() datastructs.ConfigIDose {
+	defer d.lock()()
+	return *d.id.Config
+}
+
+// Status returns the status last fetched from the API
+func (d *CloudDoser) Status() datastructs.StatusIDose {
+	defer d.lock()()
+
+	status := *d.id.Status
+	status.Status = append([]datastructs.StatusStatusIDose{}, status.Status...)
+	return status
+}
+
+// ForceNutrientDose will force a nutrient dose on the controller
+func (d *CloudDoser) ForceNutrientDose() error {
+	return d.do(d.id.ForceNutrientDose)
+}
+
+// ForcePHDose will force a pH dose on the controller
+func (d *CloudDoser) ForcePHDose() error {
+	return d.do(d.id.ForcePHDose)
+}
+
+// ForceIrrigation will force an irrigation on the controller
+func (d *CloudDoser) ForceIrrigation() error {
+	return d.do(d.id.ForceIrrigation)
+}
+
+// ForceIrrigationStation will force an irrigation on the given station
+func (d *CloudDoser) ForceIrrigationStation(n int) error {
+	return d.do(func() error { return d.id.ForceIrrigationStation(n) })
+}
+
+// SetPHTarget will set the target pH the system should dose to
+func (d *CloudDoser) SetPHTarget(target float64) error {
+	return d.do(func() error { return d.id.SetPHTarget(target) })
+}
+
+// SetNutrientTargetIn will set the target EC the system should dose to in the given unit
+func (d *CloudDoser) SetNutrientTargetIn(target float64, unit units.ConductivityUnit) error {
+	return d.do(func() error { return d.id.SetNutrientTargetIn(target, unit) })
+}
+
+// SetIrrigationMode will set how the device decides when to irrigate
+func (d *CloudDoser) SetIrrigationMode(mode ig.IrrigationMode) error {
+	return d.do(func() error { return d.id.SetIrrigationMode(mode) })
+}
+
+// SetStationName will set the name of the given irrigation station
+func (d *CloudDoser) SetStationName(n int, name string) error {
+	return d.do(func() error { return d.id.SetStationName(n, name) })
+}
+
+// SetStationIntervals will set the day and night intervals of the given station
+func (d *CloudDoser) SetStationIntervals(n int, day, night time.Duration) error {
+	return d.do(func() error { return d.id.SetStationIntervals(n, day, night) })
+}
+
+// SetStationInterval will set the interval of the given station in every mode
+func (d *CloudDoser) SetStationInterval(n int, every time.Duration) error {
+	return d.do(func() error { return d.id.SetStationInterval(n, every) })
+}
+
+// SetStationDuration will set how long each irrigation of the given station runs for
+func (d *CloudDoser) SetStationDuration(n int, dur time.Duration) error {
+	return d.do(func() error { return d.id.SetStationDuration(n, dur) })
+}
+
+// EnableStation will enable the given irrigation station
+func (d *CloudDoser) EnableStation(n int) error {
+	return d.do(func() error { return d.id.EnableStation(n) })
+}
+
+// DisableStation will disable the given irrigation station
+func (d *CloudDoser) DisableStation(n int) error {
+	return d.do(func() error { return d.id.DisableStation(n) })
+}
+
+// RestoreConfig will replace the config on the controller with a saved config
+func (d *CloudDoser) RestoreConfig(data []byte) error {
+	return d.do(func() error { return d.id.RestoreConfig(data) })
+}
+
+// Transaction runs the commands given to the doser passed to the runner in one request to the
+// API.  Commands given to d from other goroutines wait until the transaction is done.
+func (d *CloudDoser) Transaction(runner func(Doser) error) error {
+	if d.inTx {
+		return runner(d)
+	}
+
+	defer d.lock()()
+
+	tx := *d
+	tx.inTx = true
+	return d.id.Transaction(func() error { return runner(&tx) })
+}
+
+// CloudClimate is an IntelliClimate reached through the IntelliGrow API
+type CloudClimate struct {
+	cloud
+	ic *ig.IntelliClimate
+}
+
+// NewCloudClimate returns a Climate for the IntelliClimate, which is polled at the given
+// interval for updates, DefaultPollInterval if zero
+func NewCloudClimate(ic *ig.IntelliClimate, interval time.Duration) *CloudClimate {
+	c := &CloudClimate{ic: ic}
+	c.cloud = cloud{
+		mu:       new(sync.Mutex),
+		interval: pollInterval(interval),
+		dev:      ic.Device,
+		fetch:    ic.GetAll,
+		readings: func() map[string]float64 { return climateReadings(*ic.Metrics) },
+	}
+	return c
+}
+
+// Metrics returns the last readings with temperatures in °C
+func (c *CloudClimate) Metrics() datastructs.MetricsIClimate {
+	defer c.lock()()
+	return *c.ic.Metrics
+}
+
+// Config returns the config last fetched from the API
+func (c *CloudClimate) Config() datastructs.ConfigIClimate {
+	defer c.lock()()
+	return *c.ic.Config
+}
+
+// Status returns the status last fetched from the API
+func (c *CloudClimate) Status() datastructs.StatusIClimate {
+	defer c.lock()()
+
+	status := *c.ic.Status
+	status.SetPoints = append([]datastructs.SetPointIClimate{}, status.SetPoints...)
+	status.Status = append([]datastructs.StatusStatusIClimate{}, status.Status...)
+	return status
+}
@@ -0,0 +1,309 @@
+package device
+
+import (
+	"context"
+	"time"
+
+	"github.com/autogrow/go-jelly/health"
+	"github.com/autogrow/go-jelly/ig"
+	"github.com/autogrow/go-jelly/ig/datastructs"
+	"github.com/autogrow/go-jelly/sfc"
+	"github.com/autogrow/go-jelly/units"
+)
+
+// local is the part of a device reached over the NATS bus that is the same for each type of
+// device
+type local struct {
+	conn   *sfc.Connection
+	policy health.Policy
+	mt     health.MetricType
+	serial string
+	// received and connected return when the device last reported, and if it said it was
+	// connected
+	received  func() time.Time
+	connected func() bool
+	readings  func() map[string]float64
+}
+
+// Serial returns the serial number of the device
+func (l *local) Serial() string {
+	return l.serial
+}
+
+// Transport returns Local
+func (l *local) Transport() Transport {
+	return Local
+}
+
+// HealthState is offline while the connection to the bus is down, otherwise the state of the
+// device by the health policy
+func (l *local) HealthState() health.State {
+	connected := l.connected() && (l.conn == nil || l.conn.Connected())
+	return l.policy.Evaluate(l.mt, l.received(), connected, time.Now())
+}
+
+// LastUpdated returns when the device last published to the bus
+func (l *local) LastUpdated() time.Time {
+	return l.received()
+}
+
+// Readings returns the last readings published to the bus, converted to metric, keyed by metric
+func (l *local) Readings() map[string]float64 {
+	return l.readings()
+}
+
+// Refresh does nothing as updates are pushed over the bus
+func (l *local) Refresh() error {
+	return nil
+}
+
+// doseCommands are the commands shared by an sfc.IntelliDose and a transaction on it
+type doseCommands interface {
+	ForceNutrientDose() error
+	ForcePHDose() error
+	ForceIrrigation() error
+	ForceIrrigationStation(n int) error
+	SetPHTarget(target float64) error
+	SetNutrientTargetIn(target float64, unit units.ConductivityUnit) error
+	SetIrrigationMode(mode ig.IrrigationMode) error
+	SetStationName(n int, name string) error
+	SetStationIntervals(n int, day, night time.Duration) error
+	SetStationInterval(n int, every time.Duration) error
+	SetStationDuration(n int, d time.Duration) error
+	EnableStation(n int) error
+	DisableStation(n int) error
+	RestoreConfig(data []byte) error
+}
+
+// LocalDoser is an IntelliDose reached over the NATS bus of the IntelliLink
+type LocalDoser struct {
+	local
+	id *sfc.IntelliDose
+	// cmd is the IntelliDose, or the transaction on it in the copy given to the runner of a
+	// transaction
+	cmd  doseCommands
+	inTx bool
+}
+
+// NewLocalDoser returns a Doser for the IntelliDose, which must be subscribed to the connection
+// to be updated and sent commands.  Its health is evaluated with the given policy.
+func NewLocalDoser(id *sfc.IntelliDose, conn *sfc.Connection, policy health.Policy) *LocalDoser {
+	d := &LocalDoser{id: id, cmd: id}
+	d.local = local{
+		conn:      conn,
+		policy:    policy,
+		mt:        health.Rootzone,
+		serial:    id.Serial(),
+		received:  id.LastUpdated,
+		connected: id.Connected,
+		readings:  func() map[string]float64 { return doseReadings(d.Metrics()) },
+	}
+	return d
+}
+
+// Metrics returns the last readings converted from the units the device is configured with into
+// metric (°C and EC in mS/cm)
+func (d *LocalDoser) Metrics() datastructs.MetricsIDose {
+	snap := d.id.Snapshot()
+	m := snap.Reported.Metrics
+	sys := snap.Reported.Config.Units.System()
+
+	m.Ec = sys.ConductivityTo(m.Ec, units.Metric)
+	m.NutTemp = sys.TemperatureTo(m.NutTemp, units.Metric)
+	return m
+}
+
+// Config returns the config last published to the bus
+func (d *LocalDoser) Config() datastructs.ConfigIDose {
+	return d.id.Config()
+}
+
+// Status returns the status last published to the bus
+func (d *LocalDoser) Status() datastructs.StatusIDose {
+	return d.id.Settings()
+}
+
+// Updates returns a channel that is sent each update published by the device until the context
+// is done
+func (d *LocalDoser) Updates(ctx context.Context) <-chan Update {
+	ch := make(chan Update, 1)
+	snaps := d.id.Updates()
+
+	go func() {
+		defer close(ch)
+		defer d.id.Unsubscribe(snaps)
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case snap := <-snaps:
+				sendLatest(ch, Update{d.serial, Local, snap.Received, d.readings()})
+			}
+		}
+	}()
+
+	return ch
+}
+
+// ForceNutrientDose will force a nutrient dose on the controller
+func (d *LocalDoser) ForceNutrientDose() error {
+	return d.cmd.ForceNutrientDose()
+}
+
+// ForcePHDose will force a pH dose on the controller
+func (d *LocalDoser) ForcePHDose() error {
+	return d.cmd.ForcePHDose()
+}
+
+// ForceIrrigation will force an irrigation on the controller
+func (d *LocalDoser) ForceIrrigation() error {
+	return d.cmd.ForceIrrigation()
+}
+
+// ForceIrrigationStation will force an irrigation on the given station
+func (d *LocalDoser) ForceIrrigationStation(n int) error {
+	return d.cmd.ForceIrrigationStation(n)
+}
+
+// SetPHTarget will set the target pH the system should dose to
+func (d *LocalDoser) SetPHTarget(target float64) error {
+	return d.cmd.SetPHTarget(target)
+}
+
+// SetNutrientTargetIn will set the target EC the system should dose to in the given unit
+func (d *LocalDoser) SetNutrientTargetIn(target float64, unit units.ConductivityUnit) error {
+	return d.cmd.SetNutrientTargetIn(target, unit)
+}
+
+// SetIrrigationMode will set how the device decides when to irrigate
+func (d *LocalDoser) SetIrrigationMode(mode ig.IrrigationMode) error {
+	return d.cmd.SetIrrigationMode(mode)
+}
+
+// SetStationName will set the name of the given irrigation station
+func (d *LocalDoser) SetStationName(n int, name string) error {
+	return d.cmd.SetStationName(n, name)
+}
+
+// SetStationIntervals will set the day and night intervals of the given station
+func (d *LocalDoser) SetStationIntervals(n int, day, night time.Duration) error {
+	return d.cmd.SetStationIntervals(n, day, night)
+}
+
+// SetStationInterval will set the interval of the given station in every mode
+func (d *LocalDoser) SetStationInterval(n int, every time.Duration) error {
+	return d.cmd.SetStationInterval(n, every)
+}
+
+// SetStationDuration will set how long each irrigation of the given station runs for
+func (d *LocalDoser) SetStationDuration(n int, dur time.Duration) error {
+	return d.cmd.SetStationDuration(n, dur)
+}
+
+// EnableStation will enable the given irrigation station
+func (d *LocalDoser) EnableStation(n int) error {
+	return d.cmd.EnableStation(n)
+}
+
+// DisableStation will disable the given irrigation station
+func (d *LocalDoser) DisableStation(n int) error {
+	return d.cmd.DisableStation(n)
+}
+
+// RestoreConfig will replace the config on the controller with a saved config
+func (d *LocalDoser) RestoreConfig(data []byte) error {
+	return d.cmd.RestoreConfig(data)
+}
+
+// Transaction sends the commands given to the doser passed to the runner to the device as one
+// command
+func (d *LocalDoser) Transaction(runner func(Doser) error) error {
+	if d.inTx {
+		return runner(d)
+	}
+
+	return d.id.Transaction(func(tx *sfc.IntelliDoseTx) error {
+		view := *d
+		view.cmd = tx
+		view.inTx = true
+		return runner(&view)
+	})
+}
+
+// LocalClimate is an IntelliClimate reached over the NATS bus of the IntelliLink
+type LocalClimate struct {
+	local
+	ic *sfc.IntelliClimate
+}
+
+// NewLocalClimate returns a Climate for the IntelliClimate, which must be subscribed to the
+// connection to be updated.  Its health is evaluated with the given policy.
+func NewLocalClimate(ic *sfc.IntelliClimate, conn *sfc.Connection, policy health.Policy) *LocalClimate {
+	c := &LocalClimate{ic: ic}
+	c.local = local{
+		conn:      conn,
+		policy:    policy,
+		mt:        health.Climate,
+		serial:    ic.Serial(),
+		received:  ic.LastUpdated,
+		connected: ic.Connected,
+		readings:  func() map[string]float64 { return climateReadings(c.Metrics()) },
+	}
+	return c
+}
+
+// climateTempFields returns pointers to the readings reported in the device temperature unit
+func climateTempFields(m *datastructs.MetricsIClimate) []*float64 {
+	return []*float64{&m.AirTemp, &m.OutsideTemp, &m.EnviroAirTemp1, &m.EnviroAirTemp2}
+}
+
+// Metrics returns the last readings with temperatures converted from the unit the device is
+// configured with into °C
+func (c *LocalClimate) Metrics() datastructs.MetricsIClimate {
+	snap := c.ic.Snapshot()
+	m := snap.Reported.Metrics
+
+	t, err := units.ParseTemperatureUnit(snap.Reported.Config.Units.Temperature)
+	if err != nil {
+		return m
+	}
+
+	for _, v := range climateTempFields(&m) {
+		*v = units.ConvertTemperature(*v, t, units.Celsius)
+	}
+	return m
+}
+
+// Config returns the config last published to the bus
+func (c *LocalClimate) Config() datastructs.ConfigIClimate {
+	return c.ic.Config()
+}
+
+// Status returns the status last published to the bus
+func (c *LocalClimate) Status() datastructs.StatusIClimate {
+	return c.ic.Settings()
+}
+
+// Updates returns a channel that is sent each update published by the device until the context
+// is done
+func (c *LocalClimate) Updates(ctx context.Context) <-chan Update {
+	ch := make(chan Update, 1)
+	snaps := c.ic.Updates()
+
+	go func() {
+		defer close(ch)
+		defer c.ic.Unsubscribe(snaps)
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case snap := <-snaps:
+				sendLatest(ch, Update{c.serial, Local, snap.Received, c.readings()})
+			}
+		}
+	}()
+
+	return ch
+}
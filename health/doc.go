@@ -0,0 +1,15 @@
+// Package health provides a model of device health that is shared by the IntelliGrow client
+// and the local NATS client.
+//
+// A device is considered online while its readings are fresher than the stale threshold for
+// the type of metrics it reports, stale until they pass the offline threshold and offline
+// after that, or immediately if the device reports that it is disconnected:
+//
+//     policy := health.DefaultPolicy
+//     policy.Set(health.Rootzone, health.Thresholds{Stale: time.Minute, Offline: 5 * time.Minute})
+//
+//     state := policy.Evaluate(health.Rootzone, lastUpdated, true, time.Now())
+//
+// A Tracker can be used to keep simple uptime statistics by observing the state of a device
+// each time it is polled or pushes an update.
+package health
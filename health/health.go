@@ -0,0 +1,181 @@
+package health
+
+import (
+	"sync"
+	"time"
+)
+
+// State is the health state of a device
+type State string
+
+const (
+	// Unknown - the device has never been heard from
+	Unknown State = "unknown"
+	// Online - the device is connected and reporting fresh readings
+	Online State = "online"
+	// Stale - the device has not reported for longer than the stale threshold
+	Stale State = "stale"
+	// Offline - the device is disconnected or has not reported for longer than the offline threshold
+	Offline State = "offline"
+)
+
+// MetricType is the type of metrics reported by a device, each can have its own thresholds
+type MetricType string
+
+const (
+	// Climate - metrics reported by an IntelliClimate
+	Climate MetricType = "climate"
+	// Rootzone - metrics reported by an IntelliDose
+	Rootzone MetricType = "rootzone"
+)
+
+// Thresholds are the ages of the last readings after which a device is considered stale and
+// then offline
+type Thresholds struct {
+	Stale   time.Duration `json:"stale"`
+	Offline time.Duration `json:"offline"`
+}
+
+// DefaultThresholds are used for any metric type that has no thresholds set
+var DefaultThresholds = Thresholds{Stale: 2 * time.Minute, Offline: 15 * time.Minute}
+
+// Policy holds the thresholds used for each metric type
+type Policy struct {
+	Default Thresholds                `json:"default"`
+	Metrics map[MetricType]Thresholds `json:"metrics"`
+}
+
+// DefaultPolicy uses the default thresholds for all metric types
+var DefaultPolicy = Policy{Default: DefaultThresholds}
+
+// Set the thresholds to use for the given metric type
+func (p *Policy) Set(mt MetricType, th Thresholds) {
+	if p.Metrics == nil {
+		p.Metrics = map[MetricType]Thresholds{}
+	}
+	p.Metrics[mt] = th
+}
+
+// Thresholds returns the thresholds to use for the given metric type
+func (p Policy) Thresholds(mt MetricType) Thresholds {
+	if th, ok := p.Metrics[mt]; ok {
+		return th
+	}
+
+	if p.Default == (Thresholds{}) {
+		return DefaultThresholds
+	}
+
+	return p.Default
+}
+
+// Evaluate returns the state of a device reporting the given metric type that last reported
+// at the given time
+func (p Policy) Evaluate(mt MetricType, last time.Time, connected bool, now time.Time) State {
+	if last.IsZero() {
+		return Unknown
+	}
+
+	if !connected {
+		return Offline
+	}
+
+	th := p.Thresholds(mt)
+	age := now.Sub(last)
+
+	switch {
+	case age > th.Offline:
+		return Offline
+	case age > th.Stale:
+		return Stale
+	default:
+		return Online
+	}
+}
+
+// Report describes the health of a device at a point in time
+type Report struct {
+	State            State         `json:"state"`
+	LastContact      time.Time     `json:"last_contact"`
+	SinceLastContact time.Duration `json:"since_last_contact"`
+	Stats            Stats         `json:"stats"`
+}
+
+// Stats are the uptime statistics kept by a Tracker
+type Stats struct {
+	Since       time.Time     `json:"since"`
+	StateSince  time.Time     `json:"state_since"`
+	Online      time.Duration `json:"online"`
+	Stale       time.Duration `json:"stale"`
+	Offline     time.Duration `json:"offline"`
+	Transitions int           `json:"transitions"`
+}
+
+// Uptime returns the fraction of the tracked time that the device was online
+func (s Stats) Uptime() float64 {
+	total := s.Online + s.Stale + s.Offline
+	if total == 0 {
+		return 0
+	}
+	return float64(s.Online) / float64(total)
+}
+
+// Tracker keeps uptime statistics from observations of the state of a device.  The time
+// between two observations is attributed to the state seen at the first one.
+type Tracker struct {
+	lock  *sync.Mutex
+	state State
+	last  time.Time
+	stats Stats
+}
+
+// NewTracker returns a new tracker
+func NewTracker() *Tracker {
+	return &Tracker{lock: new(sync.Mutex), state: Unknown}
+}
+
+// Observe records the state of the device at the given time
+func (t *Tracker) Observe(now time.Time, s State) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if t.last.IsZero() {
+		t.stats.Since = now
+		t.stats.StateSince = now
+	} else if now.After(t.last) {
+		t.add(t.state, now.Sub(t.last))
+	}
+
+	if s != t.state && t.state != Unknown {
+		t.stats.Transitions++
+		t.stats.StateSince = now
+	}
+
+	t.state = s
+	t.last = now
+}
+
+func (t *Tracker) add(s State, d time.Duration) {
+	switch s {
+	case Online:
+		t.stats.Online += d
+	case Stale:
+		t.stats.Stale += d
+	case Offline:
+		t.stats.Offline += d
+	}
+}
+
+// State returns the last observed state
+func (t *Tracker) State() State {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return t.state
+}
+
+// Stats returns the uptime statistics up to the last observation
+func (t *Tracker) Stats() Stats {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return t.stats
+}
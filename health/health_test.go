@@ -0,0 +1,66 @@
+package health
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestEvaluate(t *testing.T) {
+	now := time.Now()
+
+	Convey("given the default policy", t, func() {
+		p := DefaultPolicy
+
+		Convey("it should be online with fresh readings", func() {
+			So(p.Evaluate(Climate, now.Add(-time.Minute), true, now), ShouldEqual, Online)
+		})
+
+		Convey("it should be stale with old readings", func() {
+			So(p.Evaluate(Climate, now.Add(-5*time.Minute), true, now), ShouldEqual, Stale)
+		})
+
+		Convey("it should be offline with very old readings", func() {
+			So(p.Evaluate(Climate, now.Add(-time.Hour), true, now), ShouldEqual, Offline)
+		})
+
+		Convey("it should be offline when disconnected", func() {
+			So(p.Evaluate(Climate, now, false, now), ShouldEqual, Offline)
+		})
+
+		Convey("it should be unknown with no readings", func() {
+			So(p.Evaluate(Climate, time.Time{}, true, now), ShouldEqual, Unknown)
+		})
+
+		Convey("when thresholds are set for a metric type", func() {
+			p.Set(Rootzone, Thresholds{Stale: 10 * time.Second, Offline: time.Minute})
+
+			Convey("it should use them for that type only", func() {
+				So(p.Evaluate(Rootzone, now.Add(-30*time.Second), true, now), ShouldEqual, Stale)
+				So(p.Evaluate(Climate, now.Add(-30*time.Second), true, now), ShouldEqual, Online)
+			})
+		})
+	})
+}
+
+func TestTracker(t *testing.T) {
+	Convey("given a tracker", t, func() {
+		tr := NewTracker()
+		start := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+
+		Convey("it should attribute time to the observed states", func() {
+			tr.Observe(start, Online)
+			tr.Observe(start.Add(3*time.Hour), Offline)
+			tr.Observe(start.Add(4*time.Hour), Online)
+
+			stats := tr.Stats()
+			So(stats.Online, ShouldEqual, 3*time.Hour)
+			So(stats.Offline, ShouldEqual, time.Hour)
+			So(stats.Transitions, ShouldEqual, 2)
+			So(stats.Uptime(), ShouldAlmostEqual, 0.75)
+			So(stats.StateSince, ShouldResemble, start.Add(4*time.Hour))
+			So(tr.State(), ShouldEqual, Online)
+		})
+	})
+}
@@ -0,0 +1,87 @@
+package ig
+
+import (
+	"fmt"
+	"io/ioutil"
+)
+
+// DoserActions are the IntelliDose methods that can be called by name with Do
+var DoserActions = map[string]bool{
+	"ForceNutrientDose": true,
+	"ForcePHDose":       true,
+	"ForceIrrigation":   true,
+	"ForceStation":      true,
+	"SetNutrientTarget": true,
+	"SetPHTarget":       true,
+	"RestoreConfig":     true,
+}
+
+// ClimateActions are the IntelliClimate methods that can be called by name with Do
+var ClimateActions = map[string]bool{
+	"SetTempTarget":    true,
+	"SetCO2Target":     true,
+	"SetRHTarget":      true,
+	"EnableCO2Dosing":  true,
+	"DisableCO2Dosing": true,
+	"RestoreConfig":    true,
+}
+
+// Action is a call of a device method by name, such as from a scheduled job or a rule
+type Action struct {
+	// Do is the name of the device method to call, such as ForceIrrigation
+	Do string
+	// Value is the argument to methods that need one such as SetPHTarget
+	Value float64
+	// Station is the irrigation station for ForceStation
+	Station string
+	// Config is the path to the saved config for RestoreConfig
+	Config string
+}
+
+// Do calls the IntelliDose method named by the action
+func (id *IntelliDose) Do(a Action) error {
+	switch a.Do {
+	case "ForceNutrientDose":
+		return id.ForceNutrientDose()
+	case "ForcePHDose":
+		return id.ForcePHDose()
+	case "ForceIrrigation":
+		return id.ForceIrrigation()
+	case "ForceStation":
+		return id.ForceStation(a.Station)
+	case "SetNutrientTarget":
+		return id.SetNutrientTarget(a.Value)
+	case "SetPHTarget":
+		return id.SetPHTarget(a.Value)
+	case "RestoreConfig":
+		data, err := ioutil.ReadFile(a.Config)
+		if err != nil {
+			return err
+		}
+		return id.RestoreConfig(data)
+	}
+	return fmt.Errorf("%s is not supported by an IntelliDose", a.Do)
+}
+
+// Do calls the IntelliClimate method named by the action
+func (ic *IntelliClimate) Do(a Action) error {
+	switch a.Do {
+	case "SetTempTarget":
+		return ic.SetTempTarget(a.Value)
+	case "SetCO2Target":
+		return ic.SetCO2Target(a.Value)
+	case "SetRHTarget":
+		return ic.SetRHTarget(a.Value)
+	case "EnableCO2Dosing":
+		return ic.EnableCO2Dosing()
+	case "DisableCO2Dosing":
+		return ic.DisableCO2Dosing()
+	case "RestoreConfig":
+		data, err := ioutil.ReadFile(a.Config)
+		if err != nil {
+			return err
+		}
+		return ic.RestoreConfig(data)
+	}
+	return fmt.Errorf("%s is not supported by an IntelliClimate", a.Do)
+}
@@ -104,14 +104,12 @@ func (c *Client) extendAuth() error {
 	return nil
 }
 
-func (c *Client) autoAuthExtender() {
-	c.tokenRefresherQuit = make(chan bool)
-	defer func() { c.tokenRefresherQuit = nil }()
+func (c *Client) autoAuthExtender(quit chan bool) {
 	timer := time.NewTimer(c.getRefreshTime())
 
 	for {
 		select {
-		case <-c.tokenRefresherQuit:
+		case <-quit:
 			return
 
 		case <-timer.C:
@@ -124,10 +122,12 @@ func (c *Client) autoAuthExtender() {
 	}
 }
 
+// getRefreshTime returns how long until the token should be refreshed, a minute before it
+// expires.  The expiry is given in seconds.
 func (c *Client) getRefreshTime() time.Duration {
 	rTime := c.auth.ExpiresIn
 	if rTime > 60 {
 		rTime -= 60
 	}
-	return time.Duration(rTime)
+	return time.Duration(rTime * float64(time.Second))
 }
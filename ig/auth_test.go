@@ -0,0 +1,25 @@
+package ig
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestRefreshTime(t *testing.T) {
+	Convey("it should refresh the token a minute before it expires", t, func() {
+		c := &Client{auth: authResponse{ExpiresIn: 3600}}
+		So(c.getRefreshTime(), ShouldEqual, 59*time.Minute)
+
+		c.auth.ExpiresIn = 30
+		So(c.getRefreshTime(), ShouldEqual, 30*time.Second)
+	})
+
+	Convey("it should be safe to close a client more than once", t, func() {
+		c := &Client{lock: new(sync.RWMutex), tokenRefresherQuit: make(chan bool)}
+		So(c.Close(), ShouldBeNil)
+		So(c.Close(), ShouldBeNil)
+	})
+}
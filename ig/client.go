@@ -12,6 +12,9 @@ import (
 	"strings"
 	"sync"
 	"time"
+
+	"github.com/autogrow/go-jelly/health"
+	"github.com/autogrow/go-jelly/units"
 )
 
 const (
@@ -39,17 +42,27 @@ type Client struct {
 	devices            *Devices
 	tokenRefresherQuit chan bool
 	url                url.URL
+	units              units.System
+	health             health.Policy
 }
 
 // NewClient creates a new client with the given username and password.  It will
 // return an error if the authentication fails
 func NewClient(user, pass string) (*Client, error) {
+	return NewClientWithHTTPClient(user, pass, &http.Client{Timeout: time.Second * 30})
+}
+
+// NewClientWithHTTPClient creates a new client that makes its requests with the given HTTP
+// client, such as one that talks to a simulator instead of the IntelliGrow API
+func NewClientWithHTTPClient(user, pass string, hc *http.Client) (*Client, error) {
 	c := &Client{
-		Client:    &http.Client{Timeout: time.Second * 30},
+		Client:    hc,
 		lock:      new(sync.RWMutex),
 		username:  user,
 		password:  pass,
 		growrooms: make(map[string]*Growroom),
+		units:     units.Metric,
+		health:    health.DefaultPolicy,
 	}
 
 	c.url.Scheme = "https"
@@ -63,19 +76,53 @@ func NewClient(user, pass string) (*Client, error) {
 		return c, err
 	}
 
-	go c.autoAuthExtender()
+	c.tokenRefresherQuit = make(chan bool)
+	go c.autoAuthExtender(c.tokenRefresherQuit)
 
 	return c, nil
 }
 
 // Close the client (read: stop trying to refresh the auth token every hour)
 func (c *Client) Close() error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
 	if c.tokenRefresherQuit != nil {
 		close(c.tokenRefresherQuit)
+		c.tokenRefresherQuit = nil
 	}
 	return nil
 }
 
+// SetUnits sets the unit system that values should be displayed in, and that setpoints are
+// given in when no unit is specified
+func (c *Client) SetUnits(sys units.System) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.units = sys
+}
+
+// Units returns the unit system chosen for display, by default this is metric
+func (c *Client) Units() units.System {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.units
+}
+
+// SetHealthPolicy sets the thresholds used to decide if devices are online, stale or offline
+func (c *Client) SetHealthPolicy(p health.Policy) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.health = p
+}
+
+// HealthPolicy returns the thresholds used to decide if devices are online, stale or offline
+func (c *Client) HealthPolicy() health.Policy {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.health
+}
+
 // AutoUpdater - automatically updates the device connected to the client
 func (c *Client) AutoUpdater(pollInterval int, quit chan bool, updateInterval chan int) {
 	ticker := time.NewTicker(time.Duration(pollInterval) * time.Second)
@@ -316,6 +363,17 @@ func (c *Client) Growroom(name string) (*Growroom, bool) {
 	return growroom, exists
 }
 
+// GrowroomOf returns the name of the growroom the device with the given serial is in, or an
+// empty string if the device is not known
+func (c *Client) GrowroomOf(serial string) string {
+	for _, d := range c.Devices() {
+		if d.ID == serial {
+			return d.Growroom
+		}
+	}
+	return ""
+}
+
 // GetGrowroom is deprecated in favour of Growroom
 func (c *Client) GetGrowroom(name string) (*Growroom, bool) {
 	return c.Growroom(name)
@@ -12,14 +12,18 @@ func TestIGClient(t *testing.T) {
 
 		username := os.Getenv("IG_USERNAME")
 		password := os.Getenv("IG_PASSWORD")
+		if username == "" || password == "" {
+			SkipSo("IG_USERNAME and IG_PASSWORD are needed to test against the API")
+			return
+		}
 
 		c, err := NewClient(username, password)
 
 		Convey("new client shouldn't be empty", func() {
 			So(err, ShouldBeNil)
-			So(c.token, ShouldNotBeNil)
-			So(c.refreshTime, ShouldNotEqual, 0)
-			So(c.refreshToken, ShouldNotBeNil)
+			So(c.getToken(), ShouldNotBeEmpty)
+			So(c.getRefreshTime(), ShouldNotEqual, 0)
+			So(c.auth.RefreshToken, ShouldNotBeEmpty)
 
 			Convey("get devices", func() {
 				err := c.GetDevices()
@@ -34,9 +38,8 @@ func TestIGClient(t *testing.T) {
 						genGet, readErr := c.GetGrowroomReading("1", grAirTemp)
 						So(genGet, ShouldNotBeEmpty)
 						So(readErr, ShouldBeNil)
-						growroom, grErr := c.GetGrowroom("1")
-						So(growroom, ShouldNotBeNil)
-						So(grErr, ShouldBeNil)
+						growroom, ok := c.GetGrowroom("1")
+						So(ok, ShouldBeTrue)
 						valid, specGet := growroom.AirTemp()
 						So(valid, ShouldBeTrue)
 						So(specGet, ShouldNotBeEmpty)
@@ -48,16 +51,15 @@ func TestIGClient(t *testing.T) {
 						genGet, readErr := c.GetGrowroomReading("1", grEC)
 						So(genGet, ShouldNotBeEmpty)
 						So(readErr, ShouldBeNil)
-						growroom, grErr := c.GetGrowroom("1")
-						So(growroom, ShouldNotBeNil)
-						So(grErr, ShouldBeNil)
+						growroom, ok := c.GetGrowroom("1")
+						So(ok, ShouldBeTrue)
 						valid, specGet := growroom.EC()
 						So(valid, ShouldBeTrue)
 						So(specGet, ShouldNotBeEmpty)
 						So(genGet, ShouldEqual, specGet)
 					})
 					Convey("test update doser setting", func() {
-						for _, id := range c.MyDevices.Dosers() {
+						for _, id := range c.devices.Dosers() {
 							err = id.GetConfigState()
 							So(err, ShouldBeNil)
 
@@ -66,11 +68,11 @@ func TestIGClient(t *testing.T) {
 							newDT := dt + 10
 							id.Status.General.NutrientDoseTime = newDT
 
-							err = id.UpdateState()
+							err = id.SaveConfigState()
 							So(err, ShouldBeNil)
 
 							dt = id.Status.General.NutrientDoseTime
-							So(int(dt), ShouldEqual, newDT)
+							So(dt, ShouldEqual, newDT)
 						}
 					})
 				})
@@ -381,6 +381,9 @@ type ClimateMetricsHistory struct {
 	Vpd     float64 `json:"vpd"`
 	CO2     float64 `json:"co2"`
 	Light   float64 `json:"light"`
+
+	EnviroAirTemp2 float64 `json:"enviro_air_temp_2"`
+	EnviroRH2      float64 `json:"enviro_rh_2"`
 }
 
 // ClimateHistoryPoint - defines a single history point reported for a IntelliClimate
@@ -1,5 +1,7 @@
 package datastructs
 
+import "github.com/autogrow/go-jelly/units"
+
 // IDose
 type iDoseShadow struct {
 	State StateIDose `json:"state"`
@@ -32,7 +34,9 @@ type ConfigIDose struct {
 	Reminders  RemindersIDose  `json:"reminder"`
 }
 
-// MetricsIDose represents the Metrics data structure from an IntelliDose packet
+// MetricsIDose represents the Metrics data structure from an IntelliDose packet.  EC and CF are
+// in hundredths when they come from the IntelliGrow API, but in the configured units when they
+// come from the device over the local network.
 type MetricsIDose struct {
 	Ec      float64 `json:"ec"`
 	NutTemp float64 `json:"nut_temp"`
@@ -78,6 +82,7 @@ type IrrigationIntervalIDose struct {
 	Day   int `json:"day"`
 	Night int `json:"night"`
 	Every int `json:"every"`
+	Days  int `json:"days,omitempty"`
 }
 
 // NutrientIDose represents the Nutrient data structure from an IntelliDose packet
@@ -133,10 +138,20 @@ type UnitsIDose struct {
 	TdsConversationStandart int    `json:"tds_conversation_standart"`
 }
 
+// System returns the unit system the device is configured to use, falling back to metric if the
+// units are not recognised
+func (u UnitsIDose) System() units.System {
+	sys, err := units.Parse(u.Temperature, u.Ec, u.TdsConversationStandart)
+	if err != nil {
+		return units.Metric
+	}
+	return sys
+}
+
 // TimesIDose represents the Times data structure from an IntelliDose packet
 type TimesIDose struct {
-	DayStart int `json:"day_start"`
-	DayEnd   int `json:"day_end"`
+	DayStart TimeOfDay `json:"day_start"`
+	DayEnd   TimeOfDay `json:"day_end"`
 }
 
 // FunctionsIDose represents the Functions data structure from an IntelliDose packet
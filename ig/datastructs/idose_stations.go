@@ -0,0 +1,96 @@
+package datastructs
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+const (
+	// MaxIrrigationStations - the most irrigation stations an IntelliDose can have
+	MaxIrrigationStations = 4
+
+	// StationFunctionPrefix - the function of irrigation station n in the status is this
+	// followed by n
+	StationFunctionPrefix = "Irrigation Station "
+)
+
+// IrrigationStationCount returns the number of irrigation stations configured on the device
+func (c *ConfigIDose) IrrigationStationCount() int {
+	n := int(c.Functions.IrrigationStations)
+	if n > MaxIrrigationStations {
+		return MaxIrrigationStations
+	}
+	return n
+}
+
+// StationFunction returns the function of the given irrigation station in the status
+func StationFunction(n int) string {
+	return StationFunctionPrefix + strconv.Itoa(n)
+}
+
+// StationStatus returns the status entry of the given irrigation station, which holds whether it
+// is enabled and forced on
+func StationStatus(status *StatusIDose, n int) (*StatusStatusIDose, error) {
+	for num := range status.Status {
+		if status.Status[num].Function == StationFunction(n) {
+			return &status.Status[num], nil
+		}
+	}
+	return nil, fmt.Errorf("no status for irrigation station %d", n)
+}
+
+// IrrigationStationFields holds pointers to the fields of an irrigation station, which are
+// spread across the config and status of the device
+type IrrigationStationFields struct {
+	Name     *string
+	Interval *IrrigationIntervalIDose
+	// Duration is in seconds
+	Duration *int
+}
+
+// IrrigationStation returns the fields of the given irrigation station (1-4), which must be one
+// of the stations configured on the device
+func IrrigationStation(cfg *ConfigIDose, status *StatusIDose, n int) (IrrigationStationFields, error) {
+	if n < 1 || n > cfg.IrrigationStationCount() {
+		return IrrigationStationFields{}, fmt.Errorf("no irrigation station %d, the device has %d stations", n, cfg.IrrigationStationCount())
+	}
+
+	fn := &cfg.Functions
+	gen := &status.General
+
+	switch n {
+	case 1:
+		return IrrigationStationFields{&fn.IrrigationStation1, &gen.IrrigationInterval1, &gen.IrrigationDuration1}, nil
+	case 2:
+		return IrrigationStationFields{&fn.IrrigationStation2, &gen.IrrigationInterval2, &gen.IrrigationDuration2}, nil
+	case 3:
+		return IrrigationStationFields{&fn.IrrigationStation3, &gen.IrrigationInterval3, &gen.IrrigationDuration3}, nil
+	default:
+		return IrrigationStationFields{&fn.IrrigationStation4, &gen.IrrigationInterval4, &gen.IrrigationDuration4}, nil
+	}
+}
+
+// ValidateIrrigationInterval checks the time between irrigations of a station, which the device
+// keeps in whole minutes
+func ValidateIrrigationInterval(d time.Duration) error {
+	switch {
+	case d < 0 || d > 24*time.Hour:
+		return fmt.Errorf("irrigation interval must be between 0 and 24h")
+	case d%time.Minute != 0:
+		return fmt.Errorf("irrigation interval must be whole minutes")
+	}
+	return nil
+}
+
+// ValidateIrrigationDuration checks how long each irrigation of a station runs for, which the
+// device keeps in whole seconds
+func ValidateIrrigationDuration(d time.Duration) error {
+	switch {
+	case d < 0 || d > 24*time.Hour:
+		return fmt.Errorf("irrigation duration must be between 0 and 24h")
+	case d%time.Second != 0:
+		return fmt.Errorf("irrigation duration must be whole seconds")
+	}
+	return nil
+}
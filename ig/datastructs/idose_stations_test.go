@@ -0,0 +1,66 @@
+package datastructs
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestIrrigationStation(t *testing.T) {
+	Convey("given a config with 2 irrigation stations", t, func() {
+		cfg := &ConfigIDose{Functions: FunctionsIDose{IrrigationStations: 2, IrrigationStation2: "Veg"}}
+		status := &StatusIDose{}
+
+		Convey("it should give the fields of a configured station", func() {
+			st, err := IrrigationStation(cfg, status, 2)
+			So(err, ShouldBeNil)
+			So(*st.Name, ShouldEqual, "Veg")
+
+			*st.Duration = 45
+			So(status.General.IrrigationDuration2, ShouldEqual, 45)
+		})
+
+		Convey("it should not give stations that aren't configured", func() {
+			for _, n := range []int{0, 3, 5} {
+				_, err := IrrigationStation(cfg, status, n)
+				So(err, ShouldNotBeNil)
+			}
+		})
+
+		Convey("it should give the status of a station that has one", func() {
+			status.Status = []StatusStatusIDose{{Function: "Irrigation Station 1"}}
+
+			st, err := StationStatus(status, 1)
+			So(err, ShouldBeNil)
+			st.Enabled = true
+			So(status.Status[0].Enabled, ShouldBeTrue)
+
+			_, err = StationStatus(status, 2)
+			So(err, ShouldNotBeNil)
+		})
+	})
+
+	Convey("it should validate intervals and durations", t, func() {
+		So(ValidateIrrigationInterval(90*time.Minute), ShouldBeNil)
+		So(ValidateIrrigationInterval(90*time.Second), ShouldNotBeNil)
+		So(ValidateIrrigationInterval(25*time.Hour), ShouldNotBeNil)
+		So(ValidateIrrigationDuration(45*time.Second), ShouldBeNil)
+		So(ValidateIrrigationDuration(-time.Second), ShouldNotBeNil)
+	})
+}
+
+func TestParseSaved(t *testing.T) {
+	Convey("it should parse a saved config on its own or in a dump of the device", t, func() {
+		for _, data := range []string{
+			`{"functions": {"irrigation_stations": 3}}`,
+			`{"device": {"id": "ASLID17081149"}, "config": {"functions": {"irrigation_stations": 3}}}`,
+		} {
+			cfg := ConfigIDose{}
+			So(cfg.ParseSaved([]byte(data)), ShouldBeNil)
+			So(cfg.IrrigationStationCount(), ShouldEqual, 3)
+		}
+
+		So((&ConfigIDose{}).ParseSaved([]byte(`{`)), ShouldNotBeNil)
+	})
+}
@@ -0,0 +1,15 @@
+package datastructs
+
+import (
+	"testing"
+
+	"github.com/autogrow/go-jelly/units"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestUnitsIDose(t *testing.T) {
+	Convey("it should give the unit system of the device", t, func() {
+		So(UnitsIDose{Temperature: "fahrenheit", Ec: "ppm", TdsConversationStandart: 700}.System().Conductivity, ShouldEqual, units.PPM700)
+		So(UnitsIDose{Ec: "bogus"}.System(), ShouldResemble, units.Metric)
+	})
+}
@@ -0,0 +1,38 @@
+package datastructs
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// ParseSaved replaces the config with a saved config, which can be the config alone or a device
+// dumped as JSON with the config under the "config" key
+func (c *ConfigIDose) ParseSaved(data []byte) error {
+	return parseSavedConfig(data, c)
+}
+
+// ParseSaved replaces the config with a saved config, which can be the config alone or a device
+// dumped as JSON with the config under the "config" key
+func (c *ConfigIClimate) ParseSaved(data []byte) error {
+	return parseSavedConfig(data, c)
+}
+
+func parseSavedConfig(data []byte, cfg interface{}) error {
+	dump := struct {
+		Config json.RawMessage `json:"config"`
+	}{}
+
+	if err := json.Unmarshal(data, &dump); err != nil {
+		return fmt.Errorf("couldn't parse config: %s", err)
+	}
+
+	if len(dump.Config) > 0 {
+		data = dump.Config
+	}
+
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return fmt.Errorf("couldn't parse config: %s", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,203 @@
+package datastructs
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const minutesPerDay = 24 * 60
+
+// TimeOfDay is a time of day in minutes since midnight.  The API reports times of day as a
+// number of minutes, but some firmware reports them over the local network as "HH:MM" strings,
+// so either is accepted when decoding.  It is always encoded as a number of minutes.
+type TimeOfDay int
+
+// UnmarshalJSON decodes a number of minutes or a "HH:MM" (or "HHMM") string, which must be
+// between 00:00 and 23:59
+func (t *TimeOfDay) UnmarshalJSON(b []byte) error {
+	var v interface{}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+
+	switch v := v.(type) {
+	case nil:
+		return nil
+	case float64:
+		if v < 0 || v >= minutesPerDay || v != float64(int(v)) {
+			return fmt.Errorf("invalid time of day: %s", b)
+		}
+		*t = TimeOfDay(v)
+		return nil
+	case string:
+		m, err := parseTimeOfDay(v)
+		if err != nil {
+			return err
+		}
+		*t = TimeOfDay(m)
+		return nil
+	}
+
+	return fmt.Errorf("invalid time of day: %s", b)
+}
+
+func parseTimeOfDay(s string) (int, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, nil
+	}
+
+	hh, mm := s, "0"
+	if i := strings.Index(s, ":"); i >= 0 {
+		hh, mm = s[:i], s[i+1:]
+	} else if len(s) == 4 {
+		hh, mm = s[:2], s[2:]
+	}
+
+	h, err := strconv.Atoi(hh)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time of day: %q", s)
+	}
+
+	m, err := strconv.Atoi(mm)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time of day: %q", s)
+	}
+
+	if h < 0 || h > 23 || m < 0 || m > 59 {
+		return 0, fmt.Errorf("invalid time of day: %q", s)
+	}
+
+	return h*60 + m, nil
+}
+
+// String returns the time of day as HH:MM
+func (t TimeOfDay) String() string {
+	return fmt.Sprintf("%02d:%02d", int(t)/60, int(t)%60)
+}
+
+// Duration returns the time of day as the time since midnight
+func (t TimeOfDay) Duration() time.Duration {
+	return time.Duration(t) * time.Minute
+}
+
+// ParseTimeOfDay parses a time of day given as "HH:MM" or "HHMM"
+func ParseTimeOfDay(s string) (TimeOfDay, error) {
+	m, err := parseTimeOfDay(s)
+	return TimeOfDay(m), err
+}
+
+// DaySchedule is the day period of a device, from the start to the end time of day in the time
+// zone of the device.  A day that ends before it starts spans midnight, and one that ends when
+// it starts has no day at all.
+type DaySchedule struct {
+	Start TimeOfDay `json:"start"`
+	End   TimeOfDay `json:"end"`
+	// Location is the time zone of the device, UTC if nil
+	Location *time.Location `json:"-"`
+}
+
+// Schedule returns the day period in the time zone of the device
+func (t TimesIDose) Schedule(loc *time.Location) DaySchedule {
+	return DaySchedule{t.DayStart, t.DayEnd, loc}
+}
+
+// ParseDaySchedule returns the day period from the start and end times given as "HH:MM"
+func ParseDaySchedule(start, end string, loc *time.Location) (DaySchedule, error) {
+	s, err := ParseTimeOfDay(start)
+	if err != nil {
+		return DaySchedule{}, err
+	}
+
+	e, err := ParseTimeOfDay(end)
+	if err != nil {
+		return DaySchedule{}, err
+	}
+
+	return DaySchedule{s, e, loc}, nil
+}
+
+func (s DaySchedule) location() *time.Location {
+	if s.Location == nil {
+		return time.UTC
+	}
+	return s.Location
+}
+
+// DayLength returns how long the day period lasts
+func (s DaySchedule) DayLength() time.Duration {
+	l := int(s.End) - int(s.Start)
+	for l < 0 {
+		l += minutesPerDay
+	}
+	for l > minutesPerDay {
+		l -= minutesPerDay
+	}
+	return time.Duration(l) * time.Minute
+}
+
+// IsDay returns true if the given time falls in the day period on the clock of the device
+func (s DaySchedule) IsDay(t time.Time) bool {
+	length := s.DayLength()
+	switch {
+	case length <= 0:
+		return false
+	case length >= minutesPerDay*time.Minute:
+		return true
+	}
+
+	t = t.In(s.location())
+	tod := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute + time.Duration(t.Second())*time.Second
+	since := (tod - s.Start.Duration() + minutesPerDay*time.Minute) % (minutesPerDay * time.Minute)
+	return since < length
+}
+
+// Period returns "day" or "night" for the given time, the same as the day_night reading of an
+// IntelliClimate
+func (s DaySchedule) Period(t time.Time) string {
+	if s.IsDay(t) {
+		return "day"
+	}
+	return "night"
+}
+
+// NextTransition returns the next time after t that the day starts or ends, and whether it is
+// day after it.  The time is zero if it is always day or always night.
+func (s DaySchedule) NextTransition(t time.Time) (time.Time, bool) {
+	length := s.DayLength()
+	if length <= 0 || length >= minutesPerDay*time.Minute {
+		return time.Time{}, length > 0
+	}
+
+	loc := s.location()
+	local := t.In(loc)
+	lengthMins := int(length / time.Minute)
+
+	// look at the days from yesterday to tomorrow as the day can span midnight, building each
+	// time from the wall clock so that daylight saving changes are followed
+	var next time.Time
+	var day bool
+	for d := -1; d <= 1; d++ {
+		start := time.Date(local.Year(), local.Month(), local.Day()+d, 0, int(s.Start), 0, 0, loc)
+		end := time.Date(local.Year(), local.Month(), local.Day()+d, 0, int(s.Start)+lengthMins, 0, 0, loc)
+
+		for _, tr := range []struct {
+			at  time.Time
+			day bool
+		}{{start, true}, {end, false}} {
+			if tr.at.After(t) && (next.IsZero() || tr.at.Before(next)) {
+				next, day = tr.at, tr.day
+			}
+		}
+	}
+
+	return next, day
+}
+
+// String describes the schedule, such as "18:00-06:00"
+func (s DaySchedule) String() string {
+	return fmt.Sprintf("%s-%s", s.Start, TimeOfDay(int(s.End)%minutesPerDay))
+}
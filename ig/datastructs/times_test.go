@@ -0,0 +1,153 @@
+package datastructs
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestTimeOfDay(t *testing.T) {
+	Convey("it should decode times of day", t, func() {
+		for _, tc := range []struct {
+			json string
+			want TimeOfDay
+		}{
+			{`390`, 390},
+			{`"06:30"`, 390},
+			{`"0630"`, 390},
+			{`"6"`, 360},
+			{`"00:00"`, 0},
+			{`1439`, 1439},
+			{`"23:59"`, 1439},
+			{`""`, 0},
+			{`null`, 0},
+		} {
+			var tod TimeOfDay
+			So(json.Unmarshal([]byte(tc.json), &tod), ShouldBeNil)
+			So(tod, ShouldEqual, tc.want)
+		}
+	})
+
+	Convey("it should not decode invalid times of day", t, func() {
+		for _, src := range []string{
+			`"24:00"`, `"24:30"`, `"12:60"`, `"-1:00"`, `"noon"`, `"6:xx"`,
+			`-1`, `1440`, `90.5`, `true`, `[]`,
+		} {
+			var tod TimeOfDay
+			So(json.Unmarshal([]byte(src), &tod), ShouldNotBeNil)
+		}
+	})
+
+	Convey("it should encode as a number of minutes", t, func() {
+		data, err := json.Marshal(struct {
+			At TimeOfDay `json:"at"`
+		}{390})
+		So(err, ShouldBeNil)
+		So(string(data), ShouldEqual, `{"at":390}`)
+		So(TimeOfDay(390).String(), ShouldEqual, "06:30")
+	})
+}
+
+func TestDaySchedule(t *testing.T) {
+	day := func(h, m int) time.Time {
+		return time.Date(2018, 6, 1, h, m, 0, 0, time.UTC)
+	}
+
+	Convey("it should give the length of the day", t, func() {
+		for _, tc := range []struct {
+			start, end TimeOfDay
+			want       time.Duration
+		}{
+			{360, 1080, 12 * time.Hour},
+			{1080, 360, 12 * time.Hour},
+			{360, 360, 0},
+			{0, 1440, 24 * time.Hour},
+			{1320, 1440, 2 * time.Hour},
+			{360, 360 + 1440, 24 * time.Hour},
+		} {
+			So(DaySchedule{Start: tc.start, End: tc.end}.DayLength(), ShouldEqual, tc.want)
+		}
+	})
+
+	Convey("it should tell whether it is day", t, func() {
+		for _, tc := range []struct {
+			start, end TimeOfDay
+			at         time.Time
+			want       bool
+		}{
+			{360, 1080, day(12, 0), true},
+			{360, 1080, day(6, 0), true},
+			{360, 1080, day(18, 0), false},
+			{360, 1080, day(3, 0), false},
+			{1080, 360, day(23, 0), true},
+			{1080, 360, day(2, 0), true},
+			{1080, 360, day(12, 0), false},
+			{360, 360, day(6, 0), false},
+			{360, 360, day(12, 0), false},
+			{0, 1440, day(3, 0), true},
+			{1320, 1440, day(23, 30), true},
+			{1320, 1440, day(0, 0), false},
+		} {
+			s := DaySchedule{Start: tc.start, End: tc.end}
+			So(s.IsDay(tc.at), ShouldEqual, tc.want)
+		}
+	})
+
+	Convey("it should give the next time the day starts or ends", t, func() {
+		for _, tc := range []struct {
+			start, end TimeOfDay
+			from       time.Time
+			want       time.Time
+			day        bool
+		}{
+			{360, 1080, day(3, 0), day(6, 0), true},
+			{360, 1080, day(6, 0), day(18, 0), false},
+			{360, 1080, day(20, 0), day(24+6, 0), true},
+			{1080, 360, day(12, 0), day(18, 0), true},
+			{1080, 360, day(20, 0), day(24+6, 0), false},
+			{1080, 360, day(2, 0), day(6, 0), false},
+			{1320, 1440, day(23, 0), day(24, 0), false},
+		} {
+			next, isDay := DaySchedule{Start: tc.start, End: tc.end}.NextTransition(tc.from)
+			So(next, ShouldEqual, tc.want)
+			So(isDay, ShouldEqual, tc.day)
+		}
+	})
+
+	Convey("it should not give a transition if it is always day or night", t, func() {
+		next, isDay := DaySchedule{Start: 360, End: 360}.NextTransition(day(12, 0))
+		So(next.IsZero(), ShouldBeTrue)
+		So(isDay, ShouldBeFalse)
+
+		next, isDay = DaySchedule{Start: 0, End: 1440}.NextTransition(day(12, 0))
+		So(next.IsZero(), ShouldBeTrue)
+		So(isDay, ShouldBeTrue)
+	})
+
+	Convey("given a day schedule in a time zone with daylight saving", t, func() {
+		loc, err := time.LoadLocation("Europe/London")
+		So(err, ShouldBeNil)
+		s := DaySchedule{Start: 360, End: 1080, Location: loc}
+
+		Convey("it should follow the clock on the day the clocks go forward", func() {
+			// the clocks go forward at 01:00 UTC on the 25th of March 2018
+			from := time.Date(2018, 3, 25, 0, 0, 0, 0, time.UTC)
+			next, isDay := s.NextTransition(from)
+			So(next, ShouldEqual, time.Date(2018, 3, 25, 6, 0, 0, 0, loc))
+			So(next.UTC(), ShouldEqual, time.Date(2018, 3, 25, 5, 0, 0, 0, time.UTC))
+			So(isDay, ShouldBeTrue)
+
+			So(s.IsDay(time.Date(2018, 3, 25, 5, 30, 0, 0, time.UTC)), ShouldBeTrue)
+			So(s.IsDay(time.Date(2018, 3, 25, 17, 30, 0, 0, time.UTC)), ShouldBeFalse)
+		})
+
+		Convey("it should follow the clock on the day the clocks go back", func() {
+			// the clocks go back at 01:00 UTC on the 28th of October 2018
+			from := time.Date(2018, 10, 28, 0, 0, 0, 0, time.UTC)
+			next, _ := s.NextTransition(from)
+			So(next.UTC(), ShouldEqual, time.Date(2018, 10, 28, 6, 0, 0, 0, time.UTC))
+		})
+	})
+}
@@ -4,6 +4,9 @@ import (
 	"fmt"
 	"strings"
 	"time"
+
+	"github.com/autogrow/go-jelly/health"
+	"github.com/autogrow/go-jelly/units"
 )
 
 const (
@@ -93,6 +96,7 @@ func (ds *Devices) UpdateClimateMetrics() error {
 
 	for _, ic := range ds.IntelliClimates {
 		err := ic.GetMetrics()
+		ic.CheckHealth()
 		if err != nil {
 			anErr = true
 			errMsg += fmt.Sprintf("Error Updating: %s: %s ", ic.GetID(), err)
@@ -100,7 +104,7 @@ func (ds *Devices) UpdateClimateMetrics() error {
 	}
 
 	if anErr {
-		return fmt.Errorf(errMsg)
+		return fmt.Errorf("%s", errMsg)
 	}
 
 	return nil
@@ -138,6 +142,7 @@ func (ds *Devices) UpdateDoserMetrics() error {
 
 	for _, id := range ds.IntelliDoses {
 		err := id.GetMetrics()
+		id.CheckHealth()
 		if err != nil {
 			anErr = true
 			errMsg += fmt.Sprintf("Error Updating: %s: %s ", id.GetID(), err)
@@ -145,7 +150,7 @@ func (ds *Devices) UpdateDoserMetrics() error {
 	}
 
 	if anErr {
-		return fmt.Errorf(errMsg)
+		return fmt.Errorf("%s", errMsg)
 	}
 
 	return nil
@@ -161,8 +166,10 @@ type Device struct {
 	LastUpdated    float64 `json:"last_updated"`
 	TimeZoneOffset float64 `json:"time_zone_offset"`
 	DeviceName     string  `json:"device_name"`
+	Connected      *bool   `json:"connected,omitempty"`
 	client         *Client
 	Readings       map[string]interface{}
+	health         *health.Tracker
 }
 
 // Average
@@ -210,6 +217,18 @@ func (d *Device) IntelliClimate() (*IntelliClimate, error) {
 // attach a valid API client to the device.
 func (d *Device) AttachClient(c *Client) {
 	d.client = c
+	if d.health == nil {
+		d.health = health.NewTracker()
+	}
+}
+
+// DisplayUnits returns the unit system chosen on the client this device is attached to, or
+// metric if there is no client
+func (d *Device) DisplayUnits() units.System {
+	if d.client == nil {
+		return units.Metric
+	}
+	return d.client.Units()
 }
 
 // GetID - returns the ID for a device
@@ -238,14 +257,112 @@ func (d *Device) IsIDose() bool {
 	return false
 }
 
-// IsValid - returns a true if the last updated time is with 1 minute of now
+// IsValid - returns a true if the device has reported recently enough that it is not considered
+// offline by the health policy of the client
 func (d *Device) IsValid() bool {
-	ld := int64(d.LastUpdated / 1000)
-	t := time.Now().Unix()
-	if (t - ld) > 6000 {
-		return false
+	state := d.HealthState()
+	return state == health.Online || state == health.Stale
+}
+
+// MetricType returns the type of metrics reported by the device
+func (d *Device) MetricType() health.MetricType {
+	if d.IsIClimate() {
+		return health.Climate
+	}
+	return health.Rootzone
+}
+
+// LastContact returns the time the device last reported to the API, from LastUpdated which is in
+// milliseconds
+func (d *Device) LastContact() time.Time {
+	if d.LastUpdated == 0 {
+		return time.Time{}
+	}
+	return msToTime(d.LastUpdated)
+}
+
+// Location returns the time zone of the device, using its offset from UTC in hours
+func (d *Device) Location() *time.Location {
+	offset := int(d.TimeZoneOffset * 3600)
+	if offset == 0 {
+		return time.UTC
+	}
+
+	sign := "+"
+	if offset < 0 {
+		sign = "-"
+	}
+
+	abs := offset
+	if abs < 0 {
+		abs = -abs
+	}
+
+	name := fmt.Sprintf("UTC%s%d", sign, abs/3600)
+	if mins := abs % 3600 / 60; mins != 0 {
+		name += fmt.Sprintf(":%02d", mins)
+	}
+
+	return time.FixedZone(name, offset)
+}
+
+func (d *Device) healthPolicy() health.Policy {
+	if d.client == nil {
+		return health.DefaultPolicy
 	}
-	return true
+	return d.client.HealthPolicy()
+}
+
+// IsConnected returns true if the device last reported that it is connected.  The IntelliGrow
+// API doesn't report this for every device, in which case the device is taken to be connected
+// and its health depends only on when it last reported.  Connected is nil until it is reported.
+func (d *Device) IsConnected() bool {
+	return d.Connected == nil || *d.Connected
+}
+
+// updateConnected takes the connected flag from a response from the API, if it has one
+func (d *Device) updateConnected(msi map[string]interface{}) {
+	if connected, ok := msi["connected"].(bool); ok {
+		d.Connected = &connected
+	}
+}
+
+// HealthState returns the current health state of the device
+func (d *Device) HealthState() health.State {
+	return d.healthPolicy().Evaluate(d.MetricType(), d.LastContact(), d.IsConnected(), time.Now())
+}
+
+// Health returns a report of the health of the device along with the uptime statistics kept
+// since it was first checked
+func (d *Device) Health() health.Report {
+	now := time.Now()
+	report := health.Report{
+		State:       d.healthPolicy().Evaluate(d.MetricType(), d.LastContact(), d.IsConnected(), now),
+		LastContact: d.LastContact(),
+	}
+
+	if !report.LastContact.IsZero() {
+		report.SinceLastContact = now.Sub(report.LastContact)
+	}
+
+	if d.health != nil {
+		report.Stats = d.health.Stats()
+	}
+
+	return report
+}
+
+// CheckHealth returns a report of the health of the device, recording the current state in the
+// uptime statistics
+func (d *Device) CheckHealth() health.Report {
+	if d.health == nil {
+		d.health = health.NewTracker()
+	}
+
+	report := d.Health()
+	d.health.Observe(time.Now(), report.State)
+	report.Stats = d.health.Stats()
+	return report
 }
 
 // GetGrowroom - returns the growroom name this device is assigned to
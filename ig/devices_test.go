@@ -0,0 +1,19 @@
+package ig
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestLastContact(t *testing.T) {
+	Convey("it should read the last contact from the milliseconds given by the API", t, func() {
+		d := &Device{ID: "ID1", LastUpdated: 1528185600123}
+		So(d.LastContact().Equal(time.Date(2018, 6, 5, 8, 0, 0, 123e6, time.UTC)), ShouldBeTrue)
+	})
+
+	Convey("it should give no last contact for a device that never reported", t, func() {
+		So((&Device{ID: "ID1"}).LastContact().IsZero(), ShouldBeTrue)
+	})
+}
@@ -4,6 +4,10 @@ import (
 	"fmt"
 	"sync"
 	"time"
+
+	"github.com/autogrow/go-jelly/calc"
+	"github.com/autogrow/go-jelly/health"
+	"github.com/autogrow/go-jelly/ig/datastructs"
 )
 
 const (
@@ -19,20 +23,24 @@ const (
 	grEC             = "ec"
 	grPH             = "ph"
 	grTemp           = "nut_temp"
+	grOutsideTemp    = "outside_temp_sensor"
 	grReadingUnknown = "value not known in climate"
 )
 
 // GrowroomClimate - climate data for a single room
 type GrowroomClimate struct {
-	AirTemp        float64 `json:"air_temp"`
-	RH             float64 `json:"rh"`
-	VPD            float64 `json:"vpd"`
-	Light          float64 `json:"light"`
-	PowerFail      bool    `json:"power_fail"`
-	FailSafeAlarms bool    `json:"fail_safe_alarms"`
-	DayNight       string  `json:"day_night"`
-	CO2            float64 `json:"co2"`
-	LastUpdate     float64 `json:"last_update"`
+	AirTemp        float64        `json:"air_temp"`
+	RH             float64        `json:"rh"`
+	VPD            float64        `json:"vpd"`
+	Light          float64        `json:"light"`
+	PowerFail      bool           `json:"power_fail"`
+	FailSafeAlarms bool           `json:"fail_safe_alarms"`
+	DayNight       string         `json:"day_night"`
+	CO2            float64        `json:"co2"`
+	LastUpdate     float64        `json:"last_update"`
+	OutsideTemp    float64        `json:"outside_temp_sensor"`
+	Sensors        []EnviroSensor `json:"sensors"`
+	Spread         *SensorSpread  `json:"spread,omitempty"`
 }
 
 // GrowroomRootzone - rootzone data for a single room
@@ -52,6 +60,8 @@ type Growroom struct {
 	Rootzone          *GrowroomRootzone `json:"rootzone"`
 	IntruderAlarm     float64           `json:"intruder_alarm"`
 	OutsideTempSensor float64           `json:"outside_temp_sensor"`
+	light             *calc.LightAccumulator
+	sensorLabels      map[string]string
 }
 
 // NewGrowroom - return a new growroom with the name specified
@@ -64,6 +74,8 @@ func NewGrowroom(name string) *Growroom {
 		&GrowroomRootzone{},
 		0,
 		0,
+		calc.NewLightAccumulator(time.UTC),
+		map[string]string{},
 	}
 	return gr
 }
@@ -143,6 +155,51 @@ func (g *Growroom) Devices() []*Device {
 	return devs
 }
 
+// Location returns the time zone of the devices in the growroom, or UTC if it has none
+func (g *Growroom) Location() *time.Location {
+	devs := g.Devices()
+	if len(devs) == 0 {
+		return time.UTC
+	}
+	return devs[0].Location()
+}
+
+// DaySchedule returns the day period of the growroom, which follows the lights of the first
+// IntelliClimate, or the day period of the first IntelliDose if it has no IntelliClimates
+func (g *Growroom) DaySchedule() (datastructs.DaySchedule, error) {
+	if climates := g.devices.Climates(); len(climates) > 0 {
+		return climates[0].DaySchedule()
+	}
+
+	if dosers := g.devices.Dosers(); len(dosers) > 0 {
+		return dosers[0].DaySchedule(), nil
+	}
+
+	return datastructs.DaySchedule{}, fmt.Errorf("no devices in %s to take the day period from", g.GetName())
+}
+
+// updateDayNight sets whether it is day or night in the growroom from the day_night reading of
+// the climate, or from the day period if the climate doesn't report it
+func (g *Growroom) updateDayNight(readings map[string]interface{}) {
+	if dn, ok := readings[grDayNight].(string); ok && dn != "" {
+		g.Climate.DayNight = dn
+		return
+	}
+
+	if ds, err := g.DaySchedule(); err == nil {
+		g.Climate.DayNight = ds.Period(time.Now())
+	}
+}
+
+// addLight adds the light reading to the daily light integral, which is split into days in the
+// time zone of the growroom
+func (g *Growroom) addLight() {
+	if loc := g.Location(); g.light.Location().String() != loc.String() {
+		g.light = calc.NewLightAccumulator(loc)
+	}
+	g.light.Add(msToTime(g.Climate.LastUpdate), g.Climate.Light)
+}
+
 // ListDevicesBySerial will return the serial numbers of all known devices
 func (g *Growroom) ListDevicesBySerial() []string {
 	serials := []string{}
@@ -221,7 +278,13 @@ func (g *Growroom) UpdateClimate() error {
 	case 1:
 		// Only one intelliclimate exists don't bother
 		g.Climate.LastUpdate = climates[0].LastUpdated
-		return updateStruct(climates[0].Readings, g.Climate)
+		if err := updateStruct(climates[0].Readings, g.Climate); err != nil {
+			return err
+		}
+		g.updateDayNight(climates[0].Readings)
+		g.addLight()
+		g.updateSensors()
+		return nil
 	default:
 		g.Climate.AirTemp = AverageClimateReadings(climates, "air_temp")
 		g.Climate.RH = AverageClimateReadings(climates, "rh")
@@ -232,7 +295,9 @@ func (g *Growroom) UpdateClimate() error {
 		g.Climate.LastUpdate = climates[0].LastUpdated
 		g.Climate.FailSafeAlarms = climates[0].Readings["fail_safe_alarms"].(bool)
 		g.Climate.PowerFail = climates[0].Readings["power_fail"].(bool)
-		g.Climate.DayNight = climates[0].Readings["day_night"].(string)
+		g.updateDayNight(climates[0].Readings)
+		g.addLight()
+		g.updateSensors()
 		return nil
 	}
 }
@@ -274,6 +339,8 @@ func (g *Growroom) GetReading(reading string) (bool, string) {
 		return true, fmt.Sprintf("fail safe alarm: %t", g.Climate.FailSafeAlarms)
 	case grDayNight:
 		return true, fmt.Sprintf("day/night: %s", g.Climate.DayNight)
+	case grOutsideTemp:
+		return true, fmt.Sprintf("%.2f", g.Climate.OutsideTemp)
 	case grEC:
 		return true, fmt.Sprintf("%.2f", g.Rootzone.EC)
 	case grPH:
@@ -287,7 +354,7 @@ func (g *Growroom) GetReading(reading string) (bool, string) {
 
 // GetRootzoneReading - returns an avaliable flag and the reading as a string
 func (g *Growroom) GetRootzoneReading(reading string) (bool, string) {
-	if (time.Now().Unix() - int64(g.Rootzone.LastUpdate)) > 60 {
+	if g.RootzoneState() != health.Online {
 		return false, ""
 	}
 
@@ -305,7 +372,7 @@ func (g *Growroom) GetRootzoneReading(reading string) (bool, string) {
 
 // GetClimateReading - returns an avaliable flag and the reading as a string
 func (g *Growroom) GetClimateReading(reading string) (bool, string) {
-	if (time.Now().Unix() - int64(g.Climate.LastUpdate)) > 60 {
+	if g.ClimateState() != health.Online {
 		return false, ""
 	}
 
@@ -324,6 +391,8 @@ func (g *Growroom) GetClimateReading(reading string) (bool, string) {
 		return true, fmt.Sprintf("fail safe alarm: %t", g.Climate.FailSafeAlarms)
 	case grDayNight:
 		return true, fmt.Sprintf("day/night: %s", g.Climate.DayNight)
+	case grOutsideTemp:
+		return true, fmt.Sprintf("%.2f", g.Climate.OutsideTemp)
 	default:
 		return false, "value not known in climate"
 	}
@@ -364,10 +433,10 @@ func (g *Growroom) WaterTemp() (bool, string) {
 	return g.GetRootzoneReading(grTemp)
 }
 
-// GetClimateHistory - returns the history for the growroom
+// GetClimateHistory - returns the history for the growroom, from the first IntelliClimate in it
 func (g *Growroom) GetClimateHistory(from, to time.Time, points int) error {
-	if len(g.devices.Climates()) > 0 {
-		return g.devices.Climates()[0].GetHistory(from, to, points)
+	if ic := g.historyClimate(); ic != nil {
+		return ic.GetHistory(from, to, points)
 	}
 	return fmt.Errorf("Growroom has no Intelliclimates")
 }
@@ -379,3 +448,50 @@ func (g *Growroom) GetDoserHistory(from, to time.Time, points int) error {
 	}
 	return fmt.Errorf("Growroom has no Intellidosers")
 }
+
+// healthPolicy returns the health policy of the client the devices in the room are attached to
+func (g *Growroom) healthPolicy() health.Policy {
+	for _, d := range g.Devices() {
+		if d.client != nil {
+			return d.client.HealthPolicy()
+		}
+	}
+	return health.DefaultPolicy
+}
+
+// connected returns true if any of the devices giving the metric type are connected
+func (g *Growroom) connected(mt health.MetricType) bool {
+	for _, d := range g.Devices() {
+		if d.MetricType() == mt && d.IsConnected() {
+			return true
+		}
+	}
+	return false
+}
+
+// ClimateState returns the health state of the growroom climate readings
+func (g *Growroom) ClimateState() health.State {
+	last := msToTime(g.Climate.LastUpdate)
+	if g.Climate.LastUpdate == 0 {
+		last = time.Time{}
+	}
+	return g.healthPolicy().Evaluate(health.Climate, last, g.connected(health.Climate), time.Now())
+}
+
+// RootzoneState returns the health state of the growroom rootzone readings
+func (g *Growroom) RootzoneState() health.State {
+	last := msToTime(g.Rootzone.LastUpdate)
+	if g.Rootzone.LastUpdate == 0 {
+		last = time.Time{}
+	}
+	return g.healthPolicy().Evaluate(health.Rootzone, last, g.connected(health.Rootzone), time.Now())
+}
+
+// Health returns a health report for each device in the growroom keyed by serial number
+func (g *Growroom) Health() map[string]health.Report {
+	reports := map[string]health.Report{}
+	for _, d := range g.Devices() {
+		reports[d.GetID()] = d.Health()
+	}
+	return reports
+}
@@ -0,0 +1,121 @@
+package ig
+
+import (
+	"github.com/autogrow/go-jelly/calc"
+	"github.com/autogrow/go-jelly/ig/datastructs"
+)
+
+// DewPoint returns the dew point of the growroom in °C.  It will return false if the RH of the
+// growroom isn't above zero.
+func (g *Growroom) DewPoint() (float64, bool) {
+	return calc.DewPoint(g.Climate.AirTemp, g.Climate.RH)
+}
+
+// AbsoluteHumidity returns the absolute humidity of the growroom in g/m³
+func (g *Growroom) AbsoluteHumidity() float64 {
+	return calc.AbsoluteHumidity(g.Climate.AirTemp, g.Climate.RH)
+}
+
+// LeafVPD returns the leaf vapour pressure deficit of the growroom in kPa, where the leaf
+// temperature is the air temperature plus the given offset
+func (g *Growroom) LeafVPD(leafOffset float64) float64 {
+	return calc.LeafVPD(g.Climate.AirTemp, g.Climate.RH, leafOffset)
+}
+
+// SecondaryVPD returns the VPD in kPa calculated from the second enviro sensor, averaged across
+// the IntelliClimates in the growroom that have one.  It will return false if there are none.
+func (g *Growroom) SecondaryVPD() (float64, bool) {
+	var sum float64
+	var count int
+
+	for _, ic := range g.devices.Climates() {
+		if !ic.Config.Functions.SecondEnviroSensor || !ic.IsValid() {
+			continue
+		}
+
+		sum += calc.VPD(ic.Metrics.EnviroAirTemp2, ic.Metrics.EnviroRH2)
+		count++
+	}
+
+	if count == 0 {
+		return 0, false
+	}
+
+	return sum / float64(count), true
+}
+
+// Derived returns all the metrics derived from the current growroom climate
+func (g *Growroom) Derived(leafOffset float64) calc.Derived {
+	at := msToTime(g.Climate.LastUpdate)
+	return calc.Derive(at, g.Climate.AirTemp, g.Climate.RH, leafOffset)
+}
+
+// DLI returns the daily light integral in mol/m²/day accumulated from the light readings taken
+// each time the growroom has been updated today.  The factor converts the light readings into
+// PPFD (µmol/m²/s).
+func (g *Growroom) DLI(factor float64) float64 {
+	return g.light.DLI(factor)
+}
+
+// DerivedHistory returns the derived metrics for each point of the climate history fetched
+// with GetClimateHistory
+func (g *Growroom) DerivedHistory(leafOffset float64) []calc.Derived {
+	derived := []calc.Derived{}
+	for _, p := range g.climateHistoryPoints() {
+		m := p.Metrics
+		derived = append(derived, calc.Derive(msToTime(p.Timestamp), m.AirTemp, m.Rh, leafOffset))
+	}
+	return derived
+}
+
+// SecondaryVPDHistory returns the VPD in kPa calculated from the second enviro sensor for each
+// point of the climate history fetched with GetClimateHistory.  It will return false if the
+// IntelliClimate the history came from has no second enviro sensor.
+func (g *Growroom) SecondaryVPDHistory() ([]calc.Sample, bool) {
+	ic := g.historyClimate()
+	if ic == nil || !ic.Config.Functions.SecondEnviroSensor {
+		return nil, false
+	}
+
+	samples := []calc.Sample{}
+	for _, p := range ic.History.Points {
+		m := p.Metrics
+		samples = append(samples, calc.Sample{Time: msToTime(p.Timestamp), Value: calc.VPD(m.EnviroAirTemp2, m.EnviroRH2)})
+	}
+	return samples, true
+}
+
+// LightHistory returns the light readings from the climate history fetched with
+// GetClimateHistory
+func (g *Growroom) LightHistory() []calc.Sample {
+	samples := []calc.Sample{}
+	for _, p := range g.climateHistoryPoints() {
+		samples = append(samples, calc.Sample{Time: msToTime(p.Timestamp), Value: p.Metrics.Light})
+	}
+	return samples
+}
+
+// DLIHistory returns the daily light integral for each day of the climate history fetched with
+// GetClimateHistory in the time zone of the growroom, using the factor to convert light readings
+// into PPFD (µmol/m²/s)
+func (g *Growroom) DLIHistory(factor float64) []calc.DailyLightIntegral {
+	return calc.DLIByDay(g.LightHistory(), factor, g.Location())
+}
+
+// historyClimate returns the IntelliClimate that the climate history of the growroom is fetched
+// from by GetClimateHistory, which is the first one in the growroom.  The history isn't combined
+// across IntelliClimates as their points aren't taken at the same times.
+func (g *Growroom) historyClimate() *IntelliClimate {
+	climates := g.devices.Climates()
+	if len(climates) == 0 {
+		return nil
+	}
+	return climates[0]
+}
+
+func (g *Growroom) climateHistoryPoints() []*datastructs.ClimateHistoryPoint {
+	if ic := g.historyClimate(); ic != nil {
+		return ic.History.Points
+	}
+	return nil
+}
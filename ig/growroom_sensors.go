@@ -0,0 +1,182 @@
+package ig
+
+import (
+	"fmt"
+
+	"github.com/autogrow/go-jelly/health"
+)
+
+// EnviroSensor - readings from a single enviro sensor attached to an IntelliClimate
+type EnviroSensor struct {
+	Device  string  `json:"device"`
+	Index   int     `json:"index"`
+	Label   string  `json:"label"`
+	AirTemp float64 `json:"air_temp"`
+	RH      float64 `json:"rh"`
+	CO2     float64 `json:"co2"`
+	Light   float64 `json:"light"`
+	Healthy bool    `json:"healthy"`
+	Problem string  `json:"problem,omitempty"`
+}
+
+// SensorSpread - the difference between the first and second enviro sensors in a room, for
+// example between the top and bottom of the canopy
+type SensorSpread struct {
+	AirTemp float64 `json:"air_temp"`
+	RH      float64 `json:"rh"`
+	CO2     float64 `json:"co2"`
+	Light   float64 `json:"light"`
+}
+
+// Check returns an error describing why the readings from the sensor cannot be trusted, or nil
+// if they look fine
+func (s EnviroSensor) Check() error {
+	switch {
+	case s.AirTemp == 0 && s.RH == 0 && s.CO2 == 0 && s.Light == 0:
+		return fmt.Errorf("no readings")
+	case s.AirTemp < -40 || s.AirTemp > 80:
+		return fmt.Errorf("air temperature %0.1f out of range", s.AirTemp)
+	case s.RH < 0 || s.RH > 100:
+		return fmt.Errorf("RH %0.1f out of range", s.RH)
+	case s.CO2 < 0:
+		return fmt.Errorf("CO2 %0.0f out of range", s.CO2)
+	}
+	return nil
+}
+
+// SetSensorLabel sets the label of the given enviro sensor (1 or 2) on an IntelliClimate in the
+// growroom, such as "Top of canopy"
+func (g *Growroom) SetSensorLabel(deviceID string, index int, label string) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	g.sensorLabels[sensorKey(deviceID, index)] = label
+}
+
+func (g *Growroom) sensorLabel(ic *IntelliClimate, index int) string {
+	g.lock.RLock()
+	defer g.lock.RUnlock()
+
+	if label, ok := g.sensorLabels[sensorKey(ic.GetID(), index)]; ok {
+		return label
+	}
+
+	name := ic.DeviceName
+	if name == "" {
+		name = ic.GetID()
+	}
+	return fmt.Sprintf("%s sensor %d", name, index)
+}
+
+func sensorKey(deviceID string, index int) string {
+	return fmt.Sprintf("%s/%d", deviceID, index)
+}
+
+// Sensors returns the readings of each enviro sensor in the growroom
+func (g *Growroom) Sensors() []EnviroSensor {
+	sensors := []EnviroSensor{}
+
+	for _, ic := range g.devices.Climates() {
+		m := ic.Metrics
+		sensors = append(sensors, g.newSensor(ic, 1, m.EnviroAirTemp1, m.EnviroRH1, m.EnviroCO21, m.EnviroLight1))
+		if ic.Config.Functions.SecondEnviroSensor {
+			sensors = append(sensors, g.newSensor(ic, 2, m.EnviroAirTemp2, m.EnviroRH2, m.EnviroCO22, m.EnviroLight2))
+		}
+	}
+
+	return sensors
+}
+
+func (g *Growroom) newSensor(ic *IntelliClimate, index int, temp, rh, co2, light float64) EnviroSensor {
+	s := EnviroSensor{
+		Device:  ic.GetID(),
+		Index:   index,
+		Label:   g.sensorLabel(ic, index),
+		AirTemp: temp,
+		RH:      rh,
+		CO2:     co2,
+		Light:   light,
+	}
+
+	err := s.Check()
+	if state := ic.HealthState(); err == nil && state != health.Online {
+		err = fmt.Errorf("device is %s", state)
+	}
+
+	s.Healthy = err == nil
+	if err != nil {
+		s.Problem = err.Error()
+	}
+
+	return s
+}
+
+// Spread returns the average difference between the first and second enviro sensor of the
+// IntelliClimates in the room that have two healthy sensors.  It will return false if there
+// are none.
+func (g *Growroom) Spread() (SensorSpread, bool) {
+	byDevice := map[string][2]*EnviroSensor{}
+	sensors := g.Sensors()
+	for i, s := range sensors {
+		pair := byDevice[s.Device]
+		pair[s.Index-1] = &sensors[i]
+		byDevice[s.Device] = pair
+	}
+
+	var spread SensorSpread
+	var count int
+	for _, pair := range byDevice {
+		if pair[0] == nil || pair[1] == nil || !pair[0].Healthy || !pair[1].Healthy {
+			continue
+		}
+
+		spread.AirTemp += pair[0].AirTemp - pair[1].AirTemp
+		spread.RH += pair[0].RH - pair[1].RH
+		spread.CO2 += pair[0].CO2 - pair[1].CO2
+		spread.Light += pair[0].Light - pair[1].Light
+		count++
+	}
+
+	if count == 0 {
+		return spread, false
+	}
+
+	n := float64(count)
+	return SensorSpread{spread.AirTemp / n, spread.RH / n, spread.CO2 / n, spread.Light / n}, true
+}
+
+// outsideTemp returns the average outside temperature reported by the IntelliClimates in the
+// room that have an outside temperature sensor
+func (g *Growroom) outsideTemp() (float64, bool) {
+	var sum float64
+	var count int
+
+	for _, ic := range g.devices.Climates() {
+		if !ic.Config.Functions.OutsideTempSensor || !ic.IsValid() {
+			continue
+		}
+		sum += ic.Metrics.OutsideTemp
+		count++
+	}
+
+	if count == 0 {
+		return 0, false
+	}
+
+	return sum / float64(count), true
+}
+
+// updateSensors updates the per sensor readings in the growroom climate
+func (g *Growroom) updateSensors() {
+	g.Climate.Sensors = g.Sensors()
+
+	if spread, ok := g.Spread(); ok {
+		g.Climate.Spread = &spread
+	} else {
+		g.Climate.Spread = nil
+	}
+
+	if temp, ok := g.outsideTemp(); ok {
+		g.Climate.OutsideTemp = temp
+		g.OutsideTempSensor = temp
+	}
+}
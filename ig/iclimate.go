@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/autogrow/go-jelly/ig/datastructs"
+	"github.com/autogrow/go-jelly/units"
 )
 
 const (
@@ -13,6 +14,9 @@ const (
 	IClimate = "iclimate"
 )
 
+// climateTempFields are the metrics fields that are reported in the device temperature unit
+var climateTempFields = []string{"air_temp", "outside_temp_sensor", "enviro_air_temp_1", "enviro_air_temp_2"}
+
 // IntelliClimate - Intelliclimate object
 type IntelliClimate struct {
 	*Device     `json:"device"`
@@ -94,7 +98,8 @@ func (ic *IntelliClimate) GetAll() error {
 	return nil
 }
 
-// GetMetrics the device by quering the endpoint passed in
+// GetMetrics the device by quering the endpoint passed in.  Temperatures are converted from
+// the unit the device is configured with into °C
 func (ic *IntelliClimate) GetMetrics() error {
 	endpoint := igMetricsURI + ic.GetID()
 	msi, err := ic.client.get(endpoint)
@@ -108,12 +113,41 @@ func (ic *IntelliClimate) GetMetrics() error {
 		return err
 	}
 
+	// the units are needed to interpret the readings so make sure we have the config
+	if !ic.ValidConfig {
+		if err := ic.GetConfig(); err != nil {
+			return err
+		}
+	}
+
 	ic.LastUpdated = updated
+	ic.updateConnected(msi)
+	devUnits := ic.Units()
+	for _, field := range climateTempFields {
+		if temp, ok := metrics[field].(float64); ok {
+			metrics[field] = devUnits.TemperatureTo(temp, units.Metric)
+		}
+	}
 	ic.Readings = metrics
 
 	return updateStruct(metrics, ic.Metrics)
 }
 
+// Units returns the unit system the device is configured to use, falling back to metric if
+// the config has not been fetched or the units are not recognised
+func (ic *IntelliClimate) Units() units.System {
+	t, err := units.ParseTemperatureUnit(ic.Config.Units.Temperature)
+	if err != nil {
+		return units.Metric
+	}
+	return units.System{Temperature: t, Conductivity: units.EC}
+}
+
+// AirTempIn returns the last air temperature reading converted to the given unit
+func (ic *IntelliClimate) AirTempIn(u units.TemperatureUnit) float64 {
+	return units.ConvertTemperature(ic.Metrics.AirTemp, units.Celsius, u)
+}
+
 // GetConfig - this pulls both the config and state from the device endpoint
 func (ic *IntelliClimate) GetConfig() error {
 	endpoint := igConfigURI + ic.GetID()
@@ -182,13 +216,26 @@ func (ic *IntelliClimate) GetConfigState() error {
 	return nil
 }
 
-// GetHistory the device by quering the history endpont for the time period specified
+// GetHistory the device by quering the history endpont for the time period specified.  Air
+// temperatures, including those of the second enviro sensor, are converted from the unit the
+// device is configured with into °C
 func (ic *IntelliClimate) GetHistory(to, from time.Time, points int) error {
 	msi, err := getHistory(ic.client, ic.GetID(), to, from, points)
 	if err != nil {
 		return err
 	}
-	return updateStruct(msi, ic.History)
+
+	if err := updateStruct(msi, ic.History); err != nil {
+		return err
+	}
+
+	devUnits := ic.Units()
+	for _, p := range ic.History.Points {
+		p.Metrics.AirTemp = devUnits.TemperatureTo(p.Metrics.AirTemp, units.Metric)
+		p.Metrics.EnviroAirTemp2 = devUnits.TemperatureTo(p.Metrics.EnviroAirTemp2, units.Metric)
+	}
+
+	return nil
 }
 
 // StatePayload builds and returns the state payload for updating a devices state or config
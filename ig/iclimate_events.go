@@ -0,0 +1,304 @@
+package ig
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ClimateEventType is whether an event from the mode/alarm history is an alarm or a mode change
+type ClimateEventType string
+
+const (
+	// AlarmEvent - an alarm raised by the controller
+	AlarmEvent ClimateEventType = "alarm"
+	// ModeEvent - a change in what the controller is doing
+	ModeEvent ClimateEventType = "mode"
+)
+
+// ClimateEventKind classifies the description of an event, it is UnknownEvent when the
+// description isn't recognised
+type ClimateEventKind string
+
+const (
+	// UnknownEvent - the description of the event isn't recognised
+	UnknownEvent ClimateEventKind = "unknown"
+
+	// HighTempAlarm - the air temperature is above the alarm limit
+	HighTempAlarm ClimateEventKind = "high_temp"
+	// LowTempAlarm - the air temperature is below the alarm limit
+	LowTempAlarm ClimateEventKind = "low_temp"
+	// HighRHAlarm - the humidity is above the alarm limit
+	HighRHAlarm ClimateEventKind = "high_rh"
+	// LowRHAlarm - the humidity is below the alarm limit
+	LowRHAlarm ClimateEventKind = "low_rh"
+	// HighCO2Alarm - the CO2 is above the alarm limit
+	HighCO2Alarm ClimateEventKind = "high_co2"
+	// LowCO2Alarm - the CO2 is below the alarm limit
+	LowCO2Alarm ClimateEventKind = "low_co2"
+	// LowLightAlarm - the light is below the alarm limit while the lights should be on
+	LowLightAlarm ClimateEventKind = "low_light"
+	// PowerFailAlarm - the controller lost power
+	PowerFailAlarm ClimateEventKind = "power_fail"
+	// FailSafeAlarm - a fail safe was triggered
+	FailSafeAlarm ClimateEventKind = "fail_safe"
+	// IntruderAlarm - the intruder input was triggered
+	IntruderAlarm ClimateEventKind = "intruder"
+	// SensorAlarm - a sensor has failed or isn't responding
+	SensorAlarm ClimateEventKind = "sensor"
+
+	// HeatingMode - the controller started heating
+	HeatingMode ClimateEventKind = "heating"
+	// CoolingMode - the controller started cooling
+	CoolingMode ClimateEventKind = "cooling"
+	// DehumidifyingMode - the controller started dehumidifying
+	DehumidifyingMode ClimateEventKind = "dehumidifying"
+	// HumidifyingMode - the controller started humidifying
+	HumidifyingMode ClimateEventKind = "humidifying"
+	// VentingMode - the controller started venting
+	VentingMode ClimateEventKind = "venting"
+	// CO2DosingMode - the controller started injecting CO2
+	CO2DosingMode ClimateEventKind = "co2_dosing"
+	// LightsOnMode - the lights turned on
+	LightsOnMode ClimateEventKind = "lights_on"
+	// LightsOffMode - the lights turned off
+	LightsOffMode ClimateEventKind = "lights_off"
+	// DayMode - the controller switched to its day setpoints
+	DayMode ClimateEventKind = "day"
+	// NightMode - the controller switched to its night setpoints
+	NightMode ClimateEventKind = "night"
+)
+
+// eventRule classifies a description that contains all of the words, and any of the alternatives
+// if there are some
+type eventRule struct {
+	kind  ClimateEventKind
+	words []string
+	any   []string
+}
+
+var (
+	highWords = []string{"high", "above", "over", "max"}
+	lowWords  = []string{"low", "below", "under", "min"}
+)
+
+// alarmRules are checked in order, so more specific rules come first
+var alarmRules = []eventRule{
+	{PowerFailAlarm, []string{"power"}, nil},
+	{FailSafeAlarm, []string{"fail"}, []string{"safe"}},
+	{IntruderAlarm, []string{"intruder"}, nil},
+	{SensorAlarm, []string{"sensor"}, []string{"fail", "fault", "error", "missing", "lost", "no "}},
+	{HighTempAlarm, []string{"temp"}, highWords},
+	{HighTempAlarm, []string{"heat"}, highWords},
+	{LowTempAlarm, []string{"temp"}, lowWords},
+	{HighRHAlarm, []string{"rh"}, highWords},
+	{HighRHAlarm, []string{"humid"}, highWords},
+	{LowRHAlarm, []string{"rh"}, lowWords},
+	{LowRHAlarm, []string{"humid"}, lowWords},
+	{HighCO2Alarm, []string{"co2"}, highWords},
+	{LowCO2Alarm, []string{"co2"}, lowWords},
+	{LowLightAlarm, []string{"light"}, lowWords},
+}
+
+var modeRules = []eventRule{
+	{DehumidifyingMode, []string{"dehumid"}, nil},
+	{HumidifyingMode, []string{"humidif"}, nil},
+	{HeatingMode, []string{"heat"}, nil},
+	{CoolingMode, []string{"cool"}, nil},
+	{VentingMode, []string{"vent"}, nil},
+	{CO2DosingMode, []string{"co2"}, nil},
+	{LightsOffMode, []string{"light", "off"}, nil},
+	{LightsOnMode, []string{"light", "on"}, nil},
+	{DayMode, []string{"day"}, nil},
+	{NightMode, []string{"night"}, nil},
+}
+
+func classifyEvent(rules []eventRule, desc string) ClimateEventKind {
+	desc = strings.ToLower(desc)
+
+	contains := func(words []string) bool {
+		for _, w := range words {
+			if !strings.Contains(desc, w) {
+				return false
+			}
+		}
+		return true
+	}
+
+	for _, rule := range rules {
+		if !contains(rule.words) {
+			continue
+		}
+
+		if len(rule.any) == 0 {
+			return rule.kind
+		}
+
+		for _, w := range rule.any {
+			if strings.Contains(desc, w) {
+				return rule.kind
+			}
+		}
+	}
+
+	return UnknownEvent
+}
+
+// ClimateEvent is an entry in the mode/alarm history of an IntelliClimate
+type ClimateEvent struct {
+	Device      string           `json:"device"`
+	Type        ClimateEventType `json:"type"`
+	Kind        ClimateEventKind `json:"kind"`
+	Description string           `json:"description"`
+	// Time is when the event happened in the time zone of the device, it is zero if the
+	// timestamp couldn't be parsed
+	Time time.Time `json:"time"`
+	// Timestamp is the timestamp as given by the device
+	Timestamp string `json:"timestamp"`
+}
+
+// String describes the event
+func (ev ClimateEvent) String() string {
+	return fmt.Sprintf("%s %s %s: %s", ev.Time.Format(time.RFC3339), ev.Device, ev.Type, ev.Description)
+}
+
+func (ev ClimateEvent) key() string {
+	return string(ev.Type) + "|" + ev.Timestamp + "|" + ev.Description
+}
+
+// eventTimeLayouts are the layouts of timestamps without a time zone, which are taken to be in
+// the time zone of the device.  Dates are day first as the controllers give them.
+var eventTimeLayouts = []string{
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"02/01/2006 15:04:05",
+	"02/01/2006 15:04",
+	"15:04:05 02/01/2006",
+	"15:04 02/01/2006",
+	"02-01-2006 15:04:05",
+	"02-01-2006 15:04",
+}
+
+// parseEventTime parses the timestamp of an event, which may be an epoch in seconds or
+// milliseconds, an RFC 3339 time or a local time in the time zone of the device
+func parseEventTime(s string, loc *time.Location) (time.Time, error) {
+	s = strings.TrimSpace(s)
+
+	if epoch, err := strconv.ParseFloat(s, 64); err == nil {
+		// anything after 1973 in milliseconds is larger than any sensible time in seconds
+		if epoch > 1e11 {
+			return msToTime(epoch).In(loc), nil
+		}
+		return time.Unix(int64(epoch), 0).In(loc), nil
+	}
+
+	if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+		return t.In(loc), nil
+	}
+
+	for _, layout := range eventTimeLayouts {
+		if t, err := time.ParseInLocation(layout, s, loc); err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("unknown timestamp format %q", s)
+}
+
+// Events returns the entries of the mode/alarm history fetched with GetState, oldest first.
+// Events with timestamps that can't be parsed have a zero time and come first.
+func (ic *IntelliClimate) Events() []ClimateEvent {
+	loc := ic.Location()
+	hist := ic.Status.ModeAlarmHistory
+	events := []ClimateEvent{}
+
+	add := func(typ ClimateEventType, rules []eventRule, desc, ts string) {
+		t, _ := parseEventTime(ts, loc)
+		events = append(events, ClimateEvent{
+			Device:      ic.GetID(),
+			Type:        typ,
+			Kind:        classifyEvent(rules, desc),
+			Description: desc,
+			Time:        t,
+			Timestamp:   ts,
+		})
+	}
+
+	for _, a := range hist.Alarms {
+		add(AlarmEvent, alarmRules, a.Description, a.Timestamp)
+	}
+
+	for _, m := range hist.Mode {
+		add(ModeEvent, modeRules, m.Description, m.Timestamp)
+	}
+
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].Time.Before(events[j].Time)
+	})
+
+	return events
+}
+
+// ClimateEventFeed gives the events in the mode/alarm history of an IntelliClimate that are new
+// since it was last polled
+type ClimateEventFeed struct {
+	ic    *IntelliClimate
+	since time.Time
+	seen  map[string]time.Time
+	mu    sync.Mutex
+}
+
+// EventFeed returns a feed of the events of the IntelliClimate from the given time, which can be
+// zero to start with every event in the history
+func (ic *IntelliClimate) EventFeed(since time.Time) *ClimateEventFeed {
+	return &ClimateEventFeed{ic: ic, since: since, seen: map[string]time.Time{}}
+}
+
+// Poll fetches the state of the device and returns the events that haven't been returned before,
+// oldest first
+func (f *ClimateEventFeed) Poll() ([]ClimateEvent, error) {
+	if err := f.ic.GetState(); err != nil {
+		return nil, err
+	}
+
+	return f.Next(), nil
+}
+
+// Next returns the events in the history last fetched that haven't been returned before, oldest
+// first.  An event that shows up late is still returned even if newer events have been.
+func (f *ClimateEventFeed) Next() []ClimateEvent {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	events := f.ic.Events()
+	fresh := []ClimateEvent{}
+	var oldest time.Time
+
+	for _, ev := range events {
+		if !ev.Time.IsZero() && (oldest.IsZero() || ev.Time.Before(oldest)) {
+			oldest = ev.Time
+		}
+
+		key := ev.key()
+		if _, ok := f.seen[key]; ok || (!ev.Time.IsZero() && ev.Time.Before(f.since)) {
+			continue
+		}
+
+		f.seen[key] = ev.Time
+		fresh = append(fresh, ev)
+	}
+
+	// forget events that have dropped out of the history, which the device keeps a limited
+	// amount of, so they can't come back
+	for key, t := range f.seen {
+		if !t.IsZero() && t.Before(oldest) {
+			delete(f.seen, key)
+		}
+	}
+
+	return fresh
+}
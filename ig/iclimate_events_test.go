@@ -0,0 +1,161 @@
+package ig
+
+import (
+	"testing"
+	"time"
+
+	"github.com/autogrow/go-jelly/ig/datastructs"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestParseEventTime(t *testing.T) {
+	loc := time.FixedZone("UTC+10", 10*3600)
+	want := time.Date(2018, 6, 4, 0, 0, 0, 0, time.UTC)
+
+	Convey("it should parse the timestamps of events", t, func() {
+		for _, ts := range []string{
+			"1528070400",
+			"1528070400000",
+			" 1528070400000 ",
+			"2018-06-04T10:00:00+10:00",
+			"2018-06-04T00:00:00Z",
+			"2018-06-04T10:00:00",
+			"2018-06-04 10:00:00",
+			"2018-06-04 10:00",
+			"04/06/2018 10:00:00",
+			"04/06/2018 10:00",
+			"10:00:00 04/06/2018",
+			"10:00 04/06/2018",
+			"04-06-2018 10:00",
+		} {
+			at, err := parseEventTime(ts, loc)
+			So(err, ShouldBeNil)
+			So(at.Equal(want), ShouldBeTrue)
+			So(at.Location(), ShouldEqual, loc)
+		}
+	})
+
+	Convey("it should not parse unknown timestamps", t, func() {
+		for _, ts := range []string{"", "yesterday", "06/2018", "2018-06-04 25:00"} {
+			_, err := parseEventTime(ts, loc)
+			So(err, ShouldNotBeNil)
+		}
+	})
+}
+
+func TestClassifyEvent(t *testing.T) {
+	Convey("it should classify alarms from their description", t, func() {
+		for _, tc := range []struct {
+			desc string
+			want ClimateEventKind
+		}{
+			{"High Temp Alarm", HighTempAlarm},
+			{"Temperature below minimum", LowTempAlarm},
+			{"Overheat", HighTempAlarm},
+			{"RH Too High", HighRHAlarm},
+			{"Low Humidity", LowRHAlarm},
+			{"CO2 Over Limit", HighCO2Alarm},
+			{"CO2 low", LowCO2Alarm},
+			{"Low light", LowLightAlarm},
+			{"Power Failure", PowerFailAlarm},
+			{"Fail Safe Triggered", FailSafeAlarm},
+			{"Intruder!", IntruderAlarm},
+			{"Sensor 2 Fault", SensorAlarm},
+			{"No sensor", SensorAlarm},
+			{"Sensor OK", UnknownEvent},
+			{"Something odd", UnknownEvent},
+		} {
+			So(classifyEvent(alarmRules, tc.desc), ShouldEqual, tc.want)
+		}
+	})
+
+	Convey("it should classify mode changes from their description", t, func() {
+		for _, tc := range []struct {
+			desc string
+			want ClimateEventKind
+		}{
+			{"Dehumidifying", DehumidifyingMode},
+			{"Humidifier On", HumidifyingMode},
+			{"Heating", HeatingMode},
+			{"Cooling On", CoolingMode},
+			{"Venting", VentingMode},
+			{"CO2 Injection", CO2DosingMode},
+			{"Lights Off", LightsOffMode},
+			{"Lights On", LightsOnMode},
+			{"Day Mode", DayMode},
+			{"Night", NightMode},
+			{"Idle", UnknownEvent},
+		} {
+			So(classifyEvent(modeRules, tc.desc), ShouldEqual, tc.want)
+		}
+	})
+}
+
+func TestClimateEvents(t *testing.T) {
+	Convey("given an IntelliClimate with a mode/alarm history", t, func() {
+		ic := NewIntelliClimate(&Device{ID: "IC1"})
+		hist := &ic.Status.ModeAlarmHistory
+		hist.Alarms = []datastructs.AlarmsIClimate{
+			{Description: "High Temp Alarm", Timestamp: "2018-06-04 10:05:00"},
+			{Description: "Power Failure", Timestamp: "sometime"},
+		}
+		hist.Mode = []datastructs.ModeIClimate{
+			{Description: "Cooling On", Timestamp: "2018-06-04 10:00:00"},
+		}
+
+		Convey("it should give the events oldest first", func() {
+			events := ic.Events()
+			So(len(events), ShouldEqual, 3)
+
+			So(events[0].Kind, ShouldEqual, PowerFailAlarm)
+			So(events[0].Time.IsZero(), ShouldBeTrue)
+			So(events[0].Timestamp, ShouldEqual, "sometime")
+
+			So(events[1].Type, ShouldEqual, ModeEvent)
+			So(events[1].Kind, ShouldEqual, CoolingMode)
+			So(events[1].Device, ShouldEqual, "IC1")
+			So(events[1].Time, ShouldEqual, time.Date(2018, 6, 4, 10, 0, 0, 0, time.UTC))
+
+			So(events[2].Type, ShouldEqual, AlarmEvent)
+			So(events[2].Kind, ShouldEqual, HighTempAlarm)
+		})
+
+		Convey("given a feed of the events", func() {
+			feed := ic.EventFeed(time.Time{})
+			So(len(feed.Next()), ShouldEqual, 3)
+
+			Convey("it should not give the same events again", func() {
+				So(feed.Next(), ShouldBeEmpty)
+			})
+
+			Convey("it should give new events, even ones older than events already given", func() {
+				hist.Alarms = append(hist.Alarms,
+					datastructs.AlarmsIClimate{Description: "Low light", Timestamp: "2018-06-04 10:10:00"},
+					datastructs.AlarmsIClimate{Description: "CO2 low", Timestamp: "2018-06-04 10:01:00"},
+				)
+
+				events := feed.Next()
+				So(len(events), ShouldEqual, 2)
+				So(events[0].Kind, ShouldEqual, LowCO2Alarm)
+				So(events[1].Kind, ShouldEqual, LowLightAlarm)
+				So(feed.Next(), ShouldBeEmpty)
+			})
+
+			Convey("it should forget events that have dropped out of the history", func() {
+				hist.Mode = nil
+				So(feed.Next(), ShouldBeEmpty)
+				So(len(feed.seen), ShouldEqual, 2)
+				_, ok := feed.seen[ClimateEvent{Type: AlarmEvent, Timestamp: "sometime", Description: "Power Failure"}.key()]
+				So(ok, ShouldBeTrue)
+			})
+		})
+
+		Convey("a feed from a time should leave out older events", func() {
+			feed := ic.EventFeed(time.Date(2018, 6, 4, 10, 3, 0, 0, time.UTC))
+			events := feed.Next()
+			So(len(events), ShouldEqual, 2)
+			So(events[0].Kind, ShouldEqual, PowerFailAlarm)
+			So(events[1].Kind, ShouldEqual, HighTempAlarm)
+		})
+	})
+}
@@ -1,28 +1,72 @@
 package ig
 
-import "fmt"
+import (
+	"math"
 
-// SetTempTarget will set the temperature that the room should be kept to
+	"github.com/autogrow/go-jelly/ig/datastructs"
+	"github.com/autogrow/go-jelly/units"
+)
+
+// SetTempTarget will set the temperature that the room should be kept to during the day, the
+// target is given in the temperature unit chosen on the client
 func (ic *IntelliClimate) SetTempTarget(target float64) error {
-	return fmt.Errorf("not implemented")
+	return ic.SetTempTargetIn(target, ic.DisplayUnits().Temperature)
+}
+
+// SetTempTargetIn will set the temperature that the room should be kept to during the day, the
+// target is given in the unit specified and converted to the unit the device is configured with
+func (ic *IntelliClimate) SetTempTargetIn(target float64, unit units.TemperatureUnit) error {
+	return ic.tx.guard(ic, func() {
+		devTarget := units.ConvertTemperature(target, unit, ic.Units().Temperature)
+		for num := range ic.Status.SetPoints {
+			ic.Status.SetPoints[num].DayTemp = devTarget
+		}
+	})
 }
 
-// SetCO2Target will set the CO2 levels in PPM that the room should be kept to
+// SetCO2Target will set the CO2 levels in PPM that the room should be kept to.  The device keeps
+// whole numbers so the target is rounded.
 func (ic *IntelliClimate) SetCO2Target(target float64) error {
-	return fmt.Errorf("not implemented")
+	return ic.tx.guard(ic, func() {
+		for num := range ic.Status.SetPoints {
+			ic.Status.SetPoints[num].CO2 = int(math.Round(target))
+		}
+	})
 }
 
-// SetRHTarget will set the RH target that the room should be kept to
+// SetRHTarget will set the RH target that the room should be kept to during the day.  The device
+// keeps whole numbers so the target is rounded.
 func (ic *IntelliClimate) SetRHTarget(target float64) error {
-	return fmt.Errorf("not implemented")
+	return ic.tx.guard(ic, func() {
+		for num := range ic.Status.SetPoints {
+			ic.Status.SetPoints[num].RhDay = int(math.Round(target))
+		}
+	})
 }
 
 // EnableCO2Dosing will enable the CO2 dosing
 func (ic *IntelliClimate) EnableCO2Dosing() error {
-	return fmt.Errorf("not implemented")
+	return ic.tx.guard(ic, func() {
+		ic.Config.Functions.Co2Injection = true
+	})
 }
 
 // DisableCO2Dosing will disable the CO2 dosing
 func (ic *IntelliClimate) DisableCO2Dosing() error {
-	return fmt.Errorf("not implemented")
+	return ic.tx.guard(ic, func() {
+		ic.Config.Functions.Co2Injection = false
+	})
+}
+
+// RestoreConfig will replace the config on the controller with a saved config, such as the
+// output of dumping the device as JSON
+func (ic *IntelliClimate) RestoreConfig(data []byte) error {
+	cfg := &datastructs.ConfigIClimate{}
+	if err := cfg.ParseSaved(data); err != nil {
+		return err
+	}
+
+	return ic.tx.guard(ic, func() {
+		ic.Config = cfg
+	})
 }
@@ -0,0 +1,34 @@
+package ig
+
+import (
+	"testing"
+
+	"github.com/autogrow/go-jelly/ig/datastructs"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestIntelliClimateTargets(t *testing.T) {
+	Convey("given an IntelliClimate in a transaction", t, func() {
+		ic := NewIntelliClimate(&Device{ID: "IC1"})
+		ic.Status.SetPoints = []datastructs.SetPointIClimate{{}, {}}
+		ic.tx.running = true
+
+		Convey("it should round the RH and CO2 targets to whole numbers", func() {
+			for _, tc := range []struct {
+				target float64
+				want   int
+			}{
+				{59.9, 60},
+				{60.4, 60},
+				{1199.5, 1200},
+			} {
+				So(ic.SetRHTarget(tc.target), ShouldBeNil)
+				So(ic.SetCO2Target(tc.target), ShouldBeNil)
+				for _, sp := range ic.Status.SetPoints {
+					So(sp.RhDay, ShouldEqual, tc.want)
+					So(sp.CO2, ShouldEqual, tc.want)
+				}
+			}
+		})
+	})
+}
@@ -0,0 +1,259 @@
+package ig
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/autogrow/go-jelly/ig/datastructs"
+)
+
+const (
+	// VegPhotoperiod - hours of light per day for vegetative growth (18/6)
+	VegPhotoperiod = 18 * time.Hour
+	// FlowerPhotoperiod - hours of light per day for flowering (12/12)
+	FlowerPhotoperiod = 12 * time.Hour
+
+	fullDay = 24 * time.Hour
+)
+
+// LightSchedule is the photoperiod of a light bank, the device keeps the on time and duration in
+// minutes
+type LightSchedule struct {
+	Bank int `json:"bank"`
+	// On is the time of day that the lights turn on
+	On time.Duration `json:"on"`
+	// Duration is how long the lights stay on for
+	Duration time.Duration `json:"duration"`
+	// Enabled is true if the light bank is enabled on the controller
+	Enabled bool `json:"enabled"`
+}
+
+// Off returns the time of day that the lights turn off
+func (ls LightSchedule) Off() time.Duration {
+	return (ls.On + ls.Duration) % fullDay
+}
+
+// Validate returns an error if the on time isn't a time of day, or the duration is more than a
+// day
+func (ls LightSchedule) Validate() error {
+	switch {
+	case ls.On < 0 || ls.On >= fullDay:
+		return fmt.Errorf("light bank %d: on time must be between 00:00 and 23:59", ls.Bank)
+	case ls.Duration < 0 || ls.Duration > fullDay:
+		return fmt.Errorf("light bank %d: duration must be between 0 and 24h", ls.Bank)
+	case ls.On%time.Minute != 0 || ls.Duration%time.Minute != 0:
+		return fmt.Errorf("light bank %d: times must be whole minutes", ls.Bank)
+	}
+	return nil
+}
+
+// String describes the schedule, such as "06:00-00:00 (18h/6h)"
+func (ls LightSchedule) String() string {
+	clock := func(d time.Duration) string {
+		return fmt.Sprintf("%02d:%02d", int(d.Hours()), int(d.Minutes())%60)
+	}
+	return fmt.Sprintf("%s-%s (%s/%s)", clock(ls.On), clock(ls.Off()), formatHours(ls.Duration), formatHours(fullDay-ls.Duration))
+}
+
+func formatHours(d time.Duration) string {
+	return strconv.FormatFloat(d.Hours(), 'f', -1, 64) + "h"
+}
+
+// LightsOn returns true if the lights are scheduled to be on at the given time, on the clock of
+// the location of t
+func (ls LightSchedule) LightsOn(t time.Time) bool {
+	return ls.DaySchedule(t.Location()).IsDay(t)
+}
+
+// NextTransition returns the next time after t, in the location of t, that the lights turn on
+// or off and whether they are on after it.  The time is zero if the lights are always on or off.
+func (ls LightSchedule) NextTransition(t time.Time) (time.Time, bool) {
+	return ls.DaySchedule(t.Location()).NextTransition(t)
+}
+
+// DaySchedule returns the photoperiod as a day period in the given time zone
+func (ls LightSchedule) DaySchedule(loc *time.Location) datastructs.DaySchedule {
+	on := datastructs.TimeOfDay(ls.On / time.Minute)
+	return datastructs.DaySchedule{
+		Start:    on,
+		End:      on + datastructs.TimeOfDay(ls.Duration/time.Minute),
+		Location: loc,
+	}
+}
+
+// lightBankNumber returns the number of the bank from the light bank field of a setpoint, which
+// may be given as "1" or "Light Bank 1"
+func lightBankNumber(s string, idx int) int {
+	s = strings.TrimSpace(s)
+	i := len(s)
+	for i > 0 && s[i-1] >= '0' && s[i-1] <= '9' {
+		i--
+	}
+
+	if n, err := strconv.Atoi(s[i:]); err == nil {
+		return n
+	}
+
+	return idx + 1
+}
+
+func (ic *IntelliClimate) lightBankEnabled(bank int) bool {
+	switch bank {
+	case 1:
+		return ic.Config.Functions.LightBank1
+	case 2:
+		return ic.Config.Functions.LightBank2
+	}
+	return false
+}
+
+// LightSchedules returns the photoperiod of each light bank
+func (ic *IntelliClimate) LightSchedules() []LightSchedule {
+	schedules := []LightSchedule{}
+	for i, sp := range ic.Status.SetPoints {
+		bank := lightBankNumber(sp.LightBank, i)
+		schedules = append(schedules, LightSchedule{
+			Bank:     bank,
+			On:       time.Duration(sp.LightOn) * time.Minute,
+			Duration: time.Duration(sp.LightDuration) * time.Minute,
+			Enabled:  ic.lightBankEnabled(bank),
+		})
+	}
+	return schedules
+}
+
+// LightSchedule returns the photoperiod of the given light bank (1 or 2)
+func (ic *IntelliClimate) LightSchedule(bank int) (LightSchedule, error) {
+	for _, ls := range ic.LightSchedules() {
+		if ls.Bank == bank {
+			return ls, nil
+		}
+	}
+	return LightSchedule{}, fmt.Errorf("no light bank %d on %s", bank, ic.GetID())
+}
+
+// SetLightSchedule will set the time of day the lights of the given bank turn on and how long
+// they stay on for
+func (ic *IntelliClimate) SetLightSchedule(bank int, on, duration time.Duration) error {
+	ls := LightSchedule{Bank: bank, On: on, Duration: duration}
+	if err := ls.Validate(); err != nil {
+		return err
+	}
+
+	return ic.tx.update(ic, func() error {
+		err := fmt.Errorf("no light bank %d on %s", bank, ic.GetID())
+		for num, sp := range ic.Status.SetPoints {
+			if lightBankNumber(sp.LightBank, num) == bank {
+				ic.Status.SetPoints[num].LightOn = int(on / time.Minute)
+				ic.Status.SetPoints[num].LightDuration = int(duration / time.Minute)
+				err = nil
+			}
+		}
+		return err
+	})
+}
+
+// SetPhotoperiod will set how long the lights of every bank stay on for, keeping the time they
+// turn on
+func (ic *IntelliClimate) SetPhotoperiod(duration time.Duration) error {
+	if err := (LightSchedule{Duration: duration}).Validate(); err != nil {
+		return err
+	}
+
+	return ic.tx.guard(ic, func() {
+		for num := range ic.Status.SetPoints {
+			ic.Status.SetPoints[num].LightDuration = int(duration / time.Minute)
+		}
+	})
+}
+
+// SwitchToVeg will set the lights of every bank to an 18/6 photoperiod
+func (ic *IntelliClimate) SwitchToVeg() error {
+	return ic.SetPhotoperiod(VegPhotoperiod)
+}
+
+// SwitchToFlower will set the lights of every bank to a 12/12 photoperiod
+func (ic *IntelliClimate) SwitchToFlower() error {
+	return ic.SetPhotoperiod(FlowerPhotoperiod)
+}
+
+// EnableLightBank will enable the given light bank (1 or 2)
+func (ic *IntelliClimate) EnableLightBank(bank int) error {
+	return ic.setLightBankEnabled(bank, true)
+}
+
+// DisableLightBank will disable the given light bank (1 or 2)
+func (ic *IntelliClimate) DisableLightBank(bank int) error {
+	return ic.setLightBankEnabled(bank, false)
+}
+
+func (ic *IntelliClimate) setLightBankEnabled(bank int, enabled bool) error {
+	if bank != 1 && bank != 2 {
+		return fmt.Errorf("no light bank %d, it must be 1 or 2", bank)
+	}
+
+	return ic.tx.guard(ic, func() {
+		if bank == 1 {
+			ic.Config.Functions.LightBank1 = enabled
+		} else {
+			ic.Config.Functions.LightBank2 = enabled
+		}
+	})
+}
+
+// NextLightTransition returns the next time after now that the lights of the given bank turn on
+// or off, in the time zone of the device, and whether they are on after it.  The time is zero if
+// the lights are always on or off.
+func (ic *IntelliClimate) NextLightTransition(bank int, now time.Time) (time.Time, bool, error) {
+	ls, err := ic.LightSchedule(bank)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+
+	next, on := ls.NextTransition(now.In(ic.Location()))
+	return next, on, nil
+}
+
+// DaySchedule returns the day period of the device, which follows the photoperiod of the first
+// light bank, in the time zone of the device
+func (ic *IntelliClimate) DaySchedule() (datastructs.DaySchedule, error) {
+	ls, err := ic.LightSchedule(1)
+	if err != nil {
+		return datastructs.DaySchedule{}, err
+	}
+	return ls.DaySchedule(ic.Location()), nil
+}
+
+// SetPhotoperiod will set how long the lights of every IntelliClimate in the growroom stay on for
+func (g *Growroom) SetPhotoperiod(duration time.Duration) error {
+	ics, _ := g.IntelliClimates()
+	if len(ics) == 0 {
+		return fmt.Errorf("no IntelliClimates in %s", g.GetName())
+	}
+
+	errs := []string{}
+	for _, ic := range ics {
+		if err := ic.SetPhotoperiod(duration); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %s", ic.GetID(), err))
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+
+	return nil
+}
+
+// SwitchToVeg will set the lights of every IntelliClimate in the growroom to an 18/6 photoperiod
+func (g *Growroom) SwitchToVeg() error {
+	return g.SetPhotoperiod(VegPhotoperiod)
+}
+
+// SwitchToFlower will set the lights of every IntelliClimate in the growroom to a 12/12
+// photoperiod
+func (g *Growroom) SwitchToFlower() error {
+	return g.SetPhotoperiod(FlowerPhotoperiod)
+}
@@ -0,0 +1,147 @@
+package ig
+
+import (
+	"testing"
+	"time"
+
+	"github.com/autogrow/go-jelly/ig/datastructs"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestLightSchedule(t *testing.T) {
+	Convey("given an 18/6 light schedule that turns on at 6pm", t, func() {
+		ls := LightSchedule{Bank: 1, On: 18 * time.Hour, Duration: VegPhotoperiod}
+		at := func(h, m int) time.Time {
+			return time.Date(2018, 6, 1, h, m, 0, 0, time.UTC)
+		}
+
+		Convey("it should turn off at noon", func() {
+			So(ls.Off(), ShouldEqual, 12*time.Hour)
+			So(ls.String(), ShouldEqual, "18:00-12:00 (18h/6h)")
+		})
+
+		Convey("it should be on across midnight", func() {
+			for _, tc := range []struct {
+				at   time.Time
+				want bool
+			}{
+				{at(23, 0), true},
+				{at(3, 0), true},
+				{at(12, 0), false},
+				{at(17, 59), false},
+			} {
+				So(ls.LightsOn(tc.at), ShouldEqual, tc.want)
+			}
+		})
+
+		Convey("it should give the next time the lights turn on or off", func() {
+			for _, tc := range []struct {
+				from, want time.Time
+				on         bool
+			}{
+				{at(3, 0), at(12, 0), false},
+				{at(12, 0), at(18, 0), true},
+				{at(20, 0), at(24+12, 0), false},
+			} {
+				next, on := ls.NextTransition(tc.from)
+				So(next, ShouldEqual, tc.want)
+				So(on, ShouldEqual, tc.on)
+			}
+		})
+
+		Convey("it should convert to a day schedule", func() {
+			ds := ls.DaySchedule(time.UTC)
+			So(ds.Start, ShouldEqual, datastructs.TimeOfDay(18*60))
+			So(ds.DayLength(), ShouldEqual, VegPhotoperiod)
+		})
+	})
+
+	Convey("it should follow the clock when daylight saving starts", t, func() {
+		loc, err := time.LoadLocation("Europe/London")
+		So(err, ShouldBeNil)
+
+		// the clocks go forward at 01:00 UTC on the 25th of March 2018, so the lights turn on at
+		// 06:00 BST which is 05:00 UTC
+		ls := LightSchedule{Bank: 1, On: 6 * time.Hour, Duration: FlowerPhotoperiod}
+		next, on := ls.NextTransition(time.Date(2018, 3, 25, 0, 0, 0, 0, time.UTC).In(loc))
+		So(next.UTC(), ShouldEqual, time.Date(2018, 3, 25, 5, 0, 0, 0, time.UTC))
+		So(on, ShouldBeTrue)
+		So(ls.LightsOn(time.Date(2018, 3, 25, 5, 30, 0, 0, time.UTC).In(loc)), ShouldBeTrue)
+	})
+
+	Convey("it should not give a transition for lights that are always on or off", t, func() {
+		now := time.Date(2018, 6, 1, 12, 0, 0, 0, time.UTC)
+
+		next, on := LightSchedule{Duration: 24 * time.Hour}.NextTransition(now)
+		So(next.IsZero(), ShouldBeTrue)
+		So(on, ShouldBeTrue)
+
+		next, on = LightSchedule{On: 6 * time.Hour}.NextTransition(now)
+		So(next.IsZero(), ShouldBeTrue)
+		So(on, ShouldBeFalse)
+	})
+
+	Convey("it should validate schedules", t, func() {
+		So(LightSchedule{On: 6 * time.Hour, Duration: 18 * time.Hour}.Validate(), ShouldBeNil)
+		So(LightSchedule{Duration: 24 * time.Hour}.Validate(), ShouldBeNil)
+
+		for _, ls := range []LightSchedule{
+			{On: 24 * time.Hour},
+			{On: -time.Minute},
+			{Duration: 25 * time.Hour},
+			{On: 6*time.Hour + 30*time.Second},
+			{Duration: 90 * time.Second},
+		} {
+			So(ls.Validate(), ShouldNotBeNil)
+		}
+	})
+}
+
+func TestIntelliClimateLightSchedules(t *testing.T) {
+	Convey("given an IntelliClimate with two light banks", t, func() {
+		ic := NewIntelliClimate(&Device{ID: "IC1"})
+		ic.Config.Functions.LightBank1 = true
+		ic.Status.SetPoints = []datastructs.SetPointIClimate{
+			{LightBank: "Light Bank 1", LightOn: 360, LightDuration: 720},
+			{LightBank: "2", LightOn: 1080, LightDuration: 1080},
+		}
+
+		Convey("it should give the schedule of each bank", func() {
+			schedules := ic.LightSchedules()
+			So(len(schedules), ShouldEqual, 2)
+			So(schedules[0], ShouldResemble, LightSchedule{Bank: 1, On: 6 * time.Hour, Duration: 12 * time.Hour, Enabled: true})
+			So(schedules[1], ShouldResemble, LightSchedule{Bank: 2, On: 18 * time.Hour, Duration: 18 * time.Hour})
+		})
+
+		Convey("it should not give a bank it doesn't have", func() {
+			_, err := ic.LightSchedule(3)
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("it should follow the first bank for the day schedule", func() {
+			ds, err := ic.DaySchedule()
+			So(err, ShouldBeNil)
+			So(ds.String(), ShouldEqual, "06:00-18:00")
+		})
+
+		Convey("it should not set an invalid schedule", func() {
+			So(ic.SetLightSchedule(1, 25*time.Hour, time.Hour), ShouldNotBeNil)
+			So(ic.SetPhotoperiod(-time.Hour), ShouldNotBeNil)
+			So(ic.EnableLightBank(3), ShouldNotBeNil)
+		})
+	})
+
+	Convey("it should read the bank number from the light bank field", t, func() {
+		for _, tc := range []struct {
+			field string
+			idx   int
+			want  int
+		}{
+			{"Light Bank 2", 0, 2},
+			{" 1 ", 1, 1},
+			{"", 1, 2},
+		} {
+			So(lightBankNumber(tc.field, tc.idx), ShouldEqual, tc.want)
+		}
+	})
+}
@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/autogrow/go-jelly/ig/datastructs"
+	"github.com/autogrow/go-jelly/units"
 )
 
 const (
@@ -18,7 +19,7 @@ const (
 	// IrrigationFunction - string used to identify the status field for irrigation
 	IrrigationFunction = "irrigation"
 	// StationFunction - string used to identify the status field for irrigation station 1
-	StationFunction = "Irrigation Station "
+	StationFunction = datastructs.StationFunctionPrefix
 )
 
 // IntelliDose - IntelliDose object
@@ -97,7 +98,8 @@ func (id *IntelliDose) GetAll() error {
 	return nil
 }
 
-// GetMetrics the device by quering the endpoint passed in
+// GetMetrics the device by quering the endpoint passed in.  The readings are converted from the
+// units the device is configured with into metric (°C and EC in mS/cm)
 func (id *IntelliDose) GetMetrics() error {
 	endpoint := igMetricsURI + id.GetID()
 	msi, err := id.client.get(endpoint)
@@ -111,13 +113,54 @@ func (id *IntelliDose) GetMetrics() error {
 		return err
 	}
 
+	// the units are needed to interpret the readings so make sure we have the config
+	if !id.ValidConfig {
+		if err := id.GetConfig(); err != nil {
+			return err
+		}
+	}
+
 	id.LastUpdated = updated
-	metrics["ec"] = metrics["ec"].(float64) / 100.0
+	id.updateConnected(msi)
+	devUnits := id.Units()
+	if ec, ok := metrics["ec"].(float64); ok {
+		metrics["ec"] = devUnits.ConductivityTo(devUnits.ConductivityFromRaw(ec), units.Metric)
+	}
+	if temp, ok := metrics["nut_temp"].(float64); ok {
+		metrics["nut_temp"] = devUnits.TemperatureTo(temp, units.Metric)
+	}
 	id.Readings = metrics
 
 	return updateStruct(metrics, id.Metrics)
 }
 
+// Units returns the unit system the device is configured to use, falling back to metric if
+// the config has not been fetched or the units are not recognised
+func (id *IntelliDose) Units() units.System {
+	return id.Config.Units.System()
+}
+
+// ECIn returns the last EC reading converted to the given unit
+func (id *IntelliDose) ECIn(u units.ConductivityUnit) float64 {
+	return units.ConvertConductivity(id.Metrics.Ec, units.EC, u)
+}
+
+// NutTempIn returns the last nutrient temperature reading converted to the given unit
+func (id *IntelliDose) NutTempIn(u units.TemperatureUnit) float64 {
+	return units.ConvertTemperature(id.Metrics.NutTemp, units.Celsius, u)
+}
+
+// DaySchedule returns the day period the device is configured with, in the time zone of the
+// device
+func (id *IntelliDose) DaySchedule() datastructs.DaySchedule {
+	return id.Config.Times.Schedule(id.Location())
+}
+
+// IsDayTime returns true if it is currently in the day period of the device
+func (id *IntelliDose) IsDayTime() bool {
+	return id.DaySchedule().IsDay(time.Now())
+}
+
 // GetConfig - this pulls both the config and state from the device endpoint
 func (id *IntelliDose) GetConfig() error {
 	endpoint := igConfigURI + id.GetID()
@@ -187,13 +230,32 @@ func (id *IntelliDose) GetConfigState() error {
 	return nil
 }
 
-// GetHistory the device by quering the history endpont for the time period specified
+// GetHistory the device by quering the history endpont for the time period specified.  The EC
+// and nutrient temperature are converted from the units the device is configured with into
+// metric (°C and EC in mS/cm)
 func (id *IntelliDose) GetHistory(to, from time.Time, points int) error {
 	msi, err := getHistory(id.client, id.GetID(), to, from, points)
 	if err != nil {
 		return err
 	}
-	return updateStruct(msi, id.History)
+
+	if err := updateStruct(msi, id.History); err != nil {
+		return err
+	}
+
+	if !id.ValidConfig {
+		if err := id.GetConfig(); err != nil {
+			return err
+		}
+	}
+
+	devUnits := id.Units()
+	for _, p := range id.History.Points {
+		p.Metrics.EC = devUnits.ConductivityTo(devUnits.ConductivityFromRaw(p.Metrics.EC), units.Metric)
+		p.Metrics.Temp = devUnits.TemperatureTo(p.Metrics.Temp, units.Metric)
+	}
+
+	return nil
 }
 
 // SaveConfigState will save the config and state
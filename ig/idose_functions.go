@@ -1,6 +1,11 @@
 package ig
 
-import "fmt"
+import (
+	"fmt"
+
+	"github.com/autogrow/go-jelly/ig/datastructs"
+	"github.com/autogrow/go-jelly/units"
+)
 
 // ForceNutrientDose will force a nutrient dose on the controller
 func (id *IntelliDose) ForceNutrientDose() error {
@@ -49,12 +54,36 @@ func (id *IntelliDose) ForceStation(stn string) error {
 
 // SetPHTarget will set the target pH the system should dose to
 func (id *IntelliDose) SetPHTarget(target float64) error {
-	return fmt.Errorf("not implemented")
+	return id.tx.guard(id, func() {
+		id.Status.SetPoints.Ph = target
+	})
 }
 
-// SetNutrientTarget will set the target EC the system should dose to
+// SetNutrientTarget will set the target EC the system should dose to, the target is given in
+// the conductivity unit chosen on the client
 func (id *IntelliDose) SetNutrientTarget(target float64) error {
-	return fmt.Errorf("not implemented")
+	return id.SetNutrientTargetIn(target, id.DisplayUnits().Conductivity)
+}
+
+// SetNutrientTargetIn will set the target EC the system should dose to, the target is given in
+// the unit specified and converted to the unit the device is configured with
+func (id *IntelliDose) SetNutrientTargetIn(target float64, unit units.ConductivityUnit) error {
+	return id.tx.guard(id, func() {
+		id.Status.SetPoints.Nutrient = units.ConvertConductivity(target, unit, id.Units().Conductivity)
+	})
+}
+
+// RestoreConfig will replace the config on the controller with a saved config, such as the
+// output of dumping the device as JSON
+func (id *IntelliDose) RestoreConfig(data []byte) error {
+	cfg := &datastructs.ConfigIDose{}
+	if err := cfg.ParseSaved(data); err != nil {
+		return err
+	}
+
+	return id.tx.guard(id, func() {
+		id.Config = cfg
+	})
 }
 
 // DisableNutrientDosing will disable the nutrient dosing
@@ -0,0 +1,277 @@
+package ig
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/autogrow/go-jelly/ig/datastructs"
+)
+
+// IrrigationMode is how the IntelliDose decides when to irrigate
+type IrrigationMode string
+
+const (
+	// IrrigationOff - irrigation is turned off
+	IrrigationOff IrrigationMode = "off"
+	// IrrigationDayNight - stations irrigate at separate day and night intervals
+	IrrigationDayNight IrrigationMode = "day_night"
+	// IrrigationEvery - stations irrigate at the same interval all day
+	IrrigationEvery IrrigationMode = "every"
+
+	// MaxIrrigationStations - the most irrigation stations an IntelliDose can have
+	MaxIrrigationStations = datastructs.MaxIrrigationStations
+)
+
+// IrrigationStation is the program of an irrigation station.  The device keeps the intervals in
+// minutes and the duration in seconds.
+type IrrigationStation struct {
+	Number  int    `json:"number"`
+	Name    string `json:"name"`
+	Enabled bool   `json:"enabled"`
+	// DayInterval and NightInterval are the time between irrigations in day/night mode
+	DayInterval   time.Duration `json:"day_interval"`
+	NightInterval time.Duration `json:"night_interval"`
+	// Interval is the time between irrigations in every mode
+	Interval time.Duration `json:"interval"`
+	// Duration is how long each irrigation runs for
+	Duration time.Duration `json:"duration"`
+}
+
+func (id *IntelliDose) station(n int) (datastructs.IrrigationStationFields, error) {
+	st, err := datastructs.IrrigationStation(id.Config, id.Status, n)
+	if err != nil {
+		return st, fmt.Errorf("%s: %s", id.GetID(), err)
+	}
+	return st, nil
+}
+
+// IrrigationStationCount returns the number of irrigation stations configured on the device
+func (id *IntelliDose) IrrigationStationCount() int {
+	return id.Config.IrrigationStationCount()
+}
+
+func (id *IntelliDose) stationStatus(n int) (*datastructs.StatusStatusIDose, error) {
+	status, err := datastructs.StationStatus(id.Status, n)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", id.GetID(), err)
+	}
+	return status, nil
+}
+
+func (id *IntelliDose) stationEnabled(n int) bool {
+	status, err := id.stationStatus(n)
+	return err == nil && status.Enabled
+}
+
+// IrrigationStation returns the program of the given irrigation station
+func (id *IntelliDose) IrrigationStation(n int) (IrrigationStation, error) {
+	st, err := id.station(n)
+	if err != nil {
+		return IrrigationStation{}, err
+	}
+
+	return IrrigationStation{
+		Number:        n,
+		Name:          *st.Name,
+		Enabled:       id.stationEnabled(n),
+		DayInterval:   time.Duration(st.Interval.Day) * time.Minute,
+		NightInterval: time.Duration(st.Interval.Night) * time.Minute,
+		Interval:      time.Duration(st.Interval.Every) * time.Minute,
+		Duration:      time.Duration(*st.Duration) * time.Second,
+	}, nil
+}
+
+// IrrigationStations returns the programs of the irrigation stations configured on the device
+func (id *IntelliDose) IrrigationStations() []IrrigationStation {
+	stations := []IrrigationStation{}
+	for n := 1; n <= id.IrrigationStationCount(); n++ {
+		st, err := id.IrrigationStation(n)
+		if err != nil {
+			continue
+		}
+		stations = append(stations, st)
+	}
+	return stations
+}
+
+// IrrigationMode returns how the device decides when to irrigate
+func (id *IntelliDose) IrrigationMode() IrrigationMode {
+	return IrrigationMode(id.Config.Functions.IrrigationMode)
+}
+
+// SetIrrigationMode will set how the device decides when to irrigate
+func (id *IntelliDose) SetIrrigationMode(mode IrrigationMode) error {
+	switch mode {
+	case IrrigationOff, IrrigationDayNight, IrrigationEvery:
+	default:
+		return fmt.Errorf("unknown irrigation mode %q", mode)
+	}
+
+	return id.tx.guard(id, func() {
+		id.Config.Functions.IrrigationMode = string(mode)
+	})
+}
+
+// updateStation validates the station number against the config pulled down by the transaction,
+// and only applies and saves the update if it is valid
+func (id *IntelliDose) updateStation(n int, update func(datastructs.IrrigationStationFields) error) error {
+	return id.tx.update(id, func() error {
+		st, err := id.station(n)
+		if err != nil {
+			return err
+		}
+		return update(st)
+	})
+}
+
+// SetStationName will set the name of the given irrigation station
+func (id *IntelliDose) SetStationName(n int, name string) error {
+	return id.updateStation(n, func(st datastructs.IrrigationStationFields) error {
+		*st.Name = name
+		return nil
+	})
+}
+
+// SetStationIntervals will set the time between irrigations during the day and night for the
+// given station, used in day/night mode
+func (id *IntelliDose) SetStationIntervals(n int, day, night time.Duration) error {
+	if err := datastructs.ValidateIrrigationInterval(day); err != nil {
+		return err
+	}
+
+	if err := datastructs.ValidateIrrigationInterval(night); err != nil {
+		return err
+	}
+
+	return id.updateStation(n, func(st datastructs.IrrigationStationFields) error {
+		st.Interval.Day = int(day / time.Minute)
+		st.Interval.Night = int(night / time.Minute)
+		return nil
+	})
+}
+
+// SetStationInterval will set the time between irrigations for the given station, used in every
+// mode
+func (id *IntelliDose) SetStationInterval(n int, every time.Duration) error {
+	if err := datastructs.ValidateIrrigationInterval(every); err != nil {
+		return err
+	}
+
+	return id.updateStation(n, func(st datastructs.IrrigationStationFields) error {
+		st.Interval.Every = int(every / time.Minute)
+		return nil
+	})
+}
+
+// SetStationDuration will set how long each irrigation of the given station runs for
+func (id *IntelliDose) SetStationDuration(n int, d time.Duration) error {
+	if err := datastructs.ValidateIrrigationDuration(d); err != nil {
+		return err
+	}
+
+	return id.updateStation(n, func(st datastructs.IrrigationStationFields) error {
+		*st.Duration = int(d / time.Second)
+		return nil
+	})
+}
+
+// EnableStation will enable the given irrigation station
+func (id *IntelliDose) EnableStation(n int) error {
+	return id.setStationEnabled(n, true)
+}
+
+// DisableStation will disable the given irrigation station
+func (id *IntelliDose) DisableStation(n int) error {
+	return id.setStationEnabled(n, false)
+}
+
+func (id *IntelliDose) setStationEnabled(n int, enabled bool) error {
+	return id.updateStation(n, func(datastructs.IrrigationStationFields) error {
+		status, err := id.stationStatus(n)
+		if err != nil {
+			return err
+		}
+		status.Enabled = enabled
+		return nil
+	})
+}
+
+// ForceIrrigationStation will force an irrigation on the given station
+func (id *IntelliDose) ForceIrrigationStation(n int) error {
+	return id.updateStation(n, func(datastructs.IrrigationStationFields) error {
+		status, err := id.stationStatus(n)
+		if err != nil {
+			return err
+		}
+		status.ForceOn = true
+		return nil
+	})
+}
+
+// NextIrrigation returns the next time after now that the given station is expected to irrigate,
+// in the time zone of the device.  Irrigations are assumed to start at the beginning of the day
+// or night, or at midnight in every mode, and repeat at the interval.  The time is zero if the
+// station isn't irrigating.
+func (id *IntelliDose) NextIrrigation(n int, now time.Time) (time.Time, error) {
+	st, err := id.IrrigationStation(n)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if !st.Enabled {
+		return time.Time{}, nil
+	}
+
+	now = now.In(id.Location())
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+
+	switch id.IrrigationMode() {
+	case IrrigationEvery:
+		if st.Interval <= 0 {
+			return time.Time{}, nil
+		}
+		return midnight.Add((now.Sub(midnight)/st.Interval + 1) * st.Interval), nil
+
+	case IrrigationDayNight:
+		return id.nextDayNightIrrigation(st, midnight, now), nil
+	}
+
+	return time.Time{}, nil
+}
+
+// nextDayNightIrrigation steps through the irrigations of each day and night period from the
+// start of the previous day until one is found after now
+func (id *IntelliDose) nextDayNightIrrigation(st IrrigationStation, midnight, now time.Time) time.Time {
+	if st.DayInterval <= 0 && st.NightInterval <= 0 {
+		return time.Time{}
+	}
+
+	ds := id.DaySchedule()
+	dayLength := ds.DayLength()
+	nightLength := fullDay - dayLength
+
+	for d := -1; d <= 1; d++ {
+		dayStart := time.Date(midnight.Year(), midnight.Month(), midnight.Day()+d, 0, int(ds.Start), 0, 0, midnight.Location())
+
+		for _, period := range []struct {
+			from     time.Time
+			length   time.Duration
+			interval time.Duration
+		}{
+			{dayStart, dayLength, st.DayInterval},
+			{dayStart.Add(dayLength), nightLength, st.NightInterval},
+		} {
+			if period.interval <= 0 {
+				continue
+			}
+
+			for t := period.from; t.Before(period.from.Add(period.length)); t = t.Add(period.interval) {
+				if t.After(now) {
+					return t
+				}
+			}
+		}
+	}
+
+	return time.Time{}
+}
@@ -0,0 +1,123 @@
+package ig
+
+import (
+	"testing"
+	"time"
+
+	"github.com/autogrow/go-jelly/ig/datastructs"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestIrrigation(t *testing.T) {
+	at := func(day, h, m int) time.Time {
+		return time.Date(2018, 6, day, h, m, 0, 0, time.UTC)
+	}
+
+	Convey("given an IntelliDose with 2 irrigation stations", t, func() {
+		id := NewIntelliDose(&Device{ID: "ID1"})
+		id.Config.Functions.IrrigationMode = string(IrrigationEvery)
+		id.Config.Functions.IrrigationStations = 2
+		id.Config.Functions.IrrigationStation1 = "Veg"
+		id.Config.Times = datastructs.TimesIDose{DayStart: 6 * 60, DayEnd: 18 * 60}
+		id.Status.General.IrrigationInterval1 = datastructs.IrrigationIntervalIDose{Day: 120, Night: 240, Every: 90}
+		id.Status.General.IrrigationDuration1 = 45
+		id.Status.Status = []datastructs.StatusStatusIDose{
+			{Function: "Irrigation Station 1", Enabled: true},
+			{Function: "Irrigation Station 2", Enabled: false},
+		}
+
+		Convey("it should give the program of a station", func() {
+			st, err := id.IrrigationStation(1)
+			So(err, ShouldBeNil)
+			So(st, ShouldResemble, IrrigationStation{
+				Number:        1,
+				Name:          "Veg",
+				Enabled:       true,
+				DayInterval:   2 * time.Hour,
+				NightInterval: 4 * time.Hour,
+				Interval:      90 * time.Minute,
+				Duration:      45 * time.Second,
+			})
+		})
+
+		Convey("it should give every configured station", func() {
+			stations := id.IrrigationStations()
+			So(len(stations), ShouldEqual, 2)
+			So(stations[1].Enabled, ShouldBeFalse)
+		})
+
+		Convey("it should not give a station that isn't configured", func() {
+			_, err := id.IrrigationStation(3)
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("it should not set invalid programs", func() {
+			So(id.SetStationInterval(1, 90*time.Second), ShouldNotBeNil)
+			So(id.SetStationIntervals(1, time.Hour, 25*time.Hour), ShouldNotBeNil)
+			So(id.SetStationDuration(1, -time.Second), ShouldNotBeNil)
+			So(id.SetStationDuration(1, 1500*time.Millisecond), ShouldNotBeNil)
+			So(id.SetIrrigationMode("sometimes"), ShouldNotBeNil)
+		})
+
+		Convey("in every mode it should irrigate at multiples of the interval since midnight", func() {
+			for _, tc := range []struct {
+				station   int
+				now, want time.Time
+			}{
+				{1, at(1, 10, 0), at(1, 10, 30)},
+				{1, at(1, 10, 30), at(1, 12, 0)},
+				{1, at(1, 23, 0), at(2, 0, 0)},
+				{2, at(1, 10, 0), time.Time{}},
+			} {
+				next, err := id.NextIrrigation(tc.station, tc.now)
+				So(err, ShouldBeNil)
+				So(next, ShouldEqual, tc.want)
+			}
+		})
+
+		Convey("in day/night mode it should irrigate at the interval of the day or night", func() {
+			id.Config.Functions.IrrigationMode = string(IrrigationDayNight)
+
+			for _, tc := range []struct {
+				now, want time.Time
+			}{
+				{at(1, 11, 0), at(1, 12, 0)},
+				{at(1, 17, 30), at(1, 18, 0)},
+				{at(1, 23, 0), at(2, 2, 0)},
+				{at(1, 5, 0), at(1, 6, 0)},
+				{at(1, 3, 0), at(1, 6, 0)},
+			} {
+				next, err := id.NextIrrigation(1, tc.now)
+				So(err, ShouldBeNil)
+				So(next, ShouldEqual, tc.want)
+			}
+
+			Convey("and only at night if there is no day interval", func() {
+				id.Status.General.IrrigationInterval1.Day = 0
+				next, err := id.NextIrrigation(1, at(1, 11, 0))
+				So(err, ShouldBeNil)
+				So(next, ShouldEqual, at(1, 18, 0))
+			})
+		})
+
+		Convey("it should give irrigation times in the time zone of the device", func() {
+			id.TimeZoneOffset = 10
+			next, err := id.NextIrrigation(1, at(1, 1, 0))
+			So(err, ShouldBeNil)
+			So(next.UTC(), ShouldEqual, at(1, 2, 0))
+			So(next.Hour(), ShouldEqual, 12)
+		})
+
+		Convey("it should not irrigate when irrigation is off", func() {
+			id.Config.Functions.IrrigationMode = string(IrrigationOff)
+			next, err := id.NextIrrigation(1, at(1, 10, 0))
+			So(err, ShouldBeNil)
+			So(next.IsZero(), ShouldBeTrue)
+		})
+
+		Convey("it should give an error for a station that isn't configured", func() {
+			_, err := id.NextIrrigation(4, at(1, 10, 0))
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
@@ -0,0 +1,234 @@
+package ig
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MaintenanceTask is a maintenance job the IntelliDose reminds the grower to do
+type MaintenanceTask string
+
+const (
+	// CleanECProbe - clean the EC probe
+	CleanECProbe MaintenanceTask = "clean_ec_probe"
+	// CleanPHProbe - clean the pH electrode
+	CleanPHProbe MaintenanceTask = "clean_ph_electrode"
+	// CheckECProbe - check the calibration of the EC probe
+	CheckECProbe MaintenanceTask = "check_ec_probe"
+	// CalibratePH - calibrate the pH electrode
+	CalibratePH MaintenanceTask = "calibrate_ph"
+	// CleanFilters - clean the filters
+	CleanFilters MaintenanceTask = "clean_filters"
+)
+
+// MaintenanceTasks are all the tasks an IntelliDose has reminders for
+var MaintenanceTasks = []MaintenanceTask{CleanECProbe, CleanPHProbe, CheckECProbe, CalibratePH, CleanFilters}
+
+// String returns a description of the task
+func (t MaintenanceTask) String() string {
+	switch t {
+	case CleanECProbe:
+		return "Clean EC probe"
+	case CleanPHProbe:
+		return "Clean pH electrode"
+	case CheckECProbe:
+		return "Check EC probe"
+	case CalibratePH:
+		return "Calibrate pH"
+	case CleanFilters:
+		return "Clean filters"
+	}
+	return string(t)
+}
+
+// MaintenanceStatus is whether a task is due
+type MaintenanceStatus string
+
+const (
+	// MaintenanceUpcoming - the task isn't due yet
+	MaintenanceUpcoming MaintenanceStatus = "upcoming"
+	// MaintenanceDue - the task is due today
+	MaintenanceDue MaintenanceStatus = "due"
+	// MaintenanceOverdue - the task was due before today
+	MaintenanceOverdue MaintenanceStatus = "overdue"
+	// MaintenanceUnscheduled - the task has never been done and reminders haven't been started
+	MaintenanceUnscheduled MaintenanceStatus = "unscheduled"
+)
+
+// MaintenanceItem is a maintenance task for a device and when it is due
+type MaintenanceItem struct {
+	Device     string          `json:"device"`
+	DeviceName string          `json:"device_name"`
+	Growroom   string          `json:"growroom"`
+	Task       MaintenanceTask `json:"task"`
+	// LastDone is when the task was last marked as done, zero if it never has been
+	LastDone time.Time `json:"last_done"`
+	// Due is zero if the task has never been done and reminders haven't been started
+	Due time.Time `json:"due"`
+}
+
+// Status returns whether the task is due at the given time, a task is due for the whole day
+// it falls due on
+func (mi MaintenanceItem) Status(now time.Time) MaintenanceStatus {
+	if mi.Due.IsZero() {
+		return MaintenanceUnscheduled
+	}
+
+	now = now.In(mi.Due.Location())
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	tomorrow := today.AddDate(0, 0, 1)
+
+	switch {
+	case !mi.Due.Before(tomorrow):
+		return MaintenanceUpcoming
+	case mi.Due.Before(today):
+		return MaintenanceOverdue
+	}
+	return MaintenanceDue
+}
+
+// parseReminderFrequency returns the period of a reminder frequency, which is either a name such
+// as "weekly" or a number of days
+func parseReminderFrequency(s string) (time.Duration, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	switch s {
+	case "daily":
+		return fullDay, nil
+	case "weekly":
+		return 7 * fullDay, nil
+	case "fortnightly", "biweekly":
+		return 14 * fullDay, nil
+	case "monthly":
+		return 30 * fullDay, nil
+	}
+
+	days, err := strconv.Atoi(strings.TrimSpace(strings.TrimSuffix(strings.TrimSuffix(s, "days"), "day")))
+	if err != nil || days <= 0 {
+		return 0, fmt.Errorf("unknown reminder frequency %q", s)
+	}
+
+	return time.Duration(days) * fullDay, nil
+}
+
+// MaintenanceFrequency returns how often the maintenance tasks are due
+func (id *IntelliDose) MaintenanceFrequency() (time.Duration, error) {
+	freq := id.Config.Reminders.Frequency
+	if freq == "" {
+		freq = id.Config.Advanced.MntnReminderFreq
+	}
+	return parseReminderFrequency(freq)
+}
+
+// reminder returns a pointer to the time the task was last done, which the device keeps in
+// milliseconds
+func (id *IntelliDose) reminder(task MaintenanceTask) (*float64, error) {
+	list := &id.Config.Reminders.ReminderList
+	switch task {
+	case CleanECProbe:
+		return &list.CleanECProbe, nil
+	case CleanPHProbe:
+		return &list.CleanpHProbe, nil
+	case CheckECProbe:
+		return &list.CheckECProbe, nil
+	case CalibratePH:
+		return &list.CalibratePH, nil
+	case CleanFilters:
+		return &list.CleanFilters, nil
+	}
+	return nil, fmt.Errorf("unknown maintenance task %q", task)
+}
+
+// Maintenance returns when each maintenance task is due, based on when it was last done or the
+// reminder start date if it never has been.  A task with neither is unscheduled and has no due
+// date.
+func (id *IntelliDose) Maintenance() ([]MaintenanceItem, error) {
+	if !id.ValidConfig {
+		if err := id.GetConfig(); err != nil {
+			return nil, err
+		}
+	}
+
+	freq, err := id.MaintenanceFrequency()
+	if err != nil {
+		return nil, err
+	}
+
+	loc := id.Location()
+	items := []MaintenanceItem{}
+	for _, task := range MaintenanceTasks {
+		last, _ := id.reminder(task)
+
+		item := MaintenanceItem{
+			Device:     id.GetID(),
+			DeviceName: id.DeviceName,
+			Growroom:   id.GetGrowroom(),
+			Task:       task,
+		}
+
+		from := id.Config.Reminders.StartDate
+		if *last != 0 {
+			item.LastDone = msToTime(*last).In(loc)
+			from = *last
+		}
+
+		if from != 0 {
+			item.Due = msToTime(from).In(loc).Add(freq)
+		}
+		items = append(items, item)
+	}
+
+	return items, nil
+}
+
+// MarkMaintenanceDone will record that the task was done at the given time, so it is next due
+// one reminder period later
+func (id *IntelliDose) MarkMaintenanceDone(task MaintenanceTask, at time.Time) error {
+	if _, err := id.reminder(task); err != nil {
+		return err
+	}
+
+	return id.tx.guard(id, func() {
+		last, _ := id.reminder(task)
+		*last = float64(at.UnixNano() / int64(time.Millisecond))
+	})
+}
+
+// UpcomingMaintenance returns the maintenance tasks of every IntelliDose that are overdue or due
+// before the given time, ordered by when they are due.  Unscheduled tasks are left out.  Errors
+// getting the config of devices are combined into the returned error, along with the tasks of the
+// other devices.
+func (c *Client) UpcomingMaintenance(before time.Time) ([]MaintenanceItem, error) {
+	ids, err := c.IntelliDoses()
+	if err != nil {
+		return nil, err
+	}
+
+	items := []MaintenanceItem{}
+	errs := []string{}
+	for _, id := range ids {
+		mis, err := id.Maintenance()
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %s", id.GetID(), err))
+			continue
+		}
+
+		for _, mi := range mis {
+			if !mi.Due.IsZero() && mi.Due.Before(before) {
+				items = append(items, mi)
+			}
+		}
+	}
+
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].Due.Before(items[j].Due)
+	})
+
+	if len(errs) > 0 {
+		return items, fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+
+	return items, nil
+}
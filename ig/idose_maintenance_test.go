@@ -0,0 +1,166 @@
+package ig
+
+import (
+	"testing"
+	"time"
+
+	"github.com/autogrow/go-jelly/ig/datastructs"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestMaintenanceStatus(t *testing.T) {
+	Convey("given a task due at 9am", t, func() {
+		due := time.Date(2018, 6, 10, 9, 0, 0, 0, time.UTC)
+		mi := MaintenanceItem{Task: CleanFilters, Due: due}
+
+		Convey("it should be due for the whole day", func() {
+			for _, tc := range []struct {
+				now  time.Time
+				want MaintenanceStatus
+			}{
+				{time.Date(2018, 6, 9, 9, 0, 0, 0, time.UTC), MaintenanceUpcoming},
+				{time.Date(2018, 6, 9, 23, 59, 0, 0, time.UTC), MaintenanceUpcoming},
+				{time.Date(2018, 6, 10, 0, 0, 0, 0, time.UTC), MaintenanceDue},
+				{due, MaintenanceDue},
+				{time.Date(2018, 6, 10, 23, 59, 0, 0, time.UTC), MaintenanceDue},
+				{time.Date(2018, 6, 11, 0, 0, 0, 0, time.UTC), MaintenanceOverdue},
+			} {
+				So(mi.Status(tc.now), ShouldEqual, tc.want)
+			}
+		})
+
+		Convey("it should use the day in the time zone of the due date", func() {
+			mi.Due = due.In(time.FixedZone("UTC+10", 10*3600))
+			So(mi.Status(time.Date(2018, 6, 10, 15, 0, 0, 0, time.UTC)), ShouldEqual, MaintenanceOverdue)
+		})
+	})
+
+	Convey("it should not give a status to a task that was never scheduled", t, func() {
+		So(MaintenanceItem{Task: CleanFilters}.Status(time.Now()), ShouldEqual, MaintenanceUnscheduled)
+	})
+}
+
+func TestReminderFrequency(t *testing.T) {
+	Convey("it should parse reminder frequencies", t, func() {
+		for _, tc := range []struct {
+			freq string
+			want time.Duration
+		}{
+			{"daily", fullDay},
+			{"Weekly", 7 * fullDay},
+			{"fortnightly", 14 * fullDay},
+			{"monthly", 30 * fullDay},
+			{"10", 10 * fullDay},
+			{"3 days", 3 * fullDay},
+		} {
+			d, err := parseReminderFrequency(tc.freq)
+			So(err, ShouldBeNil)
+			So(d, ShouldEqual, tc.want)
+		}
+	})
+
+	Convey("it should not parse unknown frequencies", t, func() {
+		for _, freq := range []string{"", "yearly", "0", "-3"} {
+			_, err := parseReminderFrequency(freq)
+			So(err, ShouldNotBeNil)
+		}
+	})
+}
+
+func TestMaintenance(t *testing.T) {
+	start := time.Date(2018, 6, 1, 0, 0, 0, 0, time.UTC)
+	done := time.Date(2018, 6, 5, 8, 0, 0, 0, time.UTC)
+
+	Convey("given an IntelliDose with weekly reminders", t, func() {
+		id := NewIntelliDose(&Device{ID: "ID1", Growroom: "Veg"})
+		id.ValidConfig = true
+		id.Config.Reminders = datastructs.RemindersIDose{
+			Frequency:    "weekly",
+			StartDate:    1527811200000,
+			ReminderList: datastructs.ReminderIDose{CleanFilters: 1528185600000},
+		}
+
+		Convey("tasks should be due a week after they were last done or the reminders started", func() {
+			items, err := id.Maintenance()
+			So(err, ShouldBeNil)
+			So(len(items), ShouldEqual, len(MaintenanceTasks))
+
+			for i, mi := range items {
+				So(mi.Task, ShouldEqual, MaintenanceTasks[i])
+				So(mi.Device, ShouldEqual, "ID1")
+				So(mi.Growroom, ShouldEqual, "Veg")
+
+				if mi.Task == CleanFilters {
+					So(mi.LastDone, ShouldEqual, done)
+					So(mi.Due, ShouldEqual, done.AddDate(0, 0, 7))
+				} else {
+					So(mi.LastDone.IsZero(), ShouldBeTrue)
+					So(mi.Due, ShouldEqual, start.AddDate(0, 0, 7))
+				}
+			}
+		})
+
+		Convey("tasks should be unscheduled if they were never done and the reminders never started", func() {
+			id.Config.Reminders.StartDate = 0
+			items, err := id.Maintenance()
+			So(err, ShouldBeNil)
+			So(items[0].Due.IsZero(), ShouldBeTrue)
+			So(items[0].Status(done), ShouldEqual, MaintenanceUnscheduled)
+			So(items[len(items)-1].Due, ShouldEqual, done.AddDate(0, 0, 7))
+		})
+
+		Convey("the frequency should fall back to the advanced config", func() {
+			id.Config.Reminders.Frequency = ""
+			id.Config.Advanced.MntnReminderFreq = "daily"
+			freq, err := id.MaintenanceFrequency()
+			So(err, ShouldBeNil)
+			So(freq, ShouldEqual, fullDay)
+		})
+
+		Convey("it should not mark an unknown task as done", func() {
+			So(id.MarkMaintenanceDone("polish_tank", done), ShouldNotBeNil)
+		})
+
+		Convey("given a client with another IntelliDose", func() {
+			other := NewIntelliDose(&Device{ID: "ID2", Growroom: "Veg"})
+			other.ValidConfig = true
+			other.Config.Reminders = datastructs.RemindersIDose{
+				Frequency:    "weekly",
+				StartDate:    1527811200000,
+				ReminderList: datastructs.ReminderIDose{CleanFilters: 1527926400000},
+			}
+			c := &Client{devices: &Devices{IntelliDoses: []*IntelliDose{id, other}}}
+
+			Convey("it should give the tasks due before the time ordered by due date", func() {
+				items, err := c.UpcomingMaintenance(time.Date(2018, 6, 12, 0, 0, 0, 0, time.UTC))
+				So(err, ShouldBeNil)
+				So(len(items), ShouldEqual, 9)
+
+				for i, mi := range items[:8] {
+					So(mi.Device, ShouldEqual, []string{"ID1", "ID2"}[i/4])
+					So(mi.Due, ShouldEqual, start.AddDate(0, 0, 7))
+				}
+				So(items[8].Device, ShouldEqual, "ID2")
+				So(items[8].Task, ShouldEqual, CleanFilters)
+				So(items[8].Due, ShouldEqual, time.Date(2018, 6, 9, 8, 0, 0, 0, time.UTC))
+			})
+
+			Convey("it should leave out unscheduled tasks", func() {
+				other.Config.Reminders.StartDate = 0
+				items, err := c.UpcomingMaintenance(time.Date(2018, 6, 12, 0, 0, 0, 0, time.UTC))
+				So(err, ShouldBeNil)
+				So(len(items), ShouldEqual, 5)
+				So(items[4].Device, ShouldEqual, "ID2")
+				So(items[4].Task, ShouldEqual, CleanFilters)
+			})
+
+			Convey("it should give the tasks of the other devices when one has an error", func() {
+				id.Config.Reminders.Frequency = "sometimes"
+				items, err := c.UpcomingMaintenance(time.Date(2018, 6, 12, 0, 0, 0, 0, time.UTC))
+				So(err, ShouldNotBeNil)
+				So(len(items), ShouldEqual, 5)
+				So(items[0].Device, ShouldEqual, "ID2")
+			})
+		})
+	})
+}
@@ -13,12 +13,22 @@ type configGetterSaver interface {
 }
 
 func (tx *transaction) guard(cgs configGetterSaver, runner func()) error {
+	return tx.update(cgs, func() error {
+		runner()
+		return nil
+	})
+}
+
+// update is like guard for a runner that can fail, in which case nothing is saved
+func (tx *transaction) update(cgs configGetterSaver, runner func() error) error {
 	if !tx.running {
 		if err := cgs.GetConfigState(); err != nil {
 			return err
 		}
 	}
-	runner()
+	if err := runner(); err != nil {
+		return err
+	}
 	if !tx.running {
 		return cgs.SaveConfigState()
 	}
@@ -0,0 +1,47 @@
+package ig
+
+import (
+	"errors"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type countingConfig struct {
+	gets, saves int
+}
+
+func (cc *countingConfig) GetConfigState() error {
+	cc.gets++
+	return nil
+}
+
+func (cc *countingConfig) SaveConfigState() error {
+	cc.saves++
+	return nil
+}
+
+func TestTransactionUpdate(t *testing.T) {
+	Convey("given a config outside of a transaction", t, func() {
+		tx := &transaction{}
+		cc := &countingConfig{}
+
+		Convey("it should get and save the config around an update", func() {
+			So(tx.update(cc, func() error { return nil }), ShouldBeNil)
+			So(cc.gets, ShouldEqual, 1)
+			So(cc.saves, ShouldEqual, 1)
+		})
+
+		Convey("it should not save the config if the update fails", func() {
+			So(tx.update(cc, func() error { return errors.New("no such station") }), ShouldNotBeNil)
+			So(cc.gets, ShouldEqual, 1)
+			So(cc.saves, ShouldEqual, 0)
+		})
+
+		Convey("it should leave the config to a running transaction", func() {
+			tx.running = true
+			So(tx.guard(cc, func() {}), ShouldBeNil)
+			So(cc.gets+cc.saves, ShouldEqual, 0)
+		})
+	})
+}
@@ -10,6 +10,7 @@ import (
 )
 
 // ValidResponse - checks the map[string]interface{} contains information for the given device, also needs to contain a Last Updated time
+// which is returned in milliseconds as given by the API
 func validResponse(msi map[string]interface{}, devType string) (map[string]interface{}, float64, error) {
 	// Check that repsonce contains an iclimate readings field
 	rawResponse, exist := msi[devType]
@@ -30,11 +31,16 @@ func validResponse(msi map[string]interface{}, devType string) (map[string]inter
 	if !exist {
 		return nil, 0, fmt.Errorf("Data doesn't contain a last updated time")
 	}
-	last := rawLastUpdated.(float64) / 1000
+	last := rawLastUpdated.(float64)
 
 	return response, last, nil
 }
 
+// msToTime converts a timestamp in milliseconds, as used by the API, to a time
+func msToTime(ms float64) time.Time {
+	return time.Unix(0, int64(ms)*int64(time.Millisecond))
+}
+
 // UpdateStruct - converts a IC reading maps to a struct as well as lastupdated and
 func updateStruct(src map[string]interface{}, target interface{}) error {
 	jBytes, err := json.Marshal(src)
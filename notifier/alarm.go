@@ -0,0 +1,50 @@
+package notifier
+
+import (
+	"fmt"
+
+	"github.com/autogrow/go-jelly/alarm"
+)
+
+// AlarmMessage returns the message for an alarm event from a device in the given room.  Raised
+// alarms keep their severity while cleared and acknowledged alarms are informational.
+func AlarmMessage(ev alarm.Event, room string) Message {
+	a := ev.Alarm
+	sev := Info
+	if ev.Kind == alarm.Raised {
+		sev = Severity(a.Severity)
+	}
+
+	return Message{
+		Key:      fmt.Sprintf("%s/%s", a.ID, ev.Kind),
+		Title:    fmt.Sprintf("Alarm %s: %s %s %s", ev.Kind, a.Device, a.Metric, a.Condition),
+		Body:     a.String(),
+		Room:     room,
+		Device:   a.Device,
+		Severity: sev,
+		Time:     ev.Time,
+		Fields: map[string]interface{}{
+			"alarm": a,
+			"kind":  ev.Kind,
+		},
+	}
+}
+
+// FromAlarms converts a stream of alarm events into messages that can be given to Run.  The
+// roomOf function returns the growroom of a device and may be nil.
+func FromAlarms(events <-chan alarm.Event, roomOf func(device string) string) <-chan Message {
+	msgs := make(chan Message)
+
+	go func() {
+		defer close(msgs)
+		for ev := range events {
+			room := ""
+			if roomOf != nil {
+				room = roomOf(ev.Alarm.Device)
+			}
+			msgs <- AlarmMessage(ev, room)
+		}
+	}()
+
+	return msgs
+}
@@ -0,0 +1,41 @@
+package notifier
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+)
+
+// Command sends messages by running a local command.  The message is given as JSON on stdin
+// and the title, body, room, device and severity are set in the NOTIFY_* environment variables.
+type Command struct {
+	Path string
+	Args []string
+}
+
+// Notify runs the command for the message
+func (c *Command) Notify(msg Message) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("failed to encode message: %s", err)
+	}
+
+	cmd := exec.Command(c.Path, c.Args...)
+	cmd.Stdin = bytes.NewBuffer(data)
+	cmd.Env = append(os.Environ(),
+		"NOTIFY_TITLE="+msg.Title,
+		"NOTIFY_BODY="+msg.Body,
+		"NOTIFY_ROOM="+msg.Room,
+		"NOTIFY_DEVICE="+msg.Device,
+		"NOTIFY_SEVERITY="+msg.Severity.String(),
+	)
+
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("command %s failed: %s: %s", c.Path, err, bytes.TrimSpace(out))
+	}
+
+	return nil
+}
@@ -0,0 +1,291 @@
+package notifier
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Route sends messages from a growroom or device that are at least as severe as the minimum
+// severity to the named channels.  An empty room or device matches any.
+type Route struct {
+	Room        string
+	Device      string
+	MinSeverity Severity
+	Channels    []string
+
+	// Template re-renders the title and body of the message with the message as the data
+	Template *Template
+}
+
+func (r Route) matches(msg Message) bool {
+	if r.Room != "" && r.Room != msg.Room {
+		return false
+	}
+
+	if r.Device != "" && r.Device != msg.Device {
+		return false
+	}
+
+	return msg.Severity >= r.MinSeverity
+}
+
+// RateLimit is the maximum number of messages to send in a period
+type RateLimit struct {
+	Count int
+	Per   time.Duration
+}
+
+// QuietHours is a time of day during which only critical messages are sent, it may cross
+// midnight.  Other messages are dropped rather than queued, so they aren't sent once the quiet
+// hours are over.
+type QuietHours struct {
+	Start    time.Duration
+	End      time.Duration
+	Location *time.Location
+}
+
+// Contains returns true if the given time is within the quiet hours
+func (q QuietHours) Contains(t time.Time) bool {
+	loc := q.Location
+	if loc == nil {
+		loc = time.Local
+	}
+
+	t = t.In(loc)
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+	tod := t.Sub(midnight)
+
+	if q.Start <= q.End {
+		return tod >= q.Start && tod < q.End
+	}
+
+	return tod >= q.Start || tod < q.End
+}
+
+type channel struct {
+	notifier Notifier
+	limit    RateLimit
+	sent     []time.Time
+}
+
+// allow returns true if the rate limit allows another message to be sent at the given time
+func (ch *channel) allow(now time.Time) bool {
+	if ch.limit.Count <= 0 {
+		return true
+	}
+
+	recent := ch.sent[:0]
+	for _, t := range ch.sent {
+		if now.Sub(t) < ch.limit.Per {
+			recent = append(recent, t)
+		}
+	}
+	ch.sent = recent
+
+	return len(ch.sent) < ch.limit.Count
+}
+
+// Dispatcher routes messages to the channels that should receive them
+type Dispatcher struct {
+	lock     *sync.Mutex
+	channels map[string]*channel
+	routes   []Route
+	sent     map[string]time.Time
+
+	// DedupWindow is how long a message with the same key is suppressed for after being sent
+	// to at least one channel
+	DedupWindow time.Duration
+
+	// Quiet are the hours that only critical messages are sent, other messages are dropped
+	Quiet *QuietHours
+
+	// Retries is the number of times to retry a failed delivery, waiting RetryDelay before the
+	// first retry and doubling it each time
+	Retries    int
+	RetryDelay time.Duration
+
+	// OnError is called with errors from delivering messages when using Run
+	OnError func(channel string, msg Message, err error)
+
+	now   func() time.Time
+	sleep func(time.Duration)
+}
+
+// NewDispatcher returns a new dispatcher that deduplicates messages for 5 minutes and retries
+// failed deliveries 3 times
+func NewDispatcher() *Dispatcher {
+	return &Dispatcher{
+		lock:        new(sync.Mutex),
+		channels:    map[string]*channel{},
+		sent:        map[string]time.Time{},
+		DedupWindow: 5 * time.Minute,
+		Retries:     3,
+		RetryDelay:  time.Second,
+		now:         time.Now,
+		sleep:       time.Sleep,
+	}
+}
+
+// AddChannel adds a notifier that routes can send messages to by name
+func (d *Dispatcher) AddChannel(name string, n Notifier) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.channels[name] = &channel{notifier: n}
+}
+
+// SetRateLimit sets the rate limit of the named channel
+func (d *Dispatcher) SetRateLimit(name string, limit RateLimit) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	ch, ok := d.channels[name]
+	if !ok {
+		return fmt.Errorf("no channel named %s", name)
+	}
+
+	ch.limit = limit
+	return nil
+}
+
+// AddRoute adds a routing rule, a message is sent through every route it matches but only once
+// to each channel
+func (d *Dispatcher) AddRoute(r Route) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.routes = append(d.routes, r)
+}
+
+// key returns the key used to deduplicate the message
+func key(msg Message) string {
+	if msg.Key != "" {
+		return msg.Key
+	}
+	return strings.Join([]string{msg.Room, msg.Device, msg.Title, msg.Body}, "\x00")
+}
+
+// Dispatch sends the message to the channels of each route it matches.  Errors from each
+// channel are combined into the returned error.
+func (d *Dispatcher) Dispatch(msg Message) error {
+	return d.dispatch(msg, nil)
+}
+
+func (d *Dispatcher) dispatch(msg Message, onError func(string, Message, error)) error {
+	if msg.Time.IsZero() {
+		msg.Time = d.now()
+	}
+
+	type delivery struct {
+		name string
+		ch   *channel
+		msg  Message
+	}
+
+	d.lock.Lock()
+	now := d.now()
+
+	if d.Quiet != nil && msg.Severity < Critical && d.Quiet.Contains(now) {
+		d.lock.Unlock()
+		return nil
+	}
+
+	for k, last := range d.sent {
+		if now.Sub(last) >= d.DedupWindow {
+			delete(d.sent, k)
+		}
+	}
+
+	k := key(msg)
+	if _, ok := d.sent[k]; ok {
+		d.lock.Unlock()
+		return nil
+	}
+	// held while delivering so the same message dispatched meanwhile isn't sent twice
+	d.sent[k] = now
+
+	errs := []string{}
+	deliveries := []delivery{}
+	seen := map[string]bool{}
+	for _, r := range d.routes {
+		if !r.matches(msg) {
+			continue
+		}
+
+		rmsg := msg
+		if r.Template != nil {
+			if err := r.Template.Render(&rmsg, msg); err != nil {
+				errs = append(errs, err.Error())
+				continue
+			}
+		}
+
+		for _, name := range r.Channels {
+			if seen[name] {
+				continue
+			}
+			seen[name] = true
+
+			ch, ok := d.channels[name]
+			if !ok {
+				errs = append(errs, fmt.Sprintf("no channel named %s", name))
+				continue
+			}
+
+			if !ch.allow(now) {
+				errs = append(errs, fmt.Sprintf("rate limit reached for channel %s", name))
+				continue
+			}
+			ch.sent = append(ch.sent, now)
+
+			deliveries = append(deliveries, delivery{name, ch, rmsg})
+		}
+	}
+	d.lock.Unlock()
+
+	delivered := false
+	for _, dl := range deliveries {
+		if err := d.deliver(dl.ch.notifier, dl.msg); err != nil {
+			if onError != nil {
+				onError(dl.name, dl.msg, err)
+			}
+			errs = append(errs, fmt.Sprintf("%s: %s", dl.name, err))
+			continue
+		}
+		delivered = true
+	}
+
+	// only a message that was sent is suppressed, so a failed one can be sent again
+	if !delivered {
+		d.lock.Lock()
+		if d.sent[k] == now {
+			delete(d.sent, k)
+		}
+		d.lock.Unlock()
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+
+	return nil
+}
+
+// deliver sends the message, retrying if it fails
+func (d *Dispatcher) deliver(n Notifier, msg Message) error {
+	delay := d.RetryDelay
+	err := n.Notify(msg)
+	for i := 0; err != nil && i < d.Retries; i++ {
+		d.sleep(delay)
+		delay *= 2
+		err = n.Notify(msg)
+	}
+	return err
+}
+
+// Run dispatches messages from the channel until it is closed, errors are passed to OnError
+func (d *Dispatcher) Run(msgs <-chan Message) {
+	for msg := range msgs {
+		d.dispatch(msg, d.OnError)
+	}
+}
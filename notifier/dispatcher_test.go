@@ -0,0 +1,112 @@
+package notifier
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type recorder struct {
+	msgs  []Message
+	fails int
+}
+
+func (r *recorder) Notify(msg Message) error {
+	if r.fails > 0 {
+		r.fails--
+		return fmt.Errorf("failed")
+	}
+	r.msgs = append(r.msgs, msg)
+	return nil
+}
+
+func TestDispatcher(t *testing.T) {
+	Convey("given a dispatcher with a route for a room", t, func() {
+		now := time.Date(2018, 1, 1, 12, 0, 0, 0, time.UTC)
+		d := NewDispatcher()
+		d.now = func() time.Time { return now }
+		d.sleep = func(time.Duration) {}
+
+		rec := &recorder{}
+		d.AddChannel("rec", rec)
+		d.AddRoute(Route{Room: "veg", MinSeverity: Warning, Channels: []string{"rec"}})
+
+		Convey("it should only send matching messages", func() {
+			So(d.Dispatch(Message{Title: "a", Room: "veg", Severity: Warning}), ShouldBeNil)
+			So(d.Dispatch(Message{Title: "b", Room: "flower", Severity: Critical}), ShouldBeNil)
+			So(d.Dispatch(Message{Title: "c", Room: "veg", Severity: Info}), ShouldBeNil)
+			So(len(rec.msgs), ShouldEqual, 1)
+			So(rec.msgs[0].Title, ShouldEqual, "a")
+		})
+
+		Convey("it should deduplicate repeated messages", func() {
+			msg := Message{Title: "a", Room: "veg", Severity: Warning}
+			d.Dispatch(msg)
+			d.Dispatch(msg)
+			So(len(rec.msgs), ShouldEqual, 1)
+
+			now = now.Add(d.DedupWindow)
+			d.Dispatch(msg)
+			So(len(rec.msgs), ShouldEqual, 2)
+		})
+
+		Convey("it should rate limit the channel", func() {
+			So(d.SetRateLimit("rec", RateLimit{Count: 2, Per: time.Hour}), ShouldBeNil)
+			for i := 0; i < 3; i++ {
+				d.Dispatch(Message{Title: fmt.Sprint(i), Room: "veg", Severity: Warning})
+			}
+			So(len(rec.msgs), ShouldEqual, 2)
+		})
+
+		Convey("it should only send critical messages in quiet hours", func() {
+			d.Quiet = &QuietHours{Start: 22 * time.Hour, End: 13 * time.Hour, Location: time.UTC}
+			d.Dispatch(Message{Title: "a", Room: "veg", Severity: Warning})
+			d.Dispatch(Message{Title: "b", Room: "veg", Severity: Critical})
+			So(len(rec.msgs), ShouldEqual, 1)
+			So(rec.msgs[0].Title, ShouldEqual, "b")
+		})
+
+		Convey("it should retry failed deliveries", func() {
+			rec.fails = 2
+			So(d.Dispatch(Message{Title: "a", Room: "veg", Severity: Warning}), ShouldBeNil)
+			So(len(rec.msgs), ShouldEqual, 1)
+
+			rec.fails = 10
+			So(d.Dispatch(Message{Title: "b", Room: "veg", Severity: Warning}), ShouldNotBeNil)
+
+			Convey("and send a message that failed again without waiting for the dedup window", func() {
+				rec.fails = 0
+				So(d.Dispatch(Message{Title: "b", Room: "veg", Severity: Warning}), ShouldBeNil)
+				So(len(rec.msgs), ShouldEqual, 2)
+			})
+		})
+
+		Convey("it should forget messages once the dedup window has passed", func() {
+			d.Dispatch(Message{Title: "a", Room: "veg", Severity: Warning})
+			So(d.sent, ShouldHaveLength, 1)
+
+			now = now.Add(d.DedupWindow)
+			d.Dispatch(Message{Title: "b", Room: "veg", Severity: Warning})
+			So(d.sent, ShouldHaveLength, 1)
+		})
+
+		Convey("it should render templates", func() {
+			tmpl, err := NewTemplate("[{{.Room}}] {{.Title}}", "{{.Body}} on {{.Device}}")
+			So(err, ShouldBeNil)
+			d.AddRoute(Route{Room: "flower", Channels: []string{"rec"}, Template: tmpl})
+
+			d.Dispatch(Message{Title: "EC low", Body: "EC is 1.0", Device: "ID1", Room: "flower"})
+			So(rec.msgs[0].Title, ShouldEqual, "[flower] EC low")
+			So(rec.msgs[0].Body, ShouldEqual, "EC is 1.0 on ID1")
+		})
+	})
+
+	Convey("a title with a line break should not add headers to an email", t, func() {
+		e := &Email{From: "jelly@example.com", To: []string{"ops@example.com"}}
+		data := string(e.message(Message{Title: "EC low\r\nBcc: someone@example.com", Body: "EC is 1.0"}))
+		So(data, ShouldNotContainSubstring, "\r\nBcc:")
+		So(data, ShouldContainSubstring, "Subject: =?UTF-8?q?EC_low=0D=0ABcc:_someone@example.com?=\r\n")
+	})
+}
@@ -0,0 +1,13 @@
+// Package notifier dispatches notifications about devices through pluggable channels such as
+// webhooks, email or local commands.
+//
+// A Dispatcher routes each message to channels based on the growroom it came from and its
+// severity, deduplicating repeated messages, rate limiting each channel, dropping
+// non-critical messages during quiet hours and retrying failed deliveries:
+//
+//     d := notifier.NewDispatcher()
+//     d.AddChannel("ops", &notifier.Webhook{URL: "https://example.com/hook"})
+//     d.AddRoute(notifier.Route{Room: "Veg 1", MinSeverity: notifier.Warning, Channels: []string{"ops"}})
+//
+//     go d.Run(notifier.FromAlarms(engine.Events(), roomOf))
+package notifier
@@ -0,0 +1,39 @@
+package notifier
+
+import (
+	"bytes"
+	"fmt"
+	"mime"
+	"net/smtp"
+	"strings"
+)
+
+// Email sends messages by SMTP
+type Email struct {
+	Addr string
+	Auth smtp.Auth
+	From string
+	To   []string
+}
+
+// Notify sends the message as an email
+func (e *Email) Notify(msg Message) error {
+	if err := smtp.SendMail(e.Addr, e.Auth, e.From, e.To, e.message(msg)); err != nil {
+		return fmt.Errorf("failed to send email: %s", err)
+	}
+
+	return nil
+}
+
+// message returns the email for the message.  The title is encoded into the subject so that
+// line breaks in it can't add headers to the email.
+func (e *Email) message(msg Message) []byte {
+	body := new(bytes.Buffer)
+	fmt.Fprintf(body, "From: %s\r\n", e.From)
+	fmt.Fprintf(body, "To: %s\r\n", strings.Join(e.To, ", "))
+	fmt.Fprintf(body, "Subject: %s\r\n", mime.QEncoding.Encode("UTF-8", msg.Title))
+	fmt.Fprintf(body, "Date: %s\r\n", msg.Time.Format("Mon, 02 Jan 2006 15:04:05 -0700"))
+	fmt.Fprintf(body, "Content-Type: text/plain; charset=UTF-8\r\n\r\n")
+	body.WriteString(msg.Body)
+	return body.Bytes()
+}
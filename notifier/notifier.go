@@ -0,0 +1,97 @@
+package notifier
+
+import (
+	"bytes"
+	"fmt"
+	"text/template"
+	"time"
+)
+
+// Severity is how important a message is
+type Severity int
+
+const (
+	// Info - informational messages such as a day/night transition
+	Info Severity = iota
+	// Warning - something needs attention
+	Warning
+	// Critical - something needs attention now, these are delivered during quiet hours
+	Critical
+)
+
+// String returns the name of the severity
+func (s Severity) String() string {
+	switch s {
+	case Info:
+		return "info"
+	case Warning:
+		return "warning"
+	case Critical:
+		return "critical"
+	default:
+		return fmt.Sprintf("severity(%d)", int(s))
+	}
+}
+
+// Message is a notification to be sent
+type Message struct {
+	Key      string                 `json:"key"`
+	Title    string                 `json:"title"`
+	Body     string                 `json:"body"`
+	Room     string                 `json:"room"`
+	Device   string                 `json:"device"`
+	Severity Severity               `json:"severity"`
+	Time     time.Time              `json:"time"`
+	Fields   map[string]interface{} `json:"fields,omitempty"`
+}
+
+// Notifier sends messages through a channel such as email
+type Notifier interface {
+	Notify(Message) error
+}
+
+// NotifierFunc allows a function to be used as a Notifier
+type NotifierFunc func(Message) error
+
+// Notify calls the function with the message
+func (f NotifierFunc) Notify(msg Message) error {
+	return f(msg)
+}
+
+// Template renders the title and body of a message from data, such as an alarm
+type Template struct {
+	title *template.Template
+	body  *template.Template
+}
+
+// NewTemplate parses the title and body templates using the text/template syntax
+func NewTemplate(title, body string) (*Template, error) {
+	t, err := template.New("title").Parse(title)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse title template: %s", err)
+	}
+
+	b, err := template.New("body").Parse(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse body template: %s", err)
+	}
+
+	return &Template{t, b}, nil
+}
+
+// Render sets the title and body of the message from the given data
+func (t *Template) Render(msg *Message, data interface{}) error {
+	title := new(bytes.Buffer)
+	if err := t.title.Execute(title, data); err != nil {
+		return fmt.Errorf("failed to render title: %s", err)
+	}
+
+	body := new(bytes.Buffer)
+	if err := t.body.Execute(body, data); err != nil {
+		return fmt.Errorf("failed to render body: %s", err)
+	}
+
+	msg.Title = title.String()
+	msg.Body = body.String()
+	return nil
+}
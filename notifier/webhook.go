@@ -0,0 +1,60 @@
+package notifier
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Webhook sends messages as JSON in a POST request to a URL
+type Webhook struct {
+	URL     string
+	Headers map[string]string
+	Client  *http.Client
+
+	// Payload builds the body of the request from the message, the message itself is sent if
+	// this is nil
+	Payload func(Message) interface{}
+}
+
+// Notify sends the message to the webhook
+func (wh *Webhook) Notify(msg Message) error {
+	var payload interface{} = msg
+	if wh.Payload != nil {
+		payload = wh.Payload(msg)
+	}
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode payload: %s", err)
+	}
+
+	req, err := http.NewRequest("POST", wh.URL, bytes.NewBuffer(data))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range wh.Headers {
+		req.Header.Set(k, v)
+	}
+
+	client := wh.Client
+	if client == nil {
+		client = &http.Client{Timeout: time.Second * 30}
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to post to webhook: %s", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return fmt.Errorf("unexpected http status: %d", res.StatusCode)
+	}
+
+	return nil
+}
@@ -0,0 +1,36 @@
+// Package recipe plans the nutrient and climate targets of a crop cycle, ramping them between
+// growth stages, and applies each day's targets to the devices in a growroom.
+//
+// Recipes and the rooms they are grown in are loaded from a JSON config file:
+//
+//     {
+//       "recipes": [
+//         {
+//           "name": "tomatoes",
+//           "stages": [
+//             {"name": "seedling", "days": 14, "targets": {"ec": 1.2, "ph": 5.8, "day_temp": 24, "rh": 75}},
+//             {"name": "vegetative", "days": 28, "interpolation": "linear", "ramp_days": 7,
+//              "targets": {"ec": 2.0, "ph": 6.0, "day_temp": 25, "rh": 65, "co2": 1000}},
+//             {"name": "fruiting", "days": 60, "targets": {"ec": 3.0, "day_temp": 23, "rh": 60, "co2": 1200}}
+//           ]
+//         }
+//       ],
+//       "plans": [
+//         {"room": "Greenhouse 1", "recipe": "tomatoes", "start": "2018-03-01"}
+//       ]
+//     }
+//
+// Targets are given in °C and EC unless the recipe has units, and those that aren't given are
+// left as they are on the devices.  A linear stage ramps from the targets of the previous
+// stage over its ramp days, or the whole stage if none are given.
+//
+// The runner applies the targets for the day to each room in a transaction per device, and
+// records each application in its log file:
+//
+//     cfg, err := recipe.Load("recipes.json")
+//     runner := recipe.NewRunner(cfg, recipe.NewClientApplier(client))
+//     runner.LogFile = "recipes.log"
+//
+//     days, err := runner.Preview("Greenhouse 1")
+//     runner.Run(15*time.Minute, quit, func(err error) { log.Println(err) })
+package recipe
@@ -0,0 +1,87 @@
+package recipe
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/autogrow/go-jelly/ig"
+	"github.com/autogrow/go-jelly/units"
+)
+
+// ClientApplier sets targets on the devices in growrooms through the IntelliGrow API
+type ClientApplier struct {
+	client *ig.Client
+}
+
+// NewClientApplier returns an applier for the growrooms known to the client
+func NewClientApplier(client *ig.Client) *ClientApplier {
+	return &ClientApplier{client}
+}
+
+// Location returns the time zone of the growroom, or UTC if it is unknown
+func (ca *ClientApplier) Location(room string) *time.Location {
+	g, ok := ca.client.Growroom(room)
+	if !ok {
+		return time.UTC
+	}
+	return g.Location()
+}
+
+// Apply sets the EC and pH targets on each IntelliDose in the growroom, and the temperature, RH
+// and CO2 targets on each IntelliClimate, with one transaction per device
+func (ca *ClientApplier) Apply(room string, t Targets, sys units.System) error {
+	g, ok := ca.client.Growroom(room)
+	if !ok {
+		return fmt.Errorf("growroom %s not found", room)
+	}
+
+	errs := []string{}
+
+	if t.EC != nil || t.PH != nil {
+		ids, _ := g.IntelliDoses()
+		for _, id := range ids {
+			err := id.Transaction(func() error {
+				if t.EC != nil {
+					id.SetNutrientTargetIn(*t.EC, sys.Conductivity)
+				}
+				if t.PH != nil {
+					id.SetPHTarget(*t.PH)
+				}
+				return nil
+			})
+
+			if err != nil {
+				errs = append(errs, fmt.Sprintf("%s: %s", id.GetID(), err))
+			}
+		}
+	}
+
+	if t.DayTemp != nil || t.RH != nil || t.CO2 != nil {
+		ics, _ := g.IntelliClimates()
+		for _, ic := range ics {
+			err := ic.Transaction(func() error {
+				if t.DayTemp != nil {
+					ic.SetTempTargetIn(*t.DayTemp, sys.Temperature)
+				}
+				if t.RH != nil {
+					ic.SetRHTarget(*t.RH)
+				}
+				if t.CO2 != nil {
+					ic.SetCO2Target(*t.CO2)
+				}
+				return nil
+			})
+
+			if err != nil {
+				errs = append(errs, fmt.Sprintf("%s: %s", ic.GetID(), err))
+			}
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+
+	return nil
+}
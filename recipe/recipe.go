@@ -0,0 +1,295 @@
+package recipe
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	"github.com/autogrow/go-jelly/units"
+)
+
+// Interpolation is how targets change from the previous stage at the start of a stage
+type Interpolation string
+
+const (
+	// Step changes the targets on the first day of the stage
+	Step Interpolation = "step"
+	// Linear ramps the targets from the previous stage over the ramp days of the stage
+	Linear Interpolation = "linear"
+)
+
+// Targets are the setpoints for a day of the crop cycle, targets that aren't set are left as
+// they are on the devices
+type Targets struct {
+	EC      *float64 `json:"ec,omitempty"`
+	PH      *float64 `json:"ph,omitempty"`
+	DayTemp *float64 `json:"day_temp,omitempty"`
+	RH      *float64 `json:"rh,omitempty"`
+	CO2     *float64 `json:"co2,omitempty"`
+}
+
+// Equal returns true if the targets are the same
+func (t Targets) Equal(o Targets) bool {
+	eq := func(a, b *float64) bool {
+		if a == nil || b == nil {
+			return a == b
+		}
+		return *a == *b
+	}
+
+	return eq(t.EC, o.EC) && eq(t.PH, o.PH) && eq(t.DayTemp, o.DayTemp) && eq(t.RH, o.RH) && eq(t.CO2, o.CO2)
+}
+
+// String describes the targets
+func (t Targets) String() string {
+	s := ""
+	add := func(name string, v *float64) {
+		if v == nil {
+			return
+		}
+		if s != "" {
+			s += " "
+		}
+		s += fmt.Sprintf("%s=%g", name, *v)
+	}
+
+	add("ec", t.EC)
+	add("ph", t.PH)
+	add("day_temp", t.DayTemp)
+	add("rh", t.RH)
+	add("co2", t.CO2)
+	return s
+}
+
+// interpolate returns the targets the fraction of the way from prev to t, targets only set in
+// one of them are not interpolated
+func (t Targets) interpolate(prev Targets, frac float64) Targets {
+	lerp := func(from, to *float64) *float64 {
+		if from == nil || to == nil {
+			return to
+		}
+		v := round(*from + (*to-*from)*frac)
+		return &v
+	}
+
+	return Targets{
+		EC:      lerp(prev.EC, t.EC),
+		PH:      lerp(prev.PH, t.PH),
+		DayTemp: lerp(prev.DayTemp, t.DayTemp),
+		RH:      lerp(prev.RH, t.RH),
+		CO2:     lerp(prev.CO2, t.CO2),
+	}
+}
+
+// round to 2 decimal places so that ramped targets are sensible setpoints
+func round(v float64) float64 {
+	if v < 0 {
+		return -round(-v)
+	}
+	return float64(int64(v*100+0.5)) / 100
+}
+
+// Stage is a growth stage of the crop cycle
+type Stage struct {
+	Name string `json:"name"`
+	Days int    `json:"days"`
+	// Interpolation is how the targets change from the previous stage, step by default
+	Interpolation Interpolation `json:"interpolation,omitempty"`
+	// RampDays is the number of days at the start of the stage that a linear interpolation
+	// ramps over, the whole stage if zero
+	RampDays int     `json:"ramp_days,omitempty"`
+	Targets  Targets `json:"targets"`
+}
+
+// Recipe is the targets for each stage of a crop cycle
+type Recipe struct {
+	Name string `json:"name"`
+	// Units are the units of the EC and temperature targets, metric if not given
+	Units  *units.System `json:"units,omitempty"`
+	Stages []Stage       `json:"stages"`
+}
+
+// System returns the units of the targets
+func (r Recipe) System() units.System {
+	if r.Units == nil {
+		return units.Metric
+	}
+	return *r.Units
+}
+
+// Validate returns an error if the recipe is invalid
+func (r Recipe) Validate() error {
+	if r.Name == "" {
+		return fmt.Errorf("recipe has no name")
+	}
+
+	if len(r.Stages) == 0 {
+		return fmt.Errorf("recipe %s has no stages", r.Name)
+	}
+
+	for _, s := range r.Stages {
+		switch {
+		case s.Days <= 0:
+			return fmt.Errorf("recipe %s: stage %s must last at least a day", r.Name, s.Name)
+		case s.Interpolation != "" && s.Interpolation != Step && s.Interpolation != Linear:
+			return fmt.Errorf("recipe %s: stage %s has unknown interpolation %q", r.Name, s.Name, s.Interpolation)
+		case s.RampDays < 0 || s.RampDays > s.Days:
+			return fmt.Errorf("recipe %s: stage %s must ramp over 0 to %d days", r.Name, s.Name, s.Days)
+		}
+	}
+
+	return nil
+}
+
+// Days returns the length of the crop cycle in days
+func (r Recipe) Days() int {
+	days := 0
+	for _, s := range r.Stages {
+		days += s.Days
+	}
+	return days
+}
+
+// TargetsOn returns the targets and stage for the given day of the crop cycle, starting at
+// zero.  It returns false if the day is before the start or after the end of the cycle.
+func (r Recipe) TargetsOn(day int) (Targets, string, bool) {
+	if day < 0 {
+		return Targets{}, "", false
+	}
+
+	var prev *Stage
+	for i, s := range r.Stages {
+		if day >= s.Days {
+			day -= s.Days
+			prev = &r.Stages[i]
+			continue
+		}
+
+		if prev == nil || s.Interpolation != Linear {
+			return s.Targets, s.Name, true
+		}
+
+		ramp := s.RampDays
+		if ramp == 0 {
+			ramp = s.Days
+		}
+
+		if day >= ramp {
+			return s.Targets, s.Name, true
+		}
+
+		return s.Targets.interpolate(prev.Targets, float64(day+1)/float64(ramp)), s.Name, true
+	}
+
+	return Targets{}, "", false
+}
+
+// Plan is a recipe being grown in a room from the start date
+type Plan struct {
+	Room   string `json:"room"`
+	Recipe string `json:"recipe"`
+	// Start is the first day of the crop cycle as YYYY-MM-DD in the time zone of the room
+	Start string `json:"start"`
+}
+
+// Day returns the day of the crop cycle at the given time, in the given time zone
+func (p Plan) Day(t time.Time, loc *time.Location) (int, error) {
+	start, err := time.Parse("2006-01-02", p.Start)
+	if err != nil {
+		return 0, fmt.Errorf("invalid start date for %s: %s", p.Room, err)
+	}
+
+	// compare the dates in UTC as days with daylight saving changes aren't 24 hours long
+	t = t.In(loc)
+	today := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+
+	return int(today.Sub(start).Hours()) / 24, nil
+}
+
+// Day is a day in the preview of a plan
+type Day struct {
+	Date    string  `json:"date"`
+	Day     int     `json:"day"`
+	Stage   string  `json:"stage"`
+	Targets Targets `json:"targets"`
+}
+
+// Preview returns the targets for every day of the crop cycle starting on the given date
+func (r Recipe) Preview(start string) ([]Day, error) {
+	first, err := time.Parse("2006-01-02", start)
+	if err != nil {
+		return nil, fmt.Errorf("invalid start date: %s", err)
+	}
+
+	days := []Day{}
+	for d := 0; d < r.Days(); d++ {
+		t, stage, _ := r.TargetsOn(d)
+		days = append(days, Day{
+			Date:    first.AddDate(0, 0, d).Format("2006-01-02"),
+			Day:     d,
+			Stage:   stage,
+			Targets: t,
+		})
+	}
+
+	return days, nil
+}
+
+// Config is the format of a recipe config file
+type Config struct {
+	Recipes []Recipe `json:"recipes"`
+	Plans   []Plan   `json:"plans"`
+}
+
+// Recipe returns the named recipe
+func (cfg Config) Recipe(name string) (Recipe, bool) {
+	for _, r := range cfg.Recipes {
+		if r.Name == name {
+			return r, true
+		}
+	}
+	return Recipe{}, false
+}
+
+// Parse returns the recipes and plans from the JSON config
+func Parse(data []byte) (Config, error) {
+	cfg := Config{}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return cfg, fmt.Errorf("couldn't parse recipes: %s", err)
+	}
+
+	for _, r := range cfg.Recipes {
+		if err := r.Validate(); err != nil {
+			return cfg, err
+		}
+	}
+
+	rooms := map[string]bool{}
+	for _, p := range cfg.Plans {
+		if _, ok := cfg.Recipe(p.Recipe); !ok {
+			return cfg, fmt.Errorf("plan for %s uses unknown recipe %s", p.Room, p.Recipe)
+		}
+
+		if _, err := p.Day(time.Now(), time.UTC); err != nil {
+			return cfg, err
+		}
+
+		if rooms[p.Room] {
+			return cfg, fmt.Errorf("there is more than one plan for %s", p.Room)
+		}
+		rooms[p.Room] = true
+	}
+
+	return cfg, nil
+}
+
+// Load returns the recipes and plans from the JSON config file at the given path
+func Load(path string) (Config, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return Config{}, fmt.Errorf("couldn't read recipes file: %s", err)
+	}
+
+	return Parse(data)
+}
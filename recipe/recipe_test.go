@@ -0,0 +1,186 @@
+package recipe
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/autogrow/go-jelly/units"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+const testConfig = `{
+  "recipes": [{
+    "name": "tomatoes",
+    "stages": [
+      {"name": "seedling", "days": 2, "targets": {"ec": 1.0, "ph": 5.8}},
+      {"name": "veg", "days": 6, "interpolation": "linear", "ramp_days": 4, "targets": {"ec": 2.0, "co2": 1000}},
+      {"name": "fruit", "days": 3, "targets": {"ec": 3.0}}
+    ]
+  }],
+  "plans": [{"room": "GH1", "recipe": "tomatoes", "start": "2018-03-01"}]
+}`
+
+type fakeApplier struct {
+	loc     *time.Location
+	applied []string
+	err     error
+}
+
+func (fa *fakeApplier) Location(room string) *time.Location {
+	return fa.loc
+}
+
+func (fa *fakeApplier) Apply(room string, t Targets, sys units.System) error {
+	fa.applied = append(fa.applied, fmt.Sprintf("%s %s", room, t))
+	return fa.err
+}
+
+func TestRecipe(t *testing.T) {
+	Convey("given a recipe config", t, func() {
+		cfg, err := Parse([]byte(testConfig))
+		So(err, ShouldBeNil)
+
+		rec, ok := cfg.Recipe("tomatoes")
+		So(ok, ShouldBeTrue)
+		So(rec.Days(), ShouldEqual, 11)
+
+		Convey("it should step and ramp the targets between stages", func() {
+			for day, want := range map[int]string{
+				0:  "ec=1 ph=5.8",
+				1:  "ec=1 ph=5.8",
+				2:  "ec=1.25 co2=1000",
+				3:  "ec=1.5 co2=1000",
+				5:  "ec=2 co2=1000",
+				7:  "ec=2 co2=1000",
+				8:  "ec=3",
+				10: "ec=3",
+			} {
+				targets, _, ok := rec.TargetsOn(day)
+				So(ok, ShouldBeTrue)
+				So(targets.String(), ShouldEqual, want)
+			}
+
+			_, stage, _ := rec.TargetsOn(3)
+			So(stage, ShouldEqual, "veg")
+
+			_, _, ok := rec.TargetsOn(11)
+			So(ok, ShouldBeFalse)
+			_, _, ok = rec.TargetsOn(-1)
+			So(ok, ShouldBeFalse)
+		})
+
+		Convey("it should preview every day of the cycle", func() {
+			days, err := rec.Preview("2018-03-01")
+			So(err, ShouldBeNil)
+			So(len(days), ShouldEqual, 11)
+			So(days[2].Date, ShouldEqual, "2018-03-03")
+			So(days[2].Stage, ShouldEqual, "veg")
+			So(days[10].Date, ShouldEqual, "2018-03-11")
+		})
+	})
+
+	Convey("it should not parse invalid configs", t, func() {
+		for _, cfg := range []string{
+			`{"recipes": [{"name": "a", "stages": [{"name": "s", "days": 0}]}]}`,
+			`{"recipes": [{"name": "a", "stages": [{"name": "s", "days": 2, "interpolation": "cubic"}]}]}`,
+			`{"recipes": [{"name": "a", "stages": [{"name": "s", "days": 2, "ramp_days": 3}]}]}`,
+			`{"recipes": [{"name": "a", "stages": []}]}`,
+			`{"recipes": [{"name": "a", "stages": [{"name": "s", "days": 2}]}], "plans": [{"room": "GH1", "recipe": "b", "start": "2018-03-01"}]}`,
+			`{"recipes": [{"name": "a", "stages": [{"name": "s", "days": 2}]}], "plans": [{"room": "GH1", "recipe": "a", "start": "1/3/2018"}]}`,
+		} {
+			_, err := Parse([]byte(cfg))
+			So(err, ShouldNotBeNil)
+		}
+	})
+}
+
+func TestRunner(t *testing.T) {
+	loc := time.FixedZone("UTC+12", 12*3600)
+
+	Convey("given a runner for a plan", t, func() {
+		dir, err := ioutil.TempDir("", "recipe")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(dir)
+
+		cfg, err := Parse([]byte(testConfig))
+		So(err, ShouldBeNil)
+
+		applier := &fakeApplier{loc: loc}
+		now := time.Date(2018, 3, 3, 8, 0, 0, 0, loc)
+		newRunner := func() *Runner {
+			r := NewRunner(cfg, applier)
+			r.LogFile = filepath.Join(dir, "recipes.log")
+			r.now = func() time.Time { return now }
+			return r
+		}
+
+		r := newRunner()
+
+		Convey("it should apply today's targets once", func() {
+			apps, err := r.Tick()
+			So(err, ShouldBeNil)
+			So(len(apps), ShouldEqual, 1)
+			So(apps[0].Day, ShouldEqual, 2)
+			So(apps[0].Stage, ShouldEqual, "veg")
+			So(apps[0].Date, ShouldEqual, "2018-03-03")
+			So(applier.applied, ShouldResemble, []string{"GH1 ec=1.25 co2=1000"})
+
+			apps, err = r.Tick()
+			So(err, ShouldBeNil)
+			So(apps, ShouldBeEmpty)
+
+			Convey("it should not apply them again after a restart", func() {
+				r := newRunner()
+				apps, err := r.Tick()
+				So(err, ShouldBeNil)
+				So(apps, ShouldBeEmpty)
+				So(len(r.History()), ShouldEqual, 1)
+			})
+
+			Convey("it should apply the next day's targets", func() {
+				now = now.Add(24 * time.Hour)
+				apps, err := r.Tick()
+				So(err, ShouldBeNil)
+				So(len(apps), ShouldEqual, 1)
+				So(applier.applied[1], ShouldEqual, "GH1 ec=1.5 co2=1000")
+			})
+		})
+
+		Convey("it should retry failed applications", func() {
+			applier.err = fmt.Errorf("device offline")
+			apps, err := r.Tick()
+			So(err, ShouldBeNil)
+			So(apps[0].Error, ShouldEqual, "device offline")
+
+			applier.err = nil
+			apps, err = r.Tick()
+			So(err, ShouldBeNil)
+			So(len(apps), ShouldEqual, 1)
+			So(apps[0].Error, ShouldBeEmpty)
+
+			log, err := ReadLog(r.LogFile)
+			So(err, ShouldBeNil)
+			So(len(log), ShouldEqual, 2)
+		})
+
+		Convey("it should do nothing before the cycle starts", func() {
+			now = time.Date(2018, 2, 28, 23, 0, 0, 0, loc)
+			apps, err := r.Tick()
+			So(err, ShouldBeNil)
+			So(apps, ShouldBeEmpty)
+		})
+
+		Convey("it should preview the plan for the room", func() {
+			days, err := r.Preview("GH1")
+			So(err, ShouldBeNil)
+			So(len(days), ShouldEqual, 11)
+
+			_, err = r.Preview("GH2")
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
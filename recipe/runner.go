@@ -0,0 +1,239 @@
+package recipe
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/autogrow/go-jelly/units"
+)
+
+// Applier sets the targets on the devices in a room
+type Applier interface {
+	// Location returns the time zone of the room that days start in
+	Location(room string) *time.Location
+	// Apply sets the targets, given in the unit system, on the devices in the room
+	Apply(room string, t Targets, sys units.System) error
+}
+
+// Application is a record of the targets applied to a room
+type Application struct {
+	Room    string    `json:"room"`
+	Recipe  string    `json:"recipe"`
+	Stage   string    `json:"stage"`
+	Day     int       `json:"day"`
+	Date    string    `json:"date"`
+	Targets Targets   `json:"targets"`
+	Time    time.Time `json:"time"`
+	Error   string    `json:"error,omitempty"`
+}
+
+// Runner applies the targets of each plan to its room once a day
+type Runner struct {
+	lock    *sync.Mutex
+	cfg     Config
+	applier Applier
+	last    map[string]Application
+	loaded  bool
+	history []Application
+
+	// LogFile is where each application is appended as a line of JSON, it is also read on the
+	// first tick so targets already applied today aren't applied again
+	LogFile string
+
+	// OnApply is called with each application
+	OnApply func(Application)
+
+	now func() time.Time
+}
+
+// NewRunner returns a runner for the plans in the config
+func NewRunner(cfg Config, applier Applier) *Runner {
+	return &Runner{
+		lock:    new(sync.Mutex),
+		cfg:     cfg,
+		applier: applier,
+		last:    map[string]Application{},
+		now:     time.Now,
+	}
+}
+
+// Preview returns the targets for every day of the crop cycle planned for the room
+func (r *Runner) Preview(room string) ([]Day, error) {
+	for _, p := range r.cfg.Plans {
+		if p.Room != room {
+			continue
+		}
+
+		rec, _ := r.cfg.Recipe(p.Recipe)
+		return rec.Preview(p.Start)
+	}
+
+	return nil, fmt.Errorf("no plan for %s", room)
+}
+
+// History returns the applications made since the runner started, along with those read from
+// the log file
+func (r *Runner) History() []Application {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return append([]Application{}, r.history...)
+}
+
+// Tick applies today's targets to any rooms that they haven't been applied to yet, returning the
+// applications made.  Failed applications are tried again on the next tick.
+func (r *Runner) Tick() ([]Application, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if !r.loaded {
+		if err := r.loadLog(); err != nil {
+			return nil, err
+		}
+		r.loaded = true
+	}
+
+	now := r.now()
+	apps := []Application{}
+
+	for _, p := range r.cfg.Plans {
+		rec, _ := r.cfg.Recipe(p.Recipe)
+		loc := r.applier.Location(p.Room)
+
+		day, err := p.Day(now, loc)
+		if err != nil {
+			return apps, err
+		}
+
+		targets, stage, ok := rec.TargetsOn(day)
+		if !ok {
+			continue
+		}
+
+		date := now.In(loc).Format("2006-01-02")
+		if last, ok := r.last[p.Room]; ok && last.Error == "" && last.Date == date && last.Targets.Equal(targets) {
+			continue
+		}
+
+		app := Application{
+			Room:    p.Room,
+			Recipe:  rec.Name,
+			Stage:   stage,
+			Day:     day,
+			Date:    date,
+			Targets: targets,
+			Time:    now,
+		}
+
+		if err := r.applier.Apply(p.Room, targets, rec.System()); err != nil {
+			app.Error = err.Error()
+		}
+
+		apps = append(apps, app)
+		if err := r.record(app); err != nil {
+			return apps, err
+		}
+	}
+
+	return apps, nil
+}
+
+func (r *Runner) record(app Application) error {
+	r.last[app.Room] = app
+	r.history = append(r.history, app)
+
+	if r.OnApply != nil {
+		r.OnApply(app)
+	}
+
+	if r.LogFile == "" {
+		return nil
+	}
+
+	data, err := json.Marshal(app)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(r.LogFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("couldn't open recipe log: %s", err)
+	}
+	defer f.Close()
+
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("couldn't write to recipe log: %s", err)
+	}
+
+	return nil
+}
+
+func (r *Runner) loadLog() error {
+	if r.LogFile == "" {
+		return nil
+	}
+
+	apps, err := ReadLog(r.LogFile)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, app := range apps {
+		r.last[app.Room] = app
+	}
+	r.history = apps
+
+	return nil
+}
+
+// Run applies the targets every interval until told to quit, errors are passed to onError if
+// it isn't nil
+func (r *Runner) Run(interval time.Duration, quit chan bool, onError func(error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	tick := func() {
+		if _, err := r.Tick(); err != nil && onError != nil {
+			onError(err)
+		}
+	}
+
+	tick()
+	for {
+		select {
+		case <-ticker.C:
+			tick()
+		case stop, ok := <-quit:
+			if !ok || stop {
+				return
+			}
+		}
+	}
+}
+
+// ReadLog returns the applications in the log at the given path
+func ReadLog(path string) ([]Application, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	apps := []Application{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		app := Application{}
+		if err := json.Unmarshal(scanner.Bytes(), &app); err != nil {
+			return nil, fmt.Errorf("couldn't parse recipe log: %s", err)
+		}
+		apps = append(apps, app)
+	}
+
+	return apps, scanner.Err()
+}
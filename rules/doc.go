@@ -0,0 +1,40 @@
+// Package rules provides simple automations that run actions on devices when conditions on
+// growroom and device metrics hold for long enough.
+//
+// Rules are loaded from a JSON config file:
+//
+//     {
+//       "rules": [
+//         {
+//           "name": "low nutrient",
+//           "room": "Veg 1",
+//           "when": "room.ec < 1.2",
+//           "for": "10m",
+//           "cooldown": "1h",
+//           "interlocks": ["room.ph > 5.2 and room.ph < 6.8"],
+//           "actions": [{"do": "ForceNutrientDose"}]
+//         },
+//         {
+//           "name": "co2 too high",
+//           "when": "room.co2 > 1800",
+//           "actions": [{"do": "DisableCO2Dosing"}, {"notify": "CO2 dosing disabled"}]
+//         }
+//       ]
+//     }
+//
+// Conditions compare metrics such as room.ec or ASLID17081149.ph with numbers or each other
+// using <, <=, >, >=, == and !=, combined with and, or, not and parentheses.  A comparison
+// with a metric that isn't available, for example because its device is offline, is unknown
+// rather than false, and stays unknown through not.  An unknown condition never fires a rule
+// and an unknown interlock blocks it.
+//
+// The engine is evaluated each time the growrooms are updated:
+//
+//     rs, err := rules.Load("rules.json")
+//     engine, err := rules.NewEngine(rs)
+//     engine.Notify = dispatcher.Dispatch
+//
+//     for _, g := range client.Growrooms() {
+//       rules.EvaluateGrowroom(engine, g)
+//     }
+package rules
@@ -0,0 +1,211 @@
+package rules
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/autogrow/go-jelly/ig"
+	"github.com/autogrow/go-jelly/notifier"
+)
+
+// Actuator runs actions against the devices in a growroom
+type Actuator interface {
+	Do(Action) error
+}
+
+// ActuatorFunc allows a function to be used as an Actuator
+type ActuatorFunc func(Action) error
+
+// Do calls the function with the action
+func (f ActuatorFunc) Do(a Action) error {
+	return f(a)
+}
+
+type compiledRule struct {
+	Rule
+	when       *Condition
+	interlocks []*Condition
+}
+
+func compile(r Rule) (*compiledRule, error) {
+	if r.Name == "" {
+		return nil, fmt.Errorf("rule has no name")
+	}
+
+	when, err := Compile(r.When)
+	if err != nil {
+		return nil, fmt.Errorf("rule %s: %s", r.Name, err)
+	}
+
+	cr := &compiledRule{Rule: r, when: when}
+	for _, src := range r.Interlocks {
+		c, err := Compile(src)
+		if err != nil {
+			return nil, fmt.Errorf("rule %s: interlock: %s", r.Name, err)
+		}
+		cr.interlocks = append(cr.interlocks, c)
+	}
+
+	if len(r.Actions) == 0 {
+		return nil, fmt.Errorf("rule %s has no actions", r.Name)
+	}
+
+	for _, a := range r.Actions {
+		switch {
+		case a.Notify != "" && a.Do != "":
+			return nil, fmt.Errorf("rule %s: action can't both do and notify", r.Name)
+		case a.Notify != "":
+		case !ig.DoserActions[a.Do] && !ig.ClimateActions[a.Do]:
+			return nil, fmt.Errorf("rule %s: unknown action %q", r.Name, a.Do)
+		case a.Do == "RestoreConfig":
+			return nil, fmt.Errorf("rule %s: RestoreConfig can't be used in a rule", r.Name)
+		case a.Do == "ForceStation" && a.Station == "":
+			return nil, fmt.Errorf("rule %s: ForceStation needs a station", r.Name)
+		}
+	}
+
+	return cr, nil
+}
+
+type ruleState struct {
+	trueSince time.Time
+	lastFired time.Time
+}
+
+// Result is the outcome of a rule that triggered during an evaluation
+type Result struct {
+	Rule    string    `json:"rule"`
+	Room    string    `json:"room"`
+	Time    time.Time `json:"time"`
+	Fired   bool      `json:"fired"`
+	Blocked string    `json:"blocked,omitempty"`
+	Errors  []string  `json:"errors,omitempty"`
+}
+
+// Engine evaluates rules against the metrics of growrooms and runs their actions
+type Engine struct {
+	lock  *sync.Mutex
+	rules []*compiledRule
+	state map[string]*ruleState
+
+	// Notify sends the messages of notify actions, such as the Dispatch method of a
+	// notifier.Dispatcher
+	Notify func(notifier.Message) error
+
+	now func() time.Time
+}
+
+// NewEngine returns an engine for the given rules
+func NewEngine(rules []Rule) (*Engine, error) {
+	e := &Engine{
+		lock:  new(sync.Mutex),
+		state: map[string]*ruleState{},
+		now:   time.Now,
+	}
+
+	for _, r := range rules {
+		cr, err := compile(r)
+		if err != nil {
+			return nil, err
+		}
+		e.rules = append(e.rules, cr)
+	}
+
+	return e, nil
+}
+
+// Evaluate the rules for the given room against the env, running the actions of any rules that
+// fire with the actuator.  The results of the rules that triggered are returned, including
+// those held back by their cooldown or an interlock.
+func (e *Engine) Evaluate(room string, env Env, act Actuator) []Result {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	now := e.now()
+	results := []Result{}
+
+	for _, r := range e.rules {
+		if r.Disabled || (r.Room != "" && r.Room != room) {
+			continue
+		}
+
+		key := r.Name + "\x00" + room
+		st, ok := e.state[key]
+		if !ok {
+			st = &ruleState{}
+			e.state[key] = st
+		}
+
+		if !r.when.Eval(env) {
+			st.trueSince = time.Time{}
+			continue
+		}
+
+		if st.trueSince.IsZero() {
+			st.trueSince = now
+		}
+
+		if now.Sub(st.trueSince) < time.Duration(r.For) {
+			continue
+		}
+
+		res := Result{Rule: r.Name, Room: room, Time: now}
+
+		if !st.lastFired.IsZero() && now.Sub(st.lastFired) < time.Duration(r.Cooldown) {
+			res.Blocked = "cooldown"
+			results = append(results, res)
+			continue
+		}
+
+		if c := failedInterlock(r, env); c != nil {
+			res.Blocked = "interlock: " + c.String()
+			results = append(results, res)
+			continue
+		}
+
+		st.lastFired = now
+		res.Fired = true
+		for _, a := range r.Actions {
+			if err := e.run(r, room, a, act); err != nil {
+				res.Errors = append(res.Errors, fmt.Sprintf("%s: %s", a, err))
+			}
+		}
+
+		results = append(results, res)
+	}
+
+	return results
+}
+
+func failedInterlock(r *compiledRule, env Env) *Condition {
+	for _, c := range r.interlocks {
+		if !c.Eval(env) {
+			return c
+		}
+	}
+	return nil
+}
+
+func (e *Engine) run(r *compiledRule, room string, a Action, act Actuator) error {
+	if a.Notify == "" {
+		if act == nil {
+			return fmt.Errorf("no actuator")
+		}
+		return act.Do(a)
+	}
+
+	if e.Notify == nil {
+		return fmt.Errorf("no notifier")
+	}
+
+	return e.Notify(notifier.Message{
+		Key:      strings.Join([]string{"rule", r.Name, room, a.Notify}, "/"),
+		Title:    fmt.Sprintf("Rule %s fired in %s", r.Name, room),
+		Body:     a.Notify,
+		Room:     room,
+		Severity: notifier.Warning,
+		Time:     e.now(),
+	})
+}
@@ -0,0 +1,201 @@
+package rules
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/autogrow/go-jelly/notifier"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestCondition(t *testing.T) {
+	Convey("given some metrics", t, func() {
+		env := MapEnv{"room.ec": 1.0, "room.ph": 6.0, "ID1.ph": 5.5}
+
+		Convey("it should evaluate comparisons", func() {
+			for src, want := range map[string]bool{
+				"room.ec < 1.2":                        true,
+				"room.ec >= 1.2":                       false,
+				"room.ph == 6":                         true,
+				"room.ph != ID1.ph":                    true,
+				"room.ec < 1.2 and room.ph > 6.5":      false,
+				"room.ec < 1.2 or room.ph > 6.5":       true,
+				"not (room.ec < 1.2 or room.ph > 6.5)": false,
+				"room.ph > -1":                         true,
+				"room.ph > -.5":                        true,
+			} {
+				c, err := Compile(src)
+				So(err, ShouldBeNil)
+				So(c.Eval(env), ShouldEqual, want)
+			}
+		})
+
+		Convey("a comparison with a missing metric should be false", func() {
+			c, err := Compile("room.co2 > 1800")
+			So(err, ShouldBeNil)
+			So(c.Eval(env), ShouldBeFalse)
+
+			c, err = Compile("room.co2 <= 1800")
+			So(err, ShouldBeNil)
+			So(c.Eval(env), ShouldBeFalse)
+		})
+
+		Convey("a missing metric should stay unknown through not, and and or", func() {
+			for src, want := range map[string]bool{
+				"not room.co2 > 1800":                     false,
+				"not (room.co2 > 1800 and room.ec < 1.2)": false,
+				"room.co2 > 1800 and room.ec > 1.2":       false,
+				"not (room.co2 > 1800 and room.ec > 1.2)": true,
+				"room.co2 > 1800 or room.ec < 1.2":        true,
+				"room.co2 > 1800 or room.ec > 1.2":        false,
+			} {
+				c, err := Compile(src)
+				So(err, ShouldBeNil)
+				So(c.Eval(env), ShouldEqual, want)
+			}
+		})
+
+		Convey("it should list the metrics used", func() {
+			c, err := Compile("room.ec < 1.2 and ID1.ph > room.ph")
+			So(err, ShouldBeNil)
+			So(c.Metrics(), ShouldResemble, []string{"room.ec", "ID1.ph", "room.ph"})
+		})
+	})
+
+	Convey("it should not compile invalid conditions", t, func() {
+		for _, src := range []string{"", "room.ec", "room.ec = 1", "room.ec < 1 and", "(room.ec < 1", "room.ec < 1)", "room.ec < $", "room.ec < -", "room.ec - 1 < 2"} {
+			_, err := Compile(src)
+			So(err, ShouldNotBeNil)
+		}
+	})
+}
+
+func TestParse(t *testing.T) {
+	Convey("it should parse a rules config", t, func() {
+		rs, err := Parse([]byte(`{"rules": [{"name": "low ec", "when": "room.ec < 1.2", "for": "10m", "actions": [{"do": "ForceNutrientDose"}]}]}`))
+		So(err, ShouldBeNil)
+		So(len(rs), ShouldEqual, 1)
+		So(time.Duration(rs[0].For), ShouldEqual, 10*time.Minute)
+		So(rs[0].Actions[0].Do, ShouldEqual, "ForceNutrientDose")
+	})
+
+	Convey("it should not parse invalid rules", t, func() {
+		for _, cfg := range []string{
+			`{"rules": [{"name": "a", "when": "room.ec < 1.2", "for": 10, "actions": [{"do": "ForceNutrientDose"}]}]}`,
+			`{"rules": [{"name": "a", "when": "room.ec <", "actions": [{"do": "ForceNutrientDose"}]}]}`,
+			`{"rules": [{"name": "a", "when": "room.ec < 1.2", "actions": [{"do": "Explode"}]}]}`,
+			`{"rules": [{"name": "a", "when": "room.ec < 1.2", "actions": [{"do": "ForceStation"}]}]}`,
+			`{"rules": [{"name": "a", "when": "room.ec < 1.2", "actions": [{"do": "RestoreConfig"}]}]}`,
+			`{"rules": [{"name": "a", "when": "room.ec < 1.2"}]}`,
+			`{"rules": [{"when": "room.ec < 1.2", "actions": [{"do": "ForceNutrientDose"}]}]}`,
+		} {
+			_, err := Parse([]byte(cfg))
+			So(err, ShouldNotBeNil)
+		}
+	})
+}
+
+func TestEngine(t *testing.T) {
+	start := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	Convey("given an engine with a rule to dose nutrient", t, func() {
+		e, err := NewEngine([]Rule{{
+			Name:       "low ec",
+			Room:       "Veg",
+			When:       "room.ec < 1.2",
+			For:        Duration(10 * time.Minute),
+			Cooldown:   Duration(time.Hour),
+			Interlocks: []string{"room.ph > 5.2 and room.ph < 6.8"},
+			Actions:    []Action{{Do: "ForceNutrientDose"}, {Notify: "dosing nutrient"}},
+		}})
+		So(err, ShouldBeNil)
+
+		now := start
+		e.now = func() time.Time { return now }
+
+		done := []Action{}
+		act := ActuatorFunc(func(a Action) error {
+			done = append(done, a)
+			return nil
+		})
+
+		msgs := []notifier.Message{}
+		e.Notify = func(msg notifier.Message) error {
+			msgs = append(msgs, msg)
+			return nil
+		}
+
+		env := MapEnv{"room.ec": 1.0, "room.ph": 6.0}
+
+		Convey("it should not fire until the condition has held for long enough", func() {
+			So(e.Evaluate("Veg", env, act), ShouldBeEmpty)
+
+			now = start.Add(5 * time.Minute)
+			So(e.Evaluate("Veg", env, act), ShouldBeEmpty)
+
+			now = start.Add(10 * time.Minute)
+			res := e.Evaluate("Veg", env, act)
+			So(len(res), ShouldEqual, 1)
+			So(res[0].Fired, ShouldBeTrue)
+			So(res[0].Errors, ShouldBeEmpty)
+			So(done, ShouldResemble, []Action{{Do: "ForceNutrientDose"}})
+			So(len(msgs), ShouldEqual, 1)
+			So(msgs[0].Room, ShouldEqual, "Veg")
+			So(msgs[0].Body, ShouldEqual, "dosing nutrient")
+
+			Convey("it should be held back by the cooldown", func() {
+				now = start.Add(30 * time.Minute)
+				res := e.Evaluate("Veg", env, act)
+				So(len(res), ShouldEqual, 1)
+				So(res[0].Fired, ShouldBeFalse)
+				So(res[0].Blocked, ShouldEqual, "cooldown")
+				So(len(done), ShouldEqual, 1)
+
+				now = start.Add(70 * time.Minute)
+				res = e.Evaluate("Veg", env, act)
+				So(res[0].Fired, ShouldBeTrue)
+				So(len(done), ShouldEqual, 2)
+			})
+		})
+
+		Convey("it should restart the duration when the condition stops holding", func() {
+			e.Evaluate("Veg", env, act)
+
+			now = start.Add(5 * time.Minute)
+			So(e.Evaluate("Veg", MapEnv{"room.ec": 1.5, "room.ph": 6.0}, act), ShouldBeEmpty)
+
+			now = start.Add(10 * time.Minute)
+			So(e.Evaluate("Veg", env, act), ShouldBeEmpty)
+			So(done, ShouldBeEmpty)
+		})
+
+		Convey("it should be blocked by a failed interlock", func() {
+			env["room.ph"] = 7.2
+			e.Evaluate("Veg", env, act)
+			now = start.Add(10 * time.Minute)
+
+			res := e.Evaluate("Veg", env, act)
+			So(len(res), ShouldEqual, 1)
+			So(res[0].Fired, ShouldBeFalse)
+			So(res[0].Blocked, ShouldEqual, "interlock: room.ph > 5.2 and room.ph < 6.8")
+			So(done, ShouldBeEmpty)
+		})
+
+		Convey("it should not fire in other rooms", func() {
+			e.Evaluate("Flower", env, act)
+			now = start.Add(10 * time.Minute)
+			So(e.Evaluate("Flower", env, act), ShouldBeEmpty)
+		})
+
+		Convey("it should report errors from the actions", func() {
+			act := ActuatorFunc(func(a Action) error { return fmt.Errorf("device offline") })
+			e.Evaluate("Veg", env, act)
+			now = start.Add(10 * time.Minute)
+
+			res := e.Evaluate("Veg", env, act)
+			So(res[0].Fired, ShouldBeTrue)
+			So(res[0].Errors, ShouldResemble, []string{"ForceNutrientDose: device offline"})
+		})
+	})
+}
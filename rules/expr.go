@@ -0,0 +1,392 @@
+package rules
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Env gives the value of the metrics that conditions refer to, such as "room.ec" or
+// "ASLID17081149.ph".  It returns false if the metric is unknown or can't be trusted.
+type Env interface {
+	Metric(name string) (float64, bool)
+}
+
+// MapEnv is an Env backed by a map
+type MapEnv map[string]float64
+
+// Metric returns the value of the metric from the map
+func (m MapEnv) Metric(name string) (float64, bool) {
+	v, ok := m[name]
+	return v, ok
+}
+
+// Condition is a compiled condition such as "room.ec < 1.2 and room.ph > 5.5"
+type Condition struct {
+	src  string
+	root node
+}
+
+// Compile parses the given condition.  Conditions compare metrics and numbers with <, <=, >,
+// >=, == and !=, and can be combined with and, or, not and parentheses.
+func Compile(src string) (*Condition, error) {
+	toks, err := lex(src)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{toks: toks}
+	root, err := p.expr()
+	if err != nil {
+		return nil, fmt.Errorf("invalid condition %q: %s", src, err)
+	}
+
+	if !p.done() {
+		return nil, fmt.Errorf("invalid condition %q: unexpected %q", src, p.peek().val)
+	}
+
+	return &Condition{src, root}, nil
+}
+
+// String returns the source of the condition
+func (c *Condition) String() string {
+	return c.src
+}
+
+// Eval returns true if the condition holds.  A comparison with a metric that is missing from
+// the env is unknown rather than false, and so is anything that depends on it, even through a
+// not, so a rule will not fire on missing data.
+func (c *Condition) Eval(env Env) bool {
+	return c.root.eval(env) == isTrue
+}
+
+// truth is the result of evaluating a condition, which is unknown if it depends on a metric
+// that is missing
+type truth int
+
+const (
+	isFalse truth = iota
+	isTrue
+	isUnknown
+)
+
+func truthOf(b bool) truth {
+	if b {
+		return isTrue
+	}
+	return isFalse
+}
+
+// Metrics returns the names of the metrics the condition refers to
+func (c *Condition) Metrics() []string {
+	names := []string{}
+	c.root.metrics(&names)
+	return names
+}
+
+type tokenKind int
+
+const (
+	tokNumber tokenKind = iota
+	tokIdent
+	tokOp
+	tokLParen
+	tokRParen
+)
+
+type token struct {
+	kind tokenKind
+	val  string
+}
+
+func lex(src string) ([]token, error) {
+	toks := []token{}
+	rs := []rune(src)
+
+	for i := 0; i < len(rs); {
+		r := rs[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(':
+			toks = append(toks, token{tokLParen, "("})
+			i++
+		case r == ')':
+			toks = append(toks, token{tokRParen, ")"})
+			i++
+		case strings.ContainsRune("<>=!", r):
+			op := string(r)
+			if i+1 < len(rs) && rs[i+1] == '=' {
+				op += "="
+			}
+			if op == "=" || op == "!" {
+				return nil, fmt.Errorf("invalid operator %q at %d", op, i)
+			}
+			toks = append(toks, token{tokOp, op})
+			i += len(op)
+		case unicode.IsDigit(r) || r == '.' || (r == '-' && i+1 < len(rs) && (unicode.IsDigit(rs[i+1]) || rs[i+1] == '.')):
+			j := i + 1
+			for j < len(rs) && (unicode.IsDigit(rs[j]) || rs[j] == '.') {
+				j++
+			}
+			toks = append(toks, token{tokNumber, string(rs[i:j])})
+			i = j
+		case unicode.IsLetter(r) || r == '_':
+			j := i + 1
+			for j < len(rs) && (unicode.IsLetter(rs[j]) || unicode.IsDigit(rs[j]) || rs[j] == '_' || rs[j] == '.') {
+				j++
+			}
+			toks = append(toks, token{tokIdent, string(rs[i:j])})
+			i = j
+		default:
+			return nil, fmt.Errorf("unexpected %q at %d", r, i)
+		}
+	}
+
+	return toks, nil
+}
+
+type parser struct {
+	toks []token
+	pos  int
+}
+
+func (p *parser) done() bool {
+	return p.pos >= len(p.toks)
+}
+
+func (p *parser) peek() token {
+	if p.done() {
+		return token{}
+	}
+	return p.toks[p.pos]
+}
+
+func (p *parser) keyword(kw string) bool {
+	t := p.peek()
+	if t.kind == tokIdent && strings.ToLower(t.val) == kw && !p.done() {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) expr() (node, error) {
+	left, err := p.term()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.keyword("or") {
+		right, err := p.term()
+		if err != nil {
+			return nil, err
+		}
+		left = orNode{left, right}
+	}
+
+	return left, nil
+}
+
+func (p *parser) term() (node, error) {
+	left, err := p.factor()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.keyword("and") {
+		right, err := p.factor()
+		if err != nil {
+			return nil, err
+		}
+		left = andNode{left, right}
+	}
+
+	return left, nil
+}
+
+func (p *parser) factor() (node, error) {
+	if p.keyword("not") {
+		n, err := p.factor()
+		if err != nil {
+			return nil, err
+		}
+		return notNode{n}, nil
+	}
+
+	if p.peek().kind == tokLParen && !p.done() {
+		p.pos++
+		n, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		if p.done() || p.peek().kind != tokRParen {
+			return nil, fmt.Errorf("missing )")
+		}
+		p.pos++
+		return n, nil
+	}
+
+	return p.comparison()
+}
+
+func (p *parser) comparison() (node, error) {
+	left, err := p.operand()
+	if err != nil {
+		return nil, err
+	}
+
+	if p.done() || p.peek().kind != tokOp {
+		return nil, fmt.Errorf("expected a comparison after %q", left)
+	}
+	op := p.peek().val
+	p.pos++
+
+	right, err := p.operand()
+	if err != nil {
+		return nil, err
+	}
+
+	return cmpNode{left, op, right}, nil
+}
+
+func (p *parser) operand() (operand, error) {
+	if p.done() {
+		return operand{}, fmt.Errorf("unexpected end")
+	}
+
+	t := p.peek()
+	p.pos++
+
+	switch t.kind {
+	case tokNumber:
+		v, err := strconv.ParseFloat(t.val, 64)
+		if err != nil {
+			return operand{}, fmt.Errorf("invalid number %q", t.val)
+		}
+		return operand{value: v}, nil
+	case tokIdent:
+		return operand{metric: t.val}, nil
+	default:
+		return operand{}, fmt.Errorf("unexpected %q", t.val)
+	}
+}
+
+type node interface {
+	eval(Env) truth
+	metrics(*[]string)
+}
+
+type operand struct {
+	metric string
+	value  float64
+}
+
+func (o operand) String() string {
+	if o.metric != "" {
+		return o.metric
+	}
+	return strconv.FormatFloat(o.value, 'f', -1, 64)
+}
+
+func (o operand) get(env Env) (float64, bool) {
+	if o.metric == "" {
+		return o.value, true
+	}
+	return env.Metric(o.metric)
+}
+
+type cmpNode struct {
+	left  operand
+	op    string
+	right operand
+}
+
+func (n cmpNode) eval(env Env) truth {
+	l, ok := n.left.get(env)
+	if !ok {
+		return isUnknown
+	}
+
+	r, ok := n.right.get(env)
+	if !ok {
+		return isUnknown
+	}
+
+	switch n.op {
+	case "<":
+		return truthOf(l < r)
+	case "<=":
+		return truthOf(l <= r)
+	case ">":
+		return truthOf(l > r)
+	case ">=":
+		return truthOf(l >= r)
+	case "==":
+		return truthOf(l == r)
+	case "!=":
+		return truthOf(l != r)
+	}
+	return isUnknown
+}
+
+func (n cmpNode) metrics(names *[]string) {
+	for _, o := range []operand{n.left, n.right} {
+		if o.metric != "" {
+			*names = append(*names, o.metric)
+		}
+	}
+}
+
+type andNode struct{ left, right node }
+
+// eval is false if either side is false, even if the other is unknown
+func (n andNode) eval(env Env) truth {
+	l, r := n.left.eval(env), n.right.eval(env)
+	switch {
+	case l == isFalse || r == isFalse:
+		return isFalse
+	case l == isUnknown || r == isUnknown:
+		return isUnknown
+	}
+	return isTrue
+}
+
+func (n andNode) metrics(names *[]string) {
+	n.left.metrics(names)
+	n.right.metrics(names)
+}
+
+type orNode struct{ left, right node }
+
+// eval is true if either side is true, even if the other is unknown
+func (n orNode) eval(env Env) truth {
+	l, r := n.left.eval(env), n.right.eval(env)
+	switch {
+	case l == isTrue || r == isTrue:
+		return isTrue
+	case l == isUnknown || r == isUnknown:
+		return isUnknown
+	}
+	return isFalse
+}
+
+func (n orNode) metrics(names *[]string) {
+	n.left.metrics(names)
+	n.right.metrics(names)
+}
+
+type notNode struct{ n node }
+
+func (n notNode) eval(env Env) truth {
+	switch n.n.eval(env) {
+	case isTrue:
+		return isFalse
+	case isFalse:
+		return isTrue
+	}
+	return isUnknown
+}
+
+func (n notNode) metrics(names *[]string) { n.n.metrics(names) }
@@ -0,0 +1,157 @@
+package rules
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/autogrow/go-jelly/alarm"
+	"github.com/autogrow/go-jelly/health"
+	"github.com/autogrow/go-jelly/ig"
+)
+
+// GrowroomEnv returns an env for the growroom.  Room metrics are named "room.<metric>" and
+// device metrics "<serial>.<metric>", using the metric names of the alarm package.  Metrics
+// are only given while the devices reporting them are online.
+func GrowroomEnv(g *ig.Growroom) Env {
+	return growroomEnv{g}
+}
+
+type growroomEnv struct {
+	g *ig.Growroom
+}
+
+func (env growroomEnv) Metric(name string) (float64, bool) {
+	i := strings.LastIndex(name, ".")
+	if i < 0 {
+		return 0, false
+	}
+
+	prefix, metric := name[:i], name[i+1:]
+	if prefix == "room" {
+		return env.roomMetric(metric)
+	}
+
+	return env.deviceMetric(prefix, metric)
+}
+
+func (env growroomEnv) roomMetric(metric string) (float64, bool) {
+	g := env.g
+
+	climate := g.HasIntelliClimate() && g.ClimateState() == health.Online
+	rootzone := g.HasIntelliDose() && g.RootzoneState() == health.Online
+
+	switch metric {
+	case "air_temp":
+		return g.Climate.AirTemp, climate
+	case "rh":
+		return g.Climate.RH, climate
+	case "vpd":
+		return g.Climate.VPD, climate
+	case "co2":
+		return g.Climate.CO2, climate
+	case "light":
+		return g.Climate.Light, climate
+	case "dew_point":
+		dp, ok := g.DewPoint()
+		return dp, climate && ok
+	case "abs_humidity":
+		return g.AbsoluteHumidity(), climate
+	case "outside_temp":
+		return g.OutsideTempSensor, climate
+	case "ec":
+		return g.Rootzone.EC, rootzone
+	case "ph":
+		return g.Rootzone.PH, rootzone
+	case "nut_temp":
+		return g.Rootzone.Temp, rootzone
+	}
+
+	return 0, false
+}
+
+func (env growroomEnv) deviceMetric(nameOrID, metric string) (float64, bool) {
+	if id, err := env.g.IntelliDose(nameOrID); err == nil {
+		if id.HealthState() != health.Online {
+			return 0, false
+		}
+		v, ok := alarm.IntelliDoseReadings(id)[metric]
+		return v, ok
+	}
+
+	if ic, err := env.g.IntelliClimate(nameOrID); err == nil {
+		if ic.HealthState() != health.Online {
+			return 0, false
+		}
+		v, ok := alarm.IntelliClimateReadings(ic)[metric]
+		return v, ok
+	}
+
+	return 0, false
+}
+
+// GrowroomActuator returns an actuator that runs actions against the devices in the growroom.
+// Actions without a device are run on every device in the room that supports them.
+func GrowroomActuator(g *ig.Growroom) Actuator {
+	return ActuatorFunc(func(a Action) error {
+		doses, climates, err := targets(g, a)
+		if err != nil {
+			return err
+		}
+
+		for _, id := range doses {
+			if err := id.Do(a.call()); err != nil {
+				return fmt.Errorf("%s: %s", id.GetID(), err)
+			}
+		}
+
+		for _, ic := range climates {
+			if err := ic.Do(a.call()); err != nil {
+				return fmt.Errorf("%s: %s", ic.GetID(), err)
+			}
+		}
+
+		return nil
+	})
+}
+
+func targets(g *ig.Growroom, a Action) ([]*ig.IntelliDose, []*ig.IntelliClimate, error) {
+	var doses []*ig.IntelliDose
+	var climates []*ig.IntelliClimate
+
+	switch {
+	case ig.DoserActions[a.Do] && a.Device != "":
+		id, err := g.IntelliDose(a.Device)
+		if err != nil {
+			return nil, nil, err
+		}
+		doses = append(doses, id)
+	case ig.DoserActions[a.Do]:
+		doses, _ = g.IntelliDoses()
+	case ig.ClimateActions[a.Do] && a.Device != "":
+		ic, err := g.IntelliClimate(a.Device)
+		if err != nil {
+			return nil, nil, err
+		}
+		climates = append(climates, ic)
+	case ig.ClimateActions[a.Do]:
+		climates, _ = g.IntelliClimates()
+	default:
+		return nil, nil, fmt.Errorf("unknown action %q", a.Do)
+	}
+
+	if len(doses) == 0 && len(climates) == 0 {
+		return nil, nil, fmt.Errorf("no devices in %s support %s", g.GetName(), a.Do)
+	}
+
+	return doses, climates, nil
+}
+
+// call returns the device method call made by the action
+func (a Action) call() ig.Action {
+	return ig.Action{Do: a.Do, Value: a.Value, Station: a.Station}
+}
+
+// EvaluateGrowroom evaluates the rules for the growroom, running actions against its devices
+func EvaluateGrowroom(e *Engine, g *ig.Growroom) []Result {
+	return e.Evaluate(g.GetName(), GrowroomEnv(g), GrowroomActuator(g))
+}
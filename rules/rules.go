@@ -0,0 +1,116 @@
+package rules
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"time"
+)
+
+// Duration is a time.Duration that is given as a string such as "10m" in config files
+type Duration time.Duration
+
+// UnmarshalJSON parses the duration from a string
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("duration must be a string such as \"10m\": %s", err)
+	}
+
+	if s == "" {
+		*d = 0
+		return nil
+	}
+
+	dur, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+
+	*d = Duration(dur)
+	return nil
+}
+
+// MarshalJSON formats the duration as a string
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// Action is something done when a rule fires, either calling a method on a device or sending
+// a notification
+type Action struct {
+	// Do is the name of the device method to call, such as ForceNutrientDose
+	Do string `json:"do,omitempty"`
+	// Device is the name or serial of the device to call the method on, if empty the method is
+	// called on all devices in the room that support it
+	Device string `json:"device,omitempty"`
+	// Value is the argument to methods that need one such as SetCO2Target
+	Value float64 `json:"value,omitempty"`
+	// Station is the irrigation station for ForceStation
+	Station string `json:"station,omitempty"`
+
+	// Notify is the message to send when the rule fires
+	Notify string `json:"notify,omitempty"`
+}
+
+// String describes the action
+func (a Action) String() string {
+	if a.Notify != "" {
+		return fmt.Sprintf("notify %q", a.Notify)
+	}
+
+	if a.Device != "" {
+		return fmt.Sprintf("%s on %s", a.Do, a.Device)
+	}
+
+	return a.Do
+}
+
+// Rule runs actions once its condition has held for a duration
+type Rule struct {
+	Name string `json:"name"`
+	// Room is the growroom that the rule applies to, empty for all rooms
+	Room string `json:"room,omitempty"`
+	// When is the condition that triggers the rule
+	When string `json:"when"`
+	// For is how long the condition must hold for before the rule fires
+	For Duration `json:"for,omitempty"`
+	// Cooldown is the minimum time between the rule firing
+	Cooldown Duration `json:"cooldown,omitempty"`
+	// Interlocks are conditions that must all hold for the actions to be run, such as the
+	// pH being within a safe range before dosing nutrient
+	Interlocks []string `json:"interlocks,omitempty"`
+	Actions    []Action `json:"actions"`
+	Disabled   bool     `json:"disabled,omitempty"`
+}
+
+// Config is the format of a rules config file
+type Config struct {
+	Rules []Rule `json:"rules"`
+}
+
+// Parse returns the rules from the JSON config
+func Parse(data []byte) ([]Rule, error) {
+	cfg := Config{}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("couldn't parse rules: %s", err)
+	}
+
+	for _, r := range cfg.Rules {
+		if _, err := compile(r); err != nil {
+			return nil, err
+		}
+	}
+
+	return cfg.Rules, nil
+}
+
+// Load returns the rules from the JSON config file at the given path
+func Load(path string) ([]Rule, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't read rules file: %s", err)
+	}
+
+	return Parse(data)
+}
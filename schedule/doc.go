@@ -0,0 +1,38 @@
+// Package schedule runs actions on devices at set times, such as an extra flush at 06:00 every
+// Monday or restoring a known good config each night.
+//
+// Jobs are loaded from a JSON config file:
+//
+//     {
+//       "jobs": [
+//         {
+//           "name": "monday flush",
+//           "device": "ASLID17081149",
+//           "schedule": "0 6 * * mon",
+//           "actions": [{"do": "ForceIrrigation"}],
+//           "catch_up": true
+//         },
+//         {
+//           "name": "nightly config",
+//           "device": "Veg Climate",
+//           "schedule": "@daily",
+//           "actions": [{"do": "RestoreConfig", "config": "/etc/ig/veg-climate.json"}]
+//         }
+//       ]
+//     }
+//
+// Schedules are 5 field cron expressions, shorthands such as @hourly and @daily, or intervals
+// that divide a day evenly such as "@every 4h", and are given in the time zone of the device.
+// The actions of a job are sent to the device in a single transaction.
+//
+// The last and next run of each job are kept in the state file so that runs missed while the
+// scheduler wasn't running are handled when it starts again: jobs with catch_up set run once,
+// others skip the missed runs.  Every run, skipped or not, is appended to the run log:
+//
+//     jobs, err := schedule.LoadJobs("jobs.json")
+//     s, err := schedule.NewScheduler(jobs, schedule.NewClientExecutor(client))
+//     s.StateFile = "schedule.state"
+//     s.LogFile = "schedule.log"
+//
+//     s.Run(30*time.Second, quit, func(err error) { log.Println(err) })
+package schedule
@@ -0,0 +1,63 @@
+package schedule
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/autogrow/go-jelly/ig"
+)
+
+// ClientExecutor runs jobs on devices through the IntelliGrow API
+type ClientExecutor struct {
+	client *ig.Client
+}
+
+// NewClientExecutor returns an executor that runs jobs on the devices known to the client
+func NewClientExecutor(client *ig.Client) *ClientExecutor {
+	return &ClientExecutor{client}
+}
+
+// Location returns the time zone of the device, or UTC if the device is unknown
+func (ce *ClientExecutor) Location(device string) *time.Location {
+	if id, err := ce.client.IntelliDose(device); err == nil {
+		return id.Location()
+	}
+
+	if ic, err := ce.client.IntelliClimate(device); err == nil {
+		return ic.Location()
+	}
+
+	return time.UTC
+}
+
+// Execute runs the actions on the device in a single transaction
+func (ce *ClientExecutor) Execute(device string, actions []Action) error {
+	if id, err := ce.client.IntelliDose(device); err == nil {
+		return id.Transaction(func() error {
+			for _, a := range actions {
+				if err := id.Do(a.call()); err != nil {
+					return fmt.Errorf("%s: %s", a, err)
+				}
+			}
+			return nil
+		})
+	}
+
+	if ic, err := ce.client.IntelliClimate(device); err == nil {
+		return ic.Transaction(func() error {
+			for _, a := range actions {
+				if err := ic.Do(a.call()); err != nil {
+					return fmt.Errorf("%s: %s", a, err)
+				}
+			}
+			return nil
+		})
+	}
+
+	return fmt.Errorf("no device found with serial/name of %s", device)
+}
+
+// call returns the device method call made by the action
+func (a Action) call() ig.Action {
+	return ig.Action{Do: a.Do, Value: a.Value, Station: a.Station, Config: a.Config}
+}
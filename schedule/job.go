@@ -0,0 +1,139 @@
+package schedule
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	"github.com/autogrow/go-jelly/ig"
+)
+
+// Action is a device method called when a job runs
+type Action struct {
+	// Do is the name of the device method to call, such as ForceIrrigation
+	Do string `json:"do"`
+	// Value is the argument to methods that need one such as SetPHTarget
+	Value float64 `json:"value,omitempty"`
+	// Station is the irrigation station for ForceStation
+	Station string `json:"station,omitempty"`
+	// Config is the path to the saved config for RestoreConfig
+	Config string `json:"config,omitempty"`
+}
+
+// String describes the action
+func (a Action) String() string {
+	switch a.Do {
+	case "ForceStation":
+		return fmt.Sprintf("%s %s", a.Do, a.Station)
+	case "RestoreConfig":
+		return fmt.Sprintf("%s %s", a.Do, a.Config)
+	case "SetNutrientTarget", "SetPHTarget", "SetTempTarget", "SetCO2Target", "SetRHTarget":
+		return fmt.Sprintf("%s %g", a.Do, a.Value)
+	}
+	return a.Do
+}
+
+// Job runs actions on a device at the times given by its schedule.  All the actions of a job
+// are sent to the device in a single transaction.
+type Job struct {
+	Name string `json:"name"`
+	// Device is the name or serial of the device to run the actions on
+	Device string `json:"device"`
+	// Schedule is a cron expression or interval such as "0 6 * * mon" or "@every 4h", in the
+	// time zone of the device
+	Schedule string   `json:"schedule"`
+	Actions  []Action `json:"actions"`
+	// CatchUp runs the job once if any runs were missed while the scheduler wasn't running,
+	// otherwise missed runs are skipped and recorded in the run log
+	CatchUp  bool `json:"catch_up,omitempty"`
+	Disabled bool `json:"disabled,omitempty"`
+}
+
+func (j Job) validate() (Schedule, error) {
+	if j.Name == "" {
+		return nil, fmt.Errorf("job has no name")
+	}
+
+	if j.Device == "" {
+		return nil, fmt.Errorf("job %s has no device", j.Name)
+	}
+
+	sched, err := Parse(j.Schedule)
+	if err != nil {
+		return nil, fmt.Errorf("job %s: %s", j.Name, err)
+	}
+
+	if len(j.Actions) == 0 {
+		return nil, fmt.Errorf("job %s has no actions", j.Name)
+	}
+
+	for _, a := range j.Actions {
+		switch {
+		case !ig.DoserActions[a.Do] && !ig.ClimateActions[a.Do]:
+			return nil, fmt.Errorf("job %s: unknown action %q", j.Name, a.Do)
+		case a.Do == "ForceStation" && a.Station == "":
+			return nil, fmt.Errorf("job %s: ForceStation needs a station", j.Name)
+		case a.Do == "RestoreConfig" && a.Config == "":
+			return nil, fmt.Errorf("job %s: RestoreConfig needs a config", j.Name)
+		}
+	}
+
+	return sched, nil
+}
+
+// Config is the format of a schedule config file
+type Config struct {
+	Jobs []Job `json:"jobs"`
+}
+
+// ParseJobs returns the jobs from the JSON config
+func ParseJobs(data []byte) ([]Job, error) {
+	cfg := Config{}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("couldn't parse jobs: %s", err)
+	}
+
+	names := map[string]bool{}
+	for _, j := range cfg.Jobs {
+		if _, err := j.validate(); err != nil {
+			return nil, err
+		}
+
+		if names[j.Name] {
+			return nil, fmt.Errorf("there is more than one job named %s", j.Name)
+		}
+		names[j.Name] = true
+	}
+
+	return cfg.Jobs, nil
+}
+
+// LoadJobs returns the jobs from the JSON config file at the given path
+func LoadJobs(path string) ([]Job, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't read jobs file: %s", err)
+	}
+
+	return ParseJobs(data)
+}
+
+// Run is an entry in the run log
+type Run struct {
+	Job    string `json:"job"`
+	Device string `json:"device"`
+	// Scheduled is the time the job was due to run
+	Scheduled time.Time `json:"scheduled"`
+	// Started is the time the job actually ran, zero if it was skipped
+	Started time.Time `json:"started,omitempty"`
+	// Missed is the number of runs that were missed while the scheduler wasn't running
+	Missed  int    `json:"missed,omitempty"`
+	Skipped bool   `json:"skipped,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// OK returns true if the job ran without error
+func (r Run) OK() bool {
+	return !r.Skipped && r.Error == ""
+}
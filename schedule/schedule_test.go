@@ -0,0 +1,188 @@
+package schedule
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type never struct{}
+
+func (never) Next(t time.Time) time.Time { return time.Time{} }
+
+type fakeExecutor struct {
+	loc  *time.Location
+	runs []string
+	err  error
+}
+
+func (fe *fakeExecutor) Location(device string) *time.Location {
+	return fe.loc
+}
+
+func (fe *fakeExecutor) Execute(device string, actions []Action) error {
+	fe.runs = append(fe.runs, fmt.Sprintf("%s %s", device, actions[0]))
+	return fe.err
+}
+
+func TestParse(t *testing.T) {
+	loc := time.FixedZone("UTC+12", 12*3600)
+	// a Sunday
+	start := time.Date(2018, 1, 7, 10, 30, 0, 0, loc)
+
+	Convey("it should give the next time for cron expressions", t, func() {
+		for spec, want := range map[string]time.Time{
+			"0 6 * * mon":    time.Date(2018, 1, 8, 6, 0, 0, 0, loc),
+			"*/15 * * * *":   time.Date(2018, 1, 7, 10, 45, 0, 0, loc),
+			"0 9-17/4 * * *": time.Date(2018, 1, 7, 13, 0, 0, 0, loc),
+			"30 10 * * 0,7":  time.Date(2018, 1, 14, 10, 30, 0, 0, loc),
+			"0 0 1 feb *":    time.Date(2018, 2, 1, 0, 0, 0, 0, loc),
+			"0 0 29 2 *":     time.Date(2020, 2, 29, 0, 0, 0, 0, loc),
+			"0 0 15 * fri":   time.Date(2018, 1, 12, 0, 0, 0, 0, loc),
+			"@daily":         time.Date(2018, 1, 8, 0, 0, 0, 0, loc),
+			"@hourly":        time.Date(2018, 1, 7, 11, 0, 0, 0, loc),
+			"@every 4h":      time.Date(2018, 1, 7, 12, 0, 0, 0, loc),
+			"@every 45m":     time.Date(2018, 1, 7, 11, 15, 0, 0, loc),
+		} {
+			sched, err := Parse(spec)
+			So(err, ShouldBeNil)
+			So(sched.Next(start), ShouldResemble, want)
+		}
+	})
+
+	Convey("it should not parse invalid specs", t, func() {
+		for _, spec := range []string{"", "* * * *", "60 * * * *", "0 24 * * *", "0 0 0 * *", "0 0 * 13 *", "0 0 * * funday", "5-1 * * * *", "*/0 * * * *", "@every 10s", "@every soon", "@every 7h", "@every 36h", "0 6 30 2 *", "0 0 31 apr,jun *"} {
+			_, err := Parse(spec)
+			So(err, ShouldNotBeNil)
+		}
+	})
+}
+
+func TestParseJobs(t *testing.T) {
+	Convey("it should parse a jobs config", t, func() {
+		jobs, err := ParseJobs([]byte(`{"jobs": [{"name": "flush", "device": "ID1", "schedule": "0 6 * * mon", "actions": [{"do": "ForceStation", "station": "2"}], "catch_up": true}]}`))
+		So(err, ShouldBeNil)
+		So(len(jobs), ShouldEqual, 1)
+		So(jobs[0].CatchUp, ShouldBeTrue)
+		So(jobs[0].Actions[0].String(), ShouldEqual, "ForceStation 2")
+	})
+
+	Convey("it should not parse invalid jobs", t, func() {
+		for _, cfg := range []string{
+			`{"jobs": [{"name": "a", "device": "ID1", "schedule": "daily", "actions": [{"do": "ForceIrrigation"}]}]}`,
+			`{"jobs": [{"name": "a", "schedule": "@daily", "actions": [{"do": "ForceIrrigation"}]}]}`,
+			`{"jobs": [{"name": "a", "device": "ID1", "schedule": "@daily", "actions": [{"do": "Explode"}]}]}`,
+			`{"jobs": [{"name": "a", "device": "ID1", "schedule": "@daily", "actions": [{"do": "RestoreConfig"}]}]}`,
+			`{"jobs": [{"name": "a", "device": "ID1", "schedule": "@daily"}]}`,
+			`{"jobs": [{"name": "a", "device": "ID1", "schedule": "@daily", "actions": [{"do": "ForceIrrigation"}]}, {"name": "a", "device": "ID2", "schedule": "@daily", "actions": [{"do": "ForceIrrigation"}]}]}`,
+		} {
+			_, err := ParseJobs([]byte(cfg))
+			So(err, ShouldNotBeNil)
+		}
+	})
+}
+
+func TestScheduler(t *testing.T) {
+	loc := time.FixedZone("UTC+12", 12*3600)
+	start := time.Date(2018, 1, 7, 10, 30, 0, 0, loc)
+
+	Convey("given a scheduler with a daily job", t, func() {
+		dir, err := ioutil.TempDir("", "schedule")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(dir)
+
+		jobs := []Job{{Name: "flush", Device: "ID1", Schedule: "0 6 * * *", Actions: []Action{{Do: "ForceIrrigation"}}}}
+		exec := &fakeExecutor{loc: loc}
+
+		now := start
+		newScheduler := func() *Scheduler {
+			s, err := NewScheduler(jobs, exec)
+			So(err, ShouldBeNil)
+			s.StateFile = filepath.Join(dir, "state.json")
+			s.LogFile = filepath.Join(dir, "runs.log")
+			s.now = func() time.Time { return now }
+			return s
+		}
+
+		s := newScheduler()
+		runs, err := s.Tick()
+		So(err, ShouldBeNil)
+		So(runs, ShouldBeEmpty)
+
+		next, ok := s.Next("flush")
+		So(ok, ShouldBeTrue)
+		So(next, ShouldResemble, time.Date(2018, 1, 8, 6, 0, 0, 0, loc))
+
+		Convey("it should run the job in the time zone of the device", func() {
+			now = time.Date(2018, 1, 8, 6, 0, 30, 0, loc)
+			runs, err := s.Tick()
+			So(err, ShouldBeNil)
+			So(len(runs), ShouldEqual, 1)
+			So(runs[0].OK(), ShouldBeTrue)
+			So(runs[0].Missed, ShouldEqual, 0)
+			So(exec.runs, ShouldResemble, []string{"ID1 ForceIrrigation"})
+
+			runs, err = s.Tick()
+			So(err, ShouldBeNil)
+			So(runs, ShouldBeEmpty)
+		})
+
+		Convey("it should record errors in the run log", func() {
+			exec.err = fmt.Errorf("device offline")
+			now = time.Date(2018, 1, 8, 6, 0, 30, 0, loc)
+			_, err := s.Tick()
+			So(err, ShouldBeNil)
+
+			log, err := ReadLog(s.LogFile)
+			So(err, ShouldBeNil)
+			So(len(log), ShouldEqual, 1)
+			So(log[0].Job, ShouldEqual, "flush")
+			So(log[0].Error, ShouldEqual, "device offline")
+		})
+
+		Convey("when restarted after missing runs", func() {
+			now = time.Date(2018, 1, 10, 12, 0, 0, 0, loc)
+
+			Convey("it should skip them", func() {
+				runs, err := newScheduler().Tick()
+				So(err, ShouldBeNil)
+				So(len(runs), ShouldEqual, 1)
+				So(runs[0].Skipped, ShouldBeTrue)
+				So(runs[0].Missed, ShouldEqual, 3)
+				So(exec.runs, ShouldBeEmpty)
+			})
+
+			Convey("it should run once if the job catches up", func() {
+				jobs[0].CatchUp = true
+				s := newScheduler()
+				runs, err := s.Tick()
+				So(err, ShouldBeNil)
+				So(len(runs), ShouldEqual, 1)
+				So(runs[0].OK(), ShouldBeTrue)
+				So(runs[0].Missed, ShouldEqual, 3)
+				So(exec.runs, ShouldResemble, []string{"ID1 ForceIrrigation"})
+
+				next, _ := s.Next("flush")
+				So(next, ShouldResemble, time.Date(2018, 1, 11, 6, 0, 0, 0, loc))
+			})
+		})
+
+		Convey("it should not run a job whose schedule has no next time", func() {
+			jobs[0].CatchUp = true
+			s := newScheduler()
+			s.StateFile = ""
+			s.jobs[0].sched = never{}
+			for i := 0; i < 3; i++ {
+				runs, err := s.Tick()
+				So(err, ShouldNotBeNil)
+				So(runs, ShouldBeEmpty)
+			}
+			So(exec.runs, ShouldBeEmpty)
+		})
+	})
+}
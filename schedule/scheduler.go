@@ -0,0 +1,333 @@
+package schedule
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// maxCatchUp is the most missed runs that are counted before skipping straight to the next run
+const maxCatchUp = 10000
+
+// Executor runs the actions of jobs on devices
+type Executor interface {
+	// Location returns the time zone of the device that the schedule is given in
+	Location(device string) *time.Location
+	// Execute runs the actions on the device in a single transaction
+	Execute(device string, actions []Action) error
+}
+
+type jobState struct {
+	Schedule string    `json:"schedule"`
+	LastRun  time.Time `json:"last_run,omitempty"`
+	Next     time.Time `json:"next"`
+}
+
+type job struct {
+	Job
+	sched Schedule
+}
+
+// Scheduler runs jobs on devices at the times given by their schedules
+type Scheduler struct {
+	lock   *sync.Mutex
+	exec   Executor
+	jobs   []*job
+	state  map[string]*jobState
+	loaded bool
+	recent []Run
+
+	// StateFile is where the last and next run times of jobs are kept, so that runs missed
+	// while the scheduler wasn't running are handled when it starts again
+	StateFile string
+
+	// LogFile is where each run is appended as a line of JSON
+	LogFile string
+
+	// Grace is how late a run can be before it is considered missed
+	Grace time.Duration
+
+	// OnRun is called with each entry added to the run log
+	OnRun func(Run)
+
+	now func() time.Time
+}
+
+// NewScheduler returns a scheduler for the given jobs that runs them with the executor
+func NewScheduler(jobs []Job, exec Executor) (*Scheduler, error) {
+	s := &Scheduler{
+		lock:  new(sync.Mutex),
+		exec:  exec,
+		state: map[string]*jobState{},
+		Grace: 5 * time.Minute,
+		now:   time.Now,
+	}
+
+	for _, j := range jobs {
+		sched, err := j.validate()
+		if err != nil {
+			return nil, err
+		}
+		s.jobs = append(s.jobs, &job{j, sched})
+	}
+
+	return s, nil
+}
+
+// Next returns the next time the named job will run
+func (s *Scheduler) Next(name string) (time.Time, bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	st, ok := s.state[name]
+	if !ok {
+		return time.Time{}, false
+	}
+
+	return st.Next, true
+}
+
+// Runs returns the most recent entries in the run log
+func (s *Scheduler) Runs() []Run {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return append([]Run{}, s.recent...)
+}
+
+// Tick runs any jobs that are due, returning the entries added to the run log
+func (s *Scheduler) Tick() ([]Run, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if !s.loaded {
+		if err := s.loadState(); err != nil {
+			return nil, err
+		}
+		s.loaded = true
+	}
+
+	now := s.now()
+	runs := []Run{}
+	changed := false
+	var schedErr error
+
+	for _, j := range s.jobs {
+		if j.Disabled {
+			continue
+		}
+
+		loc := s.exec.Location(j.Device)
+
+		st, ok := s.state[j.Name]
+		if !ok {
+			st = &jobState{}
+			s.state[j.Name] = st
+		}
+
+		// start from the last run so runs missed since are picked up, unless the schedule has
+		// changed in which case start from now
+		if st.Next.IsZero() || st.Schedule != j.Schedule {
+			base := st.LastRun
+			if base.IsZero() || st.Schedule != j.Schedule {
+				base = now
+			}
+			st.Schedule = j.Schedule
+			st.Next = j.sched.Next(base.In(loc))
+			changed = true
+		}
+
+		// a schedule with no next time would otherwise be due on every tick
+		if st.Next.IsZero() {
+			if schedErr == nil {
+				schedErr = fmt.Errorf("job %s: schedule %q has no next run", j.Name, j.Schedule)
+			}
+			continue
+		}
+
+		if now.Before(st.Next) {
+			continue
+		}
+
+		run, next := s.run(j, st.Next, now, loc)
+		if !run.Started.IsZero() {
+			st.LastRun = run.Started
+		}
+		st.Next = next
+		changed = true
+
+		runs = append(runs, run)
+		if err := s.record(run); err != nil {
+			return runs, err
+		}
+	}
+
+	if changed {
+		if err := s.saveState(); err != nil {
+			return runs, err
+		}
+	}
+
+	return runs, schedErr
+}
+
+// run the job if it is due, or skip it if it was missed, returning the run and the next time
+// the job is due
+func (s *Scheduler) run(j *job, due, now time.Time, loc *time.Location) (Run, time.Time) {
+	missed := 0
+	next := j.sched.Next(due.In(loc))
+	for !next.After(now) && missed < maxCatchUp {
+		missed++
+		due = next
+		next = j.sched.Next(due)
+	}
+
+	if !next.After(now) {
+		next = j.sched.Next(now.In(loc))
+	}
+
+	late := now.Sub(due) > s.Grace
+	if late {
+		missed++
+	}
+
+	run := Run{Job: j.Name, Device: j.Device, Scheduled: due, Missed: missed}
+
+	if late && !j.CatchUp {
+		run.Skipped = true
+		return run, next
+	}
+
+	run.Started = now
+	if err := s.exec.Execute(j.Device, j.Actions); err != nil {
+		run.Error = err.Error()
+	}
+
+	return run, next
+}
+
+func (s *Scheduler) record(run Run) error {
+	s.recent = append(s.recent, run)
+	if len(s.recent) > 100 {
+		s.recent = s.recent[len(s.recent)-100:]
+	}
+
+	if s.OnRun != nil {
+		s.OnRun(run)
+	}
+
+	if s.LogFile == "" {
+		return nil
+	}
+
+	data, err := json.Marshal(run)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(s.LogFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("couldn't open run log: %s", err)
+	}
+	defer f.Close()
+
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("couldn't write to run log: %s", err)
+	}
+
+	return nil
+}
+
+func (s *Scheduler) loadState() error {
+	if s.StateFile == "" {
+		return nil
+	}
+
+	data, err := ioutil.ReadFile(s.StateFile)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("couldn't read scheduler state: %s", err)
+	}
+
+	if err := json.Unmarshal(data, &s.state); err != nil {
+		return fmt.Errorf("couldn't parse scheduler state: %s", err)
+	}
+
+	return nil
+}
+
+// saveState writes the state to a temporary file first so that it isn't lost if the scheduler
+// stops part way through
+func (s *Scheduler) saveState() error {
+	if s.StateFile == "" {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(s.state, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp := filepath.Join(filepath.Dir(s.StateFile), "."+filepath.Base(s.StateFile)+".tmp")
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("couldn't write scheduler state: %s", err)
+	}
+
+	if err := os.Rename(tmp, s.StateFile); err != nil {
+		return fmt.Errorf("couldn't write scheduler state: %s", err)
+	}
+
+	return nil
+}
+
+// Run checks for jobs that are due every interval until told to quit, errors are passed to
+// onError if it isn't nil
+func (s *Scheduler) Run(interval time.Duration, quit chan bool, onError func(error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	tick := func() {
+		if _, err := s.Tick(); err != nil && onError != nil {
+			onError(err)
+		}
+	}
+
+	tick()
+	for {
+		select {
+		case <-ticker.C:
+			tick()
+		case stop, ok := <-quit:
+			if !ok || stop {
+				return
+			}
+		}
+	}
+}
+
+// ReadLog returns the entries in the run log at the given path
+func ReadLog(path string) ([]Run, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't open run log: %s", err)
+	}
+	defer f.Close()
+
+	runs := []Run{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		run := Run{}
+		if err := json.Unmarshal(scanner.Bytes(), &run); err != nil {
+			return nil, fmt.Errorf("couldn't parse run log: %s", err)
+		}
+		runs = append(runs, run)
+	}
+
+	return runs, scanner.Err()
+}
@@ -0,0 +1,270 @@
+package schedule
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule gives the times that a job should run
+type Schedule interface {
+	// Next returns the first time after t that the job should run, in the location of t
+	Next(t time.Time) time.Time
+}
+
+// Every runs a job at a fixed interval, aligned to midnight in the location of the job.  The
+// interval must divide a day evenly so that runs are the same distance apart across midnight.
+type Every time.Duration
+
+// Next returns the next multiple of the interval since midnight after t
+func (e Every) Next(t time.Time) time.Time {
+	d := time.Duration(e)
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	since := t.Sub(midnight)
+	return midnight.Add((since/d + 1) * d)
+}
+
+// Cron runs a job at the times matched by a cron expression
+type Cron struct {
+	src    string
+	minute uint64
+	hour   uint64
+	dom    uint64
+	month  uint64
+	dow    uint64
+	anyDOM bool
+	anyDOW bool
+}
+
+// String returns the cron expression
+func (c *Cron) String() string {
+	return c.src
+}
+
+// Next returns the first minute after t that matches the expression
+func (c *Cron) Next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+
+	// a matching time is always within the next 4 years, allowing for Feb 29th
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		if !has(c.month, int(t.Month())) {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+
+		if !c.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+
+		if !has(c.hour, t.Hour()) {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+			continue
+		}
+
+		if !has(c.minute, t.Minute()) {
+			t = t.Add(time.Minute)
+			continue
+		}
+
+		return t
+	}
+
+	return time.Time{}
+}
+
+// dayMatches follows the cron convention that when both the day of month and day of week are
+// restricted, a day matching either is used
+func (c *Cron) dayMatches(t time.Time) bool {
+	dom := has(c.dom, t.Day())
+	dow := has(c.dow, int(t.Weekday()))
+
+	switch {
+	case c.anyDOM && c.anyDOW:
+		return true
+	case c.anyDOM:
+		return dow
+	case c.anyDOW:
+		return dom
+	}
+
+	return dom || dow
+}
+
+func has(set uint64, v int) bool {
+	return set&(1<<uint(v)) != 0
+}
+
+var (
+	monthNames = map[string]int{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}
+
+	dayNames = map[string]int{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}
+
+	// matchCheckFrom starts a span of years including a leap year, used to check that a cron
+	// expression matches a date at all
+	matchCheckFrom = time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	shorthands = map[string]string{
+		"@yearly":   "0 0 1 1 *",
+		"@annually": "0 0 1 1 *",
+		"@monthly":  "0 0 1 * *",
+		"@weekly":   "0 0 * * 0",
+		"@daily":    "0 0 * * *",
+		"@midnight": "0 0 * * *",
+		"@hourly":   "0 * * * *",
+	}
+)
+
+// Parse parses a schedule spec, which is either a standard 5 field cron expression such as
+// "0 6 * * mon", a shorthand such as "@daily" or an interval such as "@every 30m"
+func Parse(spec string) (Schedule, error) {
+	spec = strings.TrimSpace(spec)
+
+	if strings.HasPrefix(spec, "@every ") {
+		d, err := time.ParseDuration(strings.TrimSpace(strings.TrimPrefix(spec, "@every ")))
+		if err != nil {
+			return nil, fmt.Errorf("invalid schedule %q: %s", spec, err)
+		}
+
+		if d < time.Minute {
+			return nil, fmt.Errorf("invalid schedule %q: interval must be at least a minute", spec)
+		}
+
+		if (24*time.Hour)%d != 0 {
+			return nil, fmt.Errorf("invalid schedule %q: interval must divide a day evenly", spec)
+		}
+
+		return Every(d), nil
+	}
+
+	expr := spec
+	if sh, ok := shorthands[spec]; ok {
+		expr = sh
+	}
+
+	c, err := parseCron(expr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid schedule %q: %s", spec, err)
+	}
+
+	// a date such as the 30th of February never comes, which Next reports as the zero time
+	if c.Next(matchCheckFrom).IsZero() {
+		return nil, fmt.Errorf("invalid schedule %q: never matches a date", spec)
+	}
+
+	c.src = spec
+	return c, nil
+}
+
+func parseCron(expr string) (*Cron, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("expected 5 fields but found %d", len(fields))
+	}
+
+	c := &Cron{
+		anyDOM: fields[2] == "*" || fields[2] == "?",
+		anyDOW: fields[4] == "*" || fields[4] == "?",
+	}
+
+	var err error
+	if c.minute, err = parseField(fields[0], 0, 59, nil); err != nil {
+		return nil, fmt.Errorf("minute: %s", err)
+	}
+
+	if c.hour, err = parseField(fields[1], 0, 23, nil); err != nil {
+		return nil, fmt.Errorf("hour: %s", err)
+	}
+
+	if c.dom, err = parseField(fields[2], 1, 31, nil); err != nil {
+		return nil, fmt.Errorf("day of month: %s", err)
+	}
+
+	if c.month, err = parseField(fields[3], 1, 12, monthNames); err != nil {
+		return nil, fmt.Errorf("month: %s", err)
+	}
+
+	if c.dow, err = parseField(fields[4], 0, 7, dayNames); err != nil {
+		return nil, fmt.Errorf("day of week: %s", err)
+	}
+
+	// 7 is also Sunday
+	if has(c.dow, 7) {
+		c.dow |= 1
+	}
+
+	return c, nil
+}
+
+// parseField parses a comma separated list of values, ranges and steps such as "1-5,*/15"
+func parseField(field string, min, max int, names map[string]int) (uint64, error) {
+	var set uint64
+
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			var err error
+			step, err = strconv.Atoi(part[i+1:])
+			if err != nil || step < 1 {
+				return 0, fmt.Errorf("invalid step in %q", part)
+			}
+			part = part[:i]
+		}
+
+		lo, hi := min, max
+		switch {
+		case part == "*" || part == "?":
+		case strings.Contains(part, "-"):
+			bounds := strings.SplitN(part, "-", 2)
+			var err error
+			if lo, err = parseValue(bounds[0], min, max, names); err != nil {
+				return 0, err
+			}
+			if hi, err = parseValue(bounds[1], min, max, names); err != nil {
+				return 0, err
+			}
+			if lo > hi {
+				return 0, fmt.Errorf("invalid range %q", part)
+			}
+		default:
+			var err error
+			if lo, err = parseValue(part, min, max, names); err != nil {
+				return 0, err
+			}
+			hi = lo
+			if step > 1 {
+				hi = max
+			}
+		}
+
+		for v := lo; v <= hi; v += step {
+			set |= 1 << uint(v)
+		}
+	}
+
+	return set, nil
+}
+
+func parseValue(s string, min, max int, names map[string]int) (int, error) {
+	if v, ok := names[strings.ToLower(s)]; ok {
+		return v, nil
+	}
+
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value %q", s)
+	}
+
+	if v < min || v > max {
+		return 0, fmt.Errorf("%d is not between %d and %d", v, min, max)
+	}
+
+	return v, nil
+}
@@ -0,0 +1,96 @@
+package sfc
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/nats-io/go-nats"
+)
+
+var (
+	// ErrNotAttached is returned when a command is given to a device that isn't subscribed to a
+	// connection
+	ErrNotAttached = errors.New("device is not subscribed to a connection")
+
+	// ErrNotConnected is the Err of a CommandError when the connection to the server is down or
+	// closed, so the command wasn't sent
+	ErrNotConnected = errors.New("not connected to the server")
+)
+
+// Ack is the reply of a device to a command
+type Ack struct {
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+// CommandError is returned when a device refuses a command, or doesn't acknowledge it in time
+type CommandError struct {
+	Serial string
+	Err    error
+	// Replied is true if the device replied to the command, but refused it
+	Replied bool
+}
+
+func (e CommandError) Error() string {
+	return fmt.Sprintf("command to %s failed: %s", e.Serial, e.Err)
+}
+
+// commandTopic is the subject that a device listens for commands on
+func commandTopic(serial string) string {
+	return topic(serial) + "/set"
+}
+
+// command is the payload of a command, which replaces the state and config of the device in
+// the same shape as a save to the IntelliGrow API
+type command struct {
+	Device string      `json:"device"`
+	State  interface{} `json:"state"`
+	Config interface{} `json:"config"`
+}
+
+// request sends the command to the device and waits for it to be acknowledged
+func (c *Connection) request(serial string, cmd command) error {
+	data, err := json.Marshal(cmd)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	nc := c.nc
+	closed := c.closed
+	c.mu.Unlock()
+
+	if closed || !nc.IsConnected() {
+		return CommandError{Serial: serial, Err: ErrNotConnected}
+	}
+
+	// a timeout isn't ErrNotConnected, as the device may have carried out the command without
+	// acknowledging it
+	msg, err := nc.Request(commandTopic(serial), data, c.opts.CommandTimeout)
+	if err == nats.ErrConnectionClosed {
+		err = ErrNotConnected
+	}
+	if err != nil {
+		return CommandError{Serial: serial, Err: err}
+	}
+
+	// an empty reply is taken as an acknowledgement
+	if len(msg.Data) == 0 {
+		return nil
+	}
+
+	ack := Ack{}
+	if err := json.Unmarshal(msg.Data, &ack); err != nil {
+		return CommandError{serial, fmt.Errorf("invalid acknowledgement: %s", err), true}
+	}
+
+	if !ack.OK {
+		if ack.Error == "" {
+			ack.Error = "refused"
+		}
+		return CommandError{serial, errors.New(ack.Error), true}
+	}
+
+	return nil
+}
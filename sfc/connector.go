@@ -1,7 +1,11 @@
 package sfc
 
 import (
+	"errors"
 	"fmt"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/nats-io/go-nats"
 )
@@ -11,23 +15,380 @@ type intelli interface {
 	Update([]byte) error
 }
 
-// ConnectToNATS will connect an intelli object to the NATS server running on an IntelliLink device
-func ConnectToNATS(i intelli, url string, timeout int) error {
-	client, err := nats.Connect(url)
+// commander is a device that can be sent commands over the connection it is subscribed to
+type commander interface {
+	attach(*Connection)
+}
+
+// Options configure a connection to the NATS server on an IntelliLink
+type Options struct {
+	// Timeout is how long to wait when connecting, 10 seconds if zero
+	Timeout time.Duration
+	// ReconnectWait is how long to wait before the first attempt to reconnect after the
+	// connection is lost, 1 second if zero.  The wait doubles after each failed attempt up to
+	// MaxReconnectWait, 1 minute if zero.
+	ReconnectWait    time.Duration
+	MaxReconnectWait time.Duration
+	// CommandTimeout is how long to wait for a device to acknowledge a command, 5 seconds if zero
+	CommandTimeout time.Duration
+	// MaxReconnects is the number of attempts to reconnect before giving up, forever if zero and
+	// never if negative
+	MaxReconnects int
+	// OnDisconnect is called with the reason when the connection is lost
+	OnDisconnect func(error)
+	// OnReconnect is called once the connection is back and the devices are subscribed again
+	OnReconnect func()
+}
+
+func (o Options) withDefaults() Options {
+	if o.Timeout <= 0 {
+		o.Timeout = 10 * time.Second
+	}
+
+	if o.ReconnectWait <= 0 {
+		o.ReconnectWait = time.Second
+	}
+
+	if o.MaxReconnectWait <= 0 {
+		o.MaxReconnectWait = time.Minute
+	}
+
+	if o.CommandTimeout <= 0 {
+		o.CommandTimeout = 5 * time.Second
+	}
+
+	if o.MaxReconnectWait < o.ReconnectWait {
+		o.MaxReconnectWait = o.ReconnectWait
+	}
+
+	return o
+}
+
+// UpdateError is an error updating a device from a payload it published
+type UpdateError struct {
+	Serial string
+	Err    error
+}
+
+func (e UpdateError) Error() string {
+	return fmt.Sprintf("failed to update %s: %s", e.Serial, e.Err)
+}
+
+// Connection is a connection to the NATS server on an IntelliLink that any number of devices can
+// be subscribed to.  It reconnects and subscribes the devices again if the connection is lost.
+type Connection struct {
+	url  string
+	opts Options
+
+	mu     sync.Mutex
+	nc     *nats.Conn
+	subs   map[string]*subscription
+	errs   chan error
+	closed bool
+	quit   chan struct{}
+}
+
+// subscription is a handler for a subject that is subscribed again on reconnecting.  They are
+// keyed by what they are for, so the same subject can be listened to for more than one reason.
+type subscription struct {
+	subject string
+	handler nats.MsgHandler
+	sub     *nats.Subscription
+}
 
+// topicPrefix is the start of the subject that each device publishes its shadow to
+const topicPrefix = "intelli/"
+
+func topic(serial string) string {
+	return topicPrefix + serial
+}
+
+// deviceSerial returns the serial of the device that published to the subject, and false if it
+// isn't the topic of a device.  Commands are sent on subjects under the topic of the device, so
+// they aren't taken as being published by it.
+func deviceSerial(subject string) (string, bool) {
+	if !strings.HasPrefix(subject, topicPrefix) {
+		return "", false
+	}
+
+	sn := strings.TrimPrefix(subject, topicPrefix)
+	if sn == "" || strings.Contains(sn, "/") {
+		return "", false
+	}
+
+	return sn, true
+}
+
+// Connect will connect to the NATS server running on an IntelliLink device
+func Connect(url string, opts Options) (*Connection, error) {
+	c := &Connection{
+		url:  url,
+		opts: opts.withDefaults(),
+		subs: map[string]*subscription{},
+		errs: make(chan error, 100),
+		quit: make(chan struct{}),
+	}
+
+	nc, err := c.dial()
+	if err != nil {
+		return nil, err
+	}
+
+	c.nc = nc
+	return c, nil
+}
+
+// ConnectToNATS will connect an intelli object to the NATS server running on an IntelliLink
+// device, waiting for the timeout in seconds to connect.  More devices can be subscribed to the
+// returned connection.
+func ConnectToNATS(i intelli, url string, timeout int) (*Connection, error) {
+	c, err := Connect(url, Options{Timeout: time.Duration(timeout) * time.Second})
 	if err != nil {
+		return nil, err
+	}
+
+	if err := c.Subscribe(i); err != nil {
+		c.Close()
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// dial connects to the server, reconnection is handled by the connection rather than the NATS
+// client so that it can back off and subscribe the devices again
+func (c *Connection) dial() (*nats.Conn, error) {
+	return nats.Connect(c.url,
+		nats.Name("go-jelly"),
+		nats.Timeout(c.opts.Timeout),
+		nats.NoReconnect(),
+		nats.ClosedHandler(c.lost),
+		nats.ErrorHandler(func(_ *nats.Conn, _ *nats.Subscription, err error) {
+			c.report(err)
+		}),
+	)
+}
+
+// lost is called when a NATS connection is closed, which is only expected when the connection
+// is closed by Close
+func (c *Connection) lost(nc *nats.Conn) {
+	c.mu.Lock()
+	current := nc == c.nc && !c.closed
+	c.mu.Unlock()
+
+	if !current {
+		return
+	}
+
+	err := nc.LastError()
+	if err == nil {
+		err = fmt.Errorf("connection to %s lost", c.url)
+	}
+
+	if c.opts.OnDisconnect != nil {
+		c.opts.OnDisconnect(err)
+	}
+
+	if c.opts.MaxReconnects >= 0 {
+		go c.reconnect()
+	}
+}
+
+func (c *Connection) reconnect() {
+	wait := c.opts.ReconnectWait
+	for attempt := 1; c.opts.MaxReconnects == 0 || attempt <= c.opts.MaxReconnects; attempt++ {
+		select {
+		case <-c.quit:
+			return
+		case <-time.After(wait):
+		}
+
+		if err := c.resubscribe(); err != nil {
+			c.report(fmt.Errorf("failed to reconnect to %s: %s", c.url, err))
+
+			if wait *= 2; wait > c.opts.MaxReconnectWait {
+				wait = c.opts.MaxReconnectWait
+			}
+			continue
+		}
+
+		if c.opts.OnReconnect != nil {
+			c.opts.OnReconnect()
+		}
+		return
+	}
+
+	c.report(fmt.Errorf("gave up reconnecting to %s after %d attempts", c.url, c.opts.MaxReconnects))
+}
+
+// resubscribe connects again and subscribes all the devices on the new connection
+func (c *Connection) resubscribe() error {
+	nc, err := c.dial()
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		nc.Close()
+		return nil
+	}
+
+	for _, s := range c.subs {
+		if s.sub, err = nc.Subscribe(s.subject, s.handler); err != nil {
+			nc.Close()
+			return err
+		}
+	}
+
+	if err := nc.FlushTimeout(c.opts.Timeout); err != nil {
+		nc.Close()
 		return err
 	}
 
-	topic := fmt.Sprintf("intelli/%s", i.Serial())
+	c.nc = nc
+	return nil
+}
+
+// report sends the error on the errors channel, dropping it if the channel is full
+func (c *Connection) report(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return
+	}
 
-	_, err = client.Subscribe(topic, func(msg *nats.Msg) {
-		i.Update(msg.Data)
+	select {
+	case c.errs <- err:
+	default:
+	}
+}
+
+// Errors returns a channel of errors from the connection, such as payloads that devices couldn't
+// be updated from and failed attempts to reconnect.  Errors are dropped if the channel isn't
+// read.  It is closed when the connection is closed.
+func (c *Connection) Errors() <-chan error {
+	return c.errs
+}
+
+// Subscribe will update the device from the payloads it publishes, and send the commands given
+// to the device over this connection
+func (c *Connection) Subscribe(i intelli) error {
+	err := c.subscribe(topic(i.Serial()), topic(i.Serial()), func(msg *nats.Msg) {
+		if err := i.Update(msg.Data); err != nil {
+			c.report(UpdateError{i.Serial(), err})
+		}
 	})
 
+	if err == errSubscribed {
+		return fmt.Errorf("%s is already subscribed", i.Serial())
+	}
+
 	if err != nil {
 		return err
 	}
 
+	if cmd, ok := i.(commander); ok {
+		cmd.attach(c)
+	}
+
+	return nil
+}
+
+// Unsubscribe will stop updating the device, and sending it commands
+func (c *Connection) Unsubscribe(i intelli) error {
+	err := c.unsubscribe(topic(i.Serial()))
+	if err == errNotSubscribed {
+		return fmt.Errorf("%s is not subscribed", i.Serial())
+	}
+
+	if cmd, ok := i.(commander); ok {
+		cmd.attach(nil)
+	}
+
+	return err
+}
+
+var (
+	errSubscribed    = errors.New("already subscribed")
+	errNotSubscribed = errors.New("not subscribed")
+)
+
+// subscribe calls the handler with the messages on the subject, on this connection and any
+// after reconnecting.  It is unsubscribed with the given key.
+func (c *Connection) subscribe(key, subject string, handler nats.MsgHandler) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return fmt.Errorf("connection is closed")
+	}
+
+	if _, ok := c.subs[key]; ok {
+		return errSubscribed
+	}
+
+	s := &subscription{subject: subject, handler: handler}
+	c.subs[key] = s
+
+	// while reconnecting the subject is subscribed once the connection is back
+	if !c.nc.IsConnected() {
+		return nil
+	}
+
+	var err error
+	if s.sub, err = c.nc.Subscribe(subject, handler); err != nil {
+		delete(c.subs, key)
+		return err
+	}
+
+	// make sure the server has the subscription before returning, so nothing published after
+	// is missed.  If the connection is lost instead it is subscribed again on reconnecting.
+	c.nc.FlushTimeout(c.opts.Timeout)
+	return nil
+}
+
+func (c *Connection) unsubscribe(key string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	s, ok := c.subs[key]
+	if !ok {
+		return errNotSubscribed
+	}
+
+	delete(c.subs, key)
+	if s.sub == nil || !c.nc.IsConnected() {
+		return nil
+	}
+
+	return s.sub.Unsubscribe()
+}
+
+// Connected returns true if the connection to the server is up
+func (c *Connection) Connected() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return !c.closed && c.nc.IsConnected()
+}
+
+// Close will close the connection and stop reconnecting
+func (c *Connection) Close() error {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return nil
+	}
+
+	c.closed = true
+	close(c.quit)
+	close(c.errs)
+	nc := c.nc
+	c.mu.Unlock()
+
+	nc.Close()
 	return nil
 }
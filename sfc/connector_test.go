@@ -0,0 +1,39 @@
+package sfc
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestOptions(t *testing.T) {
+	Convey("options should have defaults", t, func() {
+		o := Options{}.withDefaults()
+		So(o.Timeout, ShouldEqual, 10*time.Second)
+		So(o.ReconnectWait, ShouldEqual, time.Second)
+		So(o.MaxReconnectWait, ShouldEqual, time.Minute)
+		So(o.MaxReconnects, ShouldEqual, 0)
+	})
+
+	Convey("the max reconnect wait should not be less than the reconnect wait", t, func() {
+		o := Options{ReconnectWait: 2 * time.Minute}.withDefaults()
+		So(o.MaxReconnectWait, ShouldEqual, 2*time.Minute)
+	})
+}
+
+func TestConnect(t *testing.T) {
+	Convey("given a server that never answers", t, func() {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		So(err, ShouldBeNil)
+		defer l.Close()
+
+		Convey("connecting should give up after the timeout", func() {
+			start := time.Now()
+			_, err := Connect("nats://"+l.Addr().String(), Options{Timeout: 100 * time.Millisecond})
+			So(err, ShouldNotBeNil)
+			So(time.Since(start), ShouldBeLessThan, 5*time.Second)
+		})
+	})
+}
@@ -0,0 +1,278 @@
+package sfc
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nats-io/go-nats"
+)
+
+// DeviceType is the type of a device found on the bus, using the same names as the IntelliGrow
+// API
+type DeviceType string
+
+// The types of devices that can be discovered
+const (
+	IntelliDoseType    DeviceType = "idoze"
+	IntelliClimateType DeviceType = "iclimate"
+	UnknownType        DeviceType = "unknown"
+)
+
+// DefaultDisappearAfter is how long a device can go without publishing before it is considered
+// to have disappeared from the bus
+const DefaultDisappearAfter = 5 * time.Minute
+
+// DiscoveredDevice is a device that has published to the bus
+type DiscoveredDevice struct {
+	Serial    string     `json:"serial"`
+	Type      DeviceType `json:"type"`
+	FirstSeen time.Time  `json:"first_seen"`
+	LastSeen  time.Time  `json:"last_seen"`
+	// Present is false once the device has disappeared, until it publishes again
+	Present bool `json:"present"`
+}
+
+// DiscoveryEventType is the type of a discovery event
+type DiscoveryEventType string
+
+// The types of discovery events
+const (
+	DeviceAppeared    DiscoveryEventType = "appeared"
+	DeviceDisappeared DiscoveryEventType = "disappeared"
+)
+
+// DiscoveryEvent is sent when a device appears on the bus, or disappears from it
+type DiscoveryEvent struct {
+	Type   DiscoveryEventType `json:"type"`
+	Device DiscoveredDevice   `json:"device"`
+	Time   time.Time          `json:"time"`
+}
+
+// Discovery keeps a registry of the devices publishing on the bus
+type Discovery struct {
+	disappearAfter time.Duration
+
+	mu      sync.Mutex
+	devices map[string]*DiscoveredDevice
+	events  chan DiscoveryEvent
+	closed  bool
+	quit    chan struct{}
+	stop    func() error
+}
+
+// discoveryKey is the key of the subscription that discovery listens on
+const discoveryKey = "discovery"
+
+// Discover listens for the devices publishing on the bus, which are considered to have
+// disappeared after not publishing for the given duration, DefaultDisappearAfter if zero.
+//
+// Devices publish to subjects like intelli/<serial>.  As the slash isn't a token separator to
+// NATS the subject can't be matched by a wildcard under intelli, so all subjects are listened to
+// and those without the prefix ignored.
+func (c *Connection) Discover(disappearAfter time.Duration) (*Discovery, error) {
+	d := newDiscovery(disappearAfter)
+
+	err := c.subscribe(discoveryKey, ">", func(msg *nats.Msg) {
+		d.seen(msg.Subject, msg.Data, time.Now())
+	})
+
+	if err == errSubscribed {
+		return nil, fmt.Errorf("discovery is already running on this connection")
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	d.stop = func() error { return c.unsubscribe(discoveryKey) }
+	go d.expireEvery(d.disappearAfter / 4)
+	return d, nil
+}
+
+func newDiscovery(disappearAfter time.Duration) *Discovery {
+	if disappearAfter <= 0 {
+		disappearAfter = DefaultDisappearAfter
+	}
+
+	return &Discovery{
+		disappearAfter: disappearAfter,
+		devices:        map[string]*DiscoveredDevice{},
+		events:         make(chan DiscoveryEvent, 100),
+		quit:           make(chan struct{}),
+		stop:           func() error { return nil },
+	}
+}
+
+// Events returns a channel of devices appearing on and disappearing from the bus.  Events are
+// dropped if the channel isn't read.  It is closed when the discovery is closed.
+func (d *Discovery) Events() <-chan DiscoveryEvent {
+	return d.events
+}
+
+// Devices returns all the devices that have been seen, including those that have since
+// disappeared, sorted by serial
+func (d *Discovery) Devices() []DiscoveredDevice {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	devices := make([]DiscoveredDevice, 0, len(d.devices))
+	for _, dev := range d.devices {
+		devices = append(devices, *dev)
+	}
+
+	sort.Slice(devices, func(i, j int) bool { return devices[i].Serial < devices[j].Serial })
+	return devices
+}
+
+// Device returns the device with the given serial, and false if it hasn't been seen
+func (d *Discovery) Device(sn string) (DiscoveredDevice, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	dev, ok := d.devices[sn]
+	if !ok {
+		return DiscoveredDevice{}, false
+	}
+
+	return *dev, true
+}
+
+// Close stops listening for devices and closes the events channel
+func (d *Discovery) Close() error {
+	d.mu.Lock()
+	if d.closed {
+		d.mu.Unlock()
+		return nil
+	}
+
+	d.closed = true
+	close(d.quit)
+	close(d.events)
+	d.mu.Unlock()
+
+	return d.stop()
+}
+
+// seen records that a payload was published to the subject
+func (d *Discovery) seen(subject string, b []byte, now time.Time) {
+	sn, ok := deviceSerial(subject)
+	if !ok {
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.closed {
+		return
+	}
+
+	dev, ok := d.devices[sn]
+	if !ok {
+		dev = &DiscoveredDevice{Serial: sn, Type: UnknownType, FirstSeen: now}
+		d.devices[sn] = dev
+	}
+
+	// a partial update might not say what the device is, so keep the type once it is known
+	if dev.Type == UnknownType {
+		dev.Type = detectType(sn, b)
+	}
+
+	dev.LastSeen = now
+	if !dev.Present {
+		dev.Present = true
+		d.send(DiscoveryEvent{DeviceAppeared, *dev, now})
+	}
+}
+
+// expire marks the devices that haven't been seen for too long as disappeared
+func (d *Discovery) expire(now time.Time) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.closed {
+		return
+	}
+
+	for _, dev := range d.devices {
+		if dev.Present && now.Sub(dev.LastSeen) > d.disappearAfter {
+			dev.Present = false
+			d.send(DiscoveryEvent{DeviceDisappeared, *dev, now})
+		}
+	}
+}
+
+func (d *Discovery) expireEvery(interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-d.quit:
+			return
+		case now := <-t.C:
+			d.expire(now)
+		}
+	}
+}
+
+// send must be called with the lock held
+func (d *Discovery) send(ev DiscoveryEvent) {
+	select {
+	case d.events <- ev:
+	default:
+	}
+}
+
+// detectType works out the type of a device from what it says it is, the prefix of its serial,
+// or failing that the metrics it reports
+func detectType(sn string, b []byte) DeviceType {
+	var shadow struct {
+		State struct {
+			Reported struct {
+				Device  string                     `json:"device"`
+				Source  string                     `json:"source"`
+				Metrics map[string]json.RawMessage `json:"metrics"`
+			} `json:"reported"`
+		} `json:"state"`
+	}
+
+	// the payload is only looked at for clues, a bad one still means the device is there
+	json.Unmarshal(b, &shadow)
+	r := shadow.State.Reported
+
+	for _, s := range []string{r.Device, r.Source} {
+		s = strings.ToLower(s)
+		switch {
+		case strings.Contains(s, "climate"):
+			return IntelliClimateType
+		case strings.Contains(s, "dose"), strings.Contains(s, "doze"):
+			return IntelliDoseType
+		}
+	}
+
+	switch sn = strings.ToUpper(sn); {
+	case strings.HasPrefix(sn, "ASLIC"):
+		return IntelliClimateType
+	case strings.HasPrefix(sn, "ASLID"):
+		return IntelliDoseType
+	}
+
+	for _, m := range []string{"air_temp", "rh", "co2"} {
+		if _, ok := r.Metrics[m]; ok {
+			return IntelliClimateType
+		}
+	}
+
+	for _, m := range []string{"ec", "pH", "nut_temp"} {
+		if _, ok := r.Metrics[m]; ok {
+			return IntelliDoseType
+		}
+	}
+
+	return UnknownType
+}
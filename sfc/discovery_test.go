@@ -0,0 +1,71 @@
+package sfc
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestDetectType(t *testing.T) {
+	Convey("the type should be detected from the payload", t, func() {
+		So(detectType("SN1", []byte(`{"state": {"reported": {"device": "IntelliClimate"}}}`)), ShouldEqual, IntelliClimateType)
+		So(detectType("SN1", []byte(`{"state": {"reported": {"source": "intellidose"}}}`)), ShouldEqual, IntelliDoseType)
+		So(detectType("ASLIC17081150", []byte(`{}`)), ShouldEqual, IntelliClimateType)
+		So(detectType("ASLID17081149", []byte(`bad`)), ShouldEqual, IntelliDoseType)
+		So(detectType("SN1", []byte(`{"state": {"reported": {"metrics": {"pH": 6}}}}`)), ShouldEqual, IntelliDoseType)
+		So(detectType("SN1", []byte(`{"state": {"reported": {"metrics": {"rh": 60}}}}`)), ShouldEqual, IntelliClimateType)
+		So(detectType("SN1", []byte(`{}`)), ShouldEqual, UnknownType)
+	})
+}
+
+func TestDiscovery(t *testing.T) {
+	Convey("given a discovery", t, func() {
+		d := newDiscovery(time.Minute)
+		start := time.Date(2018, 6, 1, 10, 0, 0, 0, time.UTC)
+
+		Convey("devices should appear when they first publish", func() {
+			d.seen("intelli/ASLID17081149", []byte(shadowJSON), start)
+			d.seen("intelli/ASLID17081149", []byte(shadowJSON), start.Add(30*time.Second))
+			d.seen("other/subject", []byte(shadowJSON), start)
+
+			ev := <-d.Events()
+			So(ev.Type, ShouldEqual, DeviceAppeared)
+			So(ev.Device.Serial, ShouldEqual, "ASLID17081149")
+			So(ev.Device.Type, ShouldEqual, IntelliDoseType)
+			So(d.Events(), ShouldBeEmpty)
+
+			dev, ok := d.Device("ASLID17081149")
+			So(ok, ShouldBeTrue)
+			So(dev.FirstSeen, ShouldEqual, start)
+			So(dev.LastSeen, ShouldEqual, start.Add(30*time.Second))
+			So(d.Devices(), ShouldHaveLength, 1)
+		})
+
+		Convey("devices should disappear when they stop publishing, and can come back", func() {
+			d.seen("intelli/ASLIC17081150", []byte(climateShadowJSON), start)
+			<-d.Events()
+
+			d.expire(start.Add(time.Minute))
+			So(d.Events(), ShouldBeEmpty)
+
+			d.expire(start.Add(2 * time.Minute))
+			ev := <-d.Events()
+			So(ev.Type, ShouldEqual, DeviceDisappeared)
+			So(ev.Device.Present, ShouldBeFalse)
+
+			d.seen("intelli/ASLIC17081150", []byte(`{}`), start.Add(time.Hour))
+			ev = <-d.Events()
+			So(ev.Type, ShouldEqual, DeviceAppeared)
+			So(ev.Device.FirstSeen, ShouldEqual, start)
+			So(ev.Device.Type, ShouldEqual, IntelliClimateType)
+		})
+
+		Convey("closing should close the events channel", func() {
+			So(d.Close(), ShouldBeNil)
+			_, open := <-d.Events()
+			So(open, ShouldBeFalse)
+			d.seen("intelli/ASLID17081149", []byte(shadowJSON), start)
+		})
+	})
+}
@@ -3,11 +3,12 @@
 package main
 
 import (
+	"context"
 	"flag"
+	"log"
 
-	"github.com/mitsuse/pushbullet-go"
-	"github.com/mitsuse/pushbullet-go/requests"
-	"github.com/AutogrowSystems/go-jelly/sfc"
+	"github.com/autogrow/go-jelly/notifier"
+	"github.com/autogrow/go-jelly/sfc"
 )
 
 const PB_TOKEN = "YOUR PUSHBULLET TOKEN GOES HERE"
@@ -24,50 +25,45 @@ func main() {
 
 	// connect to the IntelliDose on the IntelliLink
 	idose := sfc.NewIntelliDose(sn)
-	err := sfc.ConnectToNATS(idose, "nats://localhost:4222", 10)
+	conn, err := sfc.ConnectToNATS(idose, "nats://localhost:4222", 10)
 	if err != nil {
 		panic(err)
 	}
+	defer conn.Close()
 
-	// create a pushbullet client
-	pb := pushbullet.New(PB_TOKEN)
-
-	// create night and day notifications that we can reuse
-	dayMsg := requests.NewNote()
-	dayMsg.Title = "IntelliDose "+sn
-	dayMsg.Body = "Switched to day time mode"
-	nightMsg := requests.NewNote()
-	nightMsg.Title = "IntelliDose "+sn
-	nightMsg.Body = "Switched to night time mode"
-
-	// Send the note via Pushbullet.
-	if _, err := pb.PostPushesNote(n); err != nil {
-		fmt.Fprintf(os.Stderr, "error: %s\n", err)
-		return
+	// send notes to pushbullet using a webhook
+	pb := &notifier.Webhook{
+		URL:     "https://api.pushbullet.com/v2/pushes",
+		Headers: map[string]string{"Access-Token": PB_TOKEN},
+		Payload: func(msg notifier.Message) interface{} {
+			return map[string]string{"type": "note", "title": msg.Title, "body": msg.Body}
+		},
 	}
 
-	inDayTime = false
+	d := notifier.NewDispatcher()
+	d.AddChannel("pushbullet", pb)
+	d.AddRoute(notifier.Route{Channels: []string{"pushbullet"}})
+
+	inDayTime := idose.IsDayTime()
 	for {
 		// block until the IntelliDose sends a new packet
-		idose.WaitForUpdate()
+		if _, err := idose.WaitForUpdate(context.Background()); err != nil {
+			log.Fatalf("ERROR: %s", err)
+		}
 
 		// don't spam
-		if inDayTime && idose.IsDayTime() || !inDayTime && !idose.IsDayTime() {
+		if inDayTime == idose.IsDayTime() {
 			continue
 		}
 
-		// transition to night time
-		if inDayTime && !idose.IsDayTime() {
-			if _, err := pb.PostPushesNote(nightMsg); err != nil {
-				log.Printf("ERROR: %s", err)
-			}
+		inDayTime = idose.IsDayTime()
+		msg := notifier.Message{Title: "IntelliDose " + sn, Device: sn, Body: "Switched to night time mode"}
+		if inDayTime {
+			msg.Body = "Switched to day time mode"
 		}
 
-		// transition to day time
-		if !inDayTime && idose.IsDayTime() {
-			if _, err := pb.PostPushesNote(dayMsg); err != nil {
-				log.Printf("ERROR: %s", err)
-			}
+		if err := d.Dispatch(msg); err != nil {
+			log.Printf("ERROR: %s", err)
 		}
 	}
 }
@@ -6,7 +6,7 @@ import (
 	"flag"
 	"log"
 
-	"github.com/AutogrowSystems/go-jelly/sfc"
+	"github.com/autogrow/go-jelly/sfc"
 )
 
 func main() {
@@ -21,18 +21,16 @@ func main() {
 
 	// connect to the IntelliDose on the IntelliLink
 	idose := sfc.NewIntelliDose(sn)
-	err := sfc.ConnectToNATS(idose, "nats://localhost:4222", 10)
+	conn, err := sfc.ConnectToNATS(idose, "nats://localhost:4222", 10)
 	if err != nil {
 		panic(err)
 	}
+	defer conn.Close()
 
-	lastR := sfc.MetricsIDose
-	for {
-		// block until we get an update
-		idose.WaitForUpdate()
-
+	lastR := sfc.MetricsIDose{}
+	for snap := range idose.Updates() {
 		// print out the readings
-		r := idose.Readings()
+		r := snap.Reported.Metrics
 
 		// check if they changed
 		if r == lastR {
@@ -0,0 +1,176 @@
+package sfc
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+)
+
+// NewIntelliClimate returns a new IntelliClimate with the given serial number
+func NewIntelliClimate(sn string) *IntelliClimate {
+	return &IntelliClimate{
+		serial:  sn,
+		subs:    map[chan ClimateSnapshot]struct{}{},
+		updated: make(chan struct{}),
+	}
+}
+
+// IntelliClimate represents the IntelliClimate single function controller.  It is updated from
+// the NATS callback, so its state is only read through copies that are safe to use while updates
+// arrive.
+type IntelliClimate struct {
+	serial string
+
+	mu       sync.RWMutex
+	shadow   iClimateShadow
+	received time.Time
+	subs     map[chan ClimateSnapshot]struct{}
+	// updated is closed and replaced on each update to wake up WaitForUpdate
+	updated chan struct{}
+}
+
+// ClimateSnapshot is a copy of the state reported by an IntelliClimate, which doesn't change
+// when the device is updated again
+type ClimateSnapshot struct {
+	Serial string `json:"serial"`
+	// Received is when the update was received, zero if the device hasn't reported yet
+	Received time.Time        `json:"received"`
+	Reported ReportedIClimate `json:"reported"`
+}
+
+// Serial returns the serial number of this IntelliClimate
+func (ic *IntelliClimate) Serial() string {
+	return ic.serial
+}
+
+// Snapshot returns a copy of the state last reported by the IntelliClimate
+func (ic *IntelliClimate) Snapshot() ClimateSnapshot {
+	ic.mu.RLock()
+	defer ic.mu.RUnlock()
+	return ic.snapshot()
+}
+
+func (ic *IntelliClimate) snapshot() ClimateSnapshot {
+	return ClimateSnapshot{
+		Serial:   ic.serial,
+		Received: ic.received,
+		Reported: cloneIClimate(ic.shadow.State.Reported),
+	}
+}
+
+// LastUpdated returns when the IntelliClimate last reported, zero if it hasn't yet
+func (ic *IntelliClimate) LastUpdated() time.Time {
+	ic.mu.RLock()
+	defer ic.mu.RUnlock()
+	return ic.received
+}
+
+// Connected returns true if the IntelliClimate last reported that it is connected
+func (ic *IntelliClimate) Connected() bool {
+	ic.mu.RLock()
+	defer ic.mu.RUnlock()
+	return ic.shadow.State.Reported.Connected
+}
+
+// Updates returns a channel that is sent a snapshot each time the IntelliClimate is updated.
+// Only the latest snapshot is kept if the receiver falls behind.  The channel is closed by
+// Unsubscribe.
+func (ic *IntelliClimate) Updates() <-chan ClimateSnapshot {
+	ch := make(chan ClimateSnapshot, 1)
+
+	ic.mu.Lock()
+	ic.subs[ch] = struct{}{}
+	ic.mu.Unlock()
+
+	return ch
+}
+
+// Unsubscribe stops sending updates to, and closes, a channel returned by Updates
+func (ic *IntelliClimate) Unsubscribe(updates <-chan ClimateSnapshot) {
+	ic.mu.Lock()
+	defer ic.mu.Unlock()
+
+	for ch := range ic.subs {
+		if ch == updates {
+			delete(ic.subs, ch)
+			close(ch)
+		}
+	}
+}
+
+// WaitForUpdate blocks until the IntelliClimate is next updated and returns a snapshot of the
+// update, or returns the error of the context if it is done first.  Updates that arrive between
+// calls are not waited for, use Updates to receive every update.
+func (ic *IntelliClimate) WaitForUpdate(ctx context.Context) (ClimateSnapshot, error) {
+	ic.mu.RLock()
+	updated := ic.updated
+	ic.mu.RUnlock()
+
+	select {
+	case <-updated:
+		return ic.Snapshot(), nil
+	case <-ctx.Done():
+		return ClimateSnapshot{}, ctx.Err()
+	}
+}
+
+// Update the IntelliClimate from the given JSON payload, which is merged into the state it last
+// reported.  Subscribers to Updates are sent a snapshot and WaitForUpdate will stop blocking.
+func (ic *IntelliClimate) Update(b []byte) error {
+	ic.mu.Lock()
+	defer ic.mu.Unlock()
+
+	// decode into a copy so that a bad payload leaves the state as it was, and the slices of
+	// earlier snapshots aren't overwritten
+	shadow := iClimateShadow{State: StateIClimate{Reported: cloneIClimate(ic.shadow.State.Reported)}}
+	if err := json.Unmarshal(b, &shadow); err != nil {
+		return err
+	}
+
+	ic.shadow = shadow
+	ic.received = time.Now()
+
+	snap := ic.snapshot()
+	for ch := range ic.subs {
+		// drop the stale snapshot of a slow receiver so it gets the latest
+		select {
+		case <-ch:
+		default:
+		}
+		ch <- snap
+	}
+
+	close(ic.updated)
+	ic.updated = make(chan struct{})
+
+	return nil
+}
+
+// Readings returns the current readings for the IntelliClimate
+func (ic *IntelliClimate) Readings() MetricsIClimate {
+	ic.mu.RLock()
+	defer ic.mu.RUnlock()
+	return ic.shadow.State.Reported.Metrics
+}
+
+// Config returns the configuration of the IntelliClimate
+func (ic *IntelliClimate) Config() ConfigIClimate {
+	ic.mu.RLock()
+	defer ic.mu.RUnlock()
+	return ic.shadow.State.Reported.Config
+}
+
+// Settings returns the settings of the IntelliClimate, which include the alarm limits, set
+// points, and the status of each function
+func (ic *IntelliClimate) Settings() SettingsIClimate {
+	ic.mu.RLock()
+	defer ic.mu.RUnlock()
+	return cloneIClimate(ic.shadow.State.Reported).Status
+}
+
+// IsDayTime returns true if the IntelliClimate last reported that it is day time
+func (ic *IntelliClimate) IsDayTime() bool {
+	return strings.EqualFold(ic.Readings().DayNight, "day")
+}
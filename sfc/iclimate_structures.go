@@ -0,0 +1,47 @@
+package sfc
+
+import "github.com/autogrow/go-jelly/ig/datastructs"
+
+type iClimateShadow struct {
+	State StateIClimate `json:"state"`
+}
+
+// StateIClimate represents the state of the IntelliClimate
+type StateIClimate = datastructs.StateIClimate
+
+// ReportedIClimate represents the top level report from the IntelliClimate
+type ReportedIClimate = datastructs.ReportedIClimate
+
+// cloneIClimate returns a copy of the report that doesn't share any slices
+func cloneIClimate(r ReportedIClimate) ReportedIClimate {
+	s := &r.Status
+	if s.SetPoints != nil {
+		s.SetPoints = append([]datastructs.SetPointIClimate{}, s.SetPoints...)
+	}
+
+	if s.Status != nil {
+		s.Status = append([]StatusIClimate{}, s.Status...)
+	}
+
+	if s.ModeAlarmHistory.Alarms != nil {
+		s.ModeAlarmHistory.Alarms = append([]datastructs.AlarmsIClimate{}, s.ModeAlarmHistory.Alarms...)
+	}
+
+	if s.ModeAlarmHistory.Mode != nil {
+		s.ModeAlarmHistory.Mode = append([]datastructs.ModeIClimate{}, s.ModeAlarmHistory.Mode...)
+	}
+
+	return r
+}
+
+// ConfigIClimate represents the IntelliClimate config
+type ConfigIClimate = datastructs.ConfigIClimate
+
+// MetricsIClimate represents the IntelliClimate metrics
+type MetricsIClimate = datastructs.MetricsIClimate
+
+// SettingsIClimate represents the top level of the IntelliClimate status
+type SettingsIClimate = datastructs.StatusIClimate
+
+// StatusIClimate represents the current status of a function of an IntelliClimate
+type StatusIClimate = datastructs.StatusStatusIClimate
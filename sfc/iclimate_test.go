@@ -0,0 +1,72 @@
+package sfc
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+const climateShadowJSON = `{"state": {"reported": {
+	"device": "ASLIC17081150",
+	"connected": true,
+	"metrics": {"air_temp": 24.5, "rh": 61, "co2": 900, "day_night": "Day"},
+	"status": {
+		"readings": {"air_temp": {"enabled": true, "min": 15, "max": 30}},
+		"status": [{"function": "Heating", "active": true, "installed": true}],
+		"mode_alarm_history": {"alarms": [{"description": "High Temp Alarm", "timestamp": "1527811200"}]}
+	}
+}}}`
+
+func TestIntelliClimateUpdates(t *testing.T) {
+	Convey("given an IntelliClimate", t, func() {
+		ic := NewIntelliClimate("ASLIC17081150")
+
+		Convey("it should merge updates into the reported state", func() {
+			So(ic.Update([]byte(climateShadowJSON)), ShouldBeNil)
+			So(ic.Update([]byte(`{"state": {"reported": {"metrics": {"rh": 65}}}}`)), ShouldBeNil)
+
+			r := ic.Readings()
+			So(r.Rh, ShouldEqual, 65)
+			So(r.AirTemp, ShouldEqual, 24.5)
+			So(ic.IsDayTime(), ShouldBeTrue)
+			So(ic.Connected(), ShouldBeTrue)
+			So(ic.Settings().Readings.AirTemp.Max, ShouldEqual, 30)
+		})
+
+		Convey("snapshots should not change when the device is updated", func() {
+			So(ic.Update([]byte(climateShadowJSON)), ShouldBeNil)
+			snap := ic.Snapshot()
+
+			So(ic.Update([]byte(`{"state": {"reported": {"status": {"status": [{"function": "Cooling"}], "mode_alarm_history": {"alarms": [{"description": "Low RH Alarm"}]}}}}}`)), ShouldBeNil)
+			So(snap.Reported.Status.Status[0].Function, ShouldEqual, "Heating")
+			So(snap.Reported.Status.ModeAlarmHistory.Alarms[0].Description, ShouldEqual, "High Temp Alarm")
+			So(ic.Settings().Status[0].Function, ShouldEqual, "Cooling")
+		})
+
+		Convey("subscribers should be sent the latest snapshot", func() {
+			updates := ic.Updates()
+			So(ic.Update([]byte(climateShadowJSON)), ShouldBeNil)
+
+			snap := <-updates
+			So(snap.Serial, ShouldEqual, "ASLIC17081150")
+			So(snap.Reported.Metrics.Co2, ShouldEqual, 900)
+
+			ic.Unsubscribe(updates)
+			_, open := <-updates
+			So(open, ShouldBeFalse)
+		})
+
+		Convey("WaitForUpdate should return the next update", func() {
+			go func() {
+				time.Sleep(10 * time.Millisecond)
+				ic.Update([]byte(climateShadowJSON))
+			}()
+
+			snap, err := ic.WaitForUpdate(context.Background())
+			So(err, ShouldBeNil)
+			So(snap.Reported.Device, ShouldEqual, "ASLIC17081150")
+		})
+	})
+}
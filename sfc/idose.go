@@ -1,23 +1,52 @@
 package sfc
 
 import (
+	"context"
 	"encoding/json"
+	"sync"
 	"time"
-)
 
-type empty struct{}
+	"github.com/autogrow/go-jelly/ig/datastructs"
+)
 
 // NewIntelliDose returns a new IntelliDose with the given serial number
 func NewIntelliDose(sn string) *IntelliDose {
-	return &IntelliDose{iDoseShadow: &iDoseShadow{}, serial: sn}
+	id := &IntelliDose{
+		subs:    map[chan Snapshot]struct{}{},
+		updated: make(chan struct{}),
+	}
+	id.doseCommands = doseCommands{sn, id.guard}
+	return id
 }
 
-// IntelliDose represents the IntelliDose single function controller
+// IntelliDose represents the IntelliDose single function controller.  It is updated from the
+// NATS callback, so its state is only read through copies that are safe to use while updates
+// arrive.
 type IntelliDose struct {
-	*iDoseShadow
-	updateChan  chan empty
-	LastUpdated time.Time
-	serial      string
+	// doseCommands has the serial of the device and sends commands to it
+	doseCommands
+
+	mu       sync.RWMutex
+	shadow   iDoseShadow
+	received time.Time
+	subs     map[chan Snapshot]struct{}
+	// updated is closed and replaced on each update to wake up WaitForUpdate
+	updated chan struct{}
+	// conn sends commands to the device, nil until it is subscribed to a connection
+	conn sender
+	// cmdLock is held while a command or transaction is applied and sent
+	cmdLock sync.Mutex
+	// loc is the time zone of the clock of the device
+	loc *time.Location
+}
+
+// Snapshot is a copy of the state reported by a device, which doesn't change when the device is
+// updated again
+type Snapshot struct {
+	Serial string `json:"serial"`
+	// Received is when the update was received, zero if the device hasn't reported yet
+	Received time.Time     `json:"received"`
+	Reported ReportedIDose `json:"reported"`
 }
 
 // Serial returns the serial number of this IntelliDose
@@ -25,57 +54,146 @@ func (id *IntelliDose) Serial() string {
 	return id.serial
 }
 
-func (id *IntelliDose) WaitForUpdate() {
-	last := id.LastUpdated
-	for {
-		time.Sleep(time.Second / 10)
-		if last != id.LastUpdated {
-			return
+// Snapshot returns a copy of the state last reported by the IntelliDose
+func (id *IntelliDose) Snapshot() Snapshot {
+	id.mu.RLock()
+	defer id.mu.RUnlock()
+	return id.snapshot()
+}
+
+func (id *IntelliDose) snapshot() Snapshot {
+	return Snapshot{
+		Serial:   id.serial,
+		Received: id.received,
+		Reported: cloneIDose(id.shadow.State.Reported),
+	}
+}
+
+// LastUpdated returns when the IntelliDose last reported, zero if it hasn't yet
+func (id *IntelliDose) LastUpdated() time.Time {
+	id.mu.RLock()
+	defer id.mu.RUnlock()
+	return id.received
+}
+
+// Connected returns true if the IntelliDose last reported that it is connected
+func (id *IntelliDose) Connected() bool {
+	id.mu.RLock()
+	defer id.mu.RUnlock()
+	return id.shadow.State.Reported.Connected
+}
+
+// Updates returns a channel that is sent a snapshot each time the IntelliDose is updated.  Only
+// the latest snapshot is kept if the receiver falls behind.  The channel is closed by
+// Unsubscribe.
+func (id *IntelliDose) Updates() <-chan Snapshot {
+	ch := make(chan Snapshot, 1)
+
+	id.mu.Lock()
+	id.subs[ch] = struct{}{}
+	id.mu.Unlock()
+
+	return ch
+}
+
+// Unsubscribe stops sending updates to, and closes, a channel returned by Updates
+func (id *IntelliDose) Unsubscribe(updates <-chan Snapshot) {
+	id.mu.Lock()
+	defer id.mu.Unlock()
+
+	for ch := range id.subs {
+		if ch == updates {
+			delete(id.subs, ch)
+			close(ch)
 		}
 	}
 }
 
-// Update the IntelliDose from the given JSON payload, WaitForUpdate will stop
-// blocking after this
+// WaitForUpdate blocks until the IntelliDose is next updated and returns a snapshot of the
+// update, or returns the error of the context if it is done first.  Updates that arrive between
+// calls are not waited for, use Updates to receive every update.
+func (id *IntelliDose) WaitForUpdate(ctx context.Context) (Snapshot, error) {
+	id.mu.RLock()
+	updated := id.updated
+	id.mu.RUnlock()
+
+	select {
+	case <-updated:
+		return id.Snapshot(), nil
+	case <-ctx.Done():
+		return Snapshot{}, ctx.Err()
+	}
+}
+
+// Update the IntelliDose from the given JSON payload, which is merged into the state it last
+// reported.  Subscribers to Updates are sent a snapshot and WaitForUpdate will stop blocking.
 func (id *IntelliDose) Update(b []byte) error {
-	err := json.Unmarshal(b, &id)
-	if err != nil {
+	id.mu.Lock()
+	defer id.mu.Unlock()
+
+	// decode into a copy so that a bad payload leaves the state as it was, and the slices of
+	// earlier snapshots aren't overwritten
+	shadow := iDoseShadow{State: StateIDose{Reported: cloneIDose(id.shadow.State.Reported)}}
+	if err := json.Unmarshal(b, &shadow); err != nil {
 		return err
 	}
 
-	id.LastUpdated = time.Now()
+	id.shadow = shadow
+	id.received = time.Now()
+
+	snap := id.snapshot()
+	for ch := range id.subs {
+		// drop the stale snapshot of a slow receiver so it gets the latest
+		select {
+		case <-ch:
+		default:
+		}
+		ch <- snap
+	}
+
+	close(id.updated)
+	id.updated = make(chan struct{})
 
 	return nil
 }
 
 // Readings returns the current readings for the IntelliDose
 func (id *IntelliDose) Readings() MetricsIDose {
-	return id.State.Reported.Metrics
+	id.mu.RLock()
+	defer id.mu.RUnlock()
+	return id.shadow.State.Reported.Metrics
 }
 
 // Config returns the configuration of the IntelliDose
 func (id *IntelliDose) Config() ConfigIDose {
-	return id.State.Reported.Config
+	id.mu.RLock()
+	defer id.mu.RUnlock()
+	return id.shadow.State.Reported.Config
 }
 
 // Settings returns the settings of the IntelliDose
 func (id *IntelliDose) Settings() SettingsIDose {
-	return id.State.Reported.Settings
+	id.mu.RLock()
+	defer id.mu.RUnlock()
+	return cloneIDose(id.shadow.State.Reported).Status
 }
 
-// IsDayTime returns true if the IntelliDose thinks that it is currently day time
-func (id *IntelliDose) IsDayTime() bool {
-	_now := time.Now()
-	_ds, _ := time.Parse("", id.State.Reported.Config.Times.DayStart)
-	_de, _ := time.Parse("", id.State.Reported.Config.Times.DayEnd)
-
-	now := (_now.Hour() * 100) + _now.Minute()
-	ds := (_ds.Hour() * 100) + _ds.Minute()
-	de := (_de.Hour() * 100) + _de.Minute()
+// SetLocation sets the time zone of the clock of the IntelliDose, which the shadow doesn't
+// report.  The day period is taken to be in UTC until it is set.
+func (id *IntelliDose) SetLocation(loc *time.Location) {
+	id.mu.Lock()
+	defer id.mu.Unlock()
+	id.loc = loc
+}
 
-	if now > ds && now < de {
-		return true
-	}
+// DaySchedule returns the day period the IntelliDose is configured with, in its time zone
+func (id *IntelliDose) DaySchedule() datastructs.DaySchedule {
+	id.mu.RLock()
+	defer id.mu.RUnlock()
+	return id.shadow.State.Reported.Config.Times.Schedule(id.loc)
+}
 
-	return false
+// IsDayTime returns true if it is currently in the day period of the IntelliDose
+func (id *IntelliDose) IsDayTime() bool {
+	return id.DaySchedule().IsDay(time.Now())
 }
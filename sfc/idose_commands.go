@@ -0,0 +1,300 @@
+package sfc
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/autogrow/go-jelly/ig"
+	"github.com/autogrow/go-jelly/ig/datastructs"
+	"github.com/autogrow/go-jelly/units"
+)
+
+// sender sends commands to a device, which is the connection it is subscribed to
+type sender interface {
+	request(serial string, cmd command) error
+}
+
+// doseCommands are the commands of an IntelliDose, which are shared with a transaction on it.
+// Each command is applied to a state with apply.
+type doseCommands struct {
+	serial string
+	apply  func(update func(r *ReportedIDose) error) error
+}
+
+// IntelliDoseTx is a transaction on an IntelliDose, with the same commands as the IntelliDose
+// which are applied to the state sent to the device at the end of the transaction
+type IntelliDoseTx struct {
+	doseCommands
+	pending ReportedIDose
+}
+
+func (id *IntelliDose) attach(c *Connection) {
+	id.mu.Lock()
+	defer id.mu.Unlock()
+
+	if c == nil {
+		id.conn = nil
+		return
+	}
+
+	id.conn = c
+}
+
+// commandState returns a copy of the last reported state to apply a command to
+func (id *IntelliDose) commandState() (ReportedIDose, error) {
+	snap := id.Snapshot()
+	if snap.Received.IsZero() {
+		return ReportedIDose{}, fmt.Errorf("%s has not reported its state yet", id.serial)
+	}
+
+	return snap.Reported, nil
+}
+
+// send the state and config to the device and wait for it to acknowledge them
+func (id *IntelliDose) send(r ReportedIDose) error {
+	id.mu.RLock()
+	conn := id.conn
+	id.mu.RUnlock()
+
+	if conn == nil {
+		return ErrNotAttached
+	}
+
+	return conn.request(id.serial, command{Device: id.serial, State: r.Status, Config: r.Config})
+}
+
+// guard applies the update to the last reported state and sends it to the device, waiting for
+// a transaction running on the device to be sent first
+func (id *IntelliDose) guard(update func(r *ReportedIDose) error) error {
+	id.cmdLock.Lock()
+	defer id.cmdLock.Unlock()
+
+	r, err := id.commandState()
+	if err != nil {
+		return err
+	}
+
+	if err := update(&r); err != nil {
+		return err
+	}
+
+	return id.send(r)
+}
+
+// Transaction allows multiple changes to be sent to the device in one command.  The commands
+// given to the transaction passed to the runner are sent together:
+//
+//     err := id.Transaction(func(tx *sfc.IntelliDoseTx) error {
+//       tx.ForceIrrigation()
+//       tx.SetPHTarget(6.2)
+//       return nil
+//     })
+//
+// The changes are made to the last state reported by the device, and only sent at the end of
+// the callback if it doesn't return an error.  Commands given to the IntelliDose itself wait
+// until the transaction is done, so must not be given in the runner.
+func (id *IntelliDose) Transaction(runner func(tx *IntelliDoseTx) error) error {
+	id.cmdLock.Lock()
+	defer id.cmdLock.Unlock()
+
+	r, err := id.commandState()
+	if err != nil {
+		return err
+	}
+
+	tx := &IntelliDoseTx{pending: r}
+	tx.doseCommands = doseCommands{id.serial, func(update func(r *ReportedIDose) error) error {
+		return update(&tx.pending)
+	}}
+
+	if err := runner(tx); err != nil {
+		return err
+	}
+
+	return id.send(tx.pending)
+}
+
+func forceFunction(r *ReportedIDose, function string) {
+	for num, status := range r.Status.Status {
+		if status.Function == function {
+			r.Status.Status[num].ForceOn = true
+		}
+	}
+}
+
+// ForceNutrientDose will force a nutrient dose on the controller
+func (c doseCommands) ForceNutrientDose() error {
+	return c.apply(func(r *ReportedIDose) error {
+		forceFunction(r, ig.NutrientDosingFunction)
+		return nil
+	})
+}
+
+// ForcePHDose will force a pH dose on the controller
+func (c doseCommands) ForcePHDose() error {
+	return c.apply(func(r *ReportedIDose) error {
+		forceFunction(r, ig.PHDosingFunction)
+		return nil
+	})
+}
+
+// ForceIrrigation will force an irrigation on the controller
+func (c doseCommands) ForceIrrigation() error {
+	return c.apply(func(r *ReportedIDose) error {
+		forceFunction(r, ig.IrrigationFunction)
+		return nil
+	})
+}
+
+// ForceStation will force an irrigation on the station specified (1-4)
+func (c doseCommands) ForceStation(stn string) error {
+	return c.apply(func(r *ReportedIDose) error {
+		forceFunction(r, ig.StationFunction+stn)
+		return nil
+	})
+}
+
+// SetPHTarget will set the target pH the system should dose to
+func (c doseCommands) SetPHTarget(target float64) error {
+	return c.apply(func(r *ReportedIDose) error {
+		r.Status.SetPoints.Ph = target
+		return nil
+	})
+}
+
+// SetNutrientTargetIn will set the target EC the system should dose to, the target is given in
+// the unit specified and converted to the unit the device is configured with
+func (c doseCommands) SetNutrientTargetIn(target float64, unit units.ConductivityUnit) error {
+	return c.apply(func(r *ReportedIDose) error {
+		r.Status.SetPoints.Nutrient = units.ConvertConductivity(target, unit, r.Config.Units.System().Conductivity)
+		return nil
+	})
+}
+
+// RestoreConfig will replace the config on the controller with a saved config, such as the
+// output of dumping the device as JSON
+func (c doseCommands) RestoreConfig(data []byte) error {
+	cfg := ConfigIDose{}
+	if err := cfg.ParseSaved(data); err != nil {
+		return err
+	}
+
+	return c.apply(func(r *ReportedIDose) error {
+		r.Config = cfg
+		return nil
+	})
+}
+
+// SetIrrigationMode will set how the device decides when to irrigate
+func (c doseCommands) SetIrrigationMode(mode ig.IrrigationMode) error {
+	switch mode {
+	case ig.IrrigationOff, ig.IrrigationDayNight, ig.IrrigationEvery:
+	default:
+		return fmt.Errorf("unknown irrigation mode %q", mode)
+	}
+
+	return c.apply(func(r *ReportedIDose) error {
+		r.Config.Functions.IrrigationMode = string(mode)
+		return nil
+	})
+}
+
+// updateStation validates the station number against the last reported config, and only
+// applies the update if it is valid
+func (c doseCommands) updateStation(n int, update func(*ReportedIDose, datastructs.IrrigationStationFields) error) error {
+	return c.apply(func(r *ReportedIDose) error {
+		st, err := datastructs.IrrigationStation(&r.Config, &r.Status, n)
+		if err != nil {
+			return fmt.Errorf("%s: %s", c.serial, err)
+		}
+
+		if err := update(r, st); err != nil {
+			return fmt.Errorf("%s: %s", c.serial, err)
+		}
+		return nil
+	})
+}
+
+// SetStationName will set the name of the given irrigation station
+func (c doseCommands) SetStationName(n int, name string) error {
+	return c.updateStation(n, func(_ *ReportedIDose, st datastructs.IrrigationStationFields) error {
+		*st.Name = name
+		return nil
+	})
+}
+
+// SetStationIntervals will set the time between irrigations during the day and night for the
+// given station, used in day/night mode
+func (c doseCommands) SetStationIntervals(n int, day, night time.Duration) error {
+	if err := datastructs.ValidateIrrigationInterval(day); err != nil {
+		return err
+	}
+
+	if err := datastructs.ValidateIrrigationInterval(night); err != nil {
+		return err
+	}
+
+	return c.updateStation(n, func(_ *ReportedIDose, st datastructs.IrrigationStationFields) error {
+		st.Interval.Day = int(day / time.Minute)
+		st.Interval.Night = int(night / time.Minute)
+		return nil
+	})
+}
+
+// SetStationInterval will set the time between irrigations for the given station, used in every
+// mode
+func (c doseCommands) SetStationInterval(n int, every time.Duration) error {
+	if err := datastructs.ValidateIrrigationInterval(every); err != nil {
+		return err
+	}
+
+	return c.updateStation(n, func(_ *ReportedIDose, st datastructs.IrrigationStationFields) error {
+		st.Interval.Every = int(every / time.Minute)
+		return nil
+	})
+}
+
+// SetStationDuration will set how long each irrigation of the given station runs for
+func (c doseCommands) SetStationDuration(n int, d time.Duration) error {
+	if err := datastructs.ValidateIrrigationDuration(d); err != nil {
+		return err
+	}
+
+	return c.updateStation(n, func(_ *ReportedIDose, st datastructs.IrrigationStationFields) error {
+		*st.Duration = int(d / time.Second)
+		return nil
+	})
+}
+
+// EnableStation will enable the given irrigation station
+func (c doseCommands) EnableStation(n int) error {
+	return c.setStationEnabled(n, true)
+}
+
+// DisableStation will disable the given irrigation station
+func (c doseCommands) DisableStation(n int) error {
+	return c.setStationEnabled(n, false)
+}
+
+func (c doseCommands) setStationEnabled(n int, enabled bool) error {
+	return c.updateStation(n, func(r *ReportedIDose, _ datastructs.IrrigationStationFields) error {
+		status, err := datastructs.StationStatus(&r.Status, n)
+		if err != nil {
+			return err
+		}
+		status.Enabled = enabled
+		return nil
+	})
+}
+
+// ForceIrrigationStation will force an irrigation on the given station
+func (c doseCommands) ForceIrrigationStation(n int) error {
+	return c.updateStation(n, func(r *ReportedIDose, _ datastructs.IrrigationStationFields) error {
+		status, err := datastructs.StationStatus(&r.Status, n)
+		if err != nil {
+			return err
+		}
+		status.ForceOn = true
+		return nil
+	})
+}
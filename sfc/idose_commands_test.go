@@ -0,0 +1,126 @@
+package sfc
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/autogrow/go-jelly/ig"
+	"github.com/autogrow/go-jelly/units"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type fakeSender struct {
+	sent []command
+	err  error
+}
+
+func (s *fakeSender) request(serial string, cmd command) error {
+	s.sent = append(s.sent, cmd)
+	return s.err
+}
+
+const commandShadowJSON = `{"state": {"reported": {
+	"config": {"units": {"ec": "EC"}, "functions": {"irrigation_stations": 2, "irrigation_mode": "off"}},
+	"status": {"status": [
+		{"function": "Nutrient Dosing"},
+		{"function": "irrigation"},
+		{"function": "Irrigation Station 2"}
+	]}
+}}}`
+
+func TestIntelliDoseCommands(t *testing.T) {
+	Convey("given an IntelliDose that has reported its state", t, func() {
+		id := NewIntelliDose("ASLID17081149")
+		So(id.Update([]byte(commandShadowJSON)), ShouldBeNil)
+
+		Convey("commands should fail until it is subscribed to a connection", func() {
+			So(id.ForceIrrigation(), ShouldEqual, ErrNotAttached)
+		})
+
+		Convey("once it is subscribed", func() {
+			s := &fakeSender{}
+			id.conn = s
+
+			Convey("forcing a function should send the status with the function forced on", func() {
+				So(id.ForceNutrientDose(), ShouldBeNil)
+				So(s.sent, ShouldHaveLength, 1)
+				So(s.sent[0].Device, ShouldEqual, "ASLID17081149")
+
+				status := s.sent[0].State.(SettingsIDose)
+				So(status.Status[0].ForceOn, ShouldBeTrue)
+				So(status.Status[1].ForceOn, ShouldBeFalse)
+
+				Convey("without changing the reported state", func() {
+					So(id.Settings().Status[0].ForceOn, ShouldBeFalse)
+				})
+			})
+
+			Convey("targets should be converted to the unit of the device", func() {
+				So(id.SetNutrientTargetIn(1400, units.PPM700), ShouldBeNil)
+				So(s.sent[0].State.(SettingsIDose).SetPoints.Nutrient, ShouldAlmostEqual, 2)
+			})
+
+			Convey("stations should be validated against the reported config", func() {
+				So(id.SetStationDuration(3, time.Minute), ShouldNotBeNil)
+				So(id.SetStationIntervals(2, 90*time.Second, time.Hour), ShouldNotBeNil)
+				So(id.EnableStation(1), ShouldNotBeNil)
+				So(s.sent, ShouldBeEmpty)
+
+				So(id.SetStationIntervals(2, 90*time.Minute, 4*time.Hour), ShouldBeNil)
+				So(id.ForceIrrigationStation(2), ShouldBeNil)
+				So(s.sent[0].State.(SettingsIDose).General.IrrigationInterval2.Day, ShouldEqual, 90)
+				So(s.sent[1].State.(SettingsIDose).Status[2].ForceOn, ShouldBeTrue)
+			})
+
+			Convey("a transaction should send all the changes at once", func() {
+				err := id.Transaction(func(tx *IntelliDoseTx) error {
+					tx.SetIrrigationMode(ig.IrrigationDayNight)
+					tx.SetPHTarget(6.2)
+					return nil
+				})
+
+				So(err, ShouldBeNil)
+				So(s.sent, ShouldHaveLength, 1)
+				So(s.sent[0].Config.(ConfigIDose).Functions.IrrigationMode, ShouldEqual, "day_night")
+				So(s.sent[0].State.(SettingsIDose).SetPoints.Ph, ShouldEqual, 6.2)
+			})
+
+			Convey("a failed transaction should send nothing", func() {
+				err := id.Transaction(func(tx *IntelliDoseTx) error {
+					tx.SetPHTarget(6.2)
+					return errors.New("changed my mind")
+				})
+
+				So(err, ShouldNotBeNil)
+				So(s.sent, ShouldBeEmpty)
+			})
+
+			Convey("a command from another goroutine should wait for a transaction to be sent", func() {
+				done := make(chan error)
+				err := id.Transaction(func(tx *IntelliDoseTx) error {
+					go func() { done <- id.ForceIrrigation() }()
+					time.Sleep(10 * time.Millisecond)
+					return tx.SetPHTarget(6.2)
+				})
+
+				So(err, ShouldBeNil)
+				So(<-done, ShouldBeNil)
+				So(s.sent, ShouldHaveLength, 2)
+				So(s.sent[0].State.(SettingsIDose).Status[1].ForceOn, ShouldBeFalse)
+				So(s.sent[1].State.(SettingsIDose).Status[1].ForceOn, ShouldBeTrue)
+			})
+
+			Convey("a refused command should return the error", func() {
+				s.err = CommandError{"ASLID17081149", errors.New("busy"), true}
+				So(id.ForcePHDose(), ShouldResemble, s.err)
+			})
+		})
+	})
+
+	Convey("a device that hasn't reported can't be sent commands", t, func() {
+		id := NewIntelliDose("ASLID17081149")
+		id.conn = &fakeSender{}
+		So(id.ForceIrrigation(), ShouldNotBeNil)
+	})
+}
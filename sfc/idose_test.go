@@ -0,0 +1,124 @@
+package sfc
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+const shadowJSON = `{"state": {"reported": {
+	"device": "ASLID17081149",
+	"connected": true,
+	"metrics": {"ec": 1.5, "pH": 6.1, "nut_temp": 21},
+	"status": {"status": [{"function": "Nutrient Dosing", "active": true}]}
+}}}`
+
+func TestIntelliDoseUpdates(t *testing.T) {
+	Convey("given an IntelliDose", t, func() {
+		id := NewIntelliDose("ASLID17081149")
+
+		Convey("it should merge updates into the reported state", func() {
+			So(id.Update([]byte(shadowJSON)), ShouldBeNil)
+			So(id.Update([]byte(`{"state": {"reported": {"metrics": {"ec": 1.6}}}}`)), ShouldBeNil)
+
+			r := id.Readings()
+			So(r.Ec, ShouldEqual, 1.6)
+			So(r.PH, ShouldEqual, 6.1)
+			So(id.Connected(), ShouldBeTrue)
+			So(id.LastUpdated().IsZero(), ShouldBeFalse)
+		})
+
+		Convey("day times should be read as minutes or as HH:MM", func() {
+			So(id.Update([]byte(`{"state": {"reported": {"config": {"times": {"day_start": 360, "day_end": "18:30"}}}}}`)), ShouldBeNil)
+			So(id.Config().Times.DayStart, ShouldEqual, 360)
+			So(id.Config().Times.DayEnd, ShouldEqual, 1110)
+		})
+
+		Convey("the day period should be in the time zone of the device", func() {
+			So(id.Update([]byte(`{"state": {"reported": {"config": {"times": {"day_start": "18:00", "day_end": "06:00"}}}}}`)), ShouldBeNil)
+			id.SetLocation(time.FixedZone("UTC+10:00", 10*3600))
+			ds := id.DaySchedule()
+
+			// 20:00 UTC is 06:00 the next day on the device
+			So(ds.IsDay(time.Date(2018, 6, 1, 8, 0, 0, 0, time.UTC)), ShouldBeTrue)
+			So(ds.IsDay(time.Date(2018, 6, 1, 20, 0, 0, 0, time.UTC)), ShouldBeFalse)
+			So(ds.Period(time.Date(2018, 6, 1, 19, 59, 0, 0, time.UTC)), ShouldEqual, "day")
+
+			next, day := ds.NextTransition(time.Date(2018, 6, 1, 12, 0, 0, 0, time.UTC))
+			So(next.Equal(time.Date(2018, 6, 1, 20, 0, 0, 0, time.UTC)), ShouldBeTrue)
+			So(day, ShouldBeFalse)
+		})
+
+		Convey("a bad payload should leave the state as it was", func() {
+			So(id.Update([]byte(shadowJSON)), ShouldBeNil)
+			So(id.Update([]byte(`{"state": "bad"}`)), ShouldNotBeNil)
+			So(id.Readings().Ec, ShouldEqual, 1.5)
+		})
+
+		Convey("snapshots should not change when the device is updated", func() {
+			So(id.Update([]byte(shadowJSON)), ShouldBeNil)
+			snap := id.Snapshot()
+
+			So(id.Update([]byte(`{"state": {"reported": {"metrics": {"ec": 2}, "status": {"status": [{"function": "Irrigation", "active": false}]}}}}`)), ShouldBeNil)
+			So(snap.Reported.Metrics.Ec, ShouldEqual, 1.5)
+			So(snap.Reported.Status.Status[0].Function, ShouldEqual, "Nutrient Dosing")
+			So(id.Settings().Status[0].Function, ShouldEqual, "Irrigation")
+		})
+
+		Convey("subscribers should be sent the latest snapshot", func() {
+			updates := id.Updates()
+			So(id.Update([]byte(shadowJSON)), ShouldBeNil)
+			So(id.Update([]byte(`{"state": {"reported": {"metrics": {"ec": 1.7}}}}`)), ShouldBeNil)
+
+			snap := <-updates
+			So(snap.Serial, ShouldEqual, "ASLID17081149")
+			So(snap.Reported.Metrics.Ec, ShouldEqual, 1.7)
+
+			id.Unsubscribe(updates)
+			_, open := <-updates
+			So(open, ShouldBeFalse)
+			So(id.Update([]byte(shadowJSON)), ShouldBeNil)
+		})
+
+		Convey("WaitForUpdate should return the next update", func() {
+			go func() {
+				time.Sleep(10 * time.Millisecond)
+				id.Update([]byte(shadowJSON))
+			}()
+
+			snap, err := id.WaitForUpdate(context.Background())
+			So(err, ShouldBeNil)
+			So(snap.Reported.Metrics.PH, ShouldEqual, 6.1)
+		})
+
+		Convey("WaitForUpdate should stop when the context is done", func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+			defer cancel()
+
+			_, err := id.WaitForUpdate(ctx)
+			So(err, ShouldResemble, context.DeadlineExceeded)
+		})
+
+		Convey("it should be safe to read while updates arrive", func() {
+			var wg sync.WaitGroup
+			wg.Add(2)
+			go func() {
+				defer wg.Done()
+				for i := 0; i < 100; i++ {
+					id.Update([]byte(shadowJSON))
+				}
+			}()
+			go func() {
+				defer wg.Done()
+				for i := 0; i < 100; i++ {
+					id.Snapshot()
+					id.Readings()
+				}
+			}()
+			wg.Wait()
+		})
+	})
+}
@@ -0,0 +1,155 @@
+package sfc_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/autogrow/go-jelly/sfc"
+	"github.com/autogrow/go-jelly/sfc/sfctest"
+	"github.com/autogrow/go-jelly/sim"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestIntegration(t *testing.T) {
+	Convey("given a NATS server", t, func() {
+		srv, err := sfctest.NewServer()
+		So(err, ShouldBeNil)
+		defer srv.Close()
+
+		Convey("a device connected with ConnectToNATS should be updated from its shadow", func() {
+			id := sfc.NewIntelliDose("ASLID17081149")
+			updates := id.Updates()
+
+			conn, err := sfc.ConnectToNATS(id, srv.URL, 1)
+			So(err, ShouldBeNil)
+			defer conn.Close()
+
+			So(srv.PublishIntelliDose(sfctest.IntelliDoseFixture("ASLID17081149")), ShouldBeNil)
+			So(updates, sfctest.ShouldReceiveUpdate)
+			So(id.Readings().Ec, ShouldEqual, sfctest.FixtureEC)
+			So(id.Readings().PH, ShouldEqual, sfctest.FixturePH)
+			So(id.Connected(), ShouldBeTrue)
+
+			Convey("and not from the shadows of other devices", func() {
+				So(srv.PublishIntelliDose(sfctest.IntelliDoseFixture("ASLID00000000")), ShouldBeNil)
+				So(updates, sfctest.ShouldNotReceiveUpdate)
+			})
+		})
+
+		Convey("given an IntelliClimate and an IntelliDose on one connection", func() {
+			conn, err := srv.Connect(sfc.Options{})
+			So(err, ShouldBeNil)
+			defer conn.Close()
+
+			id := sfc.NewIntelliDose("ASLID17081149")
+			ic := sfc.NewIntelliClimate("ASLIC17081150")
+			So(conn.Subscribe(id), ShouldBeNil)
+			So(conn.Subscribe(ic), ShouldBeNil)
+			doses, climates := id.Updates(), ic.Updates()
+
+			So(srv.PublishIntelliClimate(sfctest.IntelliClimateFixture("ASLIC17081150")), ShouldBeNil)
+
+			Convey("each should only be sent its own updates", func() {
+				So(climates, sfctest.ShouldReceiveUpdate, func(s sfc.ClimateSnapshot) bool {
+					return s.Reported.Metrics.AirTemp == sfctest.FixtureAirTemp
+				})
+				So(ic.IsDayTime(), ShouldBeTrue)
+				So(doses, sfctest.ShouldNotReceiveUpdate)
+			})
+
+			Convey("a partial shadow should be merged into the last", func() {
+				So(climates, sfctest.ShouldReceiveUpdate)
+				So(srv.PublishShadow("ASLIC17081150", map[string]interface{}{"metrics": map[string]float64{"co2": 1200}}), ShouldBeNil)
+				So(climates, sfctest.ShouldReceiveUpdate, func(s sfc.ClimateSnapshot) bool {
+					return s.Reported.Metrics.Co2 == 1200
+				})
+				So(ic.Readings().AirTemp, ShouldEqual, sfctest.FixtureAirTemp)
+			})
+		})
+
+		Convey("given a device answering commands", func() {
+			simulator := sim.New(time.Now())
+			simulator.AddIntelliDose(sim.NewIntelliDose("ASLID17081149"))
+			So(simulator.Attach(srv.Conn()), ShouldBeNil)
+			defer simulator.Detach()
+
+			conn, err := srv.Connect(sfc.Options{CommandTimeout: time.Second})
+			So(err, ShouldBeNil)
+			defer conn.Close()
+
+			id := sfc.NewIntelliDose("ASLID17081149")
+			So(conn.Subscribe(id), ShouldBeNil)
+			updates := id.Updates()
+			So(simulator.Publish(), ShouldBeNil)
+			So(updates, sfctest.ShouldReceiveUpdate)
+
+			Convey("a command should be acknowledged and the new state published", func() {
+				So(id.SetPHTarget(5.8), ShouldBeNil)
+				So(updates, sfctest.ShouldReceiveUpdate, func(s sfc.Snapshot) bool {
+					return s.Reported.Status.SetPoints.Ph == 5.8
+				})
+			})
+
+			Convey("a command the device refuses should return its error", func() {
+				err := id.SetPHTarget(15)
+				So(err, ShouldNotBeNil)
+				So(err.(sfc.CommandError).Replied, ShouldBeTrue)
+			})
+		})
+
+		Convey("given a connection that reconnects", func() {
+			reconnected := make(chan struct{}, 1)
+			conn, err := srv.Connect(sfc.Options{
+				ReconnectWait: 10 * time.Millisecond,
+				OnReconnect:   func() { reconnected <- struct{}{} },
+			})
+			So(err, ShouldBeNil)
+			defer conn.Close()
+
+			id := sfc.NewIntelliDose("ASLID17081149")
+			So(conn.Subscribe(id), ShouldBeNil)
+			updates := id.Updates()
+
+			Convey("the device should be updated again once the server is back", func() {
+				So(srv.Restart(), ShouldBeNil)
+
+				select {
+				case <-reconnected:
+				case <-time.After(5 * time.Second):
+					So("reconnected", ShouldBeEmpty)
+				}
+
+				So(conn.Connected(), ShouldBeTrue)
+				So(srv.PublishIntelliDose(sfctest.IntelliDoseFixture("ASLID17081149")), ShouldBeNil)
+				So(updates, sfctest.ShouldReceiveUpdate)
+			})
+		})
+
+		Convey("devices publishing should be discovered", func() {
+			conn, err := srv.Connect(sfc.Options{})
+			So(err, ShouldBeNil)
+			defer conn.Close()
+
+			disc, err := conn.Discover(0)
+			So(err, ShouldBeNil)
+			defer disc.Close()
+
+			So(srv.PublishIntelliDose(sfctest.IntelliDoseFixture("ASLID17081149")), ShouldBeNil)
+			So(srv.PublishIntelliClimate(sfctest.IntelliClimateFixture("ASLIC17081150")), ShouldBeNil)
+
+			types := map[string]sfc.DeviceType{}
+			for len(types) < 2 {
+				select {
+				case ev := <-disc.Events():
+					types[ev.Device.Serial] = ev.Device.Type
+				case <-time.After(time.Second):
+					So(types, ShouldHaveLength, 2)
+					return
+				}
+			}
+
+			So(types["ASLID17081149"], ShouldEqual, sfc.IntelliDoseType)
+			So(types["ASLIC17081150"], ShouldEqual, sfc.IntelliClimateType)
+		})
+	})
+}
@@ -0,0 +1,302 @@
+package sfc
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nats-io/go-nats"
+)
+
+// Record is a payload published by a device, as it is kept in a recording.  Recordings are
+// files of JSON lines, one record per line, which are gzipped if the file name ends in .gz.
+type Record struct {
+	Time    time.Time `json:"time"`
+	Subject string    `json:"subject"`
+	// Data is the payload if it is valid JSON, which it is for shadows, otherwise the payload is
+	// kept in Raw
+	Data json.RawMessage `json:"data,omitempty"`
+	Raw  []byte          `json:"raw,omitempty"`
+}
+
+// NewRecord returns a record of the payload published by the device with the given serial
+func NewRecord(at time.Time, serial string, payload []byte) Record {
+	return newRecord(at, topic(serial), payload)
+}
+
+func newRecord(at time.Time, subject string, payload []byte) Record {
+	r := Record{Time: at, Subject: subject}
+	if json.Valid(payload) {
+		r.Data = append(json.RawMessage{}, payload...)
+	} else {
+		r.Raw = append([]byte{}, payload...)
+	}
+	return r
+}
+
+// Serial returns the serial of the device that published the payload
+func (r Record) Serial() string {
+	sn, _ := deviceSerial(r.Subject)
+	return sn
+}
+
+// Payload returns the payload as it was published
+func (r Record) Payload() []byte {
+	if r.Data != nil {
+		return r.Data
+	}
+	return r.Raw
+}
+
+// recordKey is the key of the subscription that a recorder listens on
+const recordKey = "record"
+
+// Recorder writes the payloads published by devices to a recording
+type Recorder struct {
+	mu      sync.Mutex
+	w       *bufio.Writer
+	enc     *json.Encoder
+	closers []io.Closer
+	err     error
+	count   int
+	closed  bool
+	stop    func() error
+}
+
+// NewRecorder returns a recorder that writes records to w as JSON lines
+func NewRecorder(w io.Writer) *Recorder {
+	bw := bufio.NewWriter(w)
+	return &Recorder{
+		w:    bw,
+		enc:  json.NewEncoder(bw),
+		stop: func() error { return nil },
+	}
+}
+
+// CreateRecording returns a recorder that writes to the file at the given path, which is
+// gzipped if the path ends in .gz
+func CreateRecording(path string) (*Recorder, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if !strings.HasSuffix(path, ".gz") {
+		r := NewRecorder(f)
+		r.closers = []io.Closer{f}
+		return r, nil
+	}
+
+	gz := gzip.NewWriter(f)
+	r := NewRecorder(gz)
+	r.closers = []io.Closer{gz, f}
+	return r, nil
+}
+
+// Record writes the payload published to the subject at the given time.  Writing stops at the
+// first error, which is also returned by Close.
+func (r *Recorder) Record(at time.Time, subject string, payload []byte) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	switch {
+	case r.closed:
+		return fmt.Errorf("recorder is closed")
+	case r.err != nil:
+		return r.err
+	}
+
+	if r.err = r.enc.Encode(newRecord(at, subject, payload)); r.err != nil {
+		return r.err
+	}
+
+	r.count++
+	return nil
+}
+
+// Count returns how many records have been written
+func (r *Recorder) Count() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.count
+}
+
+// Close stops recording and flushes the records to the writer, closing the file of a recording
+// that was created
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	if r.closed {
+		r.mu.Unlock()
+		return r.err
+	}
+	r.closed = true
+	r.mu.Unlock()
+
+	// stop listening before flushing as the handler takes the lock
+	err := r.stop()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if ferr := r.w.Flush(); r.err == nil {
+		r.err = ferr
+	}
+
+	for _, c := range r.closers {
+		if cerr := c.Close(); r.err == nil {
+			r.err = cerr
+		}
+	}
+
+	if r.err == nil {
+		r.err = err
+	}
+
+	return r.err
+}
+
+// Record writes every payload published by the devices on the bus to the recorder until it is
+// closed.  Commands sent to the devices aren't recorded.
+func (c *Connection) Record(r *Recorder) error {
+	err := c.subscribe(recordKey, ">", func(msg *nats.Msg) {
+		if _, ok := deviceSerial(msg.Subject); !ok {
+			return
+		}
+
+		if err := r.Record(time.Now(), msg.Subject, msg.Data); err != nil {
+			c.report(fmt.Errorf("failed to record %s: %s", msg.Subject, err))
+		}
+	})
+
+	if err == errSubscribed {
+		return fmt.Errorf("already recording on this connection")
+	}
+
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	r.stop = func() error { return c.unsubscribe(recordKey) }
+	r.mu.Unlock()
+	return nil
+}
+
+// ReadRecording reads all the records from a recording, which is decompressed if it is gzipped
+func ReadRecording(r io.Reader) ([]Record, error) {
+	br := bufio.NewReader(r)
+
+	// gzip streams start with the magic bytes 0x1f 0x8b
+	if magic, err := br.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		br = bufio.NewReader(gz)
+	}
+
+	records := []Record{}
+	dec := json.NewDecoder(br)
+	for {
+		rec := Record{}
+		err := dec.Decode(&rec)
+		if err == io.EOF {
+			return records, nil
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("couldn't read record %d: %s", len(records)+1, err)
+		}
+
+		records = append(records, rec)
+	}
+}
+
+// OpenRecording reads all the records from the recording in the file at the given path
+func OpenRecording(path string) ([]Record, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return ReadRecording(f)
+}
+
+// ReplayTarget is given each record as it is replayed
+type ReplayTarget func(Record) error
+
+// Publisher returns a target that publishes the records to the bus, as if the devices had
+// published them
+func (c *Connection) Publisher() ReplayTarget {
+	return func(r Record) error {
+		c.mu.Lock()
+		nc := c.nc
+		closed := c.closed
+		c.mu.Unlock()
+
+		if closed {
+			return fmt.Errorf("connection is closed")
+		}
+
+		return nc.Publish(r.Subject, r.Payload())
+	}
+}
+
+// UpdateDevices returns a target that updates the devices straight from the records, without a
+// NATS server.  Records of other devices are skipped.
+func UpdateDevices(devices ...intelli) ReplayTarget {
+	bySerial := map[string]intelli{}
+	for _, d := range devices {
+		bySerial[d.Serial()] = d
+	}
+
+	return func(r Record) error {
+		d, ok := bySerial[r.Serial()]
+		if !ok {
+			return nil
+		}
+
+		if err := d.Update(r.Payload()); err != nil {
+			return UpdateError{d.Serial(), err}
+		}
+
+		return nil
+	}
+}
+
+// Replay gives the records to the target in order, waiting between them for the time between
+// when they were recorded divided by the speed.  A speed of 1 replays them in real time, 10 ten
+// times as fast, and 0 without waiting at all.  It stops at the first error from the target, or
+// when the context is done.
+func Replay(ctx context.Context, records []Record, speed float64, target ReplayTarget) error {
+	for i, r := range records {
+		if i > 0 && speed > 0 {
+			if wait := time.Duration(float64(r.Time.Sub(records[i-1].Time)) / speed); wait > 0 {
+				select {
+				case <-ctx.Done():
+					return ctx.Err()
+				case <-time.After(wait):
+				}
+			}
+		}
+
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if err := target(r); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,111 @@
+package sfc
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestRecording(t *testing.T) {
+	start := time.Date(2018, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	Convey("given a recorder", t, func() {
+		buf := &bytes.Buffer{}
+		rec := NewRecorder(buf)
+
+		So(rec.Record(start, "intelli/ASLID17081149", []byte(shadowJSON)), ShouldBeNil)
+		So(rec.Record(start.Add(time.Minute), "intelli/ASLID17081149", []byte(`{"state": {"reported": {"metrics": {"ec": 1.6}}}}`)), ShouldBeNil)
+		So(rec.Record(start.Add(2*time.Minute), "intelli/ASLIC17081150", []byte("not json")), ShouldBeNil)
+		So(rec.Close(), ShouldBeNil)
+
+		Convey("it should write a JSON line for each payload", func() {
+			So(rec.Count(), ShouldEqual, 3)
+			So(bytes.Count(buf.Bytes(), []byte("\n")), ShouldEqual, 3)
+			So(rec.Record(start, "intelli/ASLID17081149", []byte("{}")), ShouldNotBeNil)
+		})
+
+		Convey("the records should be read back as they were published", func() {
+			records, err := ReadRecording(buf)
+			So(err, ShouldBeNil)
+			So(records, ShouldHaveLength, 3)
+			So(records[0].Time.Equal(start), ShouldBeTrue)
+			So(records[0].Serial(), ShouldEqual, "ASLID17081149")
+			So(string(records[2].Payload()), ShouldEqual, "not json")
+		})
+
+		Convey("replaying them should update the devices without a NATS server", func() {
+			records, err := ReadRecording(buf)
+			So(err, ShouldBeNil)
+
+			id := NewIntelliDose("ASLID17081149")
+			So(Replay(context.Background(), records, 0, UpdateDevices(id)), ShouldBeNil)
+			So(id.Readings().Ec, ShouldEqual, 1.6)
+			So(id.Readings().PH, ShouldEqual, 6.1)
+		})
+
+		Convey("a payload the device can't take should stop the replay", func() {
+			records, err := ReadRecording(buf)
+			So(err, ShouldBeNil)
+
+			ic := NewIntelliClimate("ASLIC17081150")
+			err = Replay(context.Background(), records, 0, UpdateDevices(ic))
+			So(err, ShouldHaveSameTypeAs, UpdateError{})
+		})
+	})
+
+	Convey("a recording should be gzipped if the file name ends in .gz", t, func() {
+		dir, err := ioutil.TempDir("", "sfc")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(dir)
+
+		path := filepath.Join(dir, "stream.jsonl.gz")
+		rec, err := CreateRecording(path)
+		So(err, ShouldBeNil)
+		So(rec.Record(start, "intelli/ASLID17081149", []byte(shadowJSON)), ShouldBeNil)
+		So(rec.Close(), ShouldBeNil)
+
+		b, err := ioutil.ReadFile(path)
+		So(err, ShouldBeNil)
+		So(b[:2], ShouldResemble, []byte{0x1f, 0x8b})
+
+		records, err := OpenRecording(path)
+		So(err, ShouldBeNil)
+		So(records, ShouldHaveLength, 1)
+		So(records[0].Subject, ShouldEqual, "intelli/ASLID17081149")
+	})
+
+	Convey("given records a minute apart", t, func() {
+		records := []Record{
+			NewRecord(start, "ASLID17081149", []byte(`{"state": {"reported": {"metrics": {"ec": 1.5}}}}`)),
+			NewRecord(start.Add(time.Minute), "ASLID17081149", []byte(`{"state": {"reported": {"metrics": {"ec": 1.6}}}}`)),
+		}
+
+		Convey("they should be replayed at the given speed", func() {
+			replayed := []time.Time{}
+			target := func(Record) error {
+				replayed = append(replayed, time.Now())
+				return nil
+			}
+
+			So(Replay(context.Background(), records, 1200, target), ShouldBeNil)
+			So(replayed, ShouldHaveLength, 2)
+			So(replayed[1].Sub(replayed[0]), ShouldBeGreaterThanOrEqualTo, 50*time.Millisecond)
+		})
+
+		Convey("the replay should stop when the context is done", func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+			defer cancel()
+
+			id := NewIntelliDose("ASLID17081149")
+			err := Replay(ctx, records, 1, UpdateDevices(id))
+			So(err == context.DeadlineExceeded, ShouldBeTrue)
+			So(id.Readings().Ec, ShouldEqual, 1.5)
+		})
+	})
+}
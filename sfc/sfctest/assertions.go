@@ -0,0 +1,118 @@
+package sfctest
+
+import (
+	"fmt"
+	"reflect"
+	"time"
+)
+
+var (
+	// DefaultTimeout is how long ShouldReceiveUpdate waits for an update if no timeout is given
+	DefaultTimeout = time.Second
+	// DefaultQuietPeriod is how long ShouldNotReceiveUpdate waits if no period is given
+	DefaultQuietPeriod = 100 * time.Millisecond
+)
+
+const success = ""
+
+// ShouldReceiveUpdate is a goconvey assertion that a channel returned by the Updates method of
+// an sfc device is sent an update.  It takes an optional timeout, DefaultTimeout if not given,
+// and an optional func that is given each update and returns true if it is the one expected,
+// taking an sfc.Snapshot or sfc.ClimateSnapshot to match the channel:
+//
+//     updates := idose.Updates()
+//     srv.PublishIntelliDose(sfctest.IntelliDoseFixture("ASLID17081149"))
+//     So(updates, sfctest.ShouldReceiveUpdate, func(s sfc.Snapshot) bool {
+//       return s.Reported.Metrics.Ec == sfctest.FixtureEC
+//     })
+//
+// Updates that don't match are skipped.
+func ShouldReceiveUpdate(actual interface{}, expected ...interface{}) string {
+	ch, msg := updatesChannel(actual)
+	if msg != success {
+		return msg
+	}
+
+	timeout := DefaultTimeout
+	var match reflect.Value
+	for _, e := range expected {
+		switch v := e.(type) {
+		case time.Duration:
+			timeout = v
+		default:
+			fn := reflect.ValueOf(e)
+			if fn.Kind() != reflect.Func || fn.Type().NumIn() != 1 || fn.Type().In(0) != ch.Type().Elem() ||
+				fn.Type().NumOut() != 1 || fn.Type().Out(0).Kind() != reflect.Bool {
+				return fmt.Sprintf("Expected a timeout or a func(%s) bool, not %T", ch.Type().Elem(), e)
+			}
+			match = fn
+		}
+	}
+
+	deadline := time.After(timeout)
+	skipped := 0
+	for {
+		update, ok, timedOut := receive(ch, deadline)
+		switch {
+		case timedOut && skipped > 0:
+			return fmt.Sprintf("Expected a matching update within %s, but none of the %d received matched", timeout, skipped)
+		case timedOut:
+			return fmt.Sprintf("Expected an update within %s, but none was received", timeout)
+		case !ok:
+			return "Expected an update, but the channel was closed"
+		}
+
+		if !match.IsValid() || match.Call([]reflect.Value{update})[0].Bool() {
+			return success
+		}
+		skipped++
+	}
+}
+
+// ShouldNotReceiveUpdate is a goconvey assertion that a channel returned by the Updates method
+// of an sfc device isn't sent an update within the period given, DefaultQuietPeriod if not
+// given
+func ShouldNotReceiveUpdate(actual interface{}, expected ...interface{}) string {
+	ch, msg := updatesChannel(actual)
+	if msg != success {
+		return msg
+	}
+
+	period := DefaultQuietPeriod
+	if len(expected) > 0 {
+		d, ok := expected[0].(time.Duration)
+		if !ok {
+			return fmt.Sprintf("Expected a period to wait, not %T", expected[0])
+		}
+		period = d
+	}
+
+	update, ok, timedOut := receive(ch, time.After(period))
+	switch {
+	case timedOut:
+		return success
+	case !ok:
+		return "Expected the channel to stay open, but it was closed"
+	}
+
+	return fmt.Sprintf("Expected no update within %s, but received %+v", period, update.Interface())
+}
+
+// updatesChannel returns the channel to receive updates from, or a message if it isn't one
+func updatesChannel(actual interface{}) (reflect.Value, string) {
+	ch := reflect.ValueOf(actual)
+	if ch.Kind() != reflect.Chan || ch.Type().ChanDir()&reflect.RecvDir == 0 {
+		return ch, fmt.Sprintf("Expected a channel of updates, not %T", actual)
+	}
+	return ch, success
+}
+
+// receive waits for a value from the channel, returning timedOut if the deadline passes first
+// and ok as false if the channel is closed
+func receive(ch reflect.Value, deadline <-chan time.Time) (v reflect.Value, ok, timedOut bool) {
+	chosen, v, ok := reflect.Select([]reflect.SelectCase{
+		{Dir: reflect.SelectRecv, Chan: ch},
+		{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(deadline)},
+	})
+	return v, ok, chosen == 1
+}
@@ -0,0 +1,23 @@
+// Package sfctest provides a NATS server running in the test process, fixture shadows and
+// goconvey assertions, for hermetic tests of code that uses the sfc package.
+//
+//     srv, err := sfctest.NewServer()
+//     defer srv.Close()
+//
+//     conn, err := srv.Connect(sfc.Options{})
+//     idose := sfc.NewIntelliDose("ASLID17081149")
+//     conn.Subscribe(idose)
+//
+//     updates := idose.Updates()
+//     srv.PublishIntelliDose(sfctest.IntelliDoseFixture("ASLID17081149"))
+//     So(updates, sfctest.ShouldReceiveUpdate)
+//
+// Commands sent to the devices can be answered by attaching a simulator from the sim package to
+// the connection of the server:
+//
+//     simulator := sim.New(time.Now())
+//     simulator.AddIntelliDose(sim.NewIntelliDose("ASLID17081149"))
+//     simulator.Attach(srv.Conn())
+//
+// Restarting the server drops the connections to it, to test reconnecting.
+package sfctest
@@ -0,0 +1,46 @@
+package sfctest
+
+import (
+	"time"
+
+	"github.com/autogrow/go-jelly/sfc"
+	"github.com/autogrow/go-jelly/sim"
+)
+
+// The readings of the fixtures
+const (
+	FixtureEC      = 1.8
+	FixturePH      = 6.0
+	FixtureNutTemp = 20.0
+
+	FixtureAirTemp = 24.0
+	FixtureRH      = 60.0
+	FixtureCO2     = 800.0
+)
+
+// IntelliDoseFixture returns the reported state of a connected IntelliDose with the settings
+// of a simulated one, reading FixtureEC, FixturePH and FixtureNutTemp
+func IntelliDoseFixture(serial string) sfc.ReportedIDose {
+	r := sim.NewIntelliDose(serial).Reported()
+	r.Timestamp = time.Now().UnixNano() / int64(time.Millisecond)
+	r.Metrics = sfc.MetricsIDose{
+		Ec:      FixtureEC,
+		PH:      FixturePH,
+		NutTemp: FixtureNutTemp,
+	}
+	return r
+}
+
+// IntelliClimateFixture returns the reported state of a connected IntelliClimate with the
+// settings of a simulated one, reading FixtureAirTemp, FixtureRH and FixtureCO2 during the day
+func IntelliClimateFixture(serial string) sfc.ReportedIClimate {
+	r := sim.NewIntelliClimate(serial).Reported()
+	r.Timestamp = time.Now().UnixNano() / int64(time.Millisecond)
+	r.Metrics = sfc.MetricsIClimate{
+		AirTemp:  FixtureAirTemp,
+		Rh:       FixtureRH,
+		Co2:      FixtureCO2,
+		DayNight: "day",
+	}
+	return r
+}
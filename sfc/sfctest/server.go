@@ -0,0 +1,157 @@
+package sfctest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/autogrow/go-jelly/sfc"
+	"github.com/nats-io/go-nats"
+	"github.com/nats-io/nats-server/v2/server"
+)
+
+// readyTimeout is how long to wait for the server to accept connections
+const readyTimeout = 5 * time.Second
+
+// Server is a NATS server running in the test process on a random port, with a connection of
+// its own to publish payloads on as the devices would
+type Server struct {
+	// URL is the address to connect to the server on
+	URL string
+
+	mu   sync.Mutex
+	port int
+	srv  *server.Server
+	nc   *nats.Conn
+}
+
+// NewServer starts a NATS server on a random port of the loopback interface
+func NewServer() (*Server, error) {
+	s := &Server{}
+	if err := s.start(server.RANDOM_PORT); err != nil {
+		return nil, err
+	}
+
+	s.URL = fmt.Sprintf("nats://127.0.0.1:%d", s.port)
+	return s, nil
+}
+
+func (s *Server) start(port int) error {
+	srv, err := server.NewServer(&server.Options{
+		Host:   "127.0.0.1",
+		Port:   port,
+		NoLog:  true,
+		NoSigs: true,
+	})
+	if err != nil {
+		return fmt.Errorf("couldn't create NATS server: %s", err)
+	}
+
+	go srv.Start()
+	if !srv.ReadyForConnections(readyTimeout) {
+		srv.Shutdown()
+		return fmt.Errorf("NATS server wasn't ready after %s", readyTimeout)
+	}
+
+	addr, ok := srv.Addr().(*net.TCPAddr)
+	if !ok {
+		srv.Shutdown()
+		return fmt.Errorf("NATS server isn't listening on TCP")
+	}
+
+	nc, err := nats.Connect(fmt.Sprintf("nats://127.0.0.1:%d", addr.Port))
+	if err != nil {
+		srv.Shutdown()
+		return fmt.Errorf("couldn't connect to NATS server: %s", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.srv, s.nc, s.port = srv, nc, addr.Port
+	return nil
+}
+
+// Conn returns the connection of the server, to publish or subscribe on directly
+func (s *Server) Conn() *nats.Conn {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.nc
+}
+
+// Connect returns an sfc connection to the server
+func (s *Server) Connect(opts sfc.Options) (*sfc.Connection, error) {
+	return sfc.Connect(s.URL, opts)
+}
+
+// Publish sends the payload on the topic of the device with the given serial, and waits for
+// the server to have it
+func (s *Server) Publish(serial string, payload []byte) error {
+	nc := s.Conn()
+	if nc == nil {
+		return fmt.Errorf("server is shut down")
+	}
+
+	if err := nc.Publish("intelli/"+serial, payload); err != nil {
+		return err
+	}
+
+	return nc.Flush()
+}
+
+// PublishShadow sends the reported state of a device wrapped in a shadow, the way the devices
+// publish it
+func (s *Server) PublishShadow(serial string, reported interface{}) error {
+	data, err := json.Marshal(map[string]interface{}{
+		"state": map[string]interface{}{"reported": reported},
+	})
+	if err != nil {
+		return err
+	}
+
+	return s.Publish(serial, data)
+}
+
+// PublishIntelliDose sends the shadow of an IntelliDose with the reported state given
+func (s *Server) PublishIntelliDose(r sfc.ReportedIDose) error {
+	return s.PublishShadow(r.Device, r)
+}
+
+// PublishIntelliClimate sends the shadow of an IntelliClimate with the reported state given
+func (s *Server) PublishIntelliClimate(r sfc.ReportedIClimate) error {
+	return s.PublishShadow(r.Device, r)
+}
+
+// Shutdown stops the server, dropping the connections to it, as when an IntelliLink goes away
+func (s *Server) Shutdown() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.nc != nil {
+		s.nc.Close()
+		s.nc = nil
+	}
+
+	if s.srv != nil {
+		s.srv.Shutdown()
+		s.srv = nil
+	}
+}
+
+// Restart shuts the server down if it is running and starts it again on the same port, so
+// clients can reconnect to it
+func (s *Server) Restart() error {
+	s.Shutdown()
+
+	s.mu.Lock()
+	port := s.port
+	s.mu.Unlock()
+
+	return s.start(port)
+}
+
+// Close shuts down the server
+func (s *Server) Close() {
+	s.Shutdown()
+}
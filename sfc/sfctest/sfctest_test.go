@@ -0,0 +1,74 @@
+package sfctest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/autogrow/go-jelly/sfc"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestAssertions(t *testing.T) {
+	Convey("given a channel of updates", t, func() {
+		updates := make(chan sfc.Snapshot, 2)
+
+		Convey("ShouldReceiveUpdate should pass once an update is sent", func() {
+			updates <- sfc.Snapshot{Serial: "ASLID17081149"}
+			So(ShouldReceiveUpdate((<-chan sfc.Snapshot)(updates)), ShouldBeEmpty)
+		})
+
+		Convey("ShouldReceiveUpdate should skip updates that don't match", func() {
+			updates <- sfc.Snapshot{Serial: "ASLID17081149"}
+			updates <- sfc.Snapshot{Serial: "ASLID17081150"}
+
+			match := func(s sfc.Snapshot) bool { return s.Serial == "ASLID17081150" }
+			So(ShouldReceiveUpdate(updates, match, 10*time.Millisecond), ShouldBeEmpty)
+			So(ShouldReceiveUpdate(updates, match, 10*time.Millisecond), ShouldContainSubstring, "within 10ms")
+		})
+
+		Convey("ShouldReceiveUpdate should fail with a predicate for the wrong type", func() {
+			msg := ShouldReceiveUpdate(updates, func(s sfc.ClimateSnapshot) bool { return true })
+			So(msg, ShouldContainSubstring, "func(sfc.Snapshot) bool")
+		})
+
+		Convey("ShouldNotReceiveUpdate should fail if an update is sent", func() {
+			So(ShouldNotReceiveUpdate(updates, 10*time.Millisecond), ShouldBeEmpty)
+			updates <- sfc.Snapshot{Serial: "ASLID17081149"}
+			So(ShouldNotReceiveUpdate(updates), ShouldContainSubstring, "ASLID17081149")
+		})
+
+		Convey("a closed channel should fail both", func() {
+			close(updates)
+			So(ShouldReceiveUpdate(updates), ShouldContainSubstring, "closed")
+			So(ShouldNotReceiveUpdate(updates), ShouldContainSubstring, "closed")
+		})
+	})
+
+	Convey("anything but a channel should fail", t, func() {
+		So(ShouldReceiveUpdate(sfc.Snapshot{}), ShouldContainSubstring, "channel of updates")
+	})
+}
+
+func TestServer(t *testing.T) {
+	Convey("given two servers", t, func() {
+		a, err := NewServer()
+		So(err, ShouldBeNil)
+		defer a.Close()
+
+		b, err := NewServer()
+		So(err, ShouldBeNil)
+		defer b.Close()
+
+		Convey("they should be on different ports", func() {
+			So(a.URL, ShouldNotEqual, b.URL)
+		})
+
+		Convey("publishing should fail once a server is shut down until it is restarted", func() {
+			a.Shutdown()
+			So(a.Publish("ASLID17081149", []byte("{}")), ShouldNotBeNil)
+
+			So(a.Restart(), ShouldBeNil)
+			So(a.Publish("ASLID17081149", []byte("{}")), ShouldBeNil)
+		})
+	})
+}
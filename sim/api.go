@@ -0,0 +1,164 @@
+package sim
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"time"
+)
+
+// apiDevice is a device as it is listed by the IntelliGrow API
+type apiDevice struct {
+	ID             string  `json:"device_id"`
+	Type           string  `json:"device_type"`
+	Growroom       string  `json:"growroom"`
+	LastUpdated    int64   `json:"last_updated"`
+	TimeZoneOffset float64 `json:"time_zone_offset"`
+	DeviceName     string  `json:"device_name"`
+}
+
+// Handler returns an HTTP handler that serves the IntelliGrow API for the devices of the
+// simulator.  Any username and password are accepted, and saving a device applies its state
+// and config as a command sent over NATS would.
+func (s *Simulator) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/auth/token", s.serveToken)
+	mux.HandleFunc("/v1/auth/token/refresh", s.serveToken)
+	mux.HandleFunc("/v1/intelligrow/devices", s.serveDevices)
+	mux.HandleFunc("/v1/intelligrow/devices/metrics", s.serveDevice(func(d device, r *http.Request) interface{} {
+		return map[string]interface{}{d.deviceType(): d.apiMetrics(), "last_updated": d.lastUpdated()}
+	}))
+	mux.HandleFunc("/v1/intelligrow/devices/config", s.serveDevice(func(d device, r *http.Request) interface{} {
+		return map[string]interface{}{d.deviceType(): d.apiConfig(), "last_updated": d.lastUpdated()}
+	}))
+	mux.HandleFunc("/v1/intelligrow/devices/state", s.serveDevice(func(d device, r *http.Request) interface{} {
+		return map[string]interface{}{d.deviceType(): d.apiState(), "last_updated": d.lastUpdated()}
+	}))
+	mux.HandleFunc("/v1/intelligrow/devices/history", s.serveDevice(func(d device, r *http.Request) interface{} {
+		q := r.URL.Query()
+		points, _ := strconv.Atoi(q.Get("points"))
+		from, to := msParam(q.Get("from_date")), msParam(q.Get("to_date"))
+		if !from.IsZero() && !to.IsZero() && to.Before(from) {
+			from, to = to, from
+		}
+		return map[string]interface{}{"device": d.Serial(), "history": d.apiHistory(from, to, points)}
+	}))
+	return mux
+}
+
+// HTTPClient returns an HTTP client that has its requests served by the handler of the
+// simulator without going over the network, to give to ig.NewClientWithHTTPClient
+func (s *Simulator) HTTPClient() *http.Client {
+	return &http.Client{Transport: handlerTransport{s.Handler()}}
+}
+
+// handlerTransport is a round tripper that serves requests with a handler
+type handlerTransport struct {
+	handler http.Handler
+}
+
+func (t handlerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	rec := httptest.NewRecorder()
+	t.handler.ServeHTTP(rec, req)
+	return rec.Result(), nil
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, code int, msg string) {
+	writeJSON(w, code, map[string]string{"error": msg})
+}
+
+func msParam(v string) time.Time {
+	ms, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || ms <= 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, ms*int64(time.Millisecond))
+}
+
+func (s *Simulator) serveToken(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"api_access_token": "simulator",
+		"expires_in":       3600,
+		"refresh_token":    "simulator",
+	})
+}
+
+func (s *Simulator) serveDevices(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		now, devices := s.snapshot()
+		list := []apiDevice{}
+		for _, d := range devices {
+			_, offset := now.In(d.location()).Zone()
+			list = append(list, apiDevice{
+				ID:             d.Serial(),
+				Type:           d.deviceType(),
+				Growroom:       d.growroom(),
+				LastUpdated:    d.lastUpdated(),
+				TimeZoneOffset: float64(offset) / 3600,
+				DeviceName:     d.name(),
+			})
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"checked_devices": len(list), "devices": list})
+
+	case http.MethodPut:
+		data, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		cmd := command{}
+		if err := json.Unmarshal(data, &cmd); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		d, ok := s.device(cmd.Device)
+		if !ok {
+			writeError(w, http.StatusNotFound, "no device "+cmd.Device)
+			return
+		}
+
+		if err := d.command(data); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"device": d.Serial()})
+
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+// serveDevice serves the response given for the device named in the query
+func (s *Simulator) serveDevice(response func(d device, r *http.Request) interface{}) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+
+		serial := r.URL.Query().Get("device")
+		d, ok := s.device(serial)
+		if !ok {
+			writeError(w, http.StatusNotFound, "no device "+serial)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, response(d, r))
+	}
+}
@@ -0,0 +1,23 @@
+// Package sim simulates IntelliDose and IntelliClimate devices, so that code using the sfc and
+// ig packages can be tried out and tested without real devices.
+//
+// A simulated IntelliDose doses a reservoir whose EC and pH drift as the plants feed, and which
+// is drained by irrigation and topped up with water.  A simulated IntelliClimate switches fans,
+// heating, cooling, humidity control, CO2 injection and lights to keep a room at its setpoints.
+// Both follow the setpoints and force commands sent to them:
+//
+//     s := sim.New(time.Now())
+//     idose := sim.NewIntelliDose("ASLID17081149")
+//     idose.Reservoir.EC = 1.2
+//     s.AddIntelliDose(idose)
+//     s.Step(time.Hour)
+//
+// The devices publish their shadows on the NATS connections the simulator is attached to and
+// take commands sent to them there, and the simulator serves the IntelliGrow API:
+//
+//     s.Attach(nc)
+//     go s.Run(ctx, time.Second, 60)
+//
+//     client, err := ig.NewClientWithHTTPClient("user", "pass", s.HTTPClient())
+//     http.ListenAndServe(":8080", s.Handler())
+package sim
@@ -0,0 +1,408 @@
+package sim
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/autogrow/go-jelly/calc"
+	"github.com/autogrow/go-jelly/ig"
+	"github.com/autogrow/go-jelly/ig/datastructs"
+	"github.com/autogrow/go-jelly/units"
+)
+
+// The functions a simulated IntelliClimate reports the status of
+const (
+	FansFunction         = "Fans"
+	HeaterFunction       = "Heater"
+	AirConFunction       = "Air Conditioner"
+	CO2Function          = "CO2 Injection"
+	HumidifierFunction   = "Humidifier"
+	DehumidifierFunction = "Dehumidifier"
+	LightBank1Function   = "Light Bank 1"
+	LightBank2Function   = "Light Bank 2"
+)
+
+const (
+	// tempBand, rhBand and co2Band are how far past the setpoint the readings go before the
+	// equipment is switched on, it is switched off again at the setpoint
+	tempBand = 1.0
+	rhBand   = 5.0
+	co2Band  = 100.0
+)
+
+// IntelliClimate simulates an IntelliClimate controlling a room.  The lights follow the
+// schedule of each enabled light bank, and it is day while they are on.  The fans and air
+// conditioner cool the room to the day temperature, or the day temperature less the night drop,
+// and the heater warms it.  The fans also vent the room above the RH maximum, and the humidifier
+// and dehumidifier keep the RH to the day or night target.  CO2 is injected while the lights are
+// on and the fans are off.  Forcing a function keeps it on until the force is cleared.
+//
+// The exported fields should be set before the device is added to a simulator.  The room can be
+// read between steps, or through Reported while the simulation runs.
+type IntelliClimate struct {
+	Room *Room
+	// Growroom is the growroom the device is listed in by the API
+	Growroom string
+	// Location is the time zone of the clock of the device, UTC if nil
+	Location *time.Location
+
+	serial string
+
+	mu      sync.Mutex
+	state   datastructs.ReportedIClimate
+	out     Outputs
+	history []*datastructs.ClimateHistoryPoint
+}
+
+// NewIntelliClimate returns a simulated IntelliClimate with fans, a heater and CO2 injection,
+// keeping the room at 25°C and 1000 ppm CO2 under a 12 hour photoperiod from 06:00
+func NewIntelliClimate(serial string) *IntelliClimate {
+	st := func(fn string) datastructs.StatusStatusIClimate {
+		return datastructs.StatusStatusIClimate{Function: fn, Enabled: true, Installed: true}
+	}
+
+	return &IntelliClimate{
+		Room:     NewRoom(),
+		Location: time.UTC,
+		serial:   serial,
+		state: datastructs.ReportedIClimate{
+			Device:    serial,
+			Source:    source,
+			Connected: true,
+			Config: datastructs.ConfigIClimate{
+				Units: datastructs.UnitsIClimate{Temperature: "C"},
+				Functions: datastructs.FunctionsIClimate{
+					Fan1:         true,
+					Heater:       true,
+					Co2Sensor:    true,
+					Co2Injection: true,
+					LightBank1:   true,
+				},
+				General: datastructs.GeneralIClimate{DeviceName: serial},
+			},
+			Status: datastructs.StatusIClimate{
+				Readings: datastructs.ReadingsIClimate{
+					AirTemp: datastructs.AirTempIClimate{Enabled: true, Min: 15, Max: 32},
+					Rh:      datastructs.RhIClimate{Enabled: true, Min: 40, Max: 85},
+					CO2:     datastructs.CO2IClimate{Enabled: true, Min: 300, Max: 1800},
+				},
+				SetPoints: []datastructs.SetPointIClimate{{
+					LightBank:     "Light Bank 1",
+					LightOn:       6 * 60,
+					LightDuration: 12 * 60,
+					DayTemp:       25,
+					NightDropDeg:  5,
+					RhDay:         60,
+					RhMax:         80,
+					RhNight:       55,
+					CO2:           1000,
+				}},
+				Status: []datastructs.StatusStatusIClimate{
+					st(FansFunction),
+					st(HeaterFunction),
+					st(CO2Function),
+					st(LightBank1Function),
+				},
+			},
+		},
+	}
+}
+
+// Serial returns the serial number of the device
+func (c *IntelliClimate) Serial() string {
+	return c.serial
+}
+
+func (c *IntelliClimate) deviceType() string {
+	return "iclimate"
+}
+
+func (c *IntelliClimate) growroom() string {
+	return c.Growroom
+}
+
+func (c *IntelliClimate) location() *time.Location {
+	if c.Location == nil {
+		return time.UTC
+	}
+	return c.Location
+}
+
+// Reported returns a copy of the state the device reports
+func (c *IntelliClimate) Reported() datastructs.ReportedIClimate {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.reported()
+}
+
+func (c *IntelliClimate) reported() datastructs.ReportedIClimate {
+	r := c.state
+	r.Status.SetPoints = append([]datastructs.SetPointIClimate{}, r.Status.SetPoints...)
+	r.Status.Status = append([]datastructs.StatusStatusIClimate{}, r.Status.Status...)
+	return r
+}
+
+// Outputs returns the equipment that is switched on
+func (c *IntelliClimate) Outputs() Outputs {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.out
+}
+
+// Configure changes the state of the device, such as its setpoints, as if it was changed on the
+// device itself
+func (c *IntelliClimate) Configure(update func(r *datastructs.ReportedIClimate)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	update(&c.state)
+}
+
+func (c *IntelliClimate) temperatureUnit() units.TemperatureUnit {
+	t, err := units.ParseTemperatureUnit(c.state.Config.Units.Temperature)
+	if err != nil {
+		return units.Celsius
+	}
+	return t
+}
+
+// lightSchedules returns the schedules of the enabled light banks
+func (c *IntelliClimate) lightSchedules() []ig.LightSchedule {
+	ic := ig.NewIntelliClimate(&ig.Device{ID: c.serial, Type: c.deviceType()})
+	*ic.Config = c.state.Config
+	*ic.Status = c.reported().Status
+
+	schedules := []ig.LightSchedule{}
+	for _, ls := range ic.LightSchedules() {
+		if ls.Enabled {
+			schedules = append(schedules, ls)
+		}
+	}
+	return schedules
+}
+
+func (c *IntelliClimate) forced(name string) bool {
+	for _, st := range c.state.Status.Status {
+		if st.Function == name {
+			return st.ForceOn
+		}
+	}
+	return false
+}
+
+// hysteresis switches an output on when on is true and off when off is true, otherwise it stays
+// as it is
+func hysteresis(current, on, off bool) bool {
+	switch {
+	case on:
+		return true
+	case off:
+		return false
+	}
+	return current
+}
+
+// step advances the device and its room from the time given by dt
+func (c *IntelliClimate) step(from time.Time, dt time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	schedules := c.lightSchedules()
+	for t := from; t.Before(from.Add(dt)); {
+		chunk := resolution
+		if rest := from.Add(dt).Sub(t); rest < chunk {
+			chunk = rest
+		}
+		c.control(schedules, t)
+		c.Room.Step(chunk, c.out)
+		t = t.Add(chunk)
+	}
+
+	c.report(from.Add(dt))
+}
+
+// control switches the outputs for the readings of the room at the time given
+func (c *IntelliClimate) control(schedules []ig.LightSchedule, now time.Time) {
+	local := now.In(c.location())
+
+	lights := false
+	for _, ls := range schedules {
+		lights = lights || ls.LightsOn(local)
+	}
+
+	out := c.out
+	out.Lights = lights || c.forced(LightBank1Function) || c.forced(LightBank2Function)
+
+	fn := c.state.Config.Functions
+	if len(c.state.Status.SetPoints) > 0 {
+		sp := c.state.Status.SetPoints[0]
+		unit := c.temperatureUnit()
+		r := c.Room
+
+		target := units.ConvertTemperature(sp.DayTemp, unit, units.Celsius)
+		rhTarget := float64(sp.RhDay)
+		if !out.Lights {
+			target -= units.ConvertTemperatureDifference(sp.NightDropDeg, unit, units.Celsius)
+			rhTarget = float64(sp.RhNight)
+		}
+		rhMax := float64(sp.RhMax)
+		venting := rhMax > 0 && r.RH > rhMax
+
+		out.Fans = (fn.Fan1 || fn.Fan2) && hysteresis(out.Fans,
+			r.AirTemp > target+tempBand || venting,
+			r.AirTemp <= target && (rhMax <= 0 || r.RH <= rhMax-rhBand))
+		out.Heater = fn.Heater && hysteresis(out.Heater, r.AirTemp < target-tempBand, r.AirTemp >= target)
+		out.AirCon = fn.AirConditioner && hysteresis(out.AirCon, r.AirTemp > target+2*tempBand, r.AirTemp <= target)
+		out.Humidifier = fn.Humidifier && rhTarget > 0 && hysteresis(out.Humidifier, r.RH < rhTarget-rhBand, r.RH >= rhTarget)
+		out.Dehumidifier = fn.Dehumidifier && rhTarget > 0 && hysteresis(out.Dehumidifier, r.RH > rhTarget+rhBand, r.RH <= rhTarget)
+
+		co2 := float64(sp.CO2)
+		out.CO2 = fn.Co2Injection && out.Lights && !out.Fans && co2 > 0 &&
+			hysteresis(out.CO2, r.CO2 < co2-co2Band, r.CO2 >= co2)
+	}
+
+	out.Fans = out.Fans || c.forced(FansFunction)
+	out.Heater = out.Heater || c.forced(HeaterFunction)
+	out.AirCon = out.AirCon || c.forced(AirConFunction)
+	out.CO2 = out.CO2 || c.forced(CO2Function)
+	out.Humidifier = out.Humidifier || c.forced(HumidifierFunction)
+	out.Dehumidifier = out.Dehumidifier || c.forced(DehumidifierFunction)
+
+	c.out = out
+}
+
+// report updates the metrics and status with the state of the room and outputs, and keeps a
+// history point
+func (c *IntelliClimate) report(now time.Time) {
+	unit := c.temperatureUnit()
+	r := c.Room
+
+	dayNight := "night"
+	if c.out.Lights {
+		dayNight = "day"
+	}
+
+	m := &c.state.Metrics
+	m.AirTemp = units.ConvertTemperature(r.AirTemp, units.Celsius, unit)
+	m.OutsideTemp = units.ConvertTemperature(r.OutsideTemp, units.Celsius, unit)
+	m.Rh = r.RH
+	m.Co2 = r.CO2
+	m.Vpd = calc.VPD(r.AirTemp, r.RH)
+	m.Light = r.Light(c.out.Lights)
+	m.DayNight = dayNight
+	c.state.Timestamp = now.UnixNano() / int64(time.Millisecond)
+
+	active := map[string]bool{
+		FansFunction:         c.out.Fans,
+		HeaterFunction:       c.out.Heater,
+		AirConFunction:       c.out.AirCon,
+		CO2Function:          c.out.CO2,
+		HumidifierFunction:   c.out.Humidifier,
+		DehumidifierFunction: c.out.Dehumidifier,
+		LightBank1Function:   c.out.Lights,
+		LightBank2Function:   c.out.Lights,
+	}
+
+	status := []datastructs.DeviceStatus{}
+	for i := range c.state.Status.Status {
+		st := &c.state.Status.Status[i]
+		st.Active = active[st.Function]
+		status = append(status, datastructs.DeviceStatus{
+			Active:    st.Active,
+			Enabled:   st.Enabled,
+			ForceOn:   st.ForceOn,
+			Function:  st.Function,
+			Installed: st.Installed,
+		})
+	}
+
+	c.history = append(c.history, &datastructs.ClimateHistoryPoint{
+		Timestamp: float64(c.state.Timestamp),
+		Status:    datastructs.Status{Status: status},
+		Metrics: datastructs.ClimateMetricsHistory{
+			AirTemp: m.AirTemp,
+			Rh:      m.Rh,
+			Vpd:     m.Vpd,
+			CO2:     m.Co2,
+			Light:   m.Light,
+		},
+	})
+
+	if len(c.history) > historySize {
+		c.history = c.history[len(c.history)-historySize:]
+	}
+}
+
+// shadow returns the shadow the device publishes over NATS
+func (c *IntelliClimate) shadow() ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return json.Marshal(shadowOf(c.reported()))
+}
+
+// command applies the state and config sent to the device
+func (c *IntelliClimate) command(data []byte) error {
+	cmd, err := parseCommand(data, c.serial)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	r := c.reported()
+	if err := cmd.apply(&r.Status, &r.Config); err != nil {
+		return err
+	}
+
+	for _, sp := range r.Status.SetPoints {
+		if sp.RhDay < 0 || sp.RhDay > 100 || sp.RhNight < 0 || sp.RhNight > 100 {
+			return fmt.Errorf("RH setpoint is out of range")
+		}
+	}
+
+	c.state.Status, c.state.Config = r.Status, r.Config
+	return nil
+}
+
+func (c *IntelliClimate) apiMetrics() interface{} {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.state.Metrics
+}
+
+func (c *IntelliClimate) apiConfig() interface{} {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.state.Config
+}
+
+func (c *IntelliClimate) apiState() interface{} {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.reported().Status
+}
+
+func (c *IntelliClimate) apiHistory(from, to time.Time, points int) interface{} {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	h := datastructs.ClimateHistory{Points: []*datastructs.ClimateHistoryPoint{}}
+	for _, i := range historyBetween(len(c.history), func(i int) float64 { return c.history[i].Timestamp }, from, to, points) {
+		h.Points = append(h.Points, c.history[i])
+	}
+	return h
+}
+
+func (c *IntelliClimate) name() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.state.Config.General.DeviceName
+}
+
+func (c *IntelliClimate) lastUpdated() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.state.Timestamp
+}
@@ -0,0 +1,461 @@
+package sim
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/autogrow/go-jelly/ig"
+	"github.com/autogrow/go-jelly/ig/datastructs"
+	"github.com/autogrow/go-jelly/units"
+)
+
+const (
+	// ecDeadband and phDeadband are how far the readings have to be from the target before a
+	// dose is started
+	ecDeadband = 0.05
+	phDeadband = 0.05
+
+	defaultDoseInterval = 5 * time.Minute
+	defaultDoseTime     = 10 * time.Second
+)
+
+// IntelliDose simulates an IntelliDose dosing and irrigating from a reservoir.  It doses when
+// the EC falls below the nutrient setpoint or the pH drifts past the pH setpoint, waiting the
+// dose interval between doses, and irrigates each enabled station on the schedule of the
+// irrigation mode.  Forcing a function starts a dose or irrigation straight away, and the force
+// is cleared once it has started, as on the device.
+//
+// The exported fields should be set before the device is added to a simulator.  The reservoir
+// can be read between steps, or through Reported while the simulation runs.
+type IntelliDose struct {
+	Reservoir *Reservoir
+	// Growroom is the growroom the device is listed in by the API
+	Growroom string
+	// Location is the time zone of the clock of the device, UTC if nil
+	Location *time.Location
+
+	serial string
+
+	mu    sync.Mutex
+	state datastructs.ReportedIDose
+	// nutrientLeft and phLeft are how long the current doses have left to run, and irrigating
+	// how long each irrigating station has left
+	nutrientLeft time.Duration
+	phLeft       time.Duration
+	irrigating   map[int]time.Duration
+	lastDose     time.Time
+	history      []*datastructs.DoserHistoryPoint
+}
+
+// NewIntelliDose returns a simulated IntelliDose with a full reservoir, dosing two part
+// nutrient to 1.8 mS/cm and pH down to 6, and irrigating two stations every two hours
+func NewIntelliDose(serial string) *IntelliDose {
+	st := func(fn string) datastructs.StatusStatusIDose {
+		return datastructs.StatusStatusIDose{Function: fn, Enabled: true}
+	}
+
+	return &IntelliDose{
+		Reservoir:  NewReservoir(),
+		Location:   time.UTC,
+		serial:     serial,
+		irrigating: map[int]time.Duration{},
+		state: datastructs.ReportedIDose{
+			Device:    serial,
+			Source:    source,
+			Connected: true,
+			Config: datastructs.ConfigIDose{
+				Units: datastructs.UnitsIDose{Temperature: "C", Ec: "EC"},
+				Times: datastructs.TimesIDose{DayStart: 6 * 60, DayEnd: 18 * 60},
+				Functions: datastructs.FunctionsIDose{
+					NutrientsParts:     2,
+					PhDosing:           "down",
+					IrrigationMode:     string(ig.IrrigationEvery),
+					IrrigationStations: 2,
+					IrrigationStation1: "Station 1",
+					IrrigationStation2: "Station 2",
+				},
+				General: datastructs.GeneralIDose{DeviceName: serial},
+			},
+			Status: datastructs.StatusIDose{
+				General: datastructs.GeneralStatusIDose{
+					DoseInterval:        5,
+					NutrientDoseTime:    10,
+					PhDoseTime:          5,
+					IrrigationInterval1: datastructs.IrrigationIntervalIDose{Day: 60, Night: 180, Every: 120},
+					IrrigationInterval2: datastructs.IrrigationIntervalIDose{Day: 60, Night: 180, Every: 120},
+					IrrigationDuration1: 120,
+					IrrigationDuration2: 120,
+				},
+				Nutrient: datastructs.NutrientIDose{
+					Ec:      datastructs.EcIDose{Enabled: true, Min: 1.2, Max: 2.4},
+					Ph:      datastructs.PhIDose{Enabled: true, Min: 5.5, Max: 6.8},
+					NutTemp: datastructs.NutTempIDose{Enabled: true, Min: 16, Max: 26},
+				},
+				SetPoints: datastructs.SetPointsIDose{Nutrient: 1.8, NutrientNight: 1.8, Ph: 6, PhDosing: "down"},
+				Status: []datastructs.StatusStatusIDose{
+					st(ig.NutrientDosingFunction),
+					st(ig.PHDosingFunction),
+					st(ig.IrrigationFunction),
+					st(ig.StationFunction + "1"),
+					st(ig.StationFunction + "2"),
+				},
+			},
+		},
+	}
+}
+
+// Serial returns the serial number of the device
+func (d *IntelliDose) Serial() string {
+	return d.serial
+}
+
+func (d *IntelliDose) deviceType() string {
+	return "idoze"
+}
+
+func (d *IntelliDose) growroom() string {
+	return d.Growroom
+}
+
+func (d *IntelliDose) location() *time.Location {
+	if d.Location == nil {
+		return time.UTC
+	}
+	return d.Location
+}
+
+// Reported returns a copy of the state the device reports
+func (d *IntelliDose) Reported() datastructs.ReportedIDose {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.reported()
+}
+
+func (d *IntelliDose) reported() datastructs.ReportedIDose {
+	r := d.state
+	r.Status.Status = append([]datastructs.StatusStatusIDose{}, r.Status.Status...)
+	return r
+}
+
+// Configure changes the state of the device, such as its setpoints, as if it was changed on the
+// device itself
+func (d *IntelliDose) Configure(update func(r *datastructs.ReportedIDose)) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	update(&d.state)
+}
+
+// units returns the unit system the device is configured with
+func (d *IntelliDose) units() units.System {
+	return d.state.Config.Units.System()
+}
+
+// view returns the state of the device as an ig.IntelliDose to use its irrigation schedule
+func (d *IntelliDose) view(now time.Time) *ig.IntelliDose {
+	_, offset := now.In(d.location()).Zone()
+	id := ig.NewIntelliDose(&ig.Device{ID: d.serial, Type: d.deviceType(), TimeZoneOffset: float64(offset) / 3600})
+	*id.Config = d.state.Config
+	*id.Status = d.reported().Status
+	return id
+}
+
+// function returns the status of the function with the given name
+func (d *IntelliDose) function(name string) *datastructs.StatusStatusIDose {
+	for i := range d.state.Status.Status {
+		if d.state.Status.Status[i].Function == name {
+			return &d.state.Status.Status[i]
+		}
+	}
+	return nil
+}
+
+func (d *IntelliDose) enabled(name string) bool {
+	fn := d.function(name)
+	return fn != nil && fn.Enabled
+}
+
+// forced returns true if the function was forced on, and clears the force
+func (d *IntelliDose) forced(name string) bool {
+	fn := d.function(name)
+	if fn == nil || !fn.ForceOn {
+		return false
+	}
+	fn.ForceOn = false
+	return true
+}
+
+func secondsOr(secs byte, def time.Duration) time.Duration {
+	if secs == 0 {
+		return def
+	}
+	return time.Duration(secs) * time.Second
+}
+
+func (d *IntelliDose) doseNutrient() {
+	dur := secondsOr(d.state.Status.General.NutrientDoseTime, defaultDoseTime)
+	d.Reservoir.DoseNutrient(dur)
+	d.nutrientLeft = dur
+}
+
+func (d *IntelliDose) dosePH() {
+	dur := secondsOr(d.state.Status.General.PhDoseTime, defaultDoseTime)
+	d.Reservoir.DosePH(dur, d.phDown())
+	d.phLeft = dur
+}
+
+func (d *IntelliDose) phDown() bool {
+	return d.state.Status.SetPoints.PhDosing != "up"
+}
+
+func (d *IntelliDose) irrigate(id *ig.IntelliDose, n int) {
+	st, err := id.IrrigationStation(n)
+	if err != nil || st.Duration <= 0 {
+		return
+	}
+	d.irrigating[n] = st.Duration
+}
+
+// step advances the device and its reservoir from the time given by dt
+func (d *IntelliDose) step(from time.Time, dt time.Duration) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	id := d.view(from)
+	for t := from; t.Before(from.Add(dt)); {
+		chunk := resolution
+		if rest := from.Add(dt).Sub(t); rest < chunk {
+			chunk = rest
+		}
+		d.control(id, t, t.Add(chunk))
+		d.run(chunk)
+		t = t.Add(chunk)
+	}
+
+	d.report(from.Add(dt))
+}
+
+// control starts the doses and irrigations due between prev and now
+func (d *IntelliDose) control(id *ig.IntelliDose, prev, now time.Time) {
+	if d.forced(ig.NutrientDosingFunction) {
+		d.doseNutrient()
+	}
+
+	if d.forced(ig.PHDosingFunction) {
+		d.dosePH()
+	}
+
+	forceAll := d.forced(ig.IrrigationFunction)
+	for n := 1; n <= id.IrrigationStationCount(); n++ {
+		name := ig.StationFunction + strconv.Itoa(n)
+		if d.forced(name) || (forceAll && d.enabled(name)) {
+			d.irrigate(id, n)
+			continue
+		}
+
+		if _, running := d.irrigating[n]; running || id.IrrigationMode() == ig.IrrigationOff {
+			continue
+		}
+
+		if next, err := id.NextIrrigation(n, prev); err == nil && !next.IsZero() && !next.After(now) {
+			d.irrigate(id, n)
+		}
+	}
+
+	interval := time.Duration(d.state.Status.General.DoseInterval) * time.Minute
+	if interval <= 0 {
+		interval = defaultDoseInterval
+	}
+
+	if d.nutrientLeft > 0 || d.phLeft > 0 || now.Sub(d.lastDose) < interval {
+		return
+	}
+
+	sys := d.units()
+	sp := d.state.Status.SetPoints
+	target := sp.Nutrient
+	if d.state.Config.Functions.DayNightEc && !id.DaySchedule().IsDay(now) {
+		target = sp.NutrientNight
+	}
+	target = units.ConvertConductivity(target, sys.Conductivity, units.EC)
+
+	if !d.state.Config.Advanced.DisableEc && d.enabled(ig.NutrientDosingFunction) && d.Reservoir.EC < target-ecDeadband {
+		d.doseNutrient()
+		d.lastDose = now
+	}
+
+	if !d.state.Config.Advanced.DisablePh && d.enabled(ig.PHDosingFunction) {
+		if (d.phDown() && d.Reservoir.PH > sp.Ph+phDeadband) || (!d.phDown() && d.Reservoir.PH < sp.Ph-phDeadband) {
+			d.dosePH()
+			d.lastDose = now
+		}
+	}
+}
+
+// run advances the reservoir and the running doses and irrigations by dt
+func (d *IntelliDose) run(dt time.Duration) {
+	d.nutrientLeft = remaining(d.nutrientLeft, dt)
+	d.phLeft = remaining(d.phLeft, dt)
+
+	for n, left := range d.irrigating {
+		if left < dt {
+			d.Reservoir.Irrigate(1, left)
+		} else {
+			d.Reservoir.Irrigate(1, dt)
+		}
+
+		if left = remaining(left, dt); left > 0 {
+			d.irrigating[n] = left
+		} else {
+			delete(d.irrigating, n)
+		}
+	}
+
+	d.Reservoir.Step(dt)
+}
+
+func remaining(left, dt time.Duration) time.Duration {
+	if left <= dt {
+		return 0
+	}
+	return left - dt
+}
+
+// report updates the metrics and status with the state of the reservoir and outputs, and keeps
+// a history point
+func (d *IntelliDose) report(now time.Time) {
+	sys := d.units()
+	d.state.Metrics = datastructs.MetricsIDose{
+		Ec:      units.Metric.ConductivityTo(d.Reservoir.EC, sys),
+		PH:      d.Reservoir.PH,
+		NutTemp: units.Metric.TemperatureTo(d.Reservoir.Temp, sys),
+	}
+	d.state.Timestamp = now.UnixNano() / int64(time.Millisecond)
+
+	for i := range d.state.Status.Status {
+		st := &d.state.Status.Status[i]
+		switch st.Function {
+		case ig.NutrientDosingFunction:
+			st.Active = d.nutrientLeft > 0
+		case ig.PHDosingFunction:
+			st.Active = d.phLeft > 0
+		case ig.IrrigationFunction:
+			st.Active = len(d.irrigating) > 0
+		default:
+			if !strings.HasPrefix(st.Function, ig.StationFunction) {
+				continue
+			}
+			if n, err := strconv.Atoi(strings.TrimPrefix(st.Function, ig.StationFunction)); err == nil {
+				_, st.Active = d.irrigating[n]
+			}
+		}
+	}
+
+	d.history = append(d.history, &datastructs.DoserHistoryPoint{
+		Timestamp: float64(d.state.Timestamp),
+		Status:    datastructs.Status{Status: d.deviceStatus()},
+		Metrics: datastructs.DoseMetricsHistory{
+			EC:   sys.ConductivityToRaw(d.state.Metrics.Ec),
+			PH:   d.state.Metrics.PH,
+			Temp: d.state.Metrics.NutTemp,
+		},
+	})
+
+	if len(d.history) > historySize {
+		d.history = d.history[len(d.history)-historySize:]
+	}
+}
+
+func (d *IntelliDose) deviceStatus() []datastructs.DeviceStatus {
+	status := []datastructs.DeviceStatus{}
+	for _, st := range d.state.Status.Status {
+		status = append(status, datastructs.DeviceStatus{
+			Active:    st.Active,
+			Enabled:   st.Enabled,
+			ForceOn:   st.ForceOn,
+			Function:  st.Function,
+			Installed: true,
+		})
+	}
+	return status
+}
+
+// shadow returns the shadow the device publishes over NATS
+func (d *IntelliDose) shadow() ([]byte, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return json.Marshal(shadowOf(d.reported()))
+}
+
+// command applies the state and config sent to the device
+func (d *IntelliDose) command(data []byte) error {
+	cmd, err := parseCommand(data, d.serial)
+	if err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	r := d.reported()
+	if err := cmd.apply(&r.Status, &r.Config); err != nil {
+		return err
+	}
+
+	switch sp := r.Status.SetPoints; {
+	case sp.Ph < 0 || sp.Ph > 14:
+		return fmt.Errorf("pH setpoint %g is out of range", sp.Ph)
+	case sp.Nutrient < 0 || sp.NutrientNight < 0:
+		return fmt.Errorf("nutrient setpoint can't be negative")
+	}
+
+	d.state.Status, d.state.Config = r.Status, r.Config
+	return nil
+}
+
+// apiMetrics returns the metrics as the API gives them, with the EC raw
+func (d *IntelliDose) apiMetrics() interface{} {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	m := d.state.Metrics
+	m.Ec = d.units().ConductivityToRaw(m.Ec)
+	return m
+}
+
+func (d *IntelliDose) apiConfig() interface{} {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.state.Config
+}
+
+func (d *IntelliDose) apiState() interface{} {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.reported().Status
+}
+
+func (d *IntelliDose) apiHistory(from, to time.Time, points int) interface{} {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	h := datastructs.DoserHistory{Points: []*datastructs.DoserHistoryPoint{}}
+	for _, i := range historyBetween(len(d.history), func(i int) float64 { return d.history[i].Timestamp }, from, to, points) {
+		h.Points = append(h.Points, d.history[i])
+	}
+	return h
+}
+
+func (d *IntelliDose) name() string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.state.Config.General.DeviceName
+}
+
+func (d *IntelliDose) lastUpdated() int64 {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.state.Timestamp
+}
@@ -0,0 +1,112 @@
+package sim
+
+import (
+	"math"
+	"time"
+)
+
+// Reservoir models the nutrient solution that an IntelliDose doses and irrigates from.  EC is in
+// mS/cm and temperatures in °C.
+type Reservoir struct {
+	// Volume and Capacity are in litres
+	Volume   float64 `json:"volume"`
+	Capacity float64 `json:"capacity"`
+	EC       float64 `json:"ec"`
+	PH       float64 `json:"ph"`
+	Temp     float64 `json:"temp"`
+
+	// ECDrift and PHDrift are how much the EC and pH rise each hour as the plants feed
+	ECDrift float64 `json:"ec_drift"`
+	PHDrift float64 `json:"ph_drift"`
+	// AmbientTemp is the temperature the solution settles towards, closing TempRate of the gap
+	// each hour
+	AmbientTemp float64 `json:"ambient_temp"`
+	TempRate    float64 `json:"temp_rate"`
+
+	// NutrientStrength is how much a second of nutrient dosing raises the EC of a litre of
+	// solution, so a dose raises the EC less the fuller the reservoir is
+	NutrientStrength float64 `json:"nutrient_strength"`
+	// PHStrength is how much a second of pH dosing moves the pH of a litre of solution
+	PHStrength float64 `json:"ph_strength"`
+
+	// IrrigationFlow is how many litres a minute each irrigating station drains
+	IrrigationFlow float64 `json:"irrigation_flow"`
+	// RefillLevel is the fraction of the capacity below which the reservoir is topped up with
+	// water of WaterEC and WaterPH, as a float valve would
+	RefillLevel float64 `json:"refill_level"`
+	WaterEC     float64 `json:"water_ec"`
+	WaterPH     float64 `json:"water_ph"`
+}
+
+// NewReservoir returns a full 500 litre reservoir at 1.8 mS/cm and pH 6
+func NewReservoir() *Reservoir {
+	return &Reservoir{
+		Volume:           500,
+		Capacity:         500,
+		EC:               1.8,
+		PH:               6,
+		Temp:             20,
+		ECDrift:          0.02,
+		PHDrift:          0.05,
+		AmbientTemp:      20,
+		TempRate:         0.1,
+		NutrientStrength: 2,
+		PHStrength:       5,
+		IrrigationFlow:   5,
+		RefillLevel:      0.5,
+		WaterEC:          0.1,
+		WaterPH:          7,
+	}
+}
+
+// Step advances the reservoir by the time given
+func (r *Reservoir) Step(dt time.Duration) {
+	h := dt.Hours()
+	r.EC += r.ECDrift * h
+	r.PH += r.PHDrift * h
+	r.Temp += (r.AmbientTemp - r.Temp) * (1 - math.Exp(-r.TempRate*h))
+}
+
+// DoseNutrient runs the nutrient pumps for the time given
+func (r *Reservoir) DoseNutrient(d time.Duration) {
+	if r.Volume <= 0 {
+		return
+	}
+	r.EC += r.NutrientStrength * d.Seconds() / r.Volume
+}
+
+// DosePH runs the pH pump for the time given, lowering the pH if down is true and raising it
+// otherwise
+func (r *Reservoir) DosePH(d time.Duration, down bool) {
+	if r.Volume <= 0 {
+		return
+	}
+
+	change := r.PHStrength * d.Seconds() / r.Volume
+	if down {
+		change = -change
+	}
+	r.PH = math.Max(0, math.Min(14, r.PH+change))
+}
+
+// Irrigate drains the reservoir for the given number of stations irrigating for the time given,
+// topping it up if it falls below the refill level
+func (r *Reservoir) Irrigate(stations int, d time.Duration) {
+	r.Volume = math.Max(0, r.Volume-r.IrrigationFlow*float64(stations)*d.Minutes())
+
+	if r.Volume < r.Capacity*r.RefillLevel {
+		r.Refill()
+	}
+}
+
+// Refill tops up the reservoir with water, which dilutes the solution
+func (r *Reservoir) Refill() {
+	added := r.Capacity - r.Volume
+	if added <= 0 {
+		return
+	}
+
+	r.EC = (r.EC*r.Volume + r.WaterEC*added) / r.Capacity
+	r.PH = (r.PH*r.Volume + r.WaterPH*added) / r.Capacity
+	r.Volume = r.Capacity
+}
@@ -0,0 +1,128 @@
+package sim
+
+import (
+	"math"
+	"time"
+)
+
+// Outputs are the equipment an IntelliClimate switches, which the room responds to
+type Outputs struct {
+	Fans         bool `json:"fans"`
+	Heater       bool `json:"heater"`
+	AirCon       bool `json:"air_con"`
+	CO2          bool `json:"co2"`
+	Humidifier   bool `json:"humidifier"`
+	Dehumidifier bool `json:"dehumidifier"`
+	Lights       bool `json:"lights"`
+}
+
+// Room models the air of a climate room.  Temperatures are in °C, RH in % and CO2 in ppm.
+type Room struct {
+	AirTemp float64 `json:"air_temp"`
+	RH      float64 `json:"rh"`
+	CO2     float64 `json:"co2"`
+
+	// OutsideTemp, OutsideRH and OutsideCO2 are the air that leaks in and that the fans draw in
+	OutsideTemp float64 `json:"outside_temp"`
+	OutsideRH   float64 `json:"outside_rh"`
+	OutsideCO2  float64 `json:"outside_co2"`
+
+	// Leakage and FanExchange are the fraction of the air exchanged with outside each hour, by
+	// leaks and with the fans on
+	Leakage     float64 `json:"leakage"`
+	FanExchange float64 `json:"fan_exchange"`
+
+	// LightHeat, HeaterHeat and AirConCooling are the change in °C an hour from each
+	LightHeat     float64 `json:"light_heat"`
+	HeaterHeat    float64 `json:"heater_heat"`
+	AirConCooling float64 `json:"air_con_cooling"`
+
+	// Transpiration is the rise in RH an hour from the plants while the lights are on, and
+	// Humidification and Dehumidification the change from the equipment
+	Transpiration    float64 `json:"transpiration"`
+	Humidification   float64 `json:"humidification"`
+	Dehumidification float64 `json:"dehumidification"`
+
+	// Uptake is the CO2 taken up by the plants an hour while the lights are on, and Injection
+	// the CO2 added an hour while injecting
+	Uptake    float64 `json:"uptake"`
+	Injection float64 `json:"injection"`
+
+	// LightLevel is the light reading while the lights are on
+	LightLevel float64 `json:"light_level"`
+}
+
+// NewRoom returns a room at 22°C and 60% RH with ambient CO2, and 15°C air outside
+func NewRoom() *Room {
+	return &Room{
+		AirTemp:          22,
+		RH:               60,
+		CO2:              400,
+		OutsideTemp:      15,
+		OutsideRH:        70,
+		OutsideCO2:       400,
+		Leakage:          0.5,
+		FanExchange:      10,
+		LightHeat:        6,
+		HeaterHeat:       8,
+		AirConCooling:    10,
+		Transpiration:    5,
+		Humidification:   20,
+		Dehumidification: 20,
+		Uptake:           300,
+		Injection:        1500,
+		LightLevel:       800,
+	}
+}
+
+// Step advances the room by the time given with the outputs switched as given
+func (r *Room) Step(dt time.Duration, out Outputs) {
+	h := dt.Hours()
+
+	exchange := r.Leakage
+	if out.Fans {
+		exchange += r.FanExchange
+	}
+	mix := 1 - math.Exp(-exchange*h)
+
+	r.AirTemp += (r.OutsideTemp - r.AirTemp) * mix
+	r.RH += (r.OutsideRH - r.RH) * mix
+	r.CO2 += (r.OutsideCO2 - r.CO2) * mix
+
+	if out.Lights {
+		r.AirTemp += r.LightHeat * h
+		r.RH += r.Transpiration * h
+		r.CO2 -= r.Uptake * h
+	}
+
+	if out.Heater {
+		r.AirTemp += r.HeaterHeat * h
+	}
+
+	if out.AirCon {
+		r.AirTemp -= r.AirConCooling * h
+	}
+
+	if out.Humidifier {
+		r.RH += r.Humidification * h
+	}
+
+	if out.Dehumidifier {
+		r.RH -= r.Dehumidification * h
+	}
+
+	if out.CO2 {
+		r.CO2 += r.Injection * h
+	}
+
+	r.RH = math.Max(0, math.Min(100, r.RH))
+	r.CO2 = math.Max(0, r.CO2)
+}
+
+// Light returns the light reading with the lights as given
+func (r *Room) Light(on bool) float64 {
+	if on {
+		return r.LightLevel
+	}
+	return 0
+}
@@ -0,0 +1,188 @@
+package sim
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/autogrow/go-jelly/ig"
+	"github.com/autogrow/go-jelly/ig/datastructs"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestIntelliDose(t *testing.T) {
+	start := time.Date(2018, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	Convey("given a simulated IntelliDose with a weak solution", t, func() {
+		s := New(start)
+		id := NewIntelliDose("ASLID17081149")
+		id.Reservoir.EC = 1.2
+		id.Reservoir.PH = 6.5
+		s.AddIntelliDose(id)
+
+		Convey("it should dose the solution up to the setpoints", func() {
+			s.Step(3 * time.Hour)
+			So(s.Now(), ShouldResemble, start.Add(3*time.Hour))
+			So(id.Reservoir.EC, ShouldBeBetween, 1.7, 1.9)
+			So(id.Reservoir.PH, ShouldBeBetween, 5.9, 6.1)
+
+			r := id.Reported()
+			So(r.Metrics.Ec, ShouldAlmostEqual, id.Reservoir.EC)
+			So(r.Timestamp, ShouldEqual, start.Add(3*time.Hour).UnixNano()/int64(time.Millisecond))
+		})
+
+		Convey("it should report the EC in the unit it is configured with", func() {
+			id.Configure(func(r *datastructs.ReportedIDose) {
+				r.Config.Units.Ec = "CF"
+				r.Status.SetPoints.Nutrient = 18
+			})
+
+			s.Step(3 * time.Hour)
+			So(id.Reservoir.EC, ShouldBeBetween, 1.7, 1.9)
+			So(id.Reported().Metrics.Ec, ShouldAlmostEqual, id.Reservoir.EC*10)
+		})
+
+		Convey("it should irrigate on the schedule of the stations", func() {
+			s.Step(2*time.Hour + 5*time.Minute)
+			So(id.Reservoir.Volume, ShouldAlmostEqual, 480)
+		})
+
+		Convey("a forced irrigation should start straight away and clear the force", func() {
+			state := id.Reported().Status
+			So(state.Status[2].Function, ShouldEqual, ig.IrrigationFunction)
+			state.Status[2].ForceOn = true
+
+			data, _ := json.Marshal(map[string]interface{}{"device": "ASLID17081149", "state": state})
+			So(id.command(data), ShouldBeNil)
+
+			s.Step(time.Minute)
+			So(id.Reservoir.Volume, ShouldAlmostEqual, 490)
+			So(id.Reported().Status.Status[2].ForceOn, ShouldBeFalse)
+			So(id.Reported().Status.Status[2].Active, ShouldBeTrue)
+		})
+
+		Convey("a command for another device or out of range should be refused", func() {
+			So(id.command([]byte(`{"device": "ASLID00000000"}`)), ShouldNotBeNil)
+			So(id.command([]byte(`{"device": "ASLID17081149", "state": {"set_points": {"ph": 15}}}`)), ShouldNotBeNil)
+			So(id.Reported().Status.SetPoints.Ph, ShouldEqual, 6)
+		})
+	})
+
+	Convey("given a reservoir running low", t, func() {
+		r := NewReservoir()
+		r.Irrigate(1, 49*time.Minute)
+		So(r.Volume, ShouldAlmostEqual, 255)
+
+		Convey("it should be topped up with water when it falls below the refill level", func() {
+			r.Irrigate(1, 2*time.Minute)
+			So(r.Volume, ShouldEqual, r.Capacity)
+			So(r.EC, ShouldBeLessThan, 1.8)
+			So(r.PH, ShouldBeGreaterThan, 6)
+		})
+	})
+}
+
+func TestIntelliClimate(t *testing.T) {
+	Convey("given a simulated IntelliClimate in a cold room at night", t, func() {
+		s := New(time.Date(2018, 6, 1, 2, 0, 0, 0, time.UTC))
+		ic := NewIntelliClimate("ASLIC17081150")
+		ic.Room.AirTemp = 15
+		s.AddIntelliClimate(ic)
+
+		Convey("it should heat the room to the night temperature with the lights off", func() {
+			s.Step(time.Minute)
+			So(ic.Outputs().Heater, ShouldBeTrue)
+			So(ic.Outputs().Lights, ShouldBeFalse)
+			So(ic.Reported().Metrics.DayNight, ShouldEqual, "night")
+
+			s.Step(2 * time.Hour)
+			So(ic.Room.AirTemp, ShouldBeBetween, 18.5, 21)
+		})
+
+		Convey("it should turn the lights on and inject CO2 during the day", func() {
+			s.Step(5 * time.Hour)
+			So(ic.Outputs().Lights, ShouldBeTrue)
+			So(ic.Reported().Metrics.DayNight, ShouldEqual, "day")
+			So(ic.Room.CO2, ShouldBeGreaterThan, 850)
+		})
+
+		Convey("a forced function should stay on until the force is cleared", func() {
+			ic.Configure(func(r *datastructs.ReportedIClimate) {
+				r.Status.Status[0].ForceOn = true
+			})
+			s.Step(time.Hour)
+			So(ic.Outputs().Fans, ShouldBeTrue)
+		})
+	})
+
+	Convey("given a simulated IntelliClimate in a hot room", t, func() {
+		s := New(time.Date(2018, 6, 1, 12, 0, 0, 0, time.UTC))
+		ic := NewIntelliClimate("ASLIC17081150")
+		ic.Room.AirTemp = 32
+		s.AddIntelliClimate(ic)
+
+		Convey("it should vent the room with the fans", func() {
+			s.Step(time.Minute)
+			So(ic.Outputs().Fans, ShouldBeTrue)
+			So(ic.Outputs().CO2, ShouldBeFalse)
+
+			s.Step(time.Hour)
+			So(ic.Room.AirTemp, ShouldBeLessThan, 27)
+		})
+	})
+}
+
+func TestAPI(t *testing.T) {
+	Convey("given a simulator serving the IntelliGrow API", t, func() {
+		s := New(time.Now().Add(-time.Hour))
+		id := NewIntelliDose("ASLID17081149")
+		id.Growroom = "Veg"
+		s.AddIntelliDose(id)
+		ic := NewIntelliClimate("ASLIC17081150")
+		ic.Growroom = "Veg"
+		s.AddIntelliClimate(ic)
+		s.Step(time.Hour)
+
+		client, err := ig.NewClientWithHTTPClient("grower", "secret", s.HTTPClient())
+		So(err, ShouldBeNil)
+		defer client.Close()
+
+		Convey("the devices should be listed in their growroom", func() {
+			So(client.RefreshDevices(), ShouldBeNil)
+			So(client.ListGrowrooms(), ShouldResemble, []string{"Veg"})
+			So(client.ListDevicesBySerial(), ShouldHaveLength, 2)
+		})
+
+		Convey("the readings should be converted from raw as from the API", func() {
+			doser, err := client.IntelliDose("ASLID17081149")
+			So(err, ShouldBeNil)
+			So(doser.GetAll(), ShouldBeNil)
+			So(doser.Metrics.Ec, ShouldAlmostEqual, id.Reservoir.EC, 0.001)
+			So(doser.Status.SetPoints.Nutrient, ShouldEqual, 1.8)
+
+			climate, err := client.IntelliClimate("ASLIC17081150")
+			So(err, ShouldBeNil)
+			So(climate.GetMetrics(), ShouldBeNil)
+			So(climate.Metrics.AirTemp, ShouldAlmostEqual, ic.Room.AirTemp, 0.001)
+		})
+
+		Convey("the history should be given between the times asked for", func() {
+			s.Step(time.Hour)
+			doser, err := client.IntelliDose("ASLID17081149")
+			So(err, ShouldBeNil)
+			So(doser.GetHistory(s.Now().Add(-90*time.Minute), s.Now(), 10), ShouldBeNil)
+			So(doser.History.Points, ShouldHaveLength, 1)
+		})
+
+		Convey("saving a device should apply its state", func() {
+			doser, err := client.IntelliDose("ASLID17081149")
+			So(err, ShouldBeNil)
+			So(doser.SetPHTarget(5.8), ShouldBeNil)
+			So(id.Reported().Status.SetPoints.Ph, ShouldEqual, 5.8)
+
+			So(doser.ForceIrrigation(), ShouldBeNil)
+			s.Step(time.Minute)
+			So(id.Reservoir.Volume, ShouldBeLessThan, 500)
+		})
+	})
+}
@@ -0,0 +1,312 @@
+package sim
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/autogrow/go-jelly/sfc"
+	"github.com/nats-io/go-nats"
+)
+
+const (
+	// source is reported by the simulated devices as where their state came from
+	source = "simulator"
+	// resolution is the longest the models are advanced by at once, so the controls react to the
+	// readings within a step however long it is
+	resolution = 10 * time.Second
+	// historySize is the number of history points kept for each device
+	historySize = 1440
+	// topicPrefix is the start of the subject that each device publishes its shadow to
+	topicPrefix = "intelli/"
+	// commandSuffix ends the subject that a device listens for commands on
+	commandSuffix = "/set"
+)
+
+// device is a simulated device
+type device interface {
+	Serial() string
+	deviceType() string
+	growroom() string
+	location() *time.Location
+	step(from time.Time, dt time.Duration)
+	shadow() ([]byte, error)
+	command(data []byte) error
+	apiMetrics() interface{}
+	apiConfig() interface{}
+	apiState() interface{}
+	apiHistory(from, to time.Time, points int) interface{}
+	name() string
+	lastUpdated() int64
+}
+
+// Simulator runs simulated devices on a clock of its own, which is stepped forward by Step or
+// by Run.  Devices publish their shadows to the NATS connections the simulator is attached to
+// and take commands sent to them over NATS, the same as they would through an IntelliLink, and
+// the simulator can also serve the IntelliGrow API for the devices.
+type Simulator struct {
+	stepping sync.Mutex
+
+	mu      sync.Mutex
+	now     time.Time
+	devices []device
+	conns   []*nats.Conn
+	subs    []*nats.Subscription
+}
+
+// New returns a simulator with its clock at the time given
+func New(start time.Time) *Simulator {
+	return &Simulator{now: start}
+}
+
+// AddIntelliDose adds a simulated IntelliDose to the simulator
+func (s *Simulator) AddIntelliDose(d *IntelliDose) {
+	s.add(d)
+}
+
+// AddIntelliClimate adds a simulated IntelliClimate to the simulator
+func (s *Simulator) AddIntelliClimate(c *IntelliClimate) {
+	s.add(c)
+}
+
+func (s *Simulator) add(d device) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.devices = append(s.devices, d)
+}
+
+// device returns the device with the given serial
+func (s *Simulator) device(serial string) (device, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, d := range s.devices {
+		if d.Serial() == serial {
+			return d, true
+		}
+	}
+	return nil, false
+}
+
+func (s *Simulator) snapshot() (time.Time, []device) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.now, append([]device{}, s.devices...)
+}
+
+// Now returns the time on the clock of the simulator
+func (s *Simulator) Now() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.now
+}
+
+// Step advances the clock and all the devices by the time given
+func (s *Simulator) Step(dt time.Duration) {
+	s.stepping.Lock()
+	defer s.stepping.Unlock()
+
+	now, devices := s.snapshot()
+	for _, d := range devices {
+		d.step(now, dt)
+	}
+
+	s.mu.Lock()
+	s.now = now.Add(dt)
+	s.mu.Unlock()
+}
+
+// Attach publishes the shadows of the devices to the NATS connection on Publish, and takes the
+// commands sent to them on it, replying with an sfc.Ack.  Commands to serials the simulator
+// doesn't have are left for other devices on the bus to answer.
+func (s *Simulator) Attach(nc *nats.Conn) error {
+	sub, err := nc.Subscribe(">", func(msg *nats.Msg) {
+		if !strings.HasPrefix(msg.Subject, topicPrefix) || !strings.HasSuffix(msg.Subject, commandSuffix) {
+			return
+		}
+
+		serial := strings.TrimSuffix(strings.TrimPrefix(msg.Subject, topicPrefix), commandSuffix)
+		d, ok := s.device(serial)
+		if !ok {
+			return
+		}
+
+		ack := sfc.Ack{OK: true}
+		if err := d.command(msg.Data); err != nil {
+			ack = sfc.Ack{Error: err.Error()}
+		}
+
+		if msg.Reply != "" {
+			data, _ := json.Marshal(ack)
+			nc.Publish(msg.Reply, data)
+		}
+
+		// publish the new state straight away, as the device would
+		if ack.OK {
+			if data, err := d.shadow(); err == nil {
+				nc.Publish(topicPrefix+serial, data)
+			}
+		}
+	})
+
+	if err != nil {
+		return fmt.Errorf("couldn't subscribe to commands: %s", err)
+	}
+
+	if err := nc.Flush(); err != nil {
+		sub.Unsubscribe()
+		return fmt.Errorf("couldn't subscribe to commands: %s", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.conns = append(s.conns, nc)
+	s.subs = append(s.subs, sub)
+	return nil
+}
+
+// Detach stops taking commands from the attached NATS connections and forgets them.  The
+// connections are left open.
+func (s *Simulator) Detach() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var err error
+	for _, sub := range s.subs {
+		if uerr := sub.Unsubscribe(); uerr != nil && err == nil {
+			err = uerr
+		}
+	}
+
+	s.conns, s.subs = nil, nil
+	return err
+}
+
+// Publish sends the shadow of each device to the attached NATS connections
+func (s *Simulator) Publish() error {
+	s.mu.Lock()
+	conns := append([]*nats.Conn{}, s.conns...)
+	s.mu.Unlock()
+
+	_, devices := s.snapshot()
+	for _, d := range devices {
+		data, err := d.shadow()
+		if err != nil {
+			return fmt.Errorf("couldn't encode the shadow of %s: %s", d.Serial(), err)
+		}
+
+		for _, nc := range conns {
+			if err := nc.Publish(topicPrefix+d.Serial(), data); err != nil {
+				return fmt.Errorf("couldn't publish the shadow of %s: %s", d.Serial(), err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Run steps the simulator every interval and publishes the shadows of the devices, until the
+// context is done.  Each step advances the clock of the simulator by the interval times the
+// speed, so at a speed of 60 an hour passes in a minute.
+func (s *Simulator) Run(ctx context.Context, interval time.Duration, speed float64) error {
+	if interval <= 0 {
+		return fmt.Errorf("interval must be positive")
+	}
+
+	if speed <= 0 {
+		speed = 1
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			s.Step(time.Duration(float64(interval) * speed))
+			if err := s.Publish(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// shadowOf wraps the reported state of a device the way it is published
+func shadowOf(reported interface{}) interface{} {
+	return map[string]interface{}{
+		"state": map[string]interface{}{"reported": reported},
+	}
+}
+
+// command is the payload of a command sent over NATS, or of a save through the API
+type command struct {
+	Device string          `json:"device"`
+	State  json.RawMessage `json:"state"`
+	Config json.RawMessage `json:"config"`
+}
+
+func parseCommand(data []byte, serial string) (command, error) {
+	cmd := command{}
+	if err := json.Unmarshal(data, &cmd); err != nil {
+		return cmd, fmt.Errorf("invalid command: %s", err)
+	}
+
+	if cmd.Device != "" && cmd.Device != serial {
+		return cmd, fmt.Errorf("command is for %s not %s", cmd.Device, serial)
+	}
+
+	return cmd, nil
+}
+
+// apply the state and config of the command over the ones given, leaving out what the command
+// doesn't have
+func (cmd command) apply(state, config interface{}) error {
+	if len(cmd.State) > 0 {
+		if err := json.Unmarshal(cmd.State, state); err != nil {
+			return fmt.Errorf("invalid state: %s", err)
+		}
+	}
+
+	if len(cmd.Config) > 0 {
+		if err := json.Unmarshal(cmd.Config, config); err != nil {
+			return fmt.Errorf("invalid config: %s", err)
+		}
+	}
+
+	return nil
+}
+
+// historyBetween returns the indexes of the n history points, with timestamps in milliseconds
+// given by ts, that are between from and to.  A zero time leaves that end open.  If there are
+// more than points of them they are thinned out evenly.
+func historyBetween(n int, ts func(i int) float64, from, to time.Time, points int) []int {
+	ms := func(t time.Time) float64 {
+		return float64(t.UnixNano() / int64(time.Millisecond))
+	}
+
+	idx := []int{}
+	for i := 0; i < n; i++ {
+		if !from.IsZero() && ts(i) < ms(from) {
+			continue
+		}
+		if !to.IsZero() && ts(i) > ms(to) {
+			continue
+		}
+		idx = append(idx, i)
+	}
+
+	if points <= 0 || len(idx) <= points {
+		return idx
+	}
+
+	thinned := make([]int, points)
+	for i := range thinned {
+		thinned[i] = idx[i*len(idx)/points]
+	}
+	return thinned
+}
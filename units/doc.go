@@ -0,0 +1,14 @@
+// Package units provides the units of measurement used by Autogrow devices and conversions
+// between them.
+//
+// Each device reports the units it has been configured to display, which may not be the units
+// a user wishes to see.  The types in this package allow readings and setpoints to be converted
+// between Celsius and Fahrenheit, and between EC, CF and the 500, 640 and 700 ppm TDS scales:
+//
+//     sys, err := units.Parse("fahrenheit", "ppm", 700)
+//     if err != nil {
+//       panic(err)
+//     }
+//
+//     ec := units.ConvertConductivity(1400, sys.Conductivity, units.EC) // 2.0 mS/cm
+package units
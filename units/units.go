@@ -0,0 +1,219 @@
+package units
+
+import (
+	"fmt"
+	"strings"
+)
+
+// TemperatureUnit is a unit that a temperature can be expressed in
+type TemperatureUnit string
+
+const (
+	// Celsius - degrees Celsius
+	Celsius TemperatureUnit = "C"
+	// Fahrenheit - degrees Fahrenheit
+	Fahrenheit TemperatureUnit = "F"
+)
+
+// ConductivityUnit is a unit that the conductivity of a nutrient solution can be expressed in
+type ConductivityUnit string
+
+const (
+	// EC - electrical conductivity in mS/cm
+	EC ConductivityUnit = "EC"
+	// CF - conductivity factor, 10 times the EC
+	CF ConductivityUnit = "CF"
+	// PPM500 - total dissolved solids in ppm using the 500 (NaCl) conversion standard
+	PPM500 ConductivityUnit = "ppm500"
+	// PPM640 - total dissolved solids in ppm using the 640 conversion standard
+	PPM640 ConductivityUnit = "ppm640"
+	// PPM700 - total dissolved solids in ppm using the 700 (442) conversion standard
+	PPM700 ConductivityUnit = "ppm700"
+)
+
+// System is a set of units used to express readings and setpoints
+type System struct {
+	Temperature  TemperatureUnit  `json:"temperature"`
+	Conductivity ConductivityUnit `json:"conductivity"`
+}
+
+// Metric is the unit system used internally by the SDK, and the default for devices that
+// don't report their units
+var Metric = System{Celsius, EC}
+
+// Parse returns the unit system for the given temperature and EC unit strings as reported by
+// a device, along with the TDS conversion standard used if the EC is reported in ppm
+func Parse(temp, ec string, tdsStandard int) (System, error) {
+	t, err := ParseTemperatureUnit(temp)
+	if err != nil {
+		return Metric, err
+	}
+
+	c, err := ParseConductivityUnit(ec, tdsStandard)
+	if err != nil {
+		return Metric, err
+	}
+
+	return System{t, c}, nil
+}
+
+// ParseTemperatureUnit returns the temperature unit for the given string, an empty string is
+// treated as Celsius
+func ParseTemperatureUnit(s string) (TemperatureUnit, error) {
+	switch strings.ToLower(strings.TrimPrefix(strings.TrimSpace(s), "°")) {
+	case "", "c", "celsius", "celcius":
+		return Celsius, nil
+	case "f", "fahrenheit", "farenheit":
+		return Fahrenheit, nil
+	default:
+		return Celsius, fmt.Errorf("unknown temperature unit %q", s)
+	}
+}
+
+// ParseConductivityUnit returns the conductivity unit for the given string.  If the string refers
+// to ppm/TDS then the tdsStandard (500, 640 or 700) is used to pick the scale.  An empty string is
+// treated as EC.
+func ParseConductivityUnit(s string, tdsStandard int) (ConductivityUnit, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "ec", "ms/cm", "ms/cm²", "ms/cm2":
+		return EC, nil
+	case "cf":
+		return CF, nil
+	case "ppm", "tds":
+		return tdsUnit(tdsStandard)
+	case "ppm500", "ppm 500", "tds500":
+		return PPM500, nil
+	case "ppm640", "ppm 640", "tds640":
+		return PPM640, nil
+	case "ppm700", "ppm 700", "tds700":
+		return PPM700, nil
+	default:
+		return EC, fmt.Errorf("unknown EC unit %q", s)
+	}
+}
+
+func tdsUnit(standard int) (ConductivityUnit, error) {
+	switch standard {
+	case 500:
+		return PPM500, nil
+	case 640:
+		return PPM640, nil
+	case 700:
+		return PPM700, nil
+	default:
+		return EC, fmt.Errorf("unknown TDS conversion standard %d", standard)
+	}
+}
+
+// Symbol returns the symbol used to display the temperature unit
+func (u TemperatureUnit) Symbol() string {
+	switch u {
+	case Fahrenheit:
+		return "°F"
+	default:
+		return "°C"
+	}
+}
+
+// Symbol returns the symbol used to display the conductivity unit
+func (u ConductivityUnit) Symbol() string {
+	switch u {
+	case CF:
+		return "CF"
+	case PPM500, PPM640, PPM700:
+		return "ppm"
+	default:
+		return "mS/cm"
+	}
+}
+
+// IsPPM returns true if the unit is one of the TDS ppm scales
+func (u ConductivityUnit) IsPPM() bool {
+	return u == PPM500 || u == PPM640 || u == PPM700
+}
+
+// factor returns the number of this unit in 1 mS/cm
+func (u ConductivityUnit) factor() float64 {
+	switch u {
+	case CF:
+		return 10
+	case PPM500:
+		return 500
+	case PPM640:
+		return 640
+	case PPM700:
+		return 700
+	default:
+		return 1
+	}
+}
+
+// celsius returns true for Celsius, or no unit at all which is taken as Celsius the same as
+// ParseTemperatureUnit does
+func (u TemperatureUnit) celsius() bool {
+	return u == Celsius || u == ""
+}
+
+// ConvertTemperature converts the given temperature from one unit to another.  A unit that
+// isn't Celsius, Fahrenheit or empty leaves the temperature as it is.
+func ConvertTemperature(v float64, from, to TemperatureUnit) float64 {
+	switch {
+	case from.celsius() && to == Fahrenheit:
+		return v*9/5 + 32
+	case from == Fahrenheit && to.celsius():
+		return (v - 32) * 5 / 9
+	default:
+		return v
+	}
+}
+
+// ConvertTemperatureDifference converts a difference between two temperatures from one unit to
+// another.  A unit that isn't Celsius, Fahrenheit or empty leaves the difference as it is.
+func ConvertTemperatureDifference(v float64, from, to TemperatureUnit) float64 {
+	switch {
+	case from.celsius() && to == Fahrenheit:
+		return v * 9 / 5
+	case from == Fahrenheit && to.celsius():
+		return v * 5 / 9
+	default:
+		return v
+	}
+}
+
+// ConvertConductivity converts the given conductivity from one unit to another
+func ConvertConductivity(v float64, from, to ConductivityUnit) float64 {
+	if from == to {
+		return v
+	}
+
+	return v / from.factor() * to.factor()
+}
+
+// TemperatureTo converts a temperature expressed in this unit system into the other
+func (s System) TemperatureTo(v float64, to System) float64 {
+	return ConvertTemperature(v, s.Temperature, to.Temperature)
+}
+
+// ConductivityTo converts a conductivity expressed in this unit system into the other
+func (s System) ConductivityTo(v float64, to System) float64 {
+	return ConvertConductivity(v, s.Conductivity, to.Conductivity)
+}
+
+// ConductivityFromRaw converts a raw conductivity reading from the IntelliGrow API into this
+// unit system.  EC and CF readings are sent in hundredths while ppm readings are sent as is.
+// Readings reported by the device over the local network are already in this unit system.
+func (s System) ConductivityFromRaw(raw float64) float64 {
+	if s.Conductivity.IsPPM() {
+		return raw
+	}
+	return raw / 100.0
+}
+
+// ConductivityToRaw converts a conductivity in this unit system into the raw reading the
+// IntelliGrow API sends, the reverse of ConductivityFromRaw
+func (s System) ConductivityToRaw(v float64) float64 {
+	if s.Conductivity.IsPPM() {
+		return v
+	}
+	return v * 100.0
+}
@@ -0,0 +1,75 @@
+package units
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestParse(t *testing.T) {
+	Convey("given the units reported by a device", t, func() {
+		Convey("it should parse temperature units", func() {
+			u, err := ParseTemperatureUnit("fahrenheit")
+			So(err, ShouldBeNil)
+			So(u, ShouldEqual, Fahrenheit)
+
+			u, err = ParseTemperatureUnit("°C")
+			So(err, ShouldBeNil)
+			So(u, ShouldEqual, Celsius)
+
+			_, err = ParseTemperatureUnit("kelvin")
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("it should use the TDS standard for ppm", func() {
+			u, err := ParseConductivityUnit("ppm", 700)
+			So(err, ShouldBeNil)
+			So(u, ShouldEqual, PPM700)
+
+			_, err = ParseConductivityUnit("ppm", 600)
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("it should default to metric when nothing is reported", func() {
+			sys, err := Parse("", "", 0)
+			So(err, ShouldBeNil)
+			So(sys, ShouldResemble, Metric)
+		})
+	})
+}
+
+func TestConvert(t *testing.T) {
+	Convey("given a temperature", t, func() {
+		So(ConvertTemperature(25, Celsius, Fahrenheit), ShouldAlmostEqual, 77)
+		So(ConvertTemperature(212, Fahrenheit, Celsius), ShouldAlmostEqual, 100)
+		So(ConvertTemperature(20, Celsius, Celsius), ShouldEqual, 20)
+		So(ConvertTemperatureDifference(5, Celsius, Fahrenheit), ShouldAlmostEqual, 9)
+	})
+
+	Convey("no temperature unit should be taken as Celsius", t, func() {
+		So(ConvertTemperature(20, Celsius, ""), ShouldEqual, 20)
+		So(ConvertTemperature(20, "", Celsius), ShouldEqual, 20)
+		So(ConvertTemperature(68, Fahrenheit, ""), ShouldAlmostEqual, 20)
+		So(ConvertTemperatureDifference(5, "", Celsius), ShouldEqual, 5)
+		So(Metric.TemperatureTo(20, System{}), ShouldEqual, 20)
+	})
+
+	Convey("an unknown temperature unit should leave the temperature as it is", t, func() {
+		So(ConvertTemperature(20, Celsius, "K"), ShouldEqual, 20)
+		So(ConvertTemperatureDifference(5, "K", Fahrenheit), ShouldEqual, 5)
+	})
+
+	Convey("given a conductivity", t, func() {
+		So(ConvertConductivity(2, EC, CF), ShouldAlmostEqual, 20)
+		So(ConvertConductivity(2, EC, PPM500), ShouldAlmostEqual, 1000)
+		So(ConvertConductivity(1280, PPM640, EC), ShouldAlmostEqual, 2)
+		So(ConvertConductivity(1400, PPM700, PPM500), ShouldAlmostEqual, 1000)
+	})
+
+	Convey("given a raw reading from the API", t, func() {
+		So(Metric.ConductivityFromRaw(180), ShouldAlmostEqual, 1.8)
+		So(System{Celsius, PPM500}.ConductivityFromRaw(900), ShouldAlmostEqual, 900)
+		So(Metric.ConductivityToRaw(Metric.ConductivityFromRaw(180)), ShouldAlmostEqual, 180)
+		So(System{Celsius, PPM500}.ConductivityToRaw(900), ShouldAlmostEqual, 900)
+	})
+}
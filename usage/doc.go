@@ -0,0 +1,28 @@
+// Package usage accounts for how long the equipment driven by each function of a device ran, and
+// estimates the energy, CO2, water and nutrient it used from user supplied ratings.
+//
+// Runtimes are worked out from the active flag of each function in the history points of a
+// device, so are only as accurate as the history is fine grained.  Ratings are loaded from a JSON
+// config file, where a rating with a device applies only to that device:
+//
+//     {
+//       "ratings": [
+//         {"function": "Light Bank 1", "watts": 6000},
+//         {"function": "CO2 Injection", "co2_kg_per_hour": 1.2},
+//         {"function": "Irrigation Station 1", "water_litres_per_hour": 900},
+//         {"function": "Nutrient Dosing", "nutrient_litres_per_hour": 6},
+//         {"device": "ASLID17081149", "function": "Nutrient Dosing", "nutrient_litres_per_hour": 4}
+//       ]
+//     }
+//
+// The usage of a room is summarized for each day once the history has been fetched for each
+// device:
+//
+//     for _, d := range room.Devices() {
+//       ...GetHistory(time.Now(), time.Now().Add(-7*24*time.Hour), 2016)
+//     }
+//
+//     for _, u := range usage.GrowroomUsage(room, ratings, usage.DefaultMaxGap) {
+//       fmt.Printf("%s %0.1f kWh\n", u.Day.Format("2006-01-02"), u.Consumption.Energy)
+//     }
+package usage
@@ -0,0 +1,81 @@
+package usage
+
+import (
+	"time"
+
+	"github.com/autogrow/go-jelly/ig"
+	"github.com/autogrow/go-jelly/ig/datastructs"
+)
+
+// DefaultMaxGap is the longest time between history points that the state of the functions is
+// assumed to hold for
+const DefaultMaxGap = 30 * time.Minute
+
+func state(ts float64, status datastructs.Status) State {
+	s := State{
+		Time:   time.Unix(0, int64(ts)*int64(time.Millisecond)),
+		Active: map[string]bool{},
+	}
+
+	for _, ds := range status.Status {
+		if ds.Installed || ds.Active {
+			s.Active[ds.Function] = ds.Active
+		}
+	}
+
+	return s
+}
+
+// DoserStates returns the state of the functions at each point of the history of the
+// IntelliDose fetched with GetHistory
+func DoserStates(id *ig.IntelliDose) []State {
+	states := []State{}
+	if id.History == nil {
+		return states
+	}
+
+	for _, p := range id.History.Points {
+		states = append(states, state(p.Timestamp, p.Status))
+	}
+	return states
+}
+
+// ClimateStates returns the state of the functions at each point of the history of the
+// IntelliClimate fetched with GetHistory
+func ClimateStates(ic *ig.IntelliClimate) []State {
+	states := []State{}
+	if ic.History == nil {
+		return states
+	}
+
+	for _, p := range ic.History.Points {
+		states = append(states, state(p.Timestamp, p.Status))
+	}
+	return states
+}
+
+// GrowroomRuntimes returns the runtime of each function of every device in the growroom on each
+// day, in the time zone of the room.  The history of each device must have been fetched with
+// GetHistory.
+func GrowroomRuntimes(g *ig.Growroom, maxGap time.Duration) []Runtime {
+	loc := g.Location()
+	runtimes := []Runtime{}
+
+	ids, _ := g.IntelliDoses()
+	for _, id := range ids {
+		runtimes = append(runtimes, Runtimes(id.GetID(), DoserStates(id), loc, maxGap)...)
+	}
+
+	ics, _ := g.IntelliClimates()
+	for _, ic := range ics {
+		runtimes = append(runtimes, Runtimes(ic.GetID(), ClimateStates(ic), loc, maxGap)...)
+	}
+
+	return runtimes
+}
+
+// GrowroomUsage returns the runtime of the equipment in the growroom on each day and the
+// resources it used.  The history of each device must have been fetched with GetHistory.
+func GrowroomUsage(g *ig.Growroom, ratings Ratings, maxGap time.Duration) []Usage {
+	return Summarize(g.GetName(), GrowroomRuntimes(g, maxGap), ratings)
+}
@@ -0,0 +1,162 @@
+package usage
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Rating is what the equipment driven by a function uses while it runs
+type Rating struct {
+	// Device is the serial of the device the rating applies to, or all devices if empty
+	Device   string `json:"device,omitempty"`
+	Function string `json:"function"`
+	// Watts is the power drawn by the equipment
+	Watts float64 `json:"watts,omitempty"`
+	// CO2 is the kilograms of CO2 injected per hour
+	CO2 float64 `json:"co2_kg_per_hour,omitempty"`
+	// Water is the litres of water irrigated per hour
+	Water float64 `json:"water_litres_per_hour,omitempty"`
+	// Nutrient is the litres of nutrient or acid dosed per hour
+	Nutrient float64 `json:"nutrient_litres_per_hour,omitempty"`
+}
+
+// Consumption estimates the resources used over the time the equipment ran for
+func (r Rating) Consumption(d time.Duration) Consumption {
+	h := d.Hours()
+	return Consumption{
+		Energy:   r.Watts * h / 1000,
+		CO2:      r.CO2 * h,
+		Water:    r.Water * h,
+		Nutrient: r.Nutrient * h,
+	}
+}
+
+// Ratings are the ratings of the equipment on each device
+type Ratings []Rating
+
+// For returns the rating for the function of the device, preferring a rating for the device over
+// one for all devices.  Function names are not case sensitive.
+func (rs Ratings) For(device, function string) (Rating, bool) {
+	var found *Rating
+	for i, r := range rs {
+		if !strings.EqualFold(r.Function, function) {
+			continue
+		}
+
+		switch r.Device {
+		case device:
+			return r, true
+		case "":
+			found = &rs[i]
+		}
+	}
+
+	if found == nil {
+		return Rating{}, false
+	}
+
+	return *found, true
+}
+
+// ParseRatings returns the ratings from the JSON config
+func ParseRatings(data []byte) (Ratings, error) {
+	cfg := struct {
+		Ratings Ratings `json:"ratings"`
+	}{}
+
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("couldn't parse ratings: %s", err)
+	}
+
+	for _, r := range cfg.Ratings {
+		if r.Function == "" {
+			return nil, fmt.Errorf("rating has no function")
+		}
+
+		if r.Watts < 0 || r.CO2 < 0 || r.Water < 0 || r.Nutrient < 0 {
+			return nil, fmt.Errorf("rating for %s can't be negative", r.Function)
+		}
+	}
+
+	return cfg.Ratings, nil
+}
+
+// LoadRatings returns the ratings from the JSON config file at the given path
+func LoadRatings(path string) (Ratings, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't read ratings file: %s", err)
+	}
+
+	return ParseRatings(data)
+}
+
+// Consumption is an estimate of the resources used by equipment
+type Consumption struct {
+	Energy   float64 `json:"energy_kwh"`
+	CO2      float64 `json:"co2_kg"`
+	Water    float64 `json:"water_litres"`
+	Nutrient float64 `json:"nutrient_litres"`
+}
+
+// Add returns the total of both consumptions
+func (c Consumption) Add(o Consumption) Consumption {
+	return Consumption{
+		Energy:   c.Energy + o.Energy,
+		CO2:      c.CO2 + o.CO2,
+		Water:    c.Water + o.Water,
+		Nutrient: c.Nutrient + o.Nutrient,
+	}
+}
+
+// Usage is the runtime of the equipment in a room on a day and the resources it used
+type Usage struct {
+	Room        string      `json:"room"`
+	Day         time.Time   `json:"day"`
+	Runtimes    []Runtime   `json:"runtimes"`
+	Consumption Consumption `json:"consumption"`
+	// Unrated are the functions that ran without a rating and so aren't in the consumption, as
+	// "<device>/<function>"
+	Unrated []string `json:"unrated,omitempty"`
+}
+
+// Summarize groups the runtimes of the devices in a room by day and estimates the resources used
+// each day from the ratings
+func Summarize(room string, runtimes []Runtime, ratings Ratings) []Usage {
+	byDay := map[time.Time]*Usage{}
+	days := []time.Time{}
+
+	for _, rt := range runtimes {
+		u, ok := byDay[rt.Day]
+		if !ok {
+			u = &Usage{Room: room, Day: rt.Day, Runtimes: []Runtime{}}
+			byDay[rt.Day] = u
+			days = append(days, rt.Day)
+		}
+
+		u.Runtimes = append(u.Runtimes, rt)
+
+		r, ok := ratings.For(rt.Device, rt.Function)
+		if !ok {
+			if rt.OnTime > 0 {
+				u.Unrated = append(u.Unrated, rt.Device+"/"+rt.Function)
+			}
+			continue
+		}
+
+		u.Consumption = u.Consumption.Add(r.Consumption(rt.OnTime))
+	}
+
+	sort.Slice(days, func(i, j int) bool { return days[i].Before(days[j]) })
+
+	usages := []Usage{}
+	for _, day := range days {
+		usages = append(usages, *byDay[day])
+	}
+
+	return usages
+}
@@ -0,0 +1,120 @@
+package usage
+
+import (
+	"sort"
+	"time"
+)
+
+// State is whether each function of a device was active at a point in time
+type State struct {
+	Time   time.Time
+	Active map[string]bool
+}
+
+// Runtime is how long a function of a device ran for on a day
+type Runtime struct {
+	Device   string    `json:"device"`
+	Function string    `json:"function"`
+	Day      time.Time `json:"day"`
+	// OnTime is how long the function was active for
+	OnTime time.Duration `json:"on_time"`
+	// Observed is how much of the day the state of the function was known
+	Observed time.Duration `json:"observed"`
+	// Cycles is the number of times the function turned on
+	Cycles int `json:"cycles"`
+}
+
+// DutyCycle returns the fraction of the observed time that the function was active
+func (r Runtime) DutyCycle() float64 {
+	if r.Observed <= 0 {
+		return 0
+	}
+	return float64(r.OnTime) / float64(r.Observed)
+}
+
+type runtimeKey struct {
+	function string
+	day      time.Time
+}
+
+// Runtimes returns the runtime of each function of the device on each day, in the given time
+// zone.  Each state is assumed to hold until the next, unless they are more than the max gap
+// apart when the state in between is unknown and isn't counted.  Functions that turn on and off
+// between states are missed, so the states need to be close enough together to catch the
+// shortest cycle.  A function that is already on at the first state isn't counted as a cycle.
+func Runtimes(device string, states []State, loc *time.Location, maxGap time.Duration) []Runtime {
+	sorted := make([]State, len(states))
+	copy(sorted, states)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Time.Before(sorted[j].Time) })
+
+	runtimes := map[runtimeKey]*Runtime{}
+	runtime := func(function string, at time.Time) *Runtime {
+		day := startOfDay(at.In(loc))
+		key := runtimeKey{function, day}
+		if rt, ok := runtimes[key]; ok {
+			return rt
+		}
+
+		rt := &Runtime{Device: device, Function: function, Day: day}
+		runtimes[key] = rt
+		return rt
+	}
+
+	gap := func(from, to State) bool {
+		return maxGap > 0 && to.Time.Sub(from.Time) > maxGap
+	}
+
+	for i := 1; i < len(sorted); i++ {
+		prev, cur := sorted[i-1], sorted[i]
+		if gap(prev, cur) {
+			continue
+		}
+
+		for function, active := range cur.Active {
+			if active && !prev.Active[function] {
+				runtime(function, cur.Time).Cycles++
+			}
+		}
+	}
+
+	for i := 0; i+1 < len(sorted); i++ {
+		cur, next := sorted[i], sorted[i+1]
+		if gap(cur, next) {
+			continue
+		}
+
+		for function, active := range cur.Active {
+			for from := cur.Time; from.Before(next.Time); {
+				to := startOfDay(from.In(loc)).AddDate(0, 0, 1)
+				if to.After(next.Time) {
+					to = next.Time
+				}
+
+				rt := runtime(function, from)
+				rt.Observed += to.Sub(from)
+				if active {
+					rt.OnTime += to.Sub(from)
+				}
+				from = to
+			}
+		}
+	}
+
+	list := []Runtime{}
+	for _, rt := range runtimes {
+		list = append(list, *rt)
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		if !list[i].Day.Equal(list[j].Day) {
+			return list[i].Day.Before(list[j].Day)
+		}
+		return list[i].Function < list[j].Function
+	})
+
+	return list
+}
+
+func startOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
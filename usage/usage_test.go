@@ -0,0 +1,140 @@
+package usage
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+var start = time.Date(2018, 6, 1, 22, 0, 0, 0, time.UTC)
+
+// states returns a state every 10 minutes with the pump active as given
+func states(pump ...bool) []State {
+	list := []State{}
+	for i, on := range pump {
+		list = append(list, State{
+			Time:   start.Add(time.Duration(i) * 10 * time.Minute),
+			Active: map[string]bool{"Pump": on, "Fan": true},
+		})
+	}
+	return list
+}
+
+func TestRuntimes(t *testing.T) {
+	Convey("given the states of a device over an hour", t, func() {
+		runtimes := Runtimes("ID1", states(false, true, true, false, true, false, false), time.UTC, DefaultMaxGap)
+
+		Convey("it should total the on time, observed time and cycles of each function", func() {
+			So(runtimes, ShouldHaveLength, 2)
+			fan, pump := runtimes[0], runtimes[1]
+
+			So(pump.Device, ShouldEqual, "ID1")
+			So(pump.Function, ShouldEqual, "Pump")
+			So(pump.Day, ShouldEqual, time.Date(2018, 6, 1, 0, 0, 0, 0, time.UTC))
+			So(pump.OnTime, ShouldEqual, 30*time.Minute)
+			So(pump.Observed, ShouldEqual, time.Hour)
+			So(pump.Cycles, ShouldEqual, 2)
+			So(pump.DutyCycle(), ShouldEqual, 0.5)
+
+			So(fan.Function, ShouldEqual, "Fan")
+			So(fan.OnTime, ShouldEqual, time.Hour)
+			So(fan.Cycles, ShouldEqual, 0)
+			So(fan.DutyCycle(), ShouldEqual, 1)
+		})
+	})
+
+	Convey("given states that cross midnight", t, func() {
+		pump := []bool{}
+		for i := 0; i < 19; i++ {
+			pump = append(pump, true)
+		}
+		runtimes := Runtimes("ID1", states(pump...), time.UTC, DefaultMaxGap)
+
+		Convey("it should split the runtime between the days", func() {
+			So(runtimes, ShouldHaveLength, 4)
+			So(runtimes[1].Function, ShouldEqual, "Pump")
+			So(runtimes[1].OnTime, ShouldEqual, 2*time.Hour)
+			So(runtimes[3].Function, ShouldEqual, "Pump")
+			So(runtimes[3].Day, ShouldEqual, time.Date(2018, 6, 2, 0, 0, 0, 0, time.UTC))
+			So(runtimes[3].OnTime, ShouldEqual, time.Hour)
+		})
+
+		Convey("it should split the days in the given time zone", func() {
+			runtimes := Runtimes("ID1", states(pump...), time.FixedZone("UTC+1", 3600), DefaultMaxGap)
+			So(runtimes, ShouldHaveLength, 4)
+			So(runtimes[1].OnTime, ShouldEqual, time.Hour)
+			So(runtimes[3].OnTime, ShouldEqual, 2*time.Hour)
+		})
+	})
+
+	Convey("given states with a gap", t, func() {
+		list := states(true, false, true, true)
+		list[2].Time = list[2].Time.Add(time.Hour)
+		list[3].Time = list[3].Time.Add(time.Hour)
+		runtimes := Runtimes("ID1", list, time.UTC, DefaultMaxGap)
+
+		Convey("it should not count the time in the gap or a cycle after it", func() {
+			So(runtimes[1].Function, ShouldEqual, "Pump")
+			So(runtimes[1].OnTime, ShouldEqual, 20*time.Minute)
+			So(runtimes[1].Observed, ShouldEqual, 20*time.Minute)
+			So(runtimes[1].Cycles, ShouldEqual, 0)
+		})
+	})
+}
+
+func TestRatings(t *testing.T) {
+	Convey("given some ratings", t, func() {
+		ratings, err := ParseRatings([]byte(`{"ratings": [
+			{"function": "Pump", "watts": 500, "water_litres_per_hour": 600},
+			{"device": "ID2", "function": "pump", "watts": 1000},
+			{"function": "CO2", "co2_kg_per_hour": 1.5}
+		]}`))
+		So(err, ShouldBeNil)
+
+		Convey("it should prefer the rating for the device", func() {
+			r, ok := ratings.For("ID2", "Pump")
+			So(ok, ShouldBeTrue)
+			So(r.Watts, ShouldEqual, 1000)
+
+			r, ok = ratings.For("ID1", "Pump")
+			So(ok, ShouldBeTrue)
+			So(r.Watts, ShouldEqual, 500)
+
+			_, ok = ratings.For("ID1", "Fan")
+			So(ok, ShouldBeFalse)
+		})
+
+		Convey("it should summarize the usage of a room each day", func() {
+			day := time.Date(2018, 6, 1, 0, 0, 0, 0, time.UTC)
+			runtimes := []Runtime{
+				{Device: "ID1", Function: "Pump", Day: day, OnTime: 30 * time.Minute},
+				{Device: "ID2", Function: "Pump", Day: day, OnTime: time.Hour},
+				{Device: "ID2", Function: "CO2", Day: day, OnTime: 2 * time.Hour},
+				{Device: "ID2", Function: "Fan", Day: day, OnTime: time.Hour},
+				{Device: "ID1", Function: "Pump", Day: day.AddDate(0, 0, 1), OnTime: time.Hour},
+			}
+
+			usages := Summarize("Veg", runtimes, ratings)
+			So(usages, ShouldHaveLength, 2)
+
+			u := usages[0]
+			So(u.Room, ShouldEqual, "Veg")
+			So(u.Runtimes, ShouldHaveLength, 4)
+			So(u.Consumption.Energy, ShouldEqual, 1.25)
+			So(u.Consumption.Water, ShouldEqual, 300)
+			So(u.Consumption.CO2, ShouldEqual, 3)
+			So(u.Unrated, ShouldResemble, []string{"ID2/Fan"})
+
+			So(usages[1].Consumption.Energy, ShouldEqual, 0.5)
+		})
+	})
+
+	Convey("it should reject invalid ratings", t, func() {
+		_, err := ParseRatings([]byte(`{"ratings": [{"watts": 500}]}`))
+		So(err, ShouldNotBeNil)
+
+		_, err = ParseRatings([]byte(`{"ratings": [{"function": "Pump", "watts": -1}]}`))
+		So(err, ShouldNotBeNil)
+	})
+}
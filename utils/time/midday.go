@@ -2,9 +2,15 @@ package time
 
 import "time"
 
-// Midday gives the epoch of the midday for the given day
+// Midday gives the epoch of the midday for the given day in the local time zone of the host
 func Midday(epoch float64) float64 {
-	s := time.Unix(int64(epoch), 0)
-	midday := time.Date(s.Year(), s.Month(), s.Day(), 12, 0, 0, 0, s.Location())
+	return MiddayIn(epoch, time.Local)
+}
+
+// MiddayIn gives the epoch of the midday for the given day in the given location, such as the
+// time zone of a device
+func MiddayIn(epoch float64, loc *time.Location) float64 {
+	s := time.Unix(int64(epoch), 0).In(loc)
+	midday := time.Date(s.Year(), s.Month(), s.Day(), 12, 0, 0, 0, loc)
 	return float64(midday.Unix())
 }
@@ -31,4 +31,17 @@ func TestMidday(t *testing.T) {
 		})
 	})
 
+	Convey("it should get midday in the time zone of a device", t, func() {
+		loc := time.FixedZone("UTC+10:00", 10*3600)
+
+		// 20:00 UTC on the 1st is 06:00 on the 2nd in the device time zone
+		t := time.Date(2018, 6, 1, 20, 0, 0, 0, time.UTC)
+
+		m := time.Unix(int64(MiddayIn(float64(t.Unix()), loc)), 0).In(loc)
+		So(m.Day(), ShouldEqual, 2)
+		So(m.Hour(), ShouldEqual, 12)
+		So(m.Minute(), ShouldEqual, 0)
+		So(m.Equal(time.Date(2018, 6, 2, 2, 0, 0, 0, time.UTC)), ShouldBeTrue)
+	})
+
 }